@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/codefresh-io/hermes/pkg/backend"
+	"github.com/codefresh-io/hermes/pkg/codefresh"
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/urfave/cli"
+)
+
+var dispatchCommand = cli.Command{
+	Name:  "dispatch",
+	Usage: "pause and resume pipeline dispatch (maintenance mode)",
+	Subcommands: []cli.Command{
+		{
+			Name:        "status",
+			Usage:       "list paused dispatch scopes",
+			Description: "List accounts with paused pipeline dispatch ('-' for all accounts) and number of queued events",
+			Action:      getDispatchStatus,
+		},
+		{
+			Name: "pause",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "account",
+					Usage: "Codefresh account ID (default: all accounts)",
+				},
+			},
+			Usage:       "pause pipeline dispatch",
+			Description: "Pause pipeline dispatch for account or for all accounts. Received trigger events are validated and queued until dispatch is resumed.",
+			Action:      pauseDispatch,
+		},
+		{
+			Name: "resume",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "account",
+					Usage: "Codefresh account ID (default: all accounts)",
+				},
+				cli.BoolFlag{
+					Name:  "discard",
+					Usage: "discard queued events instead of running them",
+				},
+				cli.DurationFlag{
+					Name:   "run-history-retention",
+					Usage:  "keep execution records of queued event runs for this duration, per account (0: do not keep)",
+					Value:  7 * 24 * time.Hour,
+					EnvVar: "RUN_HISTORY_RETENTION",
+				},
+			},
+			Usage:       "resume pipeline dispatch",
+			Description: "Run queued trigger events in order (or discard them) and resume pipeline dispatch for account or for all accounts. Events failed to run are kept queued and dispatch stays paused.",
+			Action:      resumeDispatch,
+		},
+	},
+}
+
+// dispatch scope from account flag
+func getDispatchScope(c *cli.Context) string {
+	if account := c.String("account"); account != "" {
+		return account
+	}
+	return model.DispatchGlobal
+}
+
+func getDispatchStatus(c *cli.Context) error {
+	// get trigger backend
+	store, err := getStore(c, nil, nil)
+	if err != nil {
+		return err
+	}
+	states, err := store.GetDispatchStates(context.Background())
+	if err != nil {
+		return err
+	}
+	if len(states) == 0 {
+		fmt.Println("Pipeline dispatch is not paused.")
+		return nil
+	}
+	for _, s := range states {
+		fmt.Printf("scope: %s\tpaused since: %s\tqueued events: %d\n", s.Scope, s.Since.Format(time.RFC3339), s.Queued)
+	}
+	return nil
+}
+
+func pauseDispatch(c *cli.Context) error {
+	// get trigger backend
+	store, err := getStore(c, nil, nil)
+	if err != nil {
+		return err
+	}
+	scope := getDispatchScope(c)
+	if err = store.PauseDispatch(context.Background(), scope); err != nil {
+		return err
+	}
+	fmt.Printf("Pipeline dispatch paused for scope '%s'.\n", scope)
+	return nil
+}
+
+func resumeDispatch(c *cli.Context) error {
+	// get codefresh endpoint: queued events run pipelines
+	codefreshService := codefresh.NewCodefreshEndpoint(c.GlobalString("c"), c.GlobalString("t"))
+	// get trigger backend
+	store, err := getStore(c, codefreshService, nil)
+	if err != nil {
+		return err
+	}
+	history := backend.NewRunHistory(store, c.Duration("run-history-retention"))
+	runner := backend.NewRunner(codefreshService)
+	// pipeline runs queued by rate limits are kept in store: server runs them when due
+	limiter := backend.NewRateLimiter(store, runner, history)
+	dispatcher := backend.NewDispatcher(store, runner, history, limiter)
+	result, err := dispatcher.Resume(context.Background(), getDispatchScope(c), c.Bool("discard"))
+	if result != nil {
+		fmt.Printf("Dispatched: %d, moved to paused account queue: %d, discarded: %d, failed: %d queued events.\n", result.Dispatched, result.Requeued, result.Discarded, result.Failed)
+	}
+	if err != nil {
+		return err
+	}
+	if result.Failed > 0 {
+		fmt.Printf("Pipeline dispatch stays paused for scope '%s': failed events are kept queued.\n", result.Scope)
+		return nil
+	}
+	fmt.Printf("Pipeline dispatch resumed for scope '%s'.\n", result.Scope)
+	return nil
+}
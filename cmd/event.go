@@ -4,9 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"strings"
 
-	"github.com/codefresh-io/hermes/pkg/backend"
 	"github.com/codefresh-io/hermes/pkg/model"
 	"github.com/codefresh-io/hermes/pkg/provider"
 
@@ -19,7 +19,7 @@ var triggerEventCommand = cli.Command{
 	Subcommands: []cli.Command{
 		{
 			Name: "list",
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				cli.StringFlag{
 					Name:  "type",
 					Usage: "trigger event type",
@@ -41,7 +41,11 @@ var triggerEventCommand = cli.Command{
 					Name:  "quiet,q",
 					Usage: "only display trigger event URIs",
 				},
-			},
+				cli.StringFlag{
+					Name:  "sort",
+					Usage: "sort order: uri or -uri (descending)",
+				},
+			}, pageFlags...),
 			Usage:       "list defined trigger events",
 			Description: "List trigger events",
 			Action:      listEvents,
@@ -94,6 +98,59 @@ var triggerEventCommand = cli.Command{
 			Description: "Create/define trigger event",
 			Action:      createEvent,
 		},
+		{
+			Name: "update",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "description",
+					Usage: "set trigger event description",
+				},
+				cli.StringFlag{
+					Name:  "secret",
+					Usage: "replace trigger event secret ('!generate' to auto-generate)",
+				},
+				cli.BoolFlag{
+					Name:  "refresh",
+					Usage: "refresh trigger event info (endpoint, description, status) from event provider",
+				},
+				cli.StringFlag{
+					Name:  "account",
+					Usage: "Codefresh account ID",
+					Value: model.PublicAccount,
+				},
+			},
+			Usage:       "update trigger event",
+			ArgsUsage:   "<event-uri>",
+			Description: "Update trigger event in place: change description, replace secret or refresh event info",
+			Action:      updateEvent,
+		},
+		{
+			Name: "rotate-secret",
+			Flags: []cli.Flag{
+				cli.DurationFlag{
+					Name:  "grace",
+					Usage: "keep previous secret valid for this duration (0: invalidate immediately)",
+					Value: model.DefaultSecretGrace,
+				},
+				cli.BoolFlag{
+					Name:  "push",
+					Usage: "push new secret to event provider",
+				},
+				cli.StringFlag{
+					Name:  "context",
+					Usage: "Codefresh context with required credentials (used with --push)",
+				},
+				cli.StringFlag{
+					Name:  "account",
+					Usage: "Codefresh account ID",
+					Value: model.PublicAccount,
+				},
+			},
+			Usage:       "rotate trigger event secret",
+			ArgsUsage:   "<event-uri>",
+			Description: "Generate new trigger event secret; previous secret remains valid during grace period",
+			Action:      rotateEventSecret,
+		},
 		{
 			Name: "delete",
 			Flags: []cli.Flag{
@@ -114,15 +171,57 @@ var triggerEventCommand = cli.Command{
 					Usage: "Codefresh account ID",
 					Value: model.PublicAccount,
 				},
+				cli.BoolFlag{
+					Name:  "force",
+					Usage: "delete trigger event together with all its triggers",
+				},
 			},
 			Usage:       "delete trigger event",
 			ArgsUsage:   "<event-uri>",
-			Description: "Delete/undefine trigger event by event URI",
+			Description: "Delete/undefine trigger event by event URI. Use --force to delete linked triggers and filters in the same transaction.",
 			Action:      deleteEvent,
 		},
 	},
 }
 
+// listing paging flags
+var pageFlags = []cli.Flag{
+	cli.IntFlag{
+		Name:  "limit",
+		Usage: "page size (number of items loaded at once)",
+	},
+	cli.StringFlag{
+		Name:  "cursor",
+		Usage: "start listing from page cursor",
+	},
+	cli.BoolFlag{
+		Name:  "page",
+		Usage: "list single page and print next page cursor",
+	},
+}
+
+// iterate listing pages, starting from cursor; stops after first page for --page flag
+// list returns page next cursor ("" for the last page)
+func iteratePages(c *cli.Context, list func(page model.PageOptions) (string, error)) error {
+	page := model.PageOptions{Limit: c.Int("limit"), Cursor: c.String("cursor"), Sort: c.String("sort")}
+	for {
+		next, err := list(page)
+		if err != nil {
+			return err
+		}
+		if c.Bool("page") {
+			if next != "" {
+				fmt.Fprintln(os.Stderr, "next page cursor:", next)
+			}
+			return nil
+		}
+		if next == "" {
+			return nil
+		}
+		page.Cursor = next
+	}
+}
+
 func getContext(c *cli.Context) context.Context {
 	account := c.String("account")
 	return context.WithValue(context.Background(), model.ContextKeyAccount, account)
@@ -130,28 +229,42 @@ func getContext(c *cli.Context) context.Context {
 
 func listEvents(c *cli.Context) error {
 	// get trigger backend
-	eventReaderWriter := backend.NewRedisStore(c.GlobalString("redis"), c.GlobalInt("redis-port"), c.GlobalInt("redis-db"), c.GlobalString("redis-password"), nil, nil)
-	// get trigger events
-	events, err := eventReaderWriter.GetEvents(getContext(c), c.String("type"), c.String("kind"), c.String("filter"))
+	eventReaderWriter, err := getStore(c, nil, nil)
 	if err != nil {
 		return err
 	}
-	if len(events) == 0 {
-		return errors.New("no trigger events found")
-	}
-	for _, event := range events {
-		if c.Bool("quiet") {
-			fmt.Println(event.URI)
-		} else {
-			fmt.Println(event)
+	// get trigger events, page by page
+	found := false
+	err = iteratePages(c, func(page model.PageOptions) (string, error) {
+		events, err := eventReaderWriter.GetEventsPage(getContext(c), c.String("type"), c.String("kind"), c.String("filter"), page)
+		if err != nil {
+			return "", err
 		}
+		for _, event := range events.Events {
+			found = true
+			if c.Bool("quiet") {
+				fmt.Println(event.URI)
+			} else {
+				fmt.Println(event)
+			}
+		}
+		return events.NextCursor, nil
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("no trigger events found")
 	}
 	return nil
 }
 
 func getEvent(c *cli.Context) error {
 	// get trigger backend
-	eventReaderWriter := backend.NewRedisStore(c.GlobalString("redis"), c.GlobalInt("redis-port"), c.GlobalInt("redis-db"), c.GlobalString("redis-password"), nil, nil)
+	eventReaderWriter, err := getStore(c, nil, nil)
+	if err != nil {
+		return err
+	}
 	// get trigger events
 	event, err := eventReaderWriter.GetEvent(getContext(c), c.Args().First())
 	if err != nil {
@@ -165,7 +278,10 @@ func createEvent(c *cli.Context) error {
 	// get event provider informer
 	eventProvider := provider.NewEventProviderManager(c.GlobalString("config"), c.GlobalBool("skip-monitor"))
 	// get trigger backend
-	eventReaderWriter := backend.NewRedisStore(c.GlobalString("redis"), c.GlobalInt("redis-port"), c.GlobalInt("redis-db"), c.GlobalString("redis-password"), nil, eventProvider)
+	eventReaderWriter, err := getStore(c, nil, eventProvider)
+	if err != nil {
+		return err
+	}
 	// construct values map
 	values := make(map[string]string)
 	valueFlag := c.StringSlice("value")
@@ -188,11 +304,78 @@ func createEvent(c *cli.Context) error {
 	return nil
 }
 
+func updateEvent(c *cli.Context) error {
+	var update model.EventUpdate
+	if c.IsSet("description") {
+		description := c.String("description")
+		update.Description = &description
+	}
+	if c.IsSet("secret") {
+		secret := c.String("secret")
+		update.Secret = &secret
+	}
+	update.Refresh = c.Bool("refresh")
+	if update.Description == nil && update.Secret == nil && !update.Refresh {
+		return errors.New("nothing to update: set --description, --secret or --refresh")
+	}
+	// get event provider informer
+	eventProvider := provider.NewEventProviderManager(c.GlobalString("config"), c.GlobalBool("skip-monitor"))
+	// get trigger backend
+	eventReaderWriter, err := getStore(c, nil, eventProvider)
+	if err != nil {
+		return err
+	}
+	event, err := eventReaderWriter.UpdateEvent(getContext(c), c.Args().First(), update)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Trigger event successfully updated.")
+	fmt.Println(event)
+	return nil
+}
+
+func rotateEventSecret(c *cli.Context) error {
+	// get event provider informer
+	eventProvider := provider.NewEventProviderManager(c.GlobalString("config"), c.GlobalBool("skip-monitor"))
+	// get trigger backend
+	eventReaderWriter, err := getStore(c, nil, eventProvider)
+	if err != nil {
+		return err
+	}
+	rotation := model.SecretRotation{Grace: c.Duration("grace"), Push: c.Bool("push"), Context: c.String("context")}
+	event, err := eventReaderWriter.RotateEventSecret(getContext(c), c.Args().First(), rotation)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Trigger event secret successfully rotated.")
+	fmt.Println(event)
+	return nil
+}
+
 func deleteEvent(c *cli.Context) error {
+	// get event provider manager: unsubscribe from deleted event
+	eventProvider := provider.NewEventProviderManager(c.GlobalString("config"), c.GlobalBool("skip-monitor"))
 	// get trigger backend
-	eventReaderWriter := backend.NewRedisStore(c.GlobalString("redis"), c.GlobalInt("redis-port"), c.GlobalInt("redis-db"), c.GlobalString("redis-password"), nil, nil)
-	// get trigger events
-	err := eventReaderWriter.DeleteEvent(getContext(c), c.Args().First(), c.String("context"))
+	eventReaderWriter, err := getStore(c, nil, eventProvider)
+	if err != nil {
+		return err
+	}
+	if c.Bool("force") {
+		// delete trigger event with triggers
+		deletion, err := eventReaderWriter.ForceDeleteEvent(getContext(c), c.Args().First(), c.String("context"))
+		if deletion != nil {
+			for _, pipeline := range deletion.Pipelines {
+				fmt.Printf("Deleted trigger: %s -> %s\n", deletion.URI, pipeline)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Trigger event successfully deleted with %d triggers (%d filters).\n", len(deletion.Pipelines), len(deletion.Filters))
+		return nil
+	}
+	// delete trigger event
+	err = eventReaderWriter.DeleteEvent(getContext(c), c.Args().First(), c.String("context"))
 	if err != nil {
 		return err
 	}
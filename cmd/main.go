@@ -3,12 +3,17 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	newrelic "github.com/newrelic/go-agent"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 
 	"github.com/codefresh-io/go-infra/pkg/logger"
+	"github.com/codefresh-io/hermes/pkg/backend"
+	"github.com/codefresh-io/hermes/pkg/codefresh"
+	"github.com/codefresh-io/hermes/pkg/provider"
 	"github.com/codefresh-io/hermes/pkg/version"
 )
 
@@ -27,11 +32,13 @@ func main() {
 	app.UsageText = fmt.Sprintf(`Configure triggers for Codefresh pipeline execution or start trigger manager server. Process "normalized" events and run Codefresh pipelines with variables extracted from events payload.
 %s
 hermes respects following environment variables:
+   - STORE_DRIVER       - set the storage backend: %s (default redis)
    - STORE_HOST         - set the url to the Redis store server (default localhost)
    - STORE_PORT         - set Redis store port (default to 6379)
    - STORE_PASSWORD     - set Redis store password
+   - STORE_PATH         - set store file path for bolt storage backend (default hermes.db)
    
-Copyright © Codefresh.io`, version.ASCIILogo)
+Copyright © Codefresh.io`, version.ASCIILogo, strings.Join(backend.StoreDrivers(), ", "))
 	app.Before = before
 
 	app.Commands = []cli.Command{
@@ -40,6 +47,8 @@ Copyright © Codefresh.io`, version.ASCIILogo)
 		runnerCommand,
 		triggerEventCommand,
 		triggerTypeCommand,
+		storeCommand,
+		dispatchCommand,
 	}
 	app.Flags = []cli.Flag{
 		cli.StringFlag{
@@ -53,6 +62,12 @@ Copyright © Codefresh.io`, version.ASCIILogo)
 			Usage:  "Codefresh API token",
 			EnvVar: "CFAPI_TOKEN",
 		},
+		cli.StringFlag{
+			Name:   "store",
+			Usage:  fmt.Sprintf("storage backend (%s)", strings.Join(backend.StoreDrivers(), ", ")),
+			Value:  "redis",
+			EnvVar: "STORE_DRIVER",
+		},
 		cli.StringFlag{
 			Name:   "redis, r",
 			Usage:  "redis store host name",
@@ -77,6 +92,117 @@ Copyright © Codefresh.io`, version.ASCIILogo)
 			Value:  "redisPassword",
 			EnvVar: "STORE_PASSWORD",
 		},
+		cli.StringFlag{
+			Name:   "redis-username",
+			Usage:  "redis store ACL user name (Redis 6+)",
+			EnvVar: "STORE_USERNAME",
+		},
+		cli.StringFlag{
+			Name:   "redis-mode",
+			Usage:  "redis connection mode (standalone, sentinel, cluster)",
+			Value:  backend.RedisStandalone,
+			EnvVar: "STORE_MODE",
+		},
+		cli.StringSliceFlag{
+			Name:   "redis-addrs",
+			Usage:  "redis sentinel or cluster node address host:port (default: --redis:--redis-port)",
+			EnvVar: "STORE_ADDRS",
+		},
+		cli.StringFlag{
+			Name:   "redis-master",
+			Usage:  "redis sentinel master name",
+			Value:  "mymaster",
+			EnvVar: "STORE_MASTER_NAME",
+		},
+		cli.StringFlag{
+			Name:   "redis-sentinel-password",
+			Usage:  "redis sentinel password",
+			EnvVar: "STORE_SENTINEL_PASSWORD",
+		},
+		cli.StringFlag{
+			Name:   "redis-hash-tag",
+			Usage:  "redis cluster hash tag shared by all keys: all data is kept in one slot (no sharding)",
+			Value:  backend.DefaultHashTag,
+			EnvVar: "STORE_HASH_TAG",
+		},
+		cli.BoolFlag{
+			Name:   "redis-tls",
+			Usage:  "connect to redis store with TLS",
+			EnvVar: "STORE_TLS",
+		},
+		cli.BoolFlag{
+			Name:   "redis-tls-skip-verify",
+			Usage:  "skip redis store TLS certificate verification",
+			EnvVar: "STORE_TLS_SKIP_VERIFY",
+		},
+		cli.StringFlag{
+			Name:   "redis-tls-ca",
+			Usage:  "redis store TLS CA certificate file",
+			EnvVar: "STORE_TLS_CA",
+		},
+		cli.IntFlag{
+			Name:   "redis-max-idle",
+			Usage:  "maximum number of idle connections in redis pool",
+			Value:  10,
+			EnvVar: "STORE_MAX_IDLE",
+		},
+		cli.IntFlag{
+			Name:   "redis-max-active",
+			Usage:  "maximum number of connections in redis pool (0: unlimited)",
+			Value:  100,
+			EnvVar: "STORE_MAX_ACTIVE",
+		},
+		cli.BoolTFlag{
+			Name:   "redis-wait",
+			Usage:  "wait for free connection when redis pool is exhausted, instead of failing",
+			EnvVar: "STORE_WAIT",
+		},
+		cli.DurationFlag{
+			Name:   "redis-idle-timeout",
+			Usage:  "close redis connections idle for this duration",
+			Value:  240 * time.Second,
+			EnvVar: "STORE_IDLE_TIMEOUT",
+		},
+		cli.DurationFlag{
+			Name:   "redis-connect-timeout",
+			Usage:  "redis connect timeout",
+			Value:  5 * time.Second,
+			EnvVar: "STORE_CONNECT_TIMEOUT",
+		},
+		cli.DurationFlag{
+			Name:   "redis-read-timeout",
+			Usage:  "redis read timeout",
+			Value:  5 * time.Second,
+			EnvVar: "STORE_READ_TIMEOUT",
+		},
+		cli.DurationFlag{
+			Name:   "redis-write-timeout",
+			Usage:  "redis write timeout",
+			Value:  5 * time.Second,
+			EnvVar: "STORE_WRITE_TIMEOUT",
+		},
+		cli.StringFlag{
+			Name:   "store-path",
+			Usage:  "store file path (bolt storage backend)",
+			Value:  "hermes.db",
+			EnvVar: "STORE_PATH",
+		},
+		cli.StringFlag{
+			Name:   "encryption-keys",
+			Usage:  "encrypt trigger event secrets at rest with master keys: 'key-id=base64(32 bytes)' list, the first key is current",
+			EnvVar: "STORE_ENCRYPTION_KEYS",
+		},
+		cli.StringFlag{
+			Name:   "encryption-key-file",
+			Usage:  "file with master keys encrypting trigger event secrets at rest, one 'key-id=base64(32 bytes)' per line",
+			EnvVar: "STORE_ENCRYPTION_KEY_FILE",
+		},
+		cli.DurationFlag{
+			Name:   "audit-retention",
+			Usage:  "keep audit log of trigger event and trigger changes for this duration (0: disable audit log)",
+			Value:  90 * 24 * time.Hour,
+			EnvVar: "AUDIT_RETENTION",
+		},
 		cli.StringFlag{
 			Name:   "config",
 			Usage:  "type config file",
@@ -167,3 +293,42 @@ func before(c *cli.Context) error {
 
 	return nil
 }
+
+// create storage backend selected by global flags
+func getStore(c *cli.Context, pipelineSvc codefresh.PipelineService, eventProvider provider.EventProvider) (backend.Store, error) {
+	config := backend.StoreConfig{
+		Host:     c.GlobalString("redis"),
+		Port:     c.GlobalInt("redis-port"),
+		DB:       c.GlobalInt("redis-db"),
+		Password: c.GlobalString("redis-password"),
+		Username: c.GlobalString("redis-username"),
+		Path:     c.GlobalString("store-path"),
+		Mode:     c.GlobalString("redis-mode"),
+		Addrs:    c.GlobalStringSlice("redis-addrs"),
+		// sentinel and cluster
+		MasterName:       c.GlobalString("redis-master"),
+		SentinelPassword: c.GlobalString("redis-sentinel-password"),
+		HashTag:          c.GlobalString("redis-hash-tag"),
+		// TLS
+		TLS:           c.GlobalBool("redis-tls"),
+		TLSSkipVerify: c.GlobalBool("redis-tls-skip-verify"),
+		TLSCACert:     c.GlobalString("redis-tls-ca"),
+		// connection pool
+		MaxIdle:        c.GlobalInt("redis-max-idle"),
+		MaxActive:      c.GlobalInt("redis-max-active"),
+		Wait:           c.GlobalBoolT("redis-wait"),
+		IdleTimeout:    c.GlobalDuration("redis-idle-timeout"),
+		ConnectTimeout: c.GlobalDuration("redis-connect-timeout"),
+		ReadTimeout:    c.GlobalDuration("redis-read-timeout"),
+		WriteTimeout:   c.GlobalDuration("redis-write-timeout"),
+		// secrets encryption
+		EncryptionKeys:    c.GlobalString("encryption-keys"),
+		EncryptionKeyFile: c.GlobalString("encryption-key-file"),
+	}
+	store, err := backend.NewStore(c.GlobalString("store"), config, pipelineSvc, eventProvider)
+	if err != nil {
+		return nil, err
+	}
+	// record trigger event and trigger changes in audit log
+	return backend.NewAuditStore(store, c.GlobalDuration("audit-retention")), nil
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/codefresh-io/hermes/pkg/backend"
 	"github.com/codefresh-io/hermes/pkg/codefresh"
@@ -23,6 +25,34 @@ var runnerCommand = cli.Command{
 	ArgsUsage:   "<event-uri>",
 	Description: "Execute trigger for trigger event. Pass multiple variable pairs (key=value), using --var flags.",
 	Action:      runTrigger,
+	Subcommands: []cli.Command{
+		{
+			Name: "history",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "account",
+					Usage: "trigger event account (required)",
+				},
+				cli.StringFlag{
+					Name:  "event",
+					Usage: "only runs of trigger event URI",
+				},
+				cli.StringFlag{
+					Name:  "status",
+					Usage: "only runs with status (rejected, queued, skipped, started, failed, duplicate or rate-limited)",
+				},
+				cli.IntFlag{
+					Name:  "limit",
+					Usage: "maximum number of listed runs (0: all)",
+					Value: 20,
+				},
+			},
+			Usage:       "list trigger execution history",
+			ArgsUsage:   "[run-id]",
+			Description: "List recent runs of account trigger events, latest first, or show single run with matched and skipped pipelines, run IDs and errors.",
+			Action:      listRunHistory,
+		},
+	},
 }
 
 // run all pipelines connected to specified trigger
@@ -30,7 +60,10 @@ func runTrigger(c *cli.Context) error {
 	// get codefresh endpoint
 	codefreshService := codefresh.NewCodefreshEndpoint(c.GlobalString("c"), c.GlobalString("t"))
 	// get trigger service
-	triggerReaderWriter := backend.NewRedisStore(c.GlobalString("redis"), c.GlobalInt("redis-port"), c.GlobalInt("redis-db"), c.GlobalString("redis-password"), codefreshService, nil)
+	triggerReaderWriter, err := getStore(c, codefreshService, nil)
+	if err != nil {
+		return err
+	}
 	// get pipeline runner
 	runner := backend.NewRunner(codefreshService)
 	// convert command line 'var' variables (key=value) to map
@@ -69,3 +102,65 @@ func runTrigger(c *cli.Context) error {
 	}
 	return nil
 }
+
+// list account run history or show single run
+func listRunHistory(c *cli.Context) error {
+	account := c.String("account")
+	if account == "" {
+		return cli.NewExitError("account is required", 1)
+	}
+	// get trigger backend
+	store, err := getStore(c, nil, nil)
+	if err != nil {
+		return err
+	}
+	ctx := context.WithValue(context.Background(), model.ContextKeyAccount, account)
+	if id := c.Args().First(); id != "" {
+		record, err := store.GetRun(ctx, id)
+		if err != nil {
+			return err
+		}
+		printRunRecord(record)
+		if record.Replay != "" {
+			fmt.Printf("replay of: %s\n", record.Replay)
+		}
+		if record.Duplicate != "" {
+			fmt.Printf("duplicate of: %s\n", record.Duplicate)
+		}
+		for _, pipeline := range record.Skipped {
+			fmt.Printf("skipped: %s (%s)\n", pipeline.Pipeline, pipeline.Reason)
+		}
+		for _, pipeline := range record.Limited {
+			fmt.Printf("rate limited: %s (%s %s limit, %s)\n", pipeline.Pipeline, pipeline.Action, pipeline.Scope, pipeline.Delay)
+		}
+		for _, run := range record.Runs {
+			if run.Error != "" {
+				fmt.Printf("failed: %s: %s\n", run.Pipeline, run.Error)
+			} else {
+				fmt.Printf("run: %s -> %s\n", run.Pipeline, run.ID)
+			}
+		}
+		if record.Error != "" {
+			fmt.Printf("error: %s\n", record.Error)
+		}
+		return nil
+	}
+	records, err := store.GetRuns(ctx, model.RunFilter{
+		Account: account,
+		Event:   c.String("event"),
+		Status:  c.String("status"),
+		Limit:   c.Int("limit"),
+	})
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		printRunRecord(&record)
+	}
+	return nil
+}
+
+func printRunRecord(record *model.RunRecord) {
+	fmt.Printf("run: %s\treceived: %s\tevent: %s\tstatus: %s\tpipelines: %s\n",
+		record.ID, record.Received.Format(time.RFC3339), record.Event, record.Status, strings.Join(record.Matched, ","))
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/codefresh-io/hermes/pkg/model"
 
@@ -26,6 +29,58 @@ var serverCommand = cli.Command{
 			Value:  9011,
 			EnvVar: "PORT",
 		},
+		cli.BoolFlag{
+			Name:   "strict-schema",
+			Usage:  "refuse to start when store schema is older than expected (warn otherwise, unless key layout changed)",
+			EnvVar: "STRICT_SCHEMA",
+		},
+		cli.DurationFlag{
+			Name:   "secret-purge-interval",
+			Usage:  "how often to purge previous trigger event secrets after rotation grace period (0: never)",
+			Value:  time.Minute,
+			EnvVar: "SECRET_PURGE_INTERVAL",
+		},
+		cli.DurationFlag{
+			Name:   "reconcile-interval",
+			Usage:  "how often to refresh trigger event info from event providers, on single replica (0: never)",
+			Value:  time.Hour,
+			EnvVar: "RECONCILE_INTERVAL",
+		},
+		cli.DurationFlag{
+			Name:   "trigger-gc-interval",
+			Usage:  "how often to remove triggers of deleted pipelines, on single replica (0: never)",
+			Value:  24 * time.Hour,
+			EnvVar: "TRIGGER_GC_INTERVAL",
+		},
+		cli.Float64Flag{
+			Name:   "trigger-gc-rate",
+			Usage:  "maximum Codefresh pipeline checks per second during trigger garbage collection (0: no limit)",
+			Value:  5,
+			EnvVar: "TRIGGER_GC_RATE",
+		},
+		cli.DurationFlag{
+			Name:   "run-history-retention",
+			Usage:  "keep execution records of trigger runs for this duration, per account (0: do not keep)",
+			Value:  7 * 24 * time.Hour,
+			EnvVar: "RUN_HISTORY_RETENTION",
+		},
+		cli.DurationFlag{
+			Name:   "delayed-run-interval",
+			Usage:  "how often to run due pipeline runs queued by rate limits, on every replica (0: never)",
+			Value:  time.Second,
+			EnvVar: "DELAYED_RUN_INTERVAL",
+		},
+		cli.DurationFlag{
+			Name:   "dedup-ttl",
+			Usage:  "skip events delivered again within this duration, per trigger event (0: do not deduplicate)",
+			Value:  24 * time.Hour,
+			EnvVar: "DEDUP_TTL",
+		},
+		cli.StringSliceFlag{
+			Name:   "secret-reader",
+			Usage:  "auth entity (user name, user ID or service name) allowed to read trigger event secrets (default: any)",
+			EnvVar: "SECRET_READERS",
+		},
 	},
 	Usage:       "start trigger manager server",
 	Description: "Run Codefresh trigger manager server. Use REST API to manage triggers. Send normalized event payload to trigger endpoint to invoke associated Codefresh pipelines.",
@@ -39,7 +94,16 @@ func setupRouter(eventReaderWriter model.TriggerEventReaderWriter,
 	publisher model.EventPublisher,
 	checker model.SecretChecker,
 	pinger model.Pinger,
-	pipelineService codefresh.PipelineService) *gin.Engine {
+	pipelineService codefresh.PipelineService,
+	storeChecker model.StoreChecker,
+	dispatcher model.Dispatcher,
+	reconciler model.Reconciler,
+	auditReader model.AuditReader,
+	history model.RunHistory,
+	replayer model.Replayer,
+	dedup model.Deduplicator,
+	rateLimiter model.RateLimiter,
+	secretReaders []string) *gin.Engine {
 	// Creates a router without any middleware by default
 	router := gin.New()
 	router.Use(gin.Recovery())
@@ -49,11 +113,14 @@ func setupRouter(eventReaderWriter model.TriggerEventReaderWriter,
 	}
 
 	// manage trigger events
-	eventController := controller.NewTriggerEventController(eventReaderWriter)
+	eventController := controller.NewTriggerEventController(eventReaderWriter, secretReaders...)
 	eventsAPI := router.Group("/accounts/:account/events", gin.Logger())
 	{
 		eventsAPI.Handle("GET", "/", eventController.GetEvents)
 		eventsAPI.Handle("GET", "/:event", eventController.GetEvent)
+		eventsAPI.Handle("PATCH", "/:event", eventController.UpdateEvent)
+		eventsAPI.Handle("GET", "/:event/secret", eventController.GetSecret)
+		eventsAPI.Handle("POST", "/:event/secret", eventController.RotateSecret)
 		eventsAPI.Handle("DELETE", "/:event/*context", eventController.DeleteEvent)
 		eventsAPI.Handle("POST", "/", eventController.CreateEvent)
 	}
@@ -67,6 +134,8 @@ func setupRouter(eventReaderWriter model.TriggerEventReaderWriter,
 		triggersAPI.Handle("GET", "/pipeline/:pipeline", triggerController.GetPipelineTriggers)
 		triggersAPI.Handle("POST", "/:event/:pipeline", triggerController.CreateTrigger)
 		triggersAPI.Handle("DELETE", "/:event/:pipeline", triggerController.DeleteTrigger)
+		triggersAPI.Handle("POST", "/:event/:pipeline/pause", triggerController.PauseTrigger)
+		triggersAPI.Handle("POST", "/:event/:pipeline/resume", triggerController.ResumeTrigger)
 	}
 
 	triggersInternalAPI := router.Group("/accounts/:account/triggers-internal", gin.Logger())
@@ -84,13 +153,65 @@ func setupRouter(eventReaderWriter model.TriggerEventReaderWriter,
 
 	// invoke trigger with event payload
 	runAPI := router.Group("/run", gin.Logger())
-	runnerController := controller.NewRunnerController(runner, publisher, eventReaderWriter, triggerReaderWriter, checker)
+	runnerController := controller.NewRunnerController(runner, publisher, eventReaderWriter, triggerReaderWriter, checker, dispatcher, history, dedup, rateLimiter)
 	{
 		runAPI.Handle("POST", "/:event", runnerController.RunTrigger)
 	}
 
+	// trigger execution history
+	historyController := controller.NewRunHistoryController(history, replayer)
+	runsAPI := router.Group("/accounts/:account/runs", gin.Logger())
+	{
+		runsAPI.Handle("GET", "/", historyController.GetRuns)
+		runsAPI.Handle("GET", "/:id", historyController.GetRun)
+		runsAPI.Handle("POST", "/:id/replay", historyController.ReplayRun)
+	}
+
+	// pipeline run rate limits
+	rateLimitController := controller.NewRateLimitController(rateLimiter)
+	limitsAPI := router.Group("/accounts/:account/limits", gin.Logger())
+	{
+		limitsAPI.Handle("GET", "/", rateLimitController.GetLimits)
+		limitsAPI.Handle("PUT", "/", rateLimitController.SetLimit)
+		limitsAPI.Handle("DELETE", "/", rateLimitController.DeleteLimit)
+	}
+
+	// audit log of trigger event and trigger changes
+	auditController := controller.NewAuditController(auditReader)
+	auditAPI := router.Group("/accounts/:account/audit", gin.Logger())
+	{
+		auditAPI.Handle("GET", "/", auditController.GetAudit)
+	}
+
+	// storage backend admin
+	storeController := controller.NewStoreController(storeChecker)
+	adminAPI := router.Group("/admin/store", gin.Logger())
+	{
+		adminAPI.Handle("GET", "/fsck", storeController.CheckStore)
+		adminAPI.Handle("POST", "/fsck", storeController.RepairStore)
+	}
+
+	// pipeline dispatch admin (maintenance mode)
+	dispatchController := controller.NewDispatchController(dispatcher)
+	dispatchAPI := router.Group("/admin/dispatch", gin.Logger())
+	{
+		dispatchAPI.Handle("GET", "/", dispatchController.GetDispatch)
+		dispatchAPI.Handle("POST", "/pause", dispatchController.PauseDispatch)
+		dispatchAPI.Handle("POST", "/resume", dispatchController.ResumeDispatch)
+		dispatchAPI.Handle("POST", "/accounts/:account/pause", dispatchController.PauseDispatch)
+		dispatchAPI.Handle("POST", "/accounts/:account/resume", dispatchController.ResumeDispatch)
+	}
+
+	// trigger event reconciliation admin
+	reconcileController := controller.NewReconcileController(reconciler)
+	reconcileAPI := router.Group("/admin/reconcile", gin.Logger())
+	{
+		reconcileAPI.Handle("GET", "/", reconcileController.GetReconcileRuns)
+		reconcileAPI.Handle("POST", "/", reconcileController.Reconcile)
+	}
+
 	// status handlers (without logging)
-	statusController := controller.NewStatusController(pinger, pipelineService)
+	statusController := controller.NewStatusController(pinger, pipelineService, rateLimiter)
 	{
 		router.GET("/health", statusController.GetHealth)
 		router.GET("/version", statusController.GetVersion)
@@ -114,11 +235,45 @@ func runServer(c *cli.Context) error {
 	log.WithField("config", c.GlobalString("config")).Debug("monitoring types config file")
 
 	// get trigger backend service
-	triggerBackend := backend.NewRedisStore(c.GlobalString("redis"), c.GlobalInt("redis-port"), c.GlobalInt("redis-db"), c.GlobalString("redis-password"), codefreshService, eventProvider)
+	triggerBackend, err := getStore(c, codefreshService, eventProvider)
+	if err != nil {
+		return err
+	}
 	log.WithFields(log.Fields{
+		"store":        c.GlobalString("store"),
 		"redis server": c.GlobalString("redis"),
 		"redis port":   c.GlobalInt("redis-port"),
-	}).Debug("using Redis backend server")
+	}).Debug("using storage backend")
+
+	// check store schema version: refuse to start with newer schema or older key layout
+	if err = backend.CheckSchema(context.Background(), triggerBackend); err != nil {
+		if err != backend.ErrSchemaTooOld || c.Bool("strict-schema") {
+			return err
+		}
+		log.WithError(err).Warn("starting with outdated store schema")
+	}
+
+	// purge previous trigger event secrets after rotation grace period
+	if interval := c.Duration("secret-purge-interval"); interval > 0 {
+		go backend.RunSecretPurger(context.Background(), triggerBackend, interval)
+	}
+
+	// refresh trigger event info from event providers; reconciliation lock is owned by this replica
+	owner, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	owner = fmt.Sprintf("%s-%d", owner, os.Getpid())
+	reconciler := backend.NewReconciler(triggerBackend, eventProvider, owner)
+	if interval := c.Duration("reconcile-interval"); interval > 0 {
+		go backend.RunReconciler(context.Background(), reconciler, interval)
+	}
+
+	// remove triggers of pipelines deleted in Codefresh
+	if interval := c.Duration("trigger-gc-interval"); interval > 0 {
+		gc := backend.NewTriggerGC(triggerBackend, codefreshService, c.Float64("trigger-gc-rate"))
+		go backend.RunTriggerGC(context.Background(), gc, owner, interval)
+	}
 
 	// get pipeline runner service
 	runner := backend.NewRunner(codefreshService)
@@ -129,8 +284,26 @@ func runServer(c *cli.Context) error {
 	// get secret checker
 	checker := backend.NewSecretChecker()
 
+	// get run history: keeps execution records of /run calls
+	history := backend.NewRunHistory(triggerBackend, c.Duration("run-history-retention"))
+
+	// get rate limiter: limits pipeline runs per account, trigger event and trigger
+	rateLimiter := backend.NewRateLimiter(triggerBackend, runner, history)
+	if interval := c.Duration("delayed-run-interval"); interval > 0 {
+		go backend.RunDelayedRuns(context.Background(), rateLimiter, interval)
+	}
+
+	// get dispatcher: queues events while pipeline dispatch is paused
+	dispatcher := backend.NewDispatcher(triggerBackend, runner, history, rateLimiter)
+
+	// get deduplicator: remembers event deliveries
+	dedup := backend.NewDeduplicator(triggerBackend, eventProvider, c.Duration("dedup-ttl"))
+
+	// get replayer: replays events kept in run history
+	replayer := backend.NewReplayer(triggerBackend, runner, dispatcher, history, rateLimiter)
+
 	// setup router
-	router := setupRouter(triggerBackend, triggerBackend, eventProvider, runner, publisher, checker, triggerBackend, codefreshService)
+	router := setupRouter(triggerBackend, triggerBackend, eventProvider, runner, publisher, checker, triggerBackend, codefreshService, triggerBackend, dispatcher, reconciler, triggerBackend, history, replayer, dedup, rateLimiter, c.StringSlice("secret-reader"))
 
 	// use server router port
 	port := c.Int("port")
@@ -16,7 +16,7 @@ import (
 )
 
 func TestPingRoute(t *testing.T) {
-	router := setupRouter(nil, nil, nil, nil, nil, nil, nil, nil)
+	router := setupRouter(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/ping", nil)
@@ -31,7 +31,7 @@ func TestHealthRoute(t *testing.T) {
 	pinger := new(model.MockPinger)
 	codefresh := &codefresh.MockPipelineService{}
 	// setup router
-	router := setupRouter(nil, nil, nil, nil, nil, nil, pinger, codefresh)
+	router := setupRouter(nil, nil, nil, nil, nil, nil, pinger, codefresh, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	// setup mocks
 	pinger.Mock.On("Ping").Return("PONG", nil)
 	codefresh.On("Ping").Return(nil)
@@ -46,12 +46,49 @@ func TestHealthRoute(t *testing.T) {
 	assert.Equal(t, "Healthy", w.Body.String())
 }
 
+// pinger with connection pool statistics
+type poolPinger struct {
+	model.MockPinger
+}
+
+func (p *poolPinger) PoolStats() model.PoolStats {
+	return model.PoolStats{Active: 3, Idle: 2, WaitCount: 1}
+}
+
+func TestHealthRoutePoolStats(t *testing.T) {
+	// prepare mocks
+	pinger := new(poolPinger)
+	codefresh := &codefresh.MockPipelineService{}
+	// setup router
+	router := setupRouter(nil, nil, nil, nil, nil, nil, pinger, codefresh, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	// setup mocks
+	pinger.On("Ping").Return("PONG", nil)
+	codefresh.On("Ping").Return(nil)
+
+	// plain text by default
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/health", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "Healthy", w.Body.String())
+
+	// pool statistics in JSON
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/health", nil)
+	req.Header.Set("Accept", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	var health controller.HealthResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &health))
+	assert.Equal(t, controller.HealthResult{Status: "Healthy", Pool: &model.PoolStats{Active: 3, Idle: 2, WaitCount: 1}}, health)
+}
+
 func TestHealthRouteRedisError(t *testing.T) {
 	// prepare mocks
 	pinger := new(model.MockPinger)
 	codefresh := &codefresh.MockPipelineService{}
 	// setup router
-	router := setupRouter(nil, nil, nil, nil, nil, nil, pinger, codefresh)
+	router := setupRouter(nil, nil, nil, nil, nil, nil, pinger, codefresh, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	// setup mocks
 	pinger.On("Ping").Return("", errors.New("REDIS Error"))
 
@@ -79,7 +116,7 @@ func TestHealthRouteCodefreshError(t *testing.T) {
 	pinger := new(model.MockPinger)
 	codefresh := &codefresh.MockPipelineService{}
 	// setup router
-	router := setupRouter(nil, nil, nil, nil, nil, nil, pinger, codefresh)
+	router := setupRouter(nil, nil, nil, nil, nil, nil, pinger, codefresh, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	// setup mocks
 	pinger.On("Ping").Return("PONG", nil)
 	codefresh.On("Ping").Return(errors.New("Codefresh Error"))
@@ -161,7 +198,7 @@ func Test_GetTriggers(t *testing.T) {
 		// mock
 		triggerReaderWriter := new(model.MockTriggerReaderWriter)
 		// setup router
-		router := setupRouter(nil, triggerReaderWriter, nil, nil, nil, nil, nil, nil)
+		router := setupRouter(nil, triggerReaderWriter, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 		// prepare mock
 		call := triggerReaderWriter.On("GetEventTriggers", mock.Anything, "*")
 		if tt.err != nil {
@@ -191,3 +228,23 @@ func Test_GetTriggers(t *testing.T) {
 		triggerReaderWriter.Mock.AssertExpectations(t)
 	}
 }
+
+func Test_GetTriggersPage(t *testing.T) {
+	triggerReaderWriter := new(model.MockTriggerReaderWriter)
+	router := setupRouter(nil, triggerReaderWriter, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	page := &model.TriggerPage{
+		Triggers: []model.Trigger{
+			{Event: "uri:test:" + model.PublicAccountHash, Pipeline: "pipeline1", Filters: map[string]string{"tag": "master"}},
+		},
+		NextCursor: "next",
+	}
+	triggerReaderWriter.On("GetEventTriggersPage", mock.Anything, "*", model.PageOptions{Limit: 1, Sort: "pipeline"}).Return(page, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/accounts/"+model.PublicAccount+"/triggers/?limit=1&sort=pipeline&fields=pipeline", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"triggers":[{"pipeline":"pipeline1"}],"next_cursor":"next"}`, w.Body.String())
+	triggerReaderWriter.Mock.AssertExpectations(t)
+}
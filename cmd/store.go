@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/codefresh-io/hermes/pkg/backend"
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/codefresh-io/hermes/pkg/provider"
+	"github.com/urfave/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var storeCommand = cli.Command{
+	Name:  "store",
+	Usage: "storage backend maintenance",
+	Subcommands: []cli.Command{
+		{
+			Name:        "reindex",
+			Usage:       "rebuild trigger event indexes",
+			Description: "Add all existing trigger events to account and type indexes. Run once after upgrade; safe to run multiple times.",
+			Action:      reindexStore,
+		},
+		{
+			Name:        "re-encrypt",
+			Usage:       "re-encrypt trigger event secrets",
+			Description: "Encrypt plaintext trigger event secrets and secrets encrypted with previous keys with current encryption key. Run after adding new current key; safe to run multiple times.",
+			Action:      reencryptStore,
+		},
+		{
+			Name: "migrate",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "only display pending migrations",
+				},
+				cli.IntFlag{
+					Name:  "to",
+					Usage: "target schema version (default: latest)",
+				},
+			},
+			Usage:       "upgrade store schema",
+			Description: "Apply pending store schema migrations. Interrupted migration can be safely restarted.",
+			Action:      migrateStore,
+		},
+		{
+			Name: "fsck",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "repair",
+					Usage: "fix found issues (in a single transaction)",
+				},
+			},
+			Usage:       "check store consistency",
+			Description: "Find orphan and inconsistent trigger events, triggers, pipeline links, filters and indexes. Run on idle store.",
+			Action:      checkStore,
+		},
+		{
+			Name: "reconcile",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "list",
+					Usage: "only list recent reconciliation runs",
+				},
+			},
+			Usage:       "refresh trigger event info from event providers",
+			Description: "Get event info (endpoint, description, status, help) for every trigger event from its event provider and update changed fields. Trigger events that do not match any configured event type are reported as unmatched.",
+			Action:      reconcileStore,
+		},
+		{
+			Name: "export",
+			Flags: append(exportFilterFlags,
+				cli.BoolFlag{
+					Name:  "secrets",
+					Usage: "include trigger event secrets",
+				},
+				cli.StringFlag{
+					Name:  "format",
+					Usage: "export document format (yaml or json)",
+					Value: "yaml",
+				},
+				cli.StringFlag{
+					Name:  "output, o",
+					Usage: "output file (default: stdout)",
+				},
+			),
+			Usage:       "export trigger events and triggers",
+			Description: "Export trigger events with linked triggers and filters to versioned YAML or JSON document. Secrets are omitted unless '--secrets' is set.",
+			Action:      exportStore,
+		},
+		{
+			Name: "import",
+			Flags: append(exportFilterFlags,
+				cli.BoolFlag{
+					Name:  "merge",
+					Usage: "add missing trigger events and triggers, keep existing unchanged (default)",
+				},
+				cli.BoolFlag{
+					Name:  "replace",
+					Usage: "update changed and delete missing trigger events and triggers",
+				},
+				cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "only display changes",
+				},
+			),
+			Usage:       "import trigger events and triggers",
+			ArgsUsage:   "<file>",
+			Description: "Import trigger events and triggers from export document ('-' for stdin). Trigger events are restored as is: event providers and Codefresh are not called; missing secrets are generated.",
+			Action:      importStore,
+		},
+	},
+}
+
+// trigger events filter for export and import
+var exportFilterFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "account",
+		Usage: "Codefresh account ID (default: all accounts)",
+	},
+	cli.StringFlag{
+		Name:  "type",
+		Usage: "trigger event type",
+	},
+	cli.StringFlag{
+		Name:  "kind",
+		Usage: "trigger event kind",
+	},
+}
+
+func getExportFilter(c *cli.Context) backend.ExportFilter {
+	return backend.ExportFilter{
+		Account: c.String("account"),
+		Type:    c.String("type"),
+		Kind:    c.String("kind"),
+	}
+}
+
+func reindexStore(c *cli.Context) error {
+	// get trigger backend
+	store, err := getStore(c, nil, nil)
+	if err != nil {
+		return err
+	}
+	n, err := store.Reindex(context.Background())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Indexed %d trigger events.\n", n)
+	return nil
+}
+
+func reencryptStore(c *cli.Context) error {
+	// get trigger backend
+	store, err := getStore(c, nil, nil)
+	if err != nil {
+		return err
+	}
+	n, err := store.ReEncrypt(context.Background())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Re-encrypted secrets of %d trigger events.\n", n)
+	return nil
+}
+
+func migrateStore(c *cli.Context) error {
+	// get trigger backend
+	store, err := getStore(c, nil, nil)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	current, pending, err := backend.PendingMigrations(ctx, store, c.Int("to"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Store schema version: %d (latest: %d)\n", current, backend.SchemaVersion)
+	if len(pending) == 0 {
+		fmt.Println("No pending migrations.")
+	}
+	if c.Bool("dry-run") {
+		for _, m := range pending {
+			fmt.Printf("Pending migration %d: %s\n", m.Version, m.Description)
+		}
+		return nil
+	}
+	// apply pending migrations
+	err = backend.Migrate(ctx, store, c.Int("to"), func(m backend.Migration) {
+		fmt.Printf("Applying migration %d/%d: %s\n", m.Version, pending[len(pending)-1].Version, m.Description)
+	})
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		fmt.Printf("Store schema migrated to version %d.\n", pending[len(pending)-1].Version)
+	}
+	return nil
+}
+
+func checkStore(c *cli.Context) error {
+	// get trigger backend
+	store, err := getStore(c, nil, nil)
+	if err != nil {
+		return err
+	}
+	report, err := store.CheckStore(context.Background(), c.Bool("repair"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Checked %d trigger events, %d triggers, %d filters.\n", report.Events, report.Triggers, report.Filters)
+	repaired := 0
+	for _, issue := range report.Issues {
+		status := ""
+		if issue.Repaired {
+			status = " (repaired)"
+			repaired++
+		}
+		if issue.Member != "" {
+			fmt.Printf("%s: %s -> %s%s\n", issue.Type, issue.Key, issue.Member, status)
+		} else {
+			fmt.Printf("%s: %s%s\n", issue.Type, issue.Key, status)
+		}
+	}
+	fmt.Printf("Found %d issues, repaired %d.\n", len(report.Issues), repaired)
+	if len(report.Issues) > repaired {
+		return cli.NewExitError("store has inconsistencies", 1)
+	}
+	return nil
+}
+
+func reconcileStore(c *cli.Context) error {
+	// get event provider manager
+	eventProvider := provider.NewEventProviderManager(c.GlobalString("config"), true)
+	// get trigger backend
+	store, err := getStore(c, nil, eventProvider)
+	if err != nil {
+		return err
+	}
+	owner, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	reconciler := backend.NewReconciler(store, eventProvider, fmt.Sprintf("%s-%d", owner, os.Getpid()))
+	if c.Bool("list") {
+		runs, err := reconciler.GetReconcileRuns(context.Background())
+		if err != nil {
+			return err
+		}
+		for _, run := range runs {
+			printReconcileRun(&run)
+		}
+		return nil
+	}
+	run, err := reconciler.Reconcile(context.Background())
+	if err != nil {
+		return err
+	}
+	printReconcileRun(run)
+	for _, e := range run.Updated {
+		fmt.Printf("updated: %s (%s)\n", e.URI, strings.Join(e.Fields, ", "))
+	}
+	for _, uri := range run.Unmatched {
+		fmt.Printf("unmatched: %s\n", uri)
+	}
+	for _, e := range run.Failed {
+		fmt.Printf("failed: %s: %s\n", e.URI, e.Error)
+	}
+	return nil
+}
+
+func printReconcileRun(run *model.ReconcileRun) {
+	fmt.Printf("run: %s\towner: %s\tstarted: %s\tevents: %d\tupdated: %d\tunmatched: %d\tfailed: %d\n",
+		run.ID, run.Owner, run.Started.Format(time.RFC3339), run.Events, len(run.Updated), len(run.Unmatched), len(run.Failed))
+}
+
+func exportStore(c *cli.Context) error {
+	// get trigger backend
+	store, err := getStore(c, nil, nil)
+	if err != nil {
+		return err
+	}
+	doc, err := backend.Export(context.Background(), store, getExportFilter(c), c.Bool("secrets"))
+	if err != nil {
+		return err
+	}
+	var data []byte
+	switch c.String("format") {
+	case "yaml":
+		data, err = yaml.Marshal(doc)
+	case "json":
+		data, err = json.MarshalIndent(doc, "", "  ")
+		data = append(data, '\n')
+	default:
+		return fmt.Errorf("unsupported export format: %s", c.String("format"))
+	}
+	if err != nil {
+		return err
+	}
+	if c.String("output") == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return ioutil.WriteFile(c.String("output"), data, 0600)
+}
+
+func importStore(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("wrong arguments: expected export document file")
+	}
+	if c.Bool("merge") && c.Bool("replace") {
+		return errors.New("wrong arguments: cannot mix 'merge' and 'replace'")
+	}
+	// read export document (YAML or JSON)
+	var data []byte
+	var err error
+	if file := c.Args().First(); file == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+	doc := new(backend.ExportDocument)
+	if err = yaml.Unmarshal(data, doc); err != nil {
+		return err
+	}
+	// get trigger backend: restore trigger events without calling event providers and Codefresh
+	store, err := getStore(c, backend.NewImportPipelineService(), backend.NewImportProvider(doc))
+	if err != nil {
+		return err
+	}
+	opts := backend.ImportOptions{
+		Filter:  getExportFilter(c),
+		Replace: c.Bool("replace"),
+		DryRun:  c.Bool("dry-run"),
+	}
+	changes, err := backend.Import(context.Background(), store, doc, opts)
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	if err != nil {
+		return err
+	}
+	if opts.DryRun {
+		fmt.Printf("%d changes to apply.\n", len(changes))
+	} else {
+		fmt.Printf("Applied %d changes.\n", len(changes))
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -17,7 +18,7 @@ var triggerCommand = cli.Command{
 	Subcommands: []cli.Command{
 		{
 			Name: "list",
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				cli.StringFlag{
 					Name:  "event",
 					Usage: "trigger event filter (cannot be mixed with 'pipeline')",
@@ -35,7 +36,11 @@ var triggerCommand = cli.Command{
 					Name:  "with-event",
 					Usage: "also fetch trigger event data (use with --pipeline)",
 				},
-			},
+				cli.StringFlag{
+					Name:  "sort",
+					Usage: "sort order: event or pipeline, with '-' prefix for descending order (not used with --pipeline)",
+				},
+			}, pageFlags...),
 			Usage:       "list defined triggers",
 			Description: "List triggers filtered by trigger event or pipeline",
 			Action:      listTriggers,
@@ -72,50 +77,101 @@ var triggerCommand = cli.Command{
 			Description: "Delete trigger, by removing link between the trigger event and the specified pipeline",
 			Action:      deleteTrigger,
 		},
+		{
+			Name: "pause",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "account",
+					Usage: "Codefresh account ID",
+					Value: model.PublicAccount,
+				},
+			},
+			Usage:       "pause trigger",
+			ArgsUsage:   "<event-uri> <pipeline>",
+			Description: "Pause trigger: the trigger event does not run the specified pipeline until trigger is resumed; trigger filters are kept",
+			Action:      pauseTrigger,
+		},
+		{
+			Name: "resume",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "account",
+					Usage: "Codefresh account ID",
+					Value: model.PublicAccount,
+				},
+			},
+			Usage:       "resume paused trigger",
+			ArgsUsage:   "<event-uri> <pipeline>",
+			Description: "Resume paused trigger: the trigger event runs the specified pipeline again",
+			Action:      resumeTrigger,
+		},
+		{
+			Name: "gc",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "only report triggers of deleted pipelines",
+				},
+				cli.Float64Flag{
+					Name:  "rate",
+					Usage: "maximum Codefresh pipeline checks per second (0: no limit)",
+					Value: 5,
+				},
+			},
+			Usage:       "remove triggers of deleted pipelines",
+			Description: "Check every pipeline linked to trigger events in Codefresh and remove triggers of pipelines that are not found or belong to another account",
+			Action:      collectTriggers,
+		},
 	},
 }
 
 // get triggers by name(s), filter or ALL
 func listTriggers(c *cli.Context) error {
-	triggerReaderWriter := backend.NewRedisStore(c.GlobalString("redis"), c.GlobalInt("redis-port"), c.GlobalInt("redis-db"), c.GlobalString("redis-password"), nil, nil)
+	triggerReaderWriter, err := getStore(c, nil, nil)
+	if err != nil {
+		return err
+	}
 	// get event or pipeline
 	event := c.String("event")
 	pipeline := c.String("pipeline")
 
-	// triggers slice
-	var err error
-	var triggers []model.Trigger
-
-	// list by event
-	if event != "" {
-		triggers, err = triggerReaderWriter.GetEventTriggers(getContext(c), event)
-		if err != nil {
-			return err
-		}
-	}
-
 	// list by pipeline
 	if pipeline != "" {
-		triggers, err = triggerReaderWriter.GetPipelineTriggers(getContext(c), pipeline, c.Bool("with-event"))
+		triggers, err := triggerReaderWriter.GetPipelineTriggers(getContext(c), pipeline, c.Bool("with-event"))
 		if err != nil {
 			return err
 		}
+		if len(triggers) == 0 {
+			return errors.New("no triggers defined")
+		}
+		for _, t := range triggers {
+			fmt.Println(t)
+		}
+		return nil
 	}
 
-	// get all triggers for all events (private and public)
-	if event == "" && pipeline == "" {
-		triggers, err = triggerReaderWriter.GetEventTriggers(getContext(c), "*")
+	// list by event or get all triggers for all events (private and public), page by page
+	if event == "" {
+		event = "*"
+	}
+	found := false
+	err = iteratePages(c, func(page model.PageOptions) (string, error) {
+		triggers, err := triggerReaderWriter.GetEventTriggersPage(getContext(c), event, page)
 		if err != nil {
-			return err
+			return "", err
+		}
+		for _, t := range triggers.Triggers {
+			found = true
+			fmt.Println(t)
 		}
+		return triggers.NextCursor, nil
+	})
+	if err != nil {
+		return err
 	}
-
-	if len(triggers) == 0 {
+	if !found {
 		return errors.New("no triggers defined")
 	}
-	for _, t := range triggers {
-		fmt.Println(t)
-	}
 	return nil
 }
 
@@ -133,7 +189,10 @@ func createTrigger(c *cli.Context) error {
 	// get codefresh endpoint
 	codefreshService := codefresh.NewCodefreshEndpoint(c.GlobalString("c"), c.GlobalString("t"))
 	// get trigger service
-	triggerReaderWriter := backend.NewRedisStore(c.GlobalString("redis"), c.GlobalInt("redis-port"), c.GlobalInt("redis-db"), c.GlobalString("redis-password"), codefreshService, nil)
+	triggerReaderWriter, err := getStore(c, codefreshService, nil)
+	if err != nil {
+		return err
+	}
 	// create triggers for event linking it to passed pipeline(s)
 	return triggerReaderWriter.CreateTrigger(getContext(c), args.First(), args.Get(1), filters)
 }
@@ -147,7 +206,71 @@ func deleteTrigger(c *cli.Context) error {
 	// get codefresh endpoint
 	codefreshService := codefresh.NewCodefreshEndpoint(c.GlobalString("c"), c.GlobalString("t"))
 	// get trigger service
-	triggerReaderWriter := backend.NewRedisStore(c.GlobalString("redis"), c.GlobalInt("redis-port"), c.GlobalInt("redis-db"), c.GlobalString("redis-password"), codefreshService, nil)
+	triggerReaderWriter, err := getStore(c, codefreshService, nil)
+	if err != nil {
+		return err
+	}
 	// delete pipelines
 	return triggerReaderWriter.DeleteTrigger(getContext(c), args.First(), args.Get(1))
 }
+
+func pauseTrigger(c *cli.Context) error {
+	return setTriggerPaused(c, true)
+}
+
+func resumeTrigger(c *cli.Context) error {
+	return setTriggerPaused(c, false)
+}
+
+func setTriggerPaused(c *cli.Context, paused bool) error {
+	// get trigger name and pipeline
+	args := c.Args()
+	if len(args) != 2 {
+		return errors.New("wrong number of arguments")
+	}
+	// get codefresh endpoint
+	codefreshService := codefresh.NewCodefreshEndpoint(c.GlobalString("c"), c.GlobalString("t"))
+	// get trigger service
+	triggerReaderWriter, err := getStore(c, codefreshService, nil)
+	if err != nil {
+		return err
+	}
+	return triggerReaderWriter.SetTriggerPaused(getContext(c), args.First(), args.Get(1), paused)
+}
+
+func collectTriggers(c *cli.Context) error {
+	// get codefresh endpoint
+	codefreshService := codefresh.NewCodefreshEndpoint(c.GlobalString("c"), c.GlobalString("t"))
+	// get trigger backend
+	store, err := getStore(c, codefreshService, nil)
+	if err != nil {
+		return err
+	}
+	gc := backend.NewTriggerGC(store, codefreshService, c.Float64("rate"))
+	report, err := gc.Collect(context.Background(), c.Bool("dry-run"))
+	if err != nil {
+		return err
+	}
+	action := "removed"
+	if report.DryRun {
+		action = "to remove"
+	}
+	triggers := 0
+	for _, collected := range report.Collected {
+		triggers += len(collected.Events)
+		for _, event := range collected.Events {
+			fmt.Printf("%s: %s -> %s (%s in account %s)\n", action, event, collected.Pipeline, collected.Reason, collected.Account)
+		}
+	}
+	for _, pipeline := range report.Skipped {
+		fmt.Printf("skipped: %s (linked only to public trigger events)\n", pipeline)
+	}
+	for _, failure := range report.Failed {
+		fmt.Printf("failed: %s: %s\n", failure.Pipeline, failure.Error)
+	}
+	fmt.Printf("Checked %d pipelines, %s %d triggers.\n", report.Pipelines, action, triggers)
+	if len(report.Failed) > 0 {
+		return cli.NewExitError("failed to check some pipelines", 1)
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/codefresh-io/hermes/pkg/util"
+	"github.com/oklog/ulid"
+	log "github.com/sirupsen/logrus"
+)
+
+/*  Audit Log
+
+	audit:{account} (Set)           -> {entry-id}: audit entry IDs (ULID), sorted in time order
+	audit-entry:{entry-id} (Hash)   -> entry: audit entry (JSON)
+
+	* account - account that made the change (not hashed)
+
+*/
+
+// account audit log key
+func getAuditKey(account string) string {
+	return getPrefixKey("audit", account)
+}
+
+// audit entry key
+func getAuditEntryKey(id string) string {
+	return getPrefixKey("audit-entry", id)
+}
+
+// smallest ULID at time: ULID with zero entropy
+func getIDBound(t time.Time) string {
+	var id ulid.ULID
+	if err := id.SetTime(ulid.Timestamp(t)); err != nil {
+		log.WithError(err).Error("failed to set ULID time")
+	}
+	return id.String()
+}
+
+// filter sorted ULIDs by [from, to) time range (zero time: unbounded); returns latest first
+func filterIDs(ids []string, from, to time.Time) []string {
+	lower, upper := "", ""
+	if !from.IsZero() {
+		lower = getIDBound(from)
+	}
+	if !to.IsZero() {
+		upper = getIDBound(to)
+	}
+	filtered := make([]string, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		if (lower == "" || ids[i] >= lower) && (upper == "" || ids[i] < upper) {
+			filtered = append(filtered, ids[i])
+		}
+	}
+	return filtered
+}
+
+// trigger state recorded in audit entry
+type auditTrigger struct {
+	Event    string            `json:"event"`
+	Pipeline string            `json:"pipeline"`
+	Filters  map[string]string `json:"filters,omitempty"`
+	Paused   bool              `json:"paused,omitempty"`
+}
+
+// auditStore storage backend recording trigger event and trigger changes in audit log
+// audit entry is added after change is stored: before state is read just before change, outside of change transaction;
+// changes that failed to be recorded are counted (see AuditStats)
+type auditStore struct {
+	// first field: 64-bit aligned for atomic access on 32-bit platforms
+	failures int64
+	Store
+	retention time.Duration
+}
+
+// NewAuditStore record every trigger event and trigger change made through store in audit log;
+// audit entries are kept for retention period (0: audit log is disabled)
+func NewAuditStore(store Store, retention time.Duration) Store {
+	if retention <= 0 {
+		return store
+	}
+	return &auditStore{Store: store, retention: retention}
+}
+
+// add audit entry; audit failure is logged and counted, and does not fail audited change
+func (s *auditStore) audit(ctx context.Context, account, action, resource, event, pipeline string, before, after interface{}) {
+	lg := log.WithFields(getContextLogFields(ctx)).WithFields(log.Fields{"action": action, "resource": resource, "event": event, "pipeline": pipeline})
+	entry := &model.AuditEntry{Account: account, Action: action, Resource: resource, Event: event, Pipeline: pipeline, Time: time.Now().UTC()}
+	var err error
+	if entry.ID, err = util.GenerateMonotonicULID(); err != nil {
+		lg.WithError(err).Error("failed to generate audit entry ID")
+		return
+	}
+	if auth, err := model.GetAuthEntity(ctx); err == nil && auth != nil {
+		entry.Actor = &model.AuditActor{Name: auth.Name, ID: auth.ID, Type: auth.Type}
+	}
+	if requestID, ok := ctx.Value(model.ContextRequestID).(string); ok {
+		entry.RequestID = requestID
+	}
+	if entry.Before, err = marshalAuditState(before); err == nil {
+		entry.After, err = marshalAuditState(after)
+	}
+	if err == nil {
+		err = s.AppendAudit(ctx, entry, s.retention)
+	}
+	if err != nil {
+		failures := atomic.AddInt64(&s.failures, 1)
+		lg.WithError(err).WithField("audit-failures", failures).Error("failed to add audit entry, change is not recorded in audit log")
+	}
+}
+
+// AuditStats number of changes not recorded in audit log
+func (s *auditStore) AuditStats() model.AuditStats {
+	return model.AuditStats{Failures: atomic.LoadInt64(&s.failures)}
+}
+
+// AuditSecretRead record trigger event secret read in audit log; secret is not recorded
+func (s *auditStore) AuditSecretRead(ctx context.Context, event *model.Event) {
+	s.audit(ctx, auditAccount(ctx, event), model.AuditActionReadSecret, model.AuditResourceEvent, event.URI, "", nil, nil)
+}
+
+// PoolStats connection pool statistics of audited store
+func (s *auditStore) PoolStats() model.PoolStats {
+	if stats, ok := s.Store.(model.PoolStatser); ok {
+		return stats.PoolStats()
+	}
+	return model.PoolStats{}
+}
+
+// encode audited state; secrets are redacted
+func marshalAuditState(state interface{}) (json.RawMessage, error) {
+	switch v := state.(type) {
+	case nil:
+		return nil, nil
+	case *model.Event:
+		if v == nil {
+			return nil, nil
+		}
+		state = v.Redacted()
+	case *auditTrigger:
+		if v == nil {
+			return nil, nil
+		}
+	}
+	return json.Marshal(state)
+}
+
+// audit log account: context account, or trigger event account for all accounts context
+func auditAccount(ctx context.Context, event *model.Event) string {
+	account := getAccount(ctx)
+	if account == "-" && event != nil {
+		return event.Account
+	}
+	return account
+}
+
+// current trigger event state; nil if not found
+func (s *auditStore) getEvent(ctx context.Context, event string) *model.Event {
+	stored, err := s.Store.GetEvent(ctx, event)
+	if err != nil {
+		return nil
+	}
+	return stored
+}
+
+// current trigger state; nil if not found
+func (s *auditStore) getTrigger(ctx context.Context, event, pipeline string) *auditTrigger {
+	triggers, err := s.Store.GetEventTriggers(ctx, event)
+	if err != nil {
+		return nil
+	}
+	for _, t := range triggers {
+		if t.Pipeline == pipeline {
+			return &auditTrigger{Event: t.Event, Pipeline: t.Pipeline, Filters: t.Filters, Paused: t.Paused}
+		}
+	}
+	return nil
+}
+
+// CreateEvent create trigger event and record it in audit log
+func (s *auditStore) CreateEvent(ctx context.Context, eventType, kind, secret, context string, values map[string]string) (*model.Event, error) {
+	event, err := s.Store.CreateEvent(ctx, eventType, kind, secret, context, values)
+	if err == nil {
+		s.audit(ctx, auditAccount(ctx, event), model.AuditActionCreate, model.AuditResourceEvent, event.URI, "", nil, event)
+	}
+	return event, err
+}
+
+// UpdateEvent update trigger event and record change in audit log
+func (s *auditStore) UpdateEvent(ctx context.Context, event string, update model.EventUpdate) (*model.Event, error) {
+	before := s.getEvent(ctx, event)
+	after, err := s.Store.UpdateEvent(ctx, event, update)
+	if err == nil {
+		s.audit(ctx, auditAccount(ctx, after), model.AuditActionUpdate, model.AuditResourceEvent, event, "", before, after)
+	}
+	return after, err
+}
+
+// RotateEventSecret rotate trigger event secret and record change in audit log
+func (s *auditStore) RotateEventSecret(ctx context.Context, event string, rotation model.SecretRotation) (*model.Event, error) {
+	before := s.getEvent(ctx, event)
+	after, err := s.Store.RotateEventSecret(ctx, event, rotation)
+	if err == nil {
+		s.audit(ctx, auditAccount(ctx, after), model.AuditActionUpdate, model.AuditResourceEvent, event, "", before, after)
+	}
+	return after, err
+}
+
+// DeleteEvent delete trigger event and record it in audit log
+func (s *auditStore) DeleteEvent(ctx context.Context, event, context string) error {
+	before := s.getEvent(ctx, event)
+	err := s.Store.DeleteEvent(ctx, event, context)
+	if err == nil {
+		s.audit(ctx, auditAccount(ctx, before), model.AuditActionDelete, model.AuditResourceEvent, event, "", before, nil)
+	}
+	return err
+}
+
+// ForceDeleteEvent delete trigger event with its triggers and record them in audit log
+func (s *auditStore) ForceDeleteEvent(ctx context.Context, event, context string) (*model.EventDeletion, error) {
+	before := s.getEvent(ctx, event)
+	triggers, _ := s.Store.GetEventTriggers(ctx, event)
+	deletion, err := s.Store.ForceDeleteEvent(ctx, event, context)
+	if err == nil {
+		account := auditAccount(ctx, before)
+		for _, t := range triggers {
+			if containsString(deletion.Pipelines, t.Pipeline) {
+				trigger := &auditTrigger{Event: t.Event, Pipeline: t.Pipeline, Filters: t.Filters, Paused: t.Paused}
+				s.audit(ctx, account, model.AuditActionDelete, model.AuditResourceTrigger, event, t.Pipeline, trigger, nil)
+			}
+		}
+		s.audit(ctx, account, model.AuditActionDelete, model.AuditResourceEvent, event, "", before, nil)
+	}
+	return deletion, err
+}
+
+// CreateTrigger create trigger and record it in audit log
+func (s *auditStore) CreateTrigger(ctx context.Context, event, pipeline string, filters map[string]string) error {
+	before := s.getTrigger(ctx, event, pipeline)
+	err := s.Store.CreateTrigger(ctx, event, pipeline, filters)
+	if err == nil {
+		action := model.AuditActionCreate
+		if before != nil {
+			action = model.AuditActionUpdate
+		}
+		s.audit(ctx, getAccount(ctx), action, model.AuditResourceTrigger, event, pipeline, before, s.getTrigger(ctx, event, pipeline))
+	}
+	return err
+}
+
+// DeleteTrigger delete trigger and record it in audit log
+func (s *auditStore) DeleteTrigger(ctx context.Context, event, pipeline string) error {
+	before := s.getTrigger(ctx, event, pipeline)
+	err := s.Store.DeleteTrigger(ctx, event, pipeline)
+	if err == nil {
+		s.audit(ctx, getAccount(ctx), model.AuditActionDelete, model.AuditResourceTrigger, event, pipeline, before, nil)
+	}
+	return err
+}
+
+// DeleteAllTriggersByPipeline delete pipeline triggers and record them in audit log
+func (s *auditStore) DeleteAllTriggersByPipeline(ctx context.Context, pipeline string) error {
+	triggers, _ := s.Store.GetPipelineTriggers(ctx, pipeline, false)
+	err := s.Store.DeleteAllTriggersByPipeline(ctx, pipeline)
+	if err == nil {
+		for _, t := range triggers {
+			trigger := &auditTrigger{Event: t.Event, Pipeline: t.Pipeline, Filters: t.Filters, Paused: t.Paused}
+			s.audit(ctx, getAccount(ctx), model.AuditActionDelete, model.AuditResourceTrigger, t.Event, pipeline, trigger, nil)
+		}
+	}
+	return err
+}
+
+// SetTriggerPaused pause or resume trigger and record change in audit log
+func (s *auditStore) SetTriggerPaused(ctx context.Context, event, pipeline string, paused bool) error {
+	before := s.getTrigger(ctx, event, pipeline)
+	err := s.Store.SetTriggerPaused(ctx, event, pipeline, paused)
+	if err == nil {
+		s.audit(ctx, getAccount(ctx), model.AuditActionUpdate, model.AuditResourceTrigger, event, pipeline, before, s.getTrigger(ctx, event, pipeline))
+	}
+	return err
+}
+
+// DeletePipelineTriggers unlink trigger events from pipeline and record deleted triggers in audit log
+func (s *auditStore) DeletePipelineTriggers(ctx context.Context, pipeline string, events []string) ([]string, error) {
+	allCtx := context.WithValue(ctx, model.ContextKeyAccount, "-")
+	before := make([]*auditTrigger, len(events))
+	for i, event := range events {
+		before[i] = s.getTrigger(allCtx, event, pipeline)
+	}
+	deleted, err := s.Store.DeletePipelineTriggers(ctx, pipeline, events)
+	if err == nil {
+		for i, event := range events {
+			if containsString(deleted, event) {
+				s.audit(ctx, getAccount(ctx), model.AuditActionDelete, model.AuditResourceTrigger, event, pipeline, before[i], nil)
+			}
+		}
+	}
+	return deleted, err
+}
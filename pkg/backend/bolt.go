@@ -0,0 +1,266 @@
+package backend
+
+/*  BoltDB Data Model
+
+	Bolt storage backend keeps Redis data model (see redis.go) in a single file.
+	Each key prefix is a top level bucket and each key is a nested bucket:
+
+	+-----------+     +-----------------------------+     +----------------------+
+	| event     +-----> {event-uri}                 +-----> {field}: {value}     |
+	+-----------+     +-----------------------------+     +----------------------+
+	| filter    +-----> {n}:{event-uri}:{pipeline}  +-----> {field}: {filter}    |
+	+-----------+     +-----------------------------+     +----------------------+
+	| trigger   +-----> {event-uri}                 +-----> {pipeline-uid}: ""   |
+	+-----------+     +-----------------------------+     +----------------------+
+	| pipeline  +-----> {pipeline-uid}              +-----> {event-uri}: ""      |
+	+-----------+     +-----------------------------+     +----------------------+
+	| account   +-----> {account-hash}:events       +-----> {event-uri}: ""      |
+	+-----------+     +-----------------------------+     +----------------------+
+	| type      +-----> {type}:{kind}:events        +-----> {event-uri}: ""      |
+	+-----------+     +-----------------------------+     +----------------------+
+	| meta      +-----> schema                      +-----> version: {version}   |
+	+-----------+     +-----------------------------+     +----------------------+
+
+	Bolt keeps keys sorted, so set members are returned in lexicographical order (same as
+	Redis sorted set with zero scores). All changes are done inside Bolt transactions.
+
+*/
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/codefresh-io/hermes/pkg/codefresh"
+	"github.com/codefresh-io/hermes/pkg/provider"
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+type (
+	// boltDB key/value database on top of Bolt file
+	boltDB struct {
+		db *bolt.DB
+	}
+
+	// boltTx Bolt transaction
+	boltTx struct {
+		tx *bolt.Tx
+	}
+
+	// BoltStore embedded file-backed storage backend
+	// Bolt file can be opened by a single process only; use for single node installations
+	BoltStore struct {
+		kvStore
+		bolt *bolt.DB
+	}
+)
+
+// errInvalidKey error when key does not have 'prefix:' part
+var errInvalidKey = errors.New("invalid key: missing prefix")
+
+func init() {
+	RegisterStore("bolt", func(config StoreConfig, pipelineSvc codefresh.PipelineService, eventProvider provider.EventProvider) (Store, error) {
+		cipher, err := newSecretCipher(config)
+		if err != nil {
+			return nil, err
+		}
+		store, err := NewBoltStore(config.Path, pipelineSvc, eventProvider)
+		if err != nil {
+			return nil, err
+		}
+		store.cipher = cipher
+		return store, nil
+	})
+}
+
+// NewBoltStore open (or create) Bolt file for storing trigger map
+func NewBoltStore(path string, pipelineSvc codefresh.PipelineService, eventProvider provider.EventProvider) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		log.WithError(err).WithField("path", path).Error("failed to open Bolt store file")
+		return nil, err
+	}
+	return &BoltStore{
+		kvStore: kvStore{db: &boltDB{db}, pipelineSvc: pipelineSvc, eventProvider: eventProvider},
+		bolt:    db,
+	}, nil
+}
+
+// Ping check Bolt file is open
+func (b *BoltStore) Ping() (string, error) {
+	err := b.bolt.View(func(*bolt.Tx) error { return nil })
+	if err != nil {
+		log.WithError(err).Error("Failed to ping Bolt store")
+		return "", err
+	}
+	return "PONG", nil
+}
+
+// Close Bolt file
+func (b *BoltStore) Close() error {
+	return b.bolt.Close()
+}
+
+func (db *boltDB) view(fn func(tx kvTx) error) error {
+	return db.db.View(func(tx *bolt.Tx) error {
+		return fn(&boltTx{tx})
+	})
+}
+
+func (db *boltDB) update(fn func(tx kvTx) error) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		return fn(&boltTx{tx})
+	})
+}
+
+// split key into top level bucket name (key prefix) and nested bucket name
+func splitKey(key string) (string, string, error) {
+	i := strings.IndexByte(key, ':')
+	if i <= 0 || i == len(key)-1 {
+		return "", "", errInvalidKey
+	}
+	return key[:i], key[i+1:], nil
+}
+
+// get nested bucket for key; nil if not exists
+func (t *boltTx) bucket(key string) (*bolt.Bucket, error) {
+	prefix, name, err := splitKey(key)
+	if err != nil {
+		return nil, err
+	}
+	top := t.tx.Bucket([]byte(prefix))
+	if top == nil {
+		return nil, nil
+	}
+	return top.Bucket([]byte(name)), nil
+}
+
+// get or create nested bucket for key
+func (t *boltTx) createBucket(key string) (*bolt.Bucket, error) {
+	prefix, name, err := splitKey(key)
+	if err != nil {
+		return nil, err
+	}
+	top, err := t.tx.CreateBucketIfNotExists([]byte(prefix))
+	if err != nil {
+		return nil, err
+	}
+	return top.CreateBucketIfNotExists([]byte(name))
+}
+
+func (t *boltTx) exists(key string) (bool, error) {
+	b, err := t.bucket(key)
+	if err != nil {
+		return false, err
+	}
+	return b != nil, nil
+}
+
+func (t *boltTx) keys(pattern string) ([]string, error) {
+	re, err := compileKeyPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0)
+	scan := func(prefix []byte, top *bolt.Bucket) error {
+		return top.ForEach(func(k, v []byte) error {
+			// nested buckets have nil value
+			if v == nil {
+				if key := string(prefix) + ":" + string(k); re.MatchString(key) {
+					keys = append(keys, key)
+				}
+			}
+			return nil
+		})
+	}
+	// scan single top level bucket, when pattern has constant prefix
+	if i := strings.IndexByte(pattern, ':'); i > 0 && !strings.ContainsAny(pattern[:i], `*?[\`) {
+		top := t.tx.Bucket([]byte(pattern[:i]))
+		if top == nil {
+			return keys, nil
+		}
+		return keys, scan([]byte(pattern[:i]), top)
+	}
+	return keys, t.tx.ForEach(scan)
+}
+
+func (t *boltTx) getHash(key string) (map[string]string, error) {
+	fields := make(map[string]string)
+	b, err := t.bucket(key)
+	if err != nil || b == nil {
+		return fields, err
+	}
+	err = b.ForEach(func(k, v []byte) error {
+		fields[string(k)] = string(v)
+		return nil
+	})
+	return fields, err
+}
+
+func (t *boltTx) setHash(key string, fields map[string]string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	b, err := t.createBucket(key)
+	if err != nil {
+		return err
+	}
+	for k, v := range fields {
+		if err := b.Put([]byte(k), []byte(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *boltTx) getMembers(key string) ([]string, error) {
+	members := make([]string, 0)
+	b, err := t.bucket(key)
+	if err != nil || b == nil {
+		return members, err
+	}
+	err = b.ForEach(func(k, _ []byte) error {
+		members = append(members, string(k))
+		return nil
+	})
+	return members, err
+}
+
+func (t *boltTx) addMember(key, member string) error {
+	b, err := t.createBucket(key)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(member), []byte{})
+}
+
+func (t *boltTx) removeMember(key, member string) error {
+	b, err := t.bucket(key)
+	if err != nil || b == nil {
+		return err
+	}
+	if err := b.Delete([]byte(member)); err != nil {
+		return err
+	}
+	// delete empty set
+	if k, _ := b.Cursor().First(); k == nil {
+		return t.delete(key)
+	}
+	return nil
+}
+
+func (t *boltTx) delete(key string) error {
+	prefix, name, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+	top := t.tx.Bucket([]byte(prefix))
+	if top == nil {
+		return nil
+	}
+	if err := top.DeleteBucket([]byte(name)); err != nil && err != bolt.ErrBucketNotFound {
+		return err
+	}
+	return nil
+}
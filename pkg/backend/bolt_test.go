@@ -0,0 +1,76 @@
+package backend
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoltStore_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hermes.db")
+	store, err := NewBoltStore(path, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to open bolt store: %v", err)
+	}
+	err = store.db.update(func(tx kvTx) error {
+		if err := tx.setHash("event:uri", map[string]string{"secret": "s"}); err != nil {
+			return err
+		}
+		return tx.addMember("trigger:uri", "p1")
+	})
+	assert.NoError(t, err)
+	// failed transaction should not change data
+	err = store.db.update(func(tx kvTx) error {
+		if err := tx.addMember("trigger:uri", "p2"); err != nil {
+			return err
+		}
+		return errors.New("failure")
+	})
+	assert.Error(t, err)
+	assert.NoError(t, store.Close())
+
+	// reopen file and read data back
+	store, err = NewBoltStore(path, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to reopen bolt store: %v", err)
+	}
+	defer store.Close()
+	err = store.db.view(func(tx kvTx) error {
+		hash, err := tx.getHash("event:uri")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"secret": "s"}, hash)
+		members, err := tx.getMembers("trigger:uri")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"p1"}, members)
+		keys, err := tx.keys("*")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"event:uri", "trigger:uri"}, keys)
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+func Test_splitKey(t *testing.T) {
+	tests := []struct {
+		key    string
+		prefix string
+		name   string
+		err    error
+	}{
+		{"event:registry:dockerhub:repo", "event", "registry:dockerhub:repo", nil},
+		{"pipeline:123", "pipeline", "123", nil},
+		{"event", "", "", errInvalidKey},
+		{":name", "", "", errInvalidKey},
+		{"event:", "", "", errInvalidKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			prefix, name, err := splitKey(tt.key)
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.prefix, prefix)
+			assert.Equal(t, tt.name, name)
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package backend
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+/*  Secrets Encryption
+
+	Sensitive trigger event fields (secret and previous secret) are encrypted at rest with envelope encryption:
+	every value is encrypted with a new random data key (AES-256-GCM) and the data key is encrypted with the master
+	key (AES-256-GCM). Master key ID is stored alongside ciphertext, so master keys can be rotated:
+
+	enc:v1:{key-id}:{base64(nonce + encrypted data key)}:{base64(nonce + encrypted value)}
+
+	Master keys are configured as "key-id=base64(32 bytes key)" list: the first key encrypts new values, all keys
+	decrypt existing values. Values without "enc:" prefix are stored in plaintext (before encryption was enabled).
+
+*/
+
+// encrypted value prefix and format version
+const encryptedPrefix = "enc:v1:"
+
+// trigger event fields encrypted at rest
+var encryptedFields = []string{"secret", "previous_secret"}
+
+// ErrUnknownKey error when value is encrypted with master key that is not configured
+var ErrUnknownKey = errors.New("value encrypted with unknown encryption key")
+
+// ErrNoEncryptionKey error when encryption is required, but no encryption key is configured
+var ErrNoEncryptionKey = errors.New("no encryption key configured")
+
+// secretCipher encrypts and decrypts sensitive trigger event fields; nil cipher keeps fields in plaintext
+type secretCipher struct {
+	// master keys by key ID
+	keys map[string]cipher.AEAD
+	// current master key ID: used to encrypt new values
+	current string
+}
+
+// parse master keys: "key-id=base64 key" pairs separated by comma or new line; the first key is current
+func parseEncryptionKeys(spec string) (*secretCipher, error) {
+	c := &secretCipher{keys: make(map[string]cipher.AEAD)}
+	for _, entry := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || strings.Contains(kv[0], ":") {
+			return nil, fmt.Errorf("invalid encryption key entry, expected 'key-id=base64-key'")
+		}
+		id := strings.TrimSpace(kv[0])
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(kv[1]))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("invalid encryption key %s: expected base64 encoded 32 bytes key", id)
+		}
+		if _, ok := c.keys[id]; ok {
+			return nil, fmt.Errorf("duplicate encryption key %s", id)
+		}
+		if c.keys[id], err = newGCM(key); err != nil {
+			return nil, err
+		}
+		if c.current == "" {
+			c.current = id
+		}
+	}
+	if c.current == "" {
+		return nil, nil
+	}
+	return c, nil
+}
+
+// create secret cipher from store configuration; nil when no encryption key is configured
+func newSecretCipher(config StoreConfig) (*secretCipher, error) {
+	spec := config.EncryptionKeys
+	if config.EncryptionKeyFile != "" {
+		data, err := ioutil.ReadFile(config.EncryptionKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		spec = strings.Join([]string{string(data), spec}, "\n")
+	}
+	return parseEncryptionKeys(spec)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal plaintext with random nonce: nonce + ciphertext
+func seal(aead cipher.AEAD, plaintext, data []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, data), nil
+}
+
+// open sealed nonce + ciphertext
+func open(aead cipher.AEAD, sealed, data []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("invalid encrypted value")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], data)
+}
+
+// encrypt value with new data key, encrypted with current master key
+func (c *secretCipher) encrypt(value string) (string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	wrappedKey, err := seal(c.keys[c.current], dataKey, []byte(c.current))
+	if err != nil {
+		return "", err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(aead, []byte(value), nil)
+	if err != nil {
+		return "", err
+	}
+	return encryptedPrefix + c.current + ":" + base64.StdEncoding.EncodeToString(wrappedKey) + ":" +
+		base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// decrypt value; returns plaintext value as is
+func (c *secretCipher) decrypt(value string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if len(parts) != 3 {
+		return "", errors.New("invalid encrypted value")
+	}
+	if c == nil {
+		return "", ErrNoEncryptionKey
+	}
+	master, ok := c.keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("%v: %s", ErrUnknownKey, parts[0])
+	}
+	wrappedKey, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := open(master, wrappedKey, []byte(parts[0]))
+	if err != nil {
+		return "", err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(aead, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// value is encrypted with current master key
+func (c *secretCipher) isCurrent(value string) bool {
+	return c != nil && strings.HasPrefix(value, encryptedPrefix+c.current+":")
+}
+
+// encrypt non-empty sensitive fields in place; no-op for nil cipher
+func (c *secretCipher) encryptFields(fields map[string]string) error {
+	if c == nil {
+		return nil
+	}
+	for _, name := range encryptedFields {
+		if v, ok := fields[name]; ok && v != "" {
+			encrypted, err := c.encrypt(v)
+			if err != nil {
+				return err
+			}
+			fields[name] = encrypted
+		}
+	}
+	return nil
+}
+
+// decrypt sensitive fields in place
+func (c *secretCipher) decryptFields(fields map[string]string) error {
+	for _, name := range encryptedFields {
+		if v, ok := fields[name]; ok {
+			decrypted, err := c.decrypt(v)
+			if err != nil {
+				return err
+			}
+			fields[name] = decrypted
+		}
+	}
+	return nil
+}
+
+// re-encrypt sensitive fields with current master key; returns changed fields (empty when up to date)
+func (c *secretCipher) reencryptFields(fields map[string]string) (map[string]string, error) {
+	if c == nil {
+		return nil, ErrNoEncryptionKey
+	}
+	changed := make(map[string]string)
+	for _, name := range encryptedFields {
+		v := fields[name]
+		if v == "" || c.isCurrent(v) {
+			continue
+		}
+		plaintext, err := c.decrypt(v)
+		if err != nil {
+			return nil, err
+		}
+		if changed[name], err = c.encrypt(plaintext); err != nil {
+			return nil, err
+		}
+	}
+	return changed, nil
+}
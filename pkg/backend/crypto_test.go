@@ -0,0 +1,275 @@
+package backend
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+)
+
+// test master key: base64 encoded 32 bytes
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(rune(b)), 32)))
+}
+
+func testCipher(t *testing.T, spec string) *secretCipher {
+	c, err := parseEncryptionKeys(spec)
+	if err != nil {
+		t.Fatalf("failed to parse encryption keys: %v", err)
+	}
+	return c
+}
+
+func Test_parseEncryptionKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		current string
+		keys    int
+		wantErr bool
+	}{
+		{"no keys", "", "", 0, false},
+		{"single key", "k1=" + testKey('a'), "k1", 1, false},
+		{"first key is current", "k2=" + testKey('b') + ",k1=" + testKey('a'), "k2", 2, false},
+		{"key file lines", "# master keys\nk2=" + testKey('b') + "\n\nk1=" + testKey('a') + "\n", "k2", 2, false},
+		{"missing key id", "=" + testKey('a'), "", 0, true},
+		{"key id with colon", "k:1=" + testKey('a'), "", 0, true},
+		{"short key", "k1=" + base64.StdEncoding.EncodeToString([]byte("short")), "", 0, true},
+		{"not base64 key", "k1=not-base64!", "", 0, true},
+		{"duplicate key", "k1=" + testKey('a') + ",k1=" + testKey('b'), "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := parseEncryptionKeys(tt.spec)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			if tt.keys == 0 {
+				assert.Nil(t, c)
+				return
+			}
+			assert.Equal(t, tt.current, c.current)
+			assert.Equal(t, tt.keys, len(c.keys))
+		})
+	}
+}
+
+func Test_newSecretCipher(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "keys")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("k2="+testKey('b')+"\n"), 0600))
+	// key file keys go first
+	c, err := newSecretCipher(StoreConfig{EncryptionKeyFile: file, EncryptionKeys: "k1=" + testKey('a')})
+	assert.NoError(t, err)
+	assert.Equal(t, "k2", c.current)
+	assert.Equal(t, 2, len(c.keys))
+	_, err = newSecretCipher(StoreConfig{EncryptionKeyFile: filepath.Join(t.TempDir(), "missing")})
+	assert.Error(t, err)
+	c, err = newSecretCipher(StoreConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+}
+
+func Test_secretCipher(t *testing.T) {
+	c := testCipher(t, "k1="+testKey('a'))
+	encrypted, err := c.encrypt("secret")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encrypted, "enc:v1:k1:"))
+	assert.NotContains(t, encrypted, "secret")
+	// new data key and nonce for every value
+	other, err := c.encrypt("secret")
+	assert.NoError(t, err)
+	assert.NotEqual(t, encrypted, other)
+	decrypted, err := c.decrypt(encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", decrypted)
+	// plaintext value is returned as is, also without cipher
+	decrypted, err = c.decrypt("plain")
+	assert.NoError(t, err)
+	assert.Equal(t, "plain", decrypted)
+	decrypted, err = (*secretCipher)(nil).decrypt("plain")
+	assert.NoError(t, err)
+	assert.Equal(t, "plain", decrypted)
+	// encrypted value without cipher or with unknown key
+	_, err = (*secretCipher)(nil).decrypt(encrypted)
+	assert.Equal(t, ErrNoEncryptionKey, err)
+	_, err = testCipher(t, "k2="+testKey('b')).decrypt(encrypted)
+	assert.Error(t, err)
+	// the same key ID with different key material
+	_, err = testCipher(t, "k1="+testKey('b')).decrypt(encrypted)
+	assert.Error(t, err)
+	// tampered value
+	_, err = c.decrypt(encrypted[:len(encrypted)-4] + "AAAA")
+	assert.Error(t, err)
+	_, err = c.decrypt("enc:v1:k1:invalid")
+	assert.Error(t, err)
+}
+
+func Test_secretCipher_fields(t *testing.T) {
+	c := testCipher(t, "k1="+testKey('a'))
+	fields := map[string]string{"secret": "s1", "previous_secret": "", "endpoint": "http://endpoint"}
+	assert.NoError(t, c.encryptFields(fields))
+	assert.True(t, c.isCurrent(fields["secret"]))
+	assert.Equal(t, "", fields["previous_secret"])
+	assert.Equal(t, "http://endpoint", fields["endpoint"])
+	// up to date fields
+	changed, err := c.reencryptFields(fields)
+	assert.NoError(t, err)
+	assert.Empty(t, changed)
+	// rotate master key: k2 is current, k1 still decrypts
+	rotated := testCipher(t, "k2="+testKey('b')+",k1="+testKey('a'))
+	changed, err = rotated.reencryptFields(fields)
+	assert.NoError(t, err)
+	assert.True(t, rotated.isCurrent(changed["secret"]))
+	assert.NoError(t, rotated.decryptFields(changed))
+	assert.Equal(t, map[string]string{"secret": "s1"}, changed)
+	// nil cipher keeps plaintext and cannot re-encrypt
+	plain := map[string]string{"secret": "s1"}
+	assert.NoError(t, (*secretCipher)(nil).encryptFields(plain))
+	assert.Equal(t, "s1", plain["secret"])
+	_, err = (*secretCipher)(nil).reencryptFields(plain)
+	assert.Equal(t, ErrNoEncryptionKey, err)
+}
+
+// replace storage backend secret cipher, as if it was reopened with new encryption keys
+func setStoreCipher(t *testing.T, store Store, c *secretCipher) {
+	switch s := store.(type) {
+	case *RedisStore:
+		s.cipher = c
+		s.eventGetter = &RedisEventGetter{redisPool: s.redisPool, cipher: c}
+	case *MemoryStore:
+		s.cipher = c
+	case *BoltStore:
+		s.cipher = c
+	default:
+		t.Fatalf("unexpected store type %T", store)
+	}
+}
+
+// trigger event fields as stored
+func rawEventFields(t *testing.T, store Store, account, uri string) map[string]string {
+	var fields map[string]string
+	var err error
+	switch s := store.(type) {
+	case *RedisStore:
+		con := s.redisPool.GetConn()
+		defer con.Close()
+		fields, err = redis.StringMap(con.Do("HGETALL", getEventKey(account, uri)))
+	case *MemoryStore:
+		err = s.db.view(func(tx kvTx) (err error) {
+			fields, err = tx.getHash(getEventKey(account, uri))
+			return err
+		})
+	case *BoltStore:
+		err = s.db.view(func(tx kvTx) (err error) {
+			fields, err = tx.getHash(getEventKey(account, uri))
+			return err
+		})
+	default:
+		t.Fatalf("unexpected store type %T", store)
+	}
+	if err != nil {
+		t.Fatalf("failed to get trigger event fields: %v", err)
+	}
+	return fields
+}
+
+func TestStoreEncryption(t *testing.T) {
+	for _, driver := range StoreDrivers() {
+		t.Run(driver, func(t *testing.T) {
+			f := newTestStore(t, driver)
+			ctx := storeContext("A", false)
+			// encryption is not configured
+			_, err := f.ReEncrypt(ctx)
+			assert.Equal(t, ErrNoEncryptionKey, err)
+			plain := f.createEvent(t, "A", "plain", "s1", false)
+			assert.Equal(t, "s1", rawEventFields(t, f.Store, "A", plain.URI)["secret"])
+
+			// enable encryption: existing plaintext secrets are still readable
+			setStoreCipher(t, f.Store, testCipher(t, "k1="+testKey('a')))
+			event, err := f.GetEvent(ctx, plain.URI)
+			assert.NoError(t, err)
+			assert.Equal(t, "s1", event.Secret)
+			encrypted := f.createEvent(t, "A", "encrypted", "s2", false)
+			assert.Equal(t, "s2", encrypted.Secret)
+			assert.True(t, strings.HasPrefix(rawEventFields(t, f.Store, "A", encrypted.URI)["secret"], "enc:v1:k1:"))
+			event, err = f.GetEvent(ctx, encrypted.URI)
+			assert.NoError(t, err)
+			assert.Equal(t, "s2", event.Secret)
+			// create existing event with the same secret
+			_, err = f.CreateEvent(ctx, "registry", "dockerhub", "s2", "", map[string]string{"name": "encrypted"})
+			assert.NoError(t, err)
+
+			// update and rotate secrets: new and previous secrets are encrypted
+			secret := "s3"
+			_, err = f.UpdateEvent(ctx, encrypted.URI, model.EventUpdate{Secret: &secret})
+			assert.NoError(t, err)
+			_, err = f.RotateEventSecret(ctx, encrypted.URI, model.SecretRotation{Grace: time.Hour})
+			assert.NoError(t, err)
+			raw := rawEventFields(t, f.Store, "A", encrypted.URI)
+			assert.True(t, strings.HasPrefix(raw["secret"], "enc:v1:k1:"))
+			assert.True(t, strings.HasPrefix(raw["previous_secret"], "enc:v1:k1:"))
+			event, err = f.GetEvent(ctx, encrypted.URI)
+			assert.NoError(t, err)
+			assert.Equal(t, "s3", event.PreviousSecret)
+			assert.NotEqual(t, "s3", event.Secret)
+			rotated := event.Secret
+			events, err := f.GetEvents(ctx, "", "", "")
+			assert.NoError(t, err)
+			for _, e := range events {
+				assert.False(t, strings.HasPrefix(e.Secret, encryptedPrefix))
+			}
+
+			// rotate master key: re-encrypt plaintext and k1 secrets with k2
+			setStoreCipher(t, f.Store, testCipher(t, "k2="+testKey('b')+",k1="+testKey('a')))
+			n, err := f.ReEncrypt(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, 2, n)
+			for _, uri := range []string{plain.URI, encrypted.URI} {
+				raw = rawEventFields(t, f.Store, "A", uri)
+				assert.True(t, strings.HasPrefix(raw["secret"], "enc:v1:k2:"), uri)
+			}
+			assert.True(t, strings.HasPrefix(raw["previous_secret"], "enc:v1:k2:"))
+			n, err = f.ReEncrypt(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, 0, n)
+
+			// k1 is retired
+			setStoreCipher(t, f.Store, testCipher(t, "k2="+testKey('b')))
+			event, err = f.GetEvent(ctx, encrypted.URI)
+			assert.NoError(t, err)
+			assert.Equal(t, rotated, event.Secret)
+			assert.Equal(t, "s3", event.PreviousSecret)
+			event, err = f.GetEvent(ctx, plain.URI)
+			assert.NoError(t, err)
+			assert.Equal(t, "s1", event.Secret)
+
+			// unknown key
+			setStoreCipher(t, f.Store, testCipher(t, "k3="+testKey('c')))
+			_, err = f.GetEvent(ctx, encrypted.URI)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNewStore_encryptionKeys(t *testing.T) {
+	for _, driver := range []string{"memory", "bolt"} {
+		config := StoreConfig{Path: filepath.Join(t.TempDir(), "hermes.db"), EncryptionKeys: "invalid"}
+		_, err := NewStore(driver, config, nil, nil)
+		assert.Error(t, err, driver)
+	}
+	_, err := NewStore("redis", StoreConfig{EncryptionKeys: "invalid"}, nil, nil)
+	assert.Error(t, err)
+	store, err := NewStore("memory", StoreConfig{EncryptionKeys: fmt.Sprintf("k1=%s", testKey('a'))}, nil, nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, store.(*MemoryStore).cipher)
+}
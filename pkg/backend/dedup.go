@@ -0,0 +1,101 @@
+package backend
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/codefresh-io/hermes/pkg/provider"
+	log "github.com/sirupsen/logrus"
+)
+
+/*  Deduplication
+
+	delivery:{len(event)}:{event}:{delivery-id} (String) -> first delivery (JSON); expires after TTL
+	(kv stores: delivery:{event} (Hash) -> {delivery-id}: first delivery (JSON); expired fields are removed on update)
+
+*/
+
+// delivery key
+func getDeliveryKey(event, id string) string {
+	return getPrefixKey("delivery", fmt.Sprintf("%d:%s:%s", len(event), event, id))
+}
+
+// trigger event deliveries key (kv stores)
+func getDeliveriesKey(event string) string {
+	return getPrefixKey("delivery", event)
+}
+
+// Deduplicator remembers event delivery IDs per trigger event URI for TTL
+type Deduplicator struct {
+	store         Store
+	eventProvider provider.EventProvider
+	ttl           time.Duration
+}
+
+// NewDeduplicator create new deduplicator; delivery IDs are remembered for TTL (0: deduplication is disabled)
+func NewDeduplicator(store Store, eventProvider provider.EventProvider, ttl time.Duration) *Deduplicator {
+	return &Deduplicator{store: store, eventProvider: eventProvider, ttl: ttl}
+}
+
+// Deliver remember event delivery with run record ID; returns first delivery when event was already delivered
+func (d *Deduplicator) Deliver(ctx context.Context, event string, data model.NormalizedEvent, run string) (*model.Delivery, error) {
+	if d.ttl <= 0 {
+		return nil, nil
+	}
+	id := d.getDeliveryID(event, data)
+	if id == "" {
+		return nil, nil
+	}
+	now := time.Now().UTC()
+	delivery := &model.Delivery{ID: id, Event: event, Run: run, Received: now, Expires: now.Add(d.ttl)}
+	first, err := d.store.AddDelivery(ctx, delivery, d.ttl)
+	if err != nil {
+		return nil, err
+	}
+	if first != nil {
+		log.WithFields(getContextLogFields(ctx)).WithFields(log.Fields{
+			"event":    event,
+			"delivery": id,
+			"run":      first.Run,
+		}).Info("skipping duplicate event delivery")
+	}
+	return first, nil
+}
+
+// Forget forget event delivery of run record ID, when event failed to run: next delivery is not a duplicate
+func (d *Deduplicator) Forget(ctx context.Context, event string, data model.NormalizedEvent, run string) error {
+	if d.ttl <= 0 {
+		return nil
+	}
+	id := d.getDeliveryID(event, data)
+	if id == "" {
+		return nil
+	}
+	return d.store.RemoveDelivery(ctx, event, id, run)
+}
+
+// delivery ID: event type delivery ID variable, or SHA256 of original payload when event type opts in
+// ("": event cannot be deduplicated)
+func (d *Deduplicator) getDeliveryID(event string, data model.NormalizedEvent) string {
+	if d.eventProvider == nil {
+		return ""
+	}
+	eventType, err := d.eventProvider.MatchType(event)
+	if err != nil {
+		return ""
+	}
+	if eventType.DeliveryID != "" {
+		if id := data.Variables[eventType.DeliveryID]; id != "" {
+			return id
+		}
+	}
+	if !eventType.DedupPayload || data.Original == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(data.Original))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,308 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/codefresh-io/hermes/pkg/util"
+	log "github.com/sirupsen/logrus"
+)
+
+/*  Dispatch Queue
+
+	dispatch:{scope} (Hash)   -> since: time dispatch was paused (key exists while scope is paused)
+	queue:{scope} (Set)       -> {event-id}: queued event IDs (ULID), sorted in queue order
+	queued:{event-id} (Hash)  -> account, event, queued, secret (encrypted), original, variables (JSON)
+	lock:dispatch:{scope}     -> resume lock: one queue drain of scope at a time (see Locker)
+
+	* scope - account ID or '-' for all accounts (model.DispatchGlobal)
+
+*/
+
+// dispatch pause state key
+func getDispatchKey(scope string) string {
+	return getPrefixKey("dispatch", scope)
+}
+
+// dispatch queue key
+func getQueueKey(scope string) string {
+	return getPrefixKey("queue", scope)
+}
+
+// queued event key
+func getQueuedEventKey(id string) string {
+	return getPrefixKey("queued", id)
+}
+
+// convert queued event to stored hash fields; secret is encrypted with store cipher
+func queuedEventToFields(c *secretCipher, event *model.QueuedEvent) (map[string]string, error) {
+	variables, err := json.Marshal(event.Data.Variables)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]string{
+		"account":   event.Account,
+		"event":     event.Event,
+		"queued":    event.Queued.UTC().Format(time.RFC3339Nano),
+		"secret":    event.Data.Secret,
+		"original":  event.Data.Original,
+		"variables": string(variables),
+	}
+	return fields, c.encryptFields(fields)
+}
+
+// convert stored hash fields to queued event
+func fieldsToQueuedEvent(c *secretCipher, id string, fields map[string]string) (*model.QueuedEvent, error) {
+	if err := c.decryptFields(fields); err != nil {
+		return nil, err
+	}
+	event := &model.QueuedEvent{
+		ID:      id,
+		Account: fields["account"],
+		Event:   fields["event"],
+		Data:    model.NormalizedEvent{Secret: fields["secret"], Original: fields["original"]},
+	}
+	if t, err := time.Parse(time.RFC3339Nano, fields["queued"]); err == nil {
+		event.Queued = t
+	}
+	if fields["variables"] != "" {
+		if err := json.Unmarshal([]byte(fields["variables"]), &event.Data.Variables); err != nil {
+			return nil, err
+		}
+	}
+	return event, nil
+}
+
+// dispatch state of paused scope
+func newDispatchState(scope, since string, queued int) model.DispatchState {
+	state := model.DispatchState{Scope: scope, Queued: queued}
+	state.Since, _ = time.Parse(time.RFC3339Nano, since)
+	return state
+}
+
+// dispatch resume lock: one drain of scope queue at a time; extended before every queued event
+const dispatchLockTTL = 15 * time.Minute
+
+// dispatch resume lock name
+func getDispatchLock(scope string) string {
+	return getPrefixKey("dispatch", scope)
+}
+
+// Dispatcher queues normalized events while dispatch is paused and runs them on resume
+type Dispatcher struct {
+	store   Store
+	runner  model.Runner
+	history model.RunHistory
+	limiter model.RateLimiter
+}
+
+// NewDispatcher create new dispatcher; queued events are run with runner, rate limited like /run and recorded in
+// run history
+func NewDispatcher(store Store, runner model.Runner, history model.RunHistory, limiter model.RateLimiter) *Dispatcher {
+	return &Dispatcher{store: store, runner: runner, history: history, limiter: limiter}
+}
+
+// Queue queue event when dispatch is paused for all accounts or for account; nil when dispatch is not paused
+func (d *Dispatcher) Queue(ctx context.Context, account, event string, data model.NormalizedEvent) (*model.QueuedEvent, error) {
+	id, err := util.GenerateMonotonicULID()
+	if err != nil {
+		return nil, err
+	}
+	queued := &model.QueuedEvent{ID: id, Account: account, Event: event, Queued: time.Now(), Data: data}
+	for _, scope := range []string{model.DispatchGlobal, account} {
+		ok, err := d.store.QueueEvent(ctx, scope, queued)
+		if err != nil || ok {
+			return queued, err
+		}
+	}
+	return nil, nil
+}
+
+// Pause pause dispatch for scope
+func (d *Dispatcher) Pause(ctx context.Context, scope string) error {
+	return d.store.PauseDispatch(ctx, scope)
+}
+
+// GetStates list paused scopes
+func (d *Dispatcher) GetStates(ctx context.Context) ([]model.DispatchState, error) {
+	return d.store.GetDispatchStates(ctx)
+}
+
+// Resume run queued events in order (or discard them) and resume dispatch for scope
+// dispatch stays paused until queue is empty: events received while draining are queued after drained events
+// queued event is removed only after it was run; event failed to run is moved to queue tail and kept there
+func (d *Dispatcher) Resume(ctx context.Context, scope string, discard bool) (*model.DispatchResult, error) {
+	lg := log.WithFields(getContextLogFields(ctx)).WithField("scope", scope)
+	owner, err := util.GenerateMonotonicULID()
+	if err != nil {
+		return nil, err
+	}
+	locked, err := d.store.AcquireLock(ctx, getDispatchLock(scope), owner, dispatchLockTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, model.ErrDispatchResuming
+	}
+	defer func() {
+		if err := d.store.ReleaseLock(ctx, getDispatchLock(scope), owner); err != nil {
+			lg.WithError(err).Error("failed to release dispatch lock")
+		}
+	}()
+	result := &model.DispatchResult{Scope: scope}
+	// events failed to run during this drain
+	failed := make(map[string]bool)
+	for {
+		// stop draining when another resume took over expired lock
+		if err = extendLock(ctx, d.store, getDispatchLock(scope), owner, dispatchLockTTL); err != nil {
+			lg.WithError(err).Error("stopping dispatch resume")
+			return result, err
+		}
+		event, err := d.store.PeekQueuedEvent(ctx, scope)
+		if err != nil {
+			lg.WithError(err).Error("failed to get queued event")
+			return result, err
+		}
+		if event != nil && failed[event.ID] {
+			// only events failed to run (and events queued after them) are left in queue
+			lg.WithField("failed", result.Failed).Warn("failed to run some queued events, dispatch stays paused")
+			return result, nil
+		}
+		if event == nil {
+			// resume dispatch, unless new events were queued meanwhile
+			err = d.store.ResumeDispatch(ctx, scope)
+			if err == model.ErrDispatchQueueNotEmpty {
+				continue
+			}
+			if err == nil {
+				lg.WithFields(log.Fields{
+					"dispatched": result.Dispatched,
+					"requeued":   result.Requeued,
+					"discarded":  result.Discarded,
+				}).Info("dispatch resumed")
+			}
+			return result, err
+		}
+		elg := lg.WithFields(log.Fields{"queued-event": event.ID, "event": event.Event, "account": event.Account})
+		if discard {
+			elg.Info("discarding queued event")
+			if err = d.store.AckQueuedEvent(ctx, scope, event.ID); err != nil {
+				return result, err
+			}
+			result.Discarded++
+			continue
+		}
+		// global queue: keep events of paused account in account queue (new ID: queued event keys are not shared)
+		if scope == model.DispatchGlobal {
+			moved := *event
+			if moved.ID, err = util.GenerateMonotonicULID(); err != nil {
+				return result, err
+			}
+			requeued, err := d.store.QueueEvent(ctx, event.Account, &moved)
+			if err != nil {
+				elg.WithError(err).Error("failed to queue event for paused account")
+				return result, err
+			}
+			if requeued {
+				if err = d.store.AckQueuedEvent(ctx, scope, event.ID); err != nil {
+					return result, err
+				}
+				result.Requeued++
+				continue
+			}
+		}
+		if err = d.run(ctx, event); err != nil {
+			elg.WithError(err).Error("failed to run queued event, moving it to queue tail")
+			retry := *event
+			if retry.ID, err = util.GenerateMonotonicULID(); err != nil {
+				return result, err
+			}
+			if err = d.store.RequeueEvent(ctx, scope, event.ID, &retry); err != nil {
+				return result, err
+			}
+			failed[retry.ID] = true
+			result.Failed++
+			continue
+		}
+		if err = d.store.AckQueuedEvent(ctx, scope, event.ID); err != nil {
+			return result, err
+		}
+		result.Dispatched++
+	}
+}
+
+// run pipelines for queued event, like /run does for received event; run is recorded in run history
+func (d *Dispatcher) run(ctx context.Context, event *model.QueuedEvent) error {
+	record := &model.RunRecord{
+		Account:     event.Account,
+		Event:       event.Event,
+		Received:    time.Now().UTC(),
+		SecretValid: true,
+		Queued:      event.ID,
+		Data:        &model.NormalizedEvent{Original: event.Data.Original, Variables: event.Data.Variables},
+	}
+	// record ID is known before run: pipeline runs delayed by rate limits refer to it
+	var err error
+	if record.ID, err = util.GenerateMonotonicULID(); err != nil {
+		return err
+	}
+	defer func() {
+		if err := d.history.AddRun(ctx, record); err != nil {
+			log.WithFields(log.Fields{
+				"account":      event.Account,
+				"event":        event.Event,
+				"queued-event": event.ID,
+			}).WithError(err).Error("failed to record run history")
+		}
+	}()
+	// add original payload to variables
+	vars := make(map[string]string)
+	for k, v := range event.Data.Variables {
+		vars[k] = v
+	}
+	vars["EVENT_PAYLOAD"] = event.Data.Original
+	// get connected pipelines (skip account check)
+	allCtx := context.WithValue(ctx, model.ContextKeyAccount, "-")
+	pipelines, skipped, err := d.store.MatchTriggerPipelines(allCtx, event.Event, vars)
+	record.Skipped = skipped
+	if err == model.ErrPipelineNotFound || err == model.ErrTriggerNotFound || (err == nil && len(pipelines) == 0) {
+		log.WithField("event", event.Event).Warn("there are no pipelines associated with trigger event")
+		record.Status = model.RunStatusSkipped
+		return nil
+	}
+	if err != nil {
+		record.Status, record.Error = model.RunStatusFailed, err.Error()
+		return err
+	}
+	record.Matched = pipelines
+	// apply account, trigger event and trigger rate limits: rejected event is kept queued
+	if pipelines, err = limitRuns(allCtx, d.limiter, record, pipelines, event.Data); err != nil {
+		record.Status, record.Error = model.RunStatusFailed, err.Error()
+		if err == model.ErrRateLimitExceeded {
+			record.Status = model.RunStatusRateLimited
+		}
+		return err
+	}
+	if len(pipelines) == 0 {
+		record.Status = model.RunStatusRateLimited
+		return nil
+	}
+	runs, err := d.runner.Run(event.Account, pipelines, vars, event.Data)
+	if err != nil {
+		record.Status, record.Error = model.RunStatusFailed, err.Error()
+		return err
+	}
+	record.Status = model.RunStatusStarted
+	record.Runs = model.PipelineRunRecords(pipelines, runs)
+	// record execution history with run IDS
+	log.WithFields(log.Fields{
+		"account":      event.Account,
+		"event":        event.Event,
+		"queued-event": event.ID,
+		"pipelines":    pipelines,
+		"runs":         runs,
+	}).Info("pipelines for queued trigger event are running")
+	return nil
+}
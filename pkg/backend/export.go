@@ -0,0 +1,385 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/codefresh-io/hermes/pkg/codefresh"
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/codefresh-io/hermes/pkg/provider"
+	log "github.com/sirupsen/logrus"
+)
+
+// ExportVersion current export document format version
+const ExportVersion = 1
+
+type (
+	// TriggerStore trigger events and triggers reader/writer; export and import work with any storage backend
+	TriggerStore interface {
+		model.TriggerEventReaderWriter
+		model.TriggerReaderWriter
+	}
+
+	// ExportDocument versioned trigger events and triggers export (YAML or JSON)
+	ExportDocument struct {
+		Version int           `json:"version" yaml:"version"`
+		Events  []ExportEvent `json:"events" yaml:"events"`
+	}
+
+	// ExportEvent trigger event with linked triggers
+	ExportEvent struct {
+		URI         string          `json:"uri" yaml:"uri"`
+		Type        string          `json:"type" yaml:"type"`
+		Kind        string          `json:"kind" yaml:"kind"`
+		Account     string          `json:"account" yaml:"account"`
+		Secret      string          `json:"secret,omitempty" yaml:"secret,omitempty"`
+		Endpoint    string          `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+		Description string          `json:"description,omitempty" yaml:"description,omitempty"`
+		Status      string          `json:"status,omitempty" yaml:"status,omitempty"`
+		Help        string          `json:"help,omitempty" yaml:"help,omitempty"`
+		Triggers    []ExportTrigger `json:"triggers,omitempty" yaml:"triggers,omitempty"`
+	}
+
+	// ExportTrigger trigger (link to pipeline) with filters and paused state
+	ExportTrigger struct {
+		Pipeline string            `json:"pipeline" yaml:"pipeline"`
+		Filters  map[string]string `json:"filters,omitempty" yaml:"filters,omitempty"`
+		Paused   bool              `json:"paused,omitempty" yaml:"paused,omitempty"`
+	}
+
+	// ExportFilter select trigger events by account, type and kind; empty field matches all
+	ExportFilter struct {
+		Account string
+		Type    string
+		Kind    string
+	}
+
+	// ImportOptions import mode and filter
+	ImportOptions struct {
+		Filter ExportFilter
+		// Replace make store match document: update changed and delete missing trigger events and triggers
+		// default (merge): add missing trigger events and triggers, keep existing unchanged
+		Replace bool
+		// DryRun only compute changes
+		DryRun bool
+	}
+
+	// ImportChange single import change: add (+), delete (-) or update (~) trigger event or trigger
+	ImportChange struct {
+		Action   string
+		Event    string
+		Pipeline string
+		// account used to apply change
+		account string
+		// new trigger event or trigger filters and paused state
+		event   *ExportEvent
+		filters map[string]string
+		paused  bool
+	}
+
+	// importProvider event provider that restores exported trigger events without calling remote systems
+	importProvider struct {
+		events map[string]*ExportEvent
+	}
+
+	// importPipelineService skips pipeline check: triggers are restored as is
+	importPipelineService struct{}
+)
+
+// import change actions
+const (
+	ImportAdd    = "+"
+	ImportDelete = "-"
+	ImportUpdate = "~"
+)
+
+// ErrExportVersion error when export document version is not supported
+var ErrExportVersion = errors.New("unsupported export document version")
+
+// String change in diff format
+func (c ImportChange) String() string {
+	if c.Pipeline == "" {
+		return fmt.Sprintf("%s event %s", c.Action, c.Event)
+	}
+	return fmt.Sprintf("%s trigger %s -> %s", c.Action, c.Event, c.Pipeline)
+}
+
+func (f ExportFilter) match(e *ExportEvent) bool {
+	return (f.Account == "" || f.Account == e.Account) &&
+		(f.Type == "" || f.Type == e.Type) &&
+		(f.Kind == "" || f.Kind == e.Kind)
+}
+
+// context with account; public account sets public flag
+func accountContext(ctx context.Context, account string) context.Context {
+	ctx = context.WithValue(ctx, model.ContextKeyAccount, account)
+	if account == model.PublicAccount {
+		ctx = context.WithValue(ctx, model.ContextKeyPublic, true)
+	}
+	return ctx
+}
+
+// Export trigger events matching filter with linked triggers; secrets are omitted unless requested
+func Export(ctx context.Context, store TriggerStore, filter ExportFilter, secrets bool) (*ExportDocument, error) {
+	account := filter.Account
+	if account == "" {
+		account = "-"
+	}
+	events, err := store.GetEvents(context.WithValue(ctx, model.ContextKeyAccount, account), filter.Type, filter.Kind, "")
+	if err != nil {
+		return nil, err
+	}
+	doc := &ExportDocument{Version: ExportVersion, Events: make([]ExportEvent, 0, len(events))}
+	for _, event := range events {
+		e := ExportEvent{
+			URI:         event.URI,
+			Type:        event.Type,
+			Kind:        event.Kind,
+			Account:     event.Account,
+			Endpoint:    event.Endpoint,
+			Description: event.Description,
+			Status:      event.Status,
+			Help:        event.Help,
+		}
+		if !filter.match(&e) {
+			continue
+		}
+		if secrets {
+			e.Secret = event.Secret
+		}
+		triggers, err := store.GetEventTriggers(accountContext(ctx, event.Account), event.URI)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range triggers {
+			// event URI is used as pattern: skip other matching events
+			if t.Event != event.URI {
+				continue
+			}
+			var filters map[string]string
+			if len(t.Filters) > 0 {
+				filters = t.Filters
+			}
+			e.Triggers = append(e.Triggers, ExportTrigger{Pipeline: t.Pipeline, Filters: filters, Paused: t.Paused})
+		}
+		sort.Slice(e.Triggers, func(i, j int) bool { return e.Triggers[i].Pipeline < e.Triggers[j].Pipeline })
+		doc.Events = append(doc.Events, e)
+	}
+	sort.Slice(doc.Events, func(i, j int) bool { return doc.Events[i].URI < doc.Events[j].URI })
+	return doc, nil
+}
+
+// check stored trigger event differs from imported one; empty imported secret is ignored
+func eventChanged(current, imported *ExportEvent) bool {
+	return current.Type != imported.Type || current.Kind != imported.Kind || current.Account != imported.Account ||
+		(imported.Secret != "" && current.Secret != imported.Secret) ||
+		current.Endpoint != imported.Endpoint || current.Description != imported.Description ||
+		current.Status != imported.Status || current.Help != imported.Help
+}
+
+// PlanImport compute changes required to import document into store
+func PlanImport(ctx context.Context, store TriggerStore, doc *ExportDocument, opts ImportOptions) ([]ImportChange, error) {
+	if doc.Version < 1 || doc.Version > ExportVersion {
+		return nil, fmt.Errorf("%v: %d", ErrExportVersion, doc.Version)
+	}
+	stored, err := Export(ctx, store, opts.Filter, true)
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[string]*ExportEvent, len(stored.Events))
+	for i := range stored.Events {
+		current[stored.Events[i].URI] = &stored.Events[i]
+	}
+
+	changes := make([]ImportChange, 0)
+	deleteTriggers := func(e *ExportEvent) {
+		for _, t := range e.Triggers {
+			changes = append(changes, ImportChange{Action: ImportDelete, Event: e.URI, Pipeline: t.Pipeline, account: e.Account})
+		}
+	}
+	addTriggers := func(e *ExportEvent) {
+		for _, t := range e.Triggers {
+			changes = append(changes, ImportChange{Action: ImportAdd, Event: e.URI, Pipeline: t.Pipeline, account: e.Account, filters: t.Filters, paused: t.Paused})
+		}
+	}
+	imported := make(map[string]bool, len(doc.Events))
+	for i := range doc.Events {
+		e := &doc.Events[i]
+		if !opts.Filter.match(e) || imported[e.URI] {
+			continue
+		}
+		imported[e.URI] = true
+		cur, ok := current[e.URI]
+		switch {
+		case !ok:
+			changes = append(changes, ImportChange{Action: ImportAdd, Event: e.URI, account: e.Account, event: e})
+			addTriggers(e)
+		case opts.Replace && eventChanged(cur, e):
+			// trigger event is recreated with all its triggers
+			deleteTriggers(cur)
+			changes = append(changes, ImportChange{Action: ImportUpdate, Event: e.URI, account: cur.Account, event: e})
+			addTriggers(e)
+		default:
+			existing := make(map[string]ExportTrigger, len(cur.Triggers))
+			for _, t := range cur.Triggers {
+				existing[t.Pipeline] = t
+			}
+			for _, t := range e.Triggers {
+				stored, ok := existing[t.Pipeline]
+				delete(existing, t.Pipeline)
+				switch {
+				case !ok:
+					changes = append(changes, ImportChange{Action: ImportAdd, Event: e.URI, Pipeline: t.Pipeline, account: e.Account, filters: t.Filters, paused: t.Paused})
+				case opts.Replace && (!reflect.DeepEqual(stored.Filters, t.Filters) || stored.Paused != t.Paused):
+					changes = append(changes, ImportChange{Action: ImportUpdate, Event: e.URI, Pipeline: t.Pipeline, account: e.Account, filters: t.Filters, paused: t.Paused})
+				}
+			}
+			if opts.Replace {
+				for _, t := range cur.Triggers {
+					if _, ok := existing[t.Pipeline]; ok {
+						changes = append(changes, ImportChange{Action: ImportDelete, Event: e.URI, Pipeline: t.Pipeline, account: e.Account})
+					}
+				}
+			}
+		}
+	}
+	// delete stored trigger events missing from document
+	if opts.Replace {
+		for i := range stored.Events {
+			if cur := &stored.Events[i]; !imported[cur.URI] {
+				deleteTriggers(cur)
+				changes = append(changes, ImportChange{Action: ImportDelete, Event: cur.URI, account: cur.Account})
+			}
+		}
+	}
+	return changes, nil
+}
+
+// Import document into store; store should be created with NewImportProvider and NewImportPipelineService
+// changes are applied in order and returned (also on error: applied changes only)
+func Import(ctx context.Context, store TriggerStore, doc *ExportDocument, opts ImportOptions) ([]ImportChange, error) {
+	lg := log.WithFields(getContextLogFields(ctx))
+	changes, err := PlanImport(ctx, store, doc, opts)
+	if err != nil || opts.DryRun {
+		return changes, err
+	}
+	for i, c := range changes {
+		if err = applyImportChange(ctx, store, c); err != nil {
+			lg.WithError(err).WithField("change", c.String()).Error("failed to import")
+			return changes[:i], err
+		}
+	}
+	return changes, nil
+}
+
+func applyImportChange(ctx context.Context, store TriggerStore, c ImportChange) error {
+	ctx = accountContext(ctx, c.account)
+	switch {
+	case c.Pipeline != "" && c.Action == ImportAdd:
+		return importTrigger(ctx, store, c)
+	case c.Pipeline != "" && c.Action == ImportDelete:
+		return store.DeleteTrigger(ctx, c.Event, c.Pipeline)
+	case c.Pipeline != "" && c.Action == ImportUpdate:
+		if err := store.DeleteTrigger(ctx, c.Event, c.Pipeline); err != nil {
+			return err
+		}
+		return importTrigger(ctx, store, c)
+	case c.Action == ImportDelete:
+		return store.DeleteEvent(ctx, c.Event, "")
+	case c.Action == ImportUpdate:
+		if err := store.DeleteEvent(ctx, c.Event, ""); err != nil {
+			return err
+		}
+	}
+	// create trigger event (add or update)
+	ctx = accountContext(ctx, c.event.Account)
+	secret := c.event.Secret
+	if secret == "" {
+		secret = model.GenerateKeyword
+	}
+	event, err := store.CreateEvent(ctx, c.event.Type, c.event.Kind, secret, "", map[string]string{"uri": c.event.URI})
+	if err != nil {
+		return err
+	}
+	if event.URI != c.event.URI {
+		return fmt.Errorf("imported trigger event %s stored as %s", c.event.URI, event.URI)
+	}
+	return nil
+}
+
+// create imported trigger; paused trigger is paused right after it is created
+func importTrigger(ctx context.Context, store TriggerStore, c ImportChange) error {
+	if err := store.CreateTrigger(ctx, c.Event, c.Pipeline, c.filters); err != nil || !c.paused {
+		return err
+	}
+	return store.SetTriggerPaused(ctx, c.Event, c.Pipeline, true)
+}
+
+// NewImportProvider event provider for store used by Import: constructs exported event URIs and
+// returns exported event info; never calls remote event providers
+func NewImportProvider(doc *ExportDocument) provider.EventProvider {
+	p := &importProvider{events: make(map[string]*ExportEvent, len(doc.Events))}
+	for i := range doc.Events {
+		p.events[doc.Events[i].URI] = &doc.Events[i]
+	}
+	return p
+}
+
+func (p *importProvider) GetTypes() []model.EventType {
+	return nil
+}
+
+func (p *importProvider) MatchType(eventURI string) (*model.EventType, error) {
+	return nil, provider.ErrNotImplemented
+}
+
+func (p *importProvider) GetType(t string, k string) (*model.EventType, error) {
+	return nil, provider.ErrNotImplemented
+}
+
+func (p *importProvider) GetEventInfo(ctx context.Context, eventURI string, secret string) (*model.EventInfo, error) {
+	e, ok := p.events[eventURI]
+	if !ok {
+		return nil, model.ErrEventNotFound
+	}
+	return &model.EventInfo{Endpoint: e.Endpoint, Description: e.Description, Status: e.Status, Help: e.Help}, nil
+}
+
+func (p *importProvider) SubscribeToEvent(ctx context.Context, event, secret string, credentials map[string]string) (*model.EventInfo, error) {
+	return p.GetEventInfo(ctx, event, secret)
+}
+
+func (p *importProvider) UnsubscribeFromEvent(ctx context.Context, event string, credentials map[string]string) error {
+	return provider.ErrNotImplemented
+}
+
+func (p *importProvider) ConstructEventURI(t string, k string, a string, values map[string]string) (string, error) {
+	if _, ok := p.events[values["uri"]]; !ok {
+		return "", model.ErrEventNotFound
+	}
+	return values["uri"], nil
+}
+
+// NewImportPipelineService pipeline service for store used by Import: all pipelines exist
+func NewImportPipelineService() codefresh.PipelineService {
+	return importPipelineService{}
+}
+
+func (importPipelineService) GetPipeline(ctx context.Context, account, id string) (*codefresh.Pipeline, error) {
+	return &codefresh.Pipeline{ID: id, Account: account}, nil
+}
+
+func (importPipelineService) RunPipeline(accountID string, id string, vars map[string]string, event model.NormalizedEvent) (string, error) {
+	return "", provider.ErrNotImplemented
+}
+
+func (importPipelineService) PublishEvent(ctx context.Context, account string, eventURI string, event model.NormalizedEvent) error {
+	return provider.ErrNotImplemented
+}
+
+func (importPipelineService) Ping() error {
+	return nil
+}
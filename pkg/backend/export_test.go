@@ -0,0 +1,194 @@
+package backend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+)
+
+// create source store with private, public and other account trigger events and triggers
+func newExportSource(t *testing.T, driver string) *storeFixture {
+	f := newTestStore(t, driver)
+	e1 := f.createEvent(t, "A", "repo1", "secret1", false)
+	f.createEvent(t, "A", "repo2", "secret2", false)
+	e3 := f.createEvent(t, "B", "repo3", "secret3", false)
+	e4 := f.createEvent(t, "A", "public", "secret4", true)
+	assert.NoError(t, f.CreateTrigger(storeContext("A", false), e1.URI, "p1", map[string]string{"tag": "^master$"}))
+	assert.NoError(t, f.CreateTrigger(storeContext("A", false), e1.URI, "p2", nil))
+	assert.NoError(t, f.CreateTrigger(storeContext("B", false), e3.URI, "p3", nil))
+	assert.NoError(t, f.CreateTrigger(storeContext("A", false), e4.URI, "p4", nil))
+	return f
+}
+
+func exportURIs(doc *ExportDocument) []string {
+	uris := make([]string, 0, len(doc.Events))
+	for _, e := range doc.Events {
+		uris = append(uris, e.URI)
+	}
+	return uris
+}
+
+func changeStrings(changes []ImportChange) []string {
+	s := make([]string, 0, len(changes))
+	for _, c := range changes {
+		s = append(s, c.String())
+	}
+	return s
+}
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+	hashA, hashB := model.CalculateAccountHash("A"), model.CalculateAccountHash("B")
+	for _, driver := range StoreDrivers() {
+		t.Run(driver, func(t *testing.T) {
+			f := newExportSource(t, driver)
+			doc, err := Export(ctx, f, ExportFilter{}, false)
+			assert.NoError(t, err)
+			assert.Equal(t, ExportVersion, doc.Version)
+			assert.Equal(t, []string{
+				"registry:dockerhub:public:" + model.PublicAccountHash,
+				"registry:dockerhub:repo1:" + hashA,
+				"registry:dockerhub:repo2:" + hashA,
+				"registry:dockerhub:repo3:" + hashB,
+			}, exportURIs(doc))
+			assert.Equal(t, ExportEvent{
+				URI:      "registry:dockerhub:repo1:" + hashA,
+				Type:     "registry",
+				Kind:     "dockerhub",
+				Account:  "A",
+				Endpoint: "http://endpoint/repo1",
+				Status:   "active",
+				Triggers: []ExportTrigger{
+					{Pipeline: "p1", Filters: map[string]string{"tag": "^master$"}},
+					{Pipeline: "p2"},
+				},
+			}, doc.Events[1])
+			assert.Equal(t, []ExportTrigger{{Pipeline: "p4"}}, doc.Events[0].Triggers)
+			// with secrets
+			doc, err = Export(ctx, f, ExportFilter{}, true)
+			assert.NoError(t, err)
+			assert.Equal(t, "secret1", doc.Events[1].Secret)
+			// filter by account
+			doc, err = Export(ctx, f, ExportFilter{Account: "B"}, false)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"registry:dockerhub:repo3:" + hashB}, exportURIs(doc))
+			// filter by type and kind
+			doc, err = Export(ctx, f, ExportFilter{Type: "registry", Kind: "quay"}, false)
+			assert.NoError(t, err)
+			assert.Empty(t, doc.Events)
+		})
+	}
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	for _, driver := range StoreDrivers() {
+		t.Run(driver, func(t *testing.T) {
+			source := newExportSource(t, driver)
+			exported, err := Export(ctx, source, ExportFilter{}, true)
+			assert.NoError(t, err)
+			// document survives YAML round trip
+			data, err := yaml.Marshal(exported)
+			assert.NoError(t, err)
+			doc := new(ExportDocument)
+			assert.NoError(t, yaml.Unmarshal(data, doc))
+			assert.Equal(t, exported, doc)
+
+			target := openTestStore(t, driver, NewImportPipelineService(), NewImportProvider(doc))
+			// dry run does not change store
+			changes, err := Import(ctx, target, doc, ImportOptions{DryRun: true})
+			assert.NoError(t, err)
+			assert.Equal(t, 8, len(changes))
+			events, err := target.GetEvents(storeContext("-", false), "", "", "")
+			assert.NoError(t, err)
+			assert.Empty(t, events)
+			// import all
+			changes, err = Import(ctx, target, doc, ImportOptions{})
+			assert.NoError(t, err)
+			assert.Equal(t, 8, len(changes))
+			imported, err := Export(ctx, target, ExportFilter{}, true)
+			assert.NoError(t, err)
+			assert.Equal(t, exported, imported)
+			// nothing to import second time
+			changes, err = Import(ctx, target, doc, ImportOptions{})
+			assert.NoError(t, err)
+			assert.Empty(t, changes)
+		})
+	}
+}
+
+func TestImport_modes(t *testing.T) {
+	ctx := context.Background()
+	hashA := model.CalculateAccountHash("A")
+	uri1, uri2 := "registry:dockerhub:repo1:"+hashA, "registry:dockerhub:repo2:"+hashA
+	for _, driver := range StoreDrivers() {
+		t.Run(driver, func(t *testing.T) {
+			source := newExportSource(t, driver)
+			stored, err := Export(ctx, source, ExportFilter{Account: "A"}, true)
+			assert.NoError(t, err)
+			// document: repo1 with changed triggers, no repo2, new repo5
+			doc := &ExportDocument{Version: ExportVersion, Events: []ExportEvent{
+				stored.Events[0],
+				{URI: "registry:dockerhub:repo5:" + hashA, Type: "registry", Kind: "dockerhub", Account: "A"},
+			}}
+			doc.Events[0].Triggers = []ExportTrigger{{Pipeline: "p1", Filters: map[string]string{"tag": "^dev$"}}, {Pipeline: "p5"}}
+			// target provider knows all imported trigger events
+			all := &ExportDocument{Version: ExportVersion, Events: append(append([]ExportEvent{}, stored.Events...), doc.Events...)}
+			target := openTestStore(t, driver, NewImportPipelineService(), NewImportProvider(all))
+			_, err = Import(ctx, target, stored, ImportOptions{})
+			assert.NoError(t, err)
+
+			// merge: add missing only
+			changes, err := Import(ctx, target, doc, ImportOptions{Filter: ExportFilter{Account: "A"}, DryRun: true})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{
+				"+ trigger " + uri1 + " -> p5",
+				"+ event registry:dockerhub:repo5:" + hashA,
+			}, changeStrings(changes))
+
+			// replace: update changed, delete missing
+			changes, err = Import(ctx, target, doc, ImportOptions{Filter: ExportFilter{Account: "A"}, Replace: true})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{
+				"~ trigger " + uri1 + " -> p1",
+				"+ trigger " + uri1 + " -> p5",
+				"- trigger " + uri1 + " -> p2",
+				"+ event registry:dockerhub:repo5:" + hashA,
+				"- event " + uri2,
+			}, changeStrings(changes))
+			imported, err := Export(ctx, target, ExportFilter{Account: "A"}, true)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{uri1, "registry:dockerhub:repo5:" + hashA}, exportURIs(imported))
+			assert.Equal(t, doc.Events[0].Triggers, imported.Events[0].Triggers)
+			// generated secret for imported event without secret
+			assert.NotEmpty(t, imported.Events[1].Secret)
+
+			// replace changed trigger event with its triggers
+			doc.Events[0].Secret = "changed"
+			changes, err = Import(ctx, target, doc, ImportOptions{Filter: ExportFilter{Account: "A"}, Replace: true})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{
+				"- trigger " + uri1 + " -> p1",
+				"- trigger " + uri1 + " -> p5",
+				"~ event " + uri1,
+				"+ trigger " + uri1 + " -> p1",
+				"+ trigger " + uri1 + " -> p5",
+			}, changeStrings(changes))
+			event, err := target.GetEvent(storeContext("A", false), uri1)
+			assert.NoError(t, err)
+			assert.Equal(t, "changed", event.Secret)
+		})
+	}
+}
+
+func TestImport_version(t *testing.T) {
+	store := NewMemoryStore(nil, nil)
+	for _, version := range []int{0, ExportVersion + 1} {
+		_, err := Import(context.Background(), store, &ExportDocument{Version: version}, ImportOptions{})
+		assert.Error(t, err)
+	}
+}
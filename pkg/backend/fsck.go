@@ -0,0 +1,191 @@
+package backend
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/garyburd/redigo/redis"
+)
+
+type (
+	// storeSnapshot all cross-referencing store structures loaded for consistency check
+	storeSnapshot struct {
+		// events trigger event URI -> fields (account, type, kind)
+		events map[string]map[string]string
+		// triggers trigger event URI -> pipelines
+		triggers map[string][]string
+		// pipelines pipeline -> trigger event URIs
+		pipelines map[string][]string
+		// paused trigger event URI -> paused pipelines
+		paused map[string][]string
+		// filters filter keys
+		filters []string
+		// indexes account and type index key -> trigger event URIs
+		indexes map[string][]string
+	}
+
+	// storeFix single change that repairs store issue: delete key, add or remove set member
+	storeFix struct {
+		op     string
+		key    string
+		member string
+	}
+)
+
+// store fix operations
+const (
+	fixDelete = "delete"
+	fixAdd    = "add"
+	fixRemove = "remove"
+)
+
+func newStoreSnapshot() *storeSnapshot {
+	return &storeSnapshot{
+		events:    make(map[string]map[string]string),
+		triggers:  make(map[string][]string),
+		pipelines: make(map[string][]string),
+		paused:    make(map[string][]string),
+		indexes:   make(map[string][]string),
+	}
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// check snapshot consistency; returns report and fixes for repairable issues (nil fix otherwise)
+func (s *storeSnapshot) check() (*model.StoreReport, []*storeFix) {
+	report := &model.StoreReport{Events: len(s.events), Filters: len(s.filters), Issues: make([]model.StoreIssue, 0)}
+	var fixes []*storeFix
+	issue := func(issueType, key, member string, fix *storeFix) {
+		report.Issues = append(report.Issues, model.StoreIssue{Type: issueType, Key: key, Member: member})
+		fixes = append(fixes, fix)
+	}
+
+	// trigger events: valid and indexed
+	uris := make([]string, 0, len(s.events))
+	for uri := range s.events {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+	for _, uri := range uris {
+		fields := s.events[uri]
+		if fields["account"] == "" || fields["type"] == "" || fields["kind"] == "" {
+			issue(model.StoreIssueInvalidEvent, getEventKey("-", uri), "", nil)
+			continue
+		}
+		for _, key := range []string{getAccountIndexKey(fields["account"]), getTypeIndexKey(fields["type"], fields["kind"])} {
+			if !containsString(s.indexes[key], uri) {
+				issue(model.StoreIssueMissingIndex, key, uri, &storeFix{fixAdd, key, uri})
+			}
+		}
+	}
+	// indexes: only existing trigger events in right index
+	for _, key := range sortedKeys(s.indexes) {
+		for _, uri := range s.indexes[key] {
+			fields, ok := s.events[uri]
+			if !ok || (key != getAccountIndexKey(fields["account"]) && key != getTypeIndexKey(fields["type"], fields["kind"])) {
+				issue(model.StoreIssueStaleIndex, key, uri, &storeFix{fixRemove, key, uri})
+			}
+		}
+	}
+	// triggers: existing trigger event and pipeline link back
+	for _, uri := range sortedKeys(s.triggers) {
+		key := getTriggerKey("-", uri)
+		if _, ok := s.events[uri]; !ok {
+			issue(model.StoreIssueOrphanTrigger, key, "", &storeFix{fixDelete, key, ""})
+			continue
+		}
+		for _, pipeline := range s.triggers[uri] {
+			report.Triggers++
+			if !containsString(s.pipelines[pipeline], uri) {
+				pipelineKey := getPipelineKey(pipeline)
+				issue(model.StoreIssueMissingPipelineLink, pipelineKey, uri, &storeFix{fixAdd, pipelineKey, uri})
+			}
+		}
+	}
+	// pipelines: linked to existing trigger events with trigger
+	for _, pipeline := range sortedKeys(s.pipelines) {
+		key := getPipelineKey(pipeline)
+		for _, uri := range s.pipelines[pipeline] {
+			if _, ok := s.events[uri]; !ok {
+				issue(model.StoreIssueOrphanPipelineLink, key, uri, &storeFix{fixRemove, key, uri})
+			} else if !containsString(s.triggers[uri], pipeline) {
+				issue(model.StoreIssueStalePipelineLink, key, uri, &storeFix{fixRemove, key, uri})
+			}
+		}
+	}
+	// paused triggers: for existing trigger events with trigger
+	for _, uri := range sortedKeys(s.paused) {
+		key := getPausedKey("-", uri)
+		for _, pipeline := range s.paused[uri] {
+			if !containsString(s.triggers[uri], pipeline) {
+				issue(model.StoreIssueStalePausedTrigger, key, pipeline, &storeFix{fixRemove, key, pipeline})
+			}
+		}
+	}
+	// filters: for existing trigger events with trigger
+	sort.Strings(s.filters)
+	for _, key := range s.filters {
+		uri, pipeline, err := parseFilterKey(key)
+		if err != nil {
+			issue(model.StoreIssueInvalidFilterKey, key, "", nil)
+			continue
+		}
+		if _, ok := s.events[uri]; !ok || !containsString(s.triggers[uri], pipeline) {
+			issue(model.StoreIssueOrphanFilter, key, "", &storeFix{fixDelete, key, ""})
+		}
+	}
+	return report, fixes
+}
+
+// apply fix to Redis store; triggers and pipelines are sorted sets, indexes are sets
+func (f *storeFix) applyRedis(con redis.Conn) error {
+	sorted := strings.HasPrefix(f.key, "trigger:") || strings.HasPrefix(f.key, "pipeline:")
+	var err error
+	switch {
+	case f.op == fixDelete:
+		_, err = con.Do("DEL", f.key)
+	case f.op == fixAdd && sorted:
+		_, err = con.Do("ZADD", f.key, 0, f.member)
+	case f.op == fixAdd:
+		_, err = con.Do("SADD", f.key, f.member)
+	case sorted:
+		_, err = con.Do("ZREM", f.key, f.member)
+	default:
+		_, err = con.Do("SREM", f.key, f.member)
+	}
+	return err
+}
+
+// apply fix to key/value store
+func (f *storeFix) applyKV(tx kvTx) error {
+	switch f.op {
+	case fixDelete:
+		return tx.delete(f.key)
+	case fixAdd:
+		return tx.addMember(f.key, f.member)
+	default:
+		return tx.removeMember(f.key, f.member)
+	}
+}
+
+// uri from key: strip prefix
+func trimKeyPrefix(key, prefix string) string {
+	return strings.TrimPrefix(key, prefix+":")
+}
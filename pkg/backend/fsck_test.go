@@ -0,0 +1,137 @@
+package backend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+)
+
+// corrupt store bypassing storage backend API: apply changes and store filter hashes
+func corruptStore(t *testing.T, store Store, fixes []*storeFix, hashes map[string]map[string]string) {
+	var db kvDB
+	var err error
+	switch s := store.(type) {
+	case *RedisStore:
+		con := s.redisPool.GetConn()
+		for _, fix := range fixes {
+			if err = fix.applyRedis(con); err != nil {
+				break
+			}
+		}
+		for key, fields := range hashes {
+			if _, err = con.Do("HMSET", redis.Args{}.Add(key).AddFlat(fields)...); err != nil {
+				break
+			}
+		}
+	case *MemoryStore:
+		db = s.db
+	case *BoltStore:
+		db = s.db
+	default:
+		t.Fatalf("unsupported store %T", store)
+	}
+	if db != nil {
+		err = db.update(func(tx kvTx) error {
+			for _, fix := range fixes {
+				if err := fix.applyKV(tx); err != nil {
+					return err
+				}
+			}
+			for key, fields := range hashes {
+				if err := tx.setHash(key, fields); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		t.Fatalf("failed to corrupt store: %v", err)
+	}
+}
+
+func issueTypes(report *model.StoreReport) map[string]int {
+	types := make(map[string]int)
+	for _, issue := range report.Issues {
+		types[issue.Type]++
+	}
+	return types
+}
+
+func TestCheckStore(t *testing.T) {
+	ctx := context.Background()
+	for _, driver := range StoreDrivers() {
+		t.Run(driver, func(t *testing.T) {
+			f := newTestStore(t, driver)
+			e1 := f.createEvent(t, "A", "repo1", "secret", false)
+			e2 := f.createEvent(t, "A", "repo2", "secret", false)
+			assert.NoError(t, f.CreateTrigger(storeContext("A", false), e1.URI, "p1", map[string]string{"tag": "^v1$"}))
+			assert.NoError(t, f.CreateTrigger(storeContext("A", false), e2.URI, "p2", map[string]string{"tag": "^v2$"}))
+
+			// consistent store
+			report, err := f.CheckStore(ctx, false)
+			assert.NoError(t, err)
+			assert.Equal(t, &model.StoreReport{Events: 2, Triggers: 2, Filters: 2, Issues: []model.StoreIssue{}}, report)
+
+			corruptStore(t, f.Store, []*storeFix{
+				// deleted trigger event: orphan trigger, pipeline link, filter and index entries
+				{fixDelete, getEventKey("A", e2.URI), ""},
+				// half deleted trigger
+				{fixRemove, getPipelineKey("p1"), e1.URI},
+				{fixAdd, getPipelineKey("p3"), e1.URI},
+				// not indexed event
+				{fixRemove, getTypeIndexKey("registry", "dockerhub"), e1.URI},
+				// paused state of deleted trigger
+				{fixAdd, getPausedKey("A", e1.URI), "p9"},
+			}, map[string]map[string]string{
+				getFilterKey(e1.URI, "p9"):       {"tag": "^v9$"},
+				getLegacyFilterKey(e1.URI, "p1"): {"tag": "^v1$"},
+			})
+			want := map[string]int{
+				model.StoreIssueOrphanTrigger:       1,
+				model.StoreIssueOrphanPipelineLink:  1,
+				model.StoreIssueOrphanFilter:        2,
+				model.StoreIssueStaleIndex:          2,
+				model.StoreIssueMissingPipelineLink: 1,
+				model.StoreIssueStalePipelineLink:   1,
+				model.StoreIssueMissingIndex:        1,
+				model.StoreIssueInvalidFilterKey:    1,
+				model.StoreIssueStalePausedTrigger:  1,
+			}
+			report, err = f.CheckStore(ctx, false)
+			assert.NoError(t, err)
+			assert.Equal(t, want, issueTypes(report))
+			for _, issue := range report.Issues {
+				assert.False(t, issue.Repaired)
+			}
+
+			// repair all but invalid filter key
+			report, err = f.CheckStore(ctx, true)
+			assert.NoError(t, err)
+			assert.Equal(t, want, issueTypes(report))
+			for _, issue := range report.Issues {
+				assert.Equal(t, issue.Type != model.StoreIssueInvalidFilterKey, issue.Repaired, issue.Type)
+			}
+			report, err = f.CheckStore(ctx, false)
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]int{model.StoreIssueInvalidFilterKey: 1}, issueTypes(report))
+			assert.Equal(t, 1, report.Events)
+			assert.Equal(t, 1, report.Triggers)
+
+			// repaired store works
+			pipelines, err := f.GetTriggerPipelines(storeContext("-", false), e1.URI, map[string]string{"tag": "v1"})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"p1"}, pipelines)
+			triggers, err := f.GetPipelineTriggers(storeContext("A", false), "p1", false)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(triggers))
+			events, err := f.GetEvents(storeContext("A", false), "registry", "dockerhub", "")
+			assert.NoError(t, err)
+			assert.Equal(t, []model.Event{*e1}, events)
+		})
+	}
+}
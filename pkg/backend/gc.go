@@ -0,0 +1,160 @@
+package backend
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/codefresh-io/hermes/pkg/codefresh"
+	"github.com/codefresh-io/hermes/pkg/model"
+	log "github.com/sirupsen/logrus"
+)
+
+// triggerGCLock trigger garbage collection lock name
+const triggerGCLock = "trigger-gc"
+
+// TriggerGC removes triggers of pipelines deleted in Codefresh (or moved to another account)
+type TriggerGC struct {
+	store       Store
+	pipelineSvc codefresh.PipelineService
+	rate        float64
+}
+
+// NewTriggerGC create new trigger garbage collector; rate limits Codefresh pipeline checks per second (0: no limit)
+func NewTriggerGC(store Store, pipelineSvc codefresh.PipelineService, rate float64) *TriggerGC {
+	return &TriggerGC{store: store, pipelineSvc: pipelineSvc, rate: rate}
+}
+
+// Collect check every pipeline linked to trigger events in Codefresh, for every account of its trigger events;
+// unlink account trigger events from pipeline not found or not matching account (only report on dry run)
+// triggers of public trigger events are removed when pipeline is missing for all accounts
+func (gc *TriggerGC) Collect(ctx context.Context, dryRun bool) (*model.TriggerGCReport, error) {
+	lg := log.WithFields(getContextLogFields(ctx)).WithField("dry-run", dryRun)
+	lg.Debug("collecting triggers of deleted pipelines")
+	pipelines, err := gc.store.GetPipelineEvents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(pipelines))
+	for pipeline := range pipelines {
+		names = append(names, pipeline)
+	}
+	sort.Strings(names)
+	// throttle Codefresh API calls
+	var throttle <-chan time.Time
+	if gc.rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / gc.rate))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+	report := &model.TriggerGCReport{DryRun: dryRun}
+	allCtx := context.WithValue(ctx, model.ContextKeyAccount, "-")
+	for _, pipeline := range names {
+		report.Pipelines++
+		plg := lg.WithField("pipeline", pipeline)
+		// group linked trigger events by account
+		accountEvents := make(map[string][]string)
+		var accounts, public []string
+		var failed error
+		for _, uri := range pipelines[pipeline] {
+			event, err := gc.store.GetEvent(allCtx, uri)
+			if err == model.ErrEventNotFound || err == model.ErrTriggerNotFound {
+				// orphan pipeline link is repaired by fsck
+				continue
+			}
+			if err != nil {
+				failed = err
+				break
+			}
+			if event.Account == model.PublicAccount {
+				public = append(public, uri)
+				continue
+			}
+			if _, ok := accountEvents[event.Account]; !ok {
+				accounts = append(accounts, event.Account)
+			}
+			accountEvents[event.Account] = append(accountEvents[event.Account], uri)
+		}
+		if failed != nil {
+			report.Failed = append(report.Failed, model.TriggerGCFailure{Pipeline: pipeline, Error: failed.Error()})
+			continue
+		}
+		if len(accounts) == 0 {
+			if len(public) > 0 {
+				report.Skipped = append(report.Skipped, pipeline)
+			}
+			continue
+		}
+		sort.Strings(accounts)
+		missing := 0
+		for _, account := range accounts {
+			if throttle != nil {
+				select {
+				case <-ctx.Done():
+					return report, ctx.Err()
+				case <-throttle:
+				}
+			}
+			var reason string
+			_, err = gc.pipelineSvc.GetPipeline(ctx, account, pipeline)
+			switch err {
+			case nil:
+				continue
+			case codefresh.ErrPipelineNotFound:
+				reason = model.GCReasonNotFound
+			case codefresh.ErrPipelineNoMatch:
+				reason = model.GCReasonNoMatch
+			default:
+				report.Failed = append(report.Failed, model.TriggerGCFailure{Pipeline: pipeline, Account: account, Error: err.Error()})
+				continue
+			}
+			missing++
+			events := accountEvents[account]
+			if missing == len(accounts) {
+				events = append(events, public...)
+			}
+			if !dryRun {
+				// triggers changed since pipeline check are kept
+				events, err = gc.store.DeletePipelineTriggers(context.WithValue(ctx, model.ContextKeyAccount, account), pipeline, events)
+				if err != nil {
+					report.Failed = append(report.Failed, model.TriggerGCFailure{Pipeline: pipeline, Account: account, Error: err.Error()})
+					continue
+				}
+				if len(events) == 0 {
+					continue
+				}
+				plg.WithFields(log.Fields{"account": account, "reason": reason, "events": events}).Info("removed triggers of deleted pipeline")
+			}
+			report.Collected = append(report.Collected, model.CollectedTriggers{Pipeline: pipeline, Account: account, Reason: reason, Events: events})
+		}
+	}
+	lg.WithFields(log.Fields{
+		"pipelines": report.Pipelines,
+		"collected": len(report.Collected),
+		"skipped":   len(report.Skipped),
+		"failed":    len(report.Failed),
+	}).Info("collected triggers of deleted pipelines")
+	return report, nil
+}
+
+// RunTriggerGC collect triggers of deleted pipelines every interval, until context is canceled
+// only one replica (lock owner) collects triggers during half interval
+func RunTriggerGC(ctx context.Context, gc *TriggerGC, owner string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// lock is not released: it expires before next run
+			locked, err := gc.store.AcquireLock(ctx, triggerGCLock, owner, interval/2)
+			if err != nil || !locked {
+				continue
+			}
+			if _, err = gc.Collect(ctx, false); err != nil {
+				log.WithError(err).Error("failed to collect triggers of deleted pipelines")
+			}
+		}
+	}
+}
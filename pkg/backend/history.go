@@ -0,0 +1,108 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/codefresh-io/hermes/pkg/util"
+	log "github.com/sirupsen/logrus"
+)
+
+/*  Run History
+
+	runs:{account} (Set)       -> {run-id}: run record IDs (ULID), sorted in time order
+	run:{run-id} (Hash)        -> run: run record (JSON), payload: normalized event without secret (JSON)
+
+	* account - trigger event account (not hashed)
+
+*/
+
+// account run history key
+func getRunsKey(account string) string {
+	return getPrefixKey("runs", account)
+}
+
+// run record key
+func getRunKey(id string) string {
+	return getPrefixKey("run", id)
+}
+
+// convert run record to stored hash fields: record and normalized event for replay (secret is not stored)
+func runRecordToFields(record *model.RunRecord) (map[string]string, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]string{"run": string(data)}
+	if record.Data != nil {
+		payload, err := json.Marshal(model.NormalizedEvent{Original: record.Data.Original, Variables: record.Data.Variables})
+		if err != nil {
+			return nil, err
+		}
+		fields["payload"] = string(payload)
+	}
+	return fields, nil
+}
+
+// convert stored hash fields to run record with normalized event; record of another account is not found
+func fieldsToRunRecord(ctx context.Context, fields map[string]string) (*model.RunRecord, error) {
+	if fields["run"] == "" {
+		return nil, model.ErrRunNotFound
+	}
+	record := new(model.RunRecord)
+	if err := json.Unmarshal([]byte(fields["run"]), record); err != nil {
+		return nil, err
+	}
+	if account := getAccount(ctx); account != "-" && account != record.Account {
+		return nil, model.ErrRunNotFound
+	}
+	if fields["payload"] != "" {
+		record.Data = new(model.NormalizedEvent)
+		if err := json.Unmarshal([]byte(fields["payload"]), record.Data); err != nil {
+			return nil, err
+		}
+	}
+	return record, nil
+}
+
+// RunHistory keeps execution records of /run calls for retention period
+type RunHistory struct {
+	store     Store
+	retention time.Duration
+}
+
+// NewRunHistory create run history; records are kept for retention period (0: records are not kept)
+func NewRunHistory(store Store, retention time.Duration) *RunHistory {
+	return &RunHistory{store: store, retention: retention}
+}
+
+// AddRun add run record, removing account records older than retention; record ID is generated when empty
+func (h *RunHistory) AddRun(ctx context.Context, record *model.RunRecord) error {
+	if h.retention <= 0 {
+		return nil
+	}
+	if record.ID == "" {
+		id, err := util.GenerateMonotonicULID()
+		if err != nil {
+			return err
+		}
+		record.ID = id
+	}
+	return h.store.SaveRun(ctx, record, h.retention)
+}
+
+// GetRuns list account run records matching filter, latest first
+func (h *RunHistory) GetRuns(ctx context.Context, filter model.RunFilter) ([]model.RunRecord, error) {
+	return h.store.GetRuns(ctx, filter)
+}
+
+// GetRun get context account run record
+func (h *RunHistory) GetRun(ctx context.Context, id string) (*model.RunRecord, error) {
+	record, err := h.store.GetRun(ctx, id)
+	if err != nil && err != model.ErrRunNotFound {
+		log.WithFields(getContextLogFields(ctx)).WithField("run", id).WithError(err).Error("failed to get run record")
+	}
+	return record, err
+}
@@ -0,0 +1,2128 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codefresh-io/hermes/pkg/codefresh"
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/codefresh-io/hermes/pkg/provider"
+	"github.com/codefresh-io/hermes/pkg/util"
+	newrelic "github.com/newrelic/go-agent"
+	log "github.com/sirupsen/logrus"
+)
+
+/*  Key/Value Data Model
+
+	Embedded storage backends keep the same data model as Redis backend (see redis.go):
+	the same keys for trigger events, triggers, pipelines, paused triggers, filters and indexes, stored as
+	hashes (field -> value) and sets (sorted members).
+
+*/
+
+type (
+	// kvTx key/value store transaction, modeled after Redis hash and sorted set commands
+	kvTx interface {
+		// exists check if key exists
+		exists(key string) (bool, error)
+		// keys find all keys matching Redis glob-style pattern
+		keys(pattern string) ([]string, error)
+		// getHash get all hash fields (empty map for missing key)
+		getHash(key string) (map[string]string, error)
+		// setHash set hash fields, keeping other existing fields
+		setHash(key string, fields map[string]string) error
+		// getMembers get lexicographically sorted set members
+		getMembers(key string) ([]string, error)
+		// addMember add member to set
+		addMember(key, member string) error
+		// removeMember remove member from set; set without members is deleted
+		removeMember(key, member string) error
+		// delete remove key
+		delete(key string) error
+	}
+
+	// kvDB transactional key/value database
+	kvDB interface {
+		// view run read-only transaction
+		view(fn func(tx kvTx) error) error
+		// update run read-write transaction; all changes are discarded on error
+		update(fn func(tx kvTx) error) error
+	}
+
+	// kvStore implements storage backend interfaces on top of transactional key/value database
+	kvStore struct {
+		db            kvDB
+		pipelineSvc   codefresh.PipelineService
+		eventProvider provider.EventProvider
+		cipher        *secretCipher
+	}
+)
+
+// convert Redis glob-style pattern (*, ?, [...]) into regular expression
+func compileKeyPattern(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			b.WriteString("(?s:.*)")
+		case '?':
+			b.WriteString("(?s:.)")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta(pattern[i:]))
+				i = len(pattern)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "^") {
+				class = "^" + strings.Replace(class[1:], `\`, `\\`, -1)
+			} else {
+				class = strings.Replace(class, `\`, `\\`, -1)
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// get trigger pipelines with filters and paused state for the trigger event key
+func (s *kvStore) getTriggers(tx kvTx, key string) ([]model.Trigger, error) {
+	pipelines, err := tx.getMembers(key)
+	if err != nil {
+		return nil, err
+	}
+	uri := strings.TrimPrefix(key, "trigger:")
+	paused, err := tx.getMembers(getPausedKey("-", uri))
+	if err != nil {
+		return nil, err
+	}
+	triggers := make([]model.Trigger, 0, len(pipelines))
+	for _, pipeline := range pipelines {
+		filters, err := tx.getHash(getFilterKey(uri, pipeline))
+		if err != nil {
+			return nil, err
+		}
+		triggers = append(triggers, model.Trigger{
+			Event:    uri,
+			Pipeline: pipeline,
+			Filters:  filters,
+			Paused:   containsString(paused, pipeline),
+		})
+	}
+	return triggers, nil
+}
+
+// find trigger event URIs matching filter (see findEvents in redis.go)
+func (s *kvStore) findEvents(tx kvTx, account, eventType, kind, filter string) ([]string, error) {
+	var members []string
+	var err error
+	switch {
+	case account != "-":
+		members, err = tx.getMembers(getAccountIndexKey(account))
+	case eventType != "" && kind != "":
+		members, err = tx.getMembers(getTypeIndexKey(eventType, kind))
+	default:
+		keys, err := tx.keys(getPrefixKey("event", getEventPattern(account, filter)))
+		if err != nil {
+			return nil, err
+		}
+		for i, k := range keys {
+			keys[i] = strings.TrimPrefix(k, "event:")
+		}
+		return keys, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	re, err := compileKeyPattern(getEventPattern(account, filter))
+	if err != nil {
+		return nil, err
+	}
+	uris := make([]string, 0, len(members))
+	for _, m := range members {
+		if re.MatchString(m) {
+			uris = append(uris, m)
+		}
+	}
+	return uris, nil
+}
+
+// get event from store inside transaction
+func (s *kvStore) getEvent(tx kvTx, account, event string) (*model.Event, error) {
+	eventKey := getEventKey(account, event)
+	fields, err := tx.getHash(eventKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, model.ErrEventNotFound
+	}
+	if err = s.cipher.decryptFields(fields); err != nil {
+		return nil, err
+	}
+	return model.StringsMapToEvent(event, fields), nil
+}
+
+// kvPageReader reads listing page items of account inside transaction
+type kvPageReader struct {
+	s       *kvStore
+	tx      kvTx
+	account string
+}
+
+func (p *kvPageReader) event(uri string) (*model.Event, error) {
+	event, err := p.s.getEvent(p.tx, p.account, uri)
+	if err == model.ErrEventNotFound {
+		return nil, nil
+	}
+	return event, err
+}
+
+func (p *kvPageReader) pipelines(uri string) ([]string, error) {
+	return p.tx.getMembers(getTriggerKey(p.account, uri))
+}
+
+func (p *kvPageReader) filters(uri, pipeline string) (map[string]string, error) {
+	return p.tx.getHash(getFilterKey(uri, pipeline))
+}
+
+func (p *kvPageReader) paused(uri, pipeline string) (bool, error) {
+	paused, err := p.tx.getMembers(getPausedKey(p.account, uri))
+	return containsString(paused, pipeline), err
+}
+
+//-------------------------- TriggerReaderWriter Interface -------------------------
+
+// GetEventTriggers get list of triggers for specified event
+func (s *kvStore) GetEventTriggers(ctx context.Context, event string) ([]model.Trigger, error) {
+	account := getAccount(ctx)
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.WithFields(log.Fields{
+		"event":   event,
+		"account": account,
+	}).Debug("get triggers for event")
+	// record NewRelic segment
+	if txn := getNewRelicTransaction(ctx); txn != nil {
+		seg := newrelic.StartSegment(txn, util.GetCurrentFuncName())
+		defer seg.End()
+	}
+
+	triggers := make([]model.Trigger, 0)
+	err := s.db.view(func(tx kvTx) error {
+		// get trigger events for account
+		uris, err := s.findEvents(tx, account, "", "", event)
+		if err != nil {
+			return err
+		}
+		// get matching public trigger events
+		if account != model.PublicAccount {
+			publicURIs, err := s.findEvents(tx, model.PublicAccount, "", "", event)
+			if err != nil {
+				return err
+			}
+			uris = util.MergeStrings(publicURIs, uris)
+		}
+		// iterate through all trigger keys and get linked pipelines
+		for _, uri := range uris {
+			t, err := s.getTriggers(tx, getTriggerKey(account, uri))
+			if err != nil {
+				return err
+			}
+			triggers = append(triggers, t...)
+		}
+		return nil
+	})
+	if err != nil {
+		lg.WithError(err).Error("failed to find triggers")
+		return nil, err
+	}
+	return triggers, nil
+}
+
+// GetEventTriggersPage get page of triggers for specified event
+func (s *kvStore) GetEventTriggersPage(ctx context.Context, event string, page model.PageOptions) (*model.TriggerPage, error) {
+	account := getAccount(ctx)
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.WithFields(log.Fields{
+		"event":   event,
+		"account": account,
+		"limit":   page.Limit,
+		"sort":    page.Sort,
+	}).Debug("get triggers page for event")
+	// record NewRelic segment
+	if txn := getNewRelicTransaction(ctx); txn != nil {
+		seg := newrelic.StartSegment(txn, util.GetCurrentFuncName())
+		defer seg.End()
+	}
+
+	var triggers *model.TriggerPage
+	err := s.db.view(func(tx kvTx) error {
+		// get trigger events for account and matching public trigger events
+		uris, err := s.findEvents(tx, account, "", "", event)
+		if err != nil {
+			return err
+		}
+		if account != model.PublicAccount {
+			publicURIs, err := s.findEvents(tx, model.PublicAccount, "", "", event)
+			if err != nil {
+				return err
+			}
+			uris = append(uris, publicURIs...)
+		}
+		triggers, err = triggersPage(&kvPageReader{s, tx, account}, uris, page)
+		return err
+	})
+	if err != nil {
+		lg.WithError(err).Error("failed to get triggers page")
+		return nil, err
+	}
+	return triggers, nil
+}
+
+// GetPipelineTriggers get list of defined triggers for specified pipeline
+func (s *kvStore) GetPipelineTriggers(ctx context.Context, pipeline string, withEvent bool) ([]model.Trigger, error) {
+	account := getAccount(ctx)
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.WithFields(log.Fields{
+		"pipeline": pipeline,
+		"account":  account,
+	}).Debug("get triggers for pipeline")
+	// record NewRelic segment
+	if txn := getNewRelicTransaction(ctx); txn != nil {
+		seg := newrelic.StartSegment(txn, util.GetCurrentFuncName())
+		defer seg.End()
+	}
+
+	var triggers []model.Trigger
+	err := s.db.view(func(tx kvTx) error {
+		pipelineKey := getPipelineKey(pipeline)
+		exists, err := tx.exists(pipelineKey)
+		if err != nil || !exists {
+			return err
+		}
+		events, err := tx.getMembers(pipelineKey)
+		if err != nil {
+			return err
+		}
+		// for all linked trigger events, check if event belongs to context account of it's a public event
+		triggers = make([]model.Trigger, 0)
+		for _, event := range events {
+			if !model.MatchAccount(account, event) && !model.MatchPublicAccount(event) {
+				continue
+			}
+			filters, err := tx.getHash(getFilterKey(event, pipeline))
+			if err != nil {
+				return err
+			}
+			paused, err := tx.getMembers(getPausedKey(account, event))
+			if err != nil {
+				return err
+			}
+			trigger := model.Trigger{
+				Event:    event,
+				Pipeline: pipeline,
+				Filters:  filters,
+				Paused:   containsString(paused, pipeline),
+			}
+			// get event object, if asked
+			if withEvent {
+				eventData, err := s.getEvent(tx, account, event)
+				if err != nil {
+					lg.WithField("event-uri", event).WithError(err).Error("error getting event details")
+					return err
+				}
+				trigger.EventData = *eventData
+			}
+			triggers = append(triggers, trigger)
+		}
+		return nil
+	})
+	if err != nil {
+		lg.WithField("pipeline", pipeline).WithError(err).Error("error finding triggers for pipeline")
+		return nil, err
+	}
+	if len(triggers) == 0 {
+		lg.WithField("pipeline", pipeline).Warn("failed to find triggers for pipeline")
+	}
+	return triggers, nil
+}
+
+// DeleteTrigger delete trigger: unlink event from pipeline
+func (s *kvStore) DeleteTrigger(ctx context.Context, event, pipeline string) error {
+	account := getAccount(ctx)
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.WithFields(log.Fields{
+		"pipeline": pipeline,
+		"event":    event,
+		"account":  account,
+	}).Debug("deleting trigger")
+	// record NewRelic segment
+	if txn := getNewRelicTransaction(ctx); txn != nil {
+		seg := newrelic.StartSegment(txn, util.GetCurrentFuncName())
+		defer seg.End()
+	}
+
+	// check event account match: public or private
+	if !model.MatchPublicAccount(event) && !model.MatchAccount(account, event) {
+		lg.WithField("event", event).Error("failed to match trigger for trigger-event")
+		return model.ErrTriggerNotFound
+	}
+
+	// check Codefresh pipeline match; ignore all errors beside "no match"
+	_, err := s.pipelineSvc.GetPipeline(ctx, account, pipeline)
+	if err == codefresh.ErrPipelineNoMatch {
+		lg.WithError(err).Error("attempt to remove pipeline from another account")
+		return err
+	}
+
+	err = s.db.update(func(tx kvTx) error {
+		// remove pipeline from Triggers
+		if err := tx.removeMember(getTriggerKey(account, event), pipeline); err != nil {
+			return err
+		}
+		// remove trigger(s) from Pipelines
+		if err := tx.removeMember(getPipelineKey(pipeline), event); err != nil {
+			return err
+		}
+		// remove pipeline from paused triggers
+		if err := tx.removeMember(getPausedKey(account, event), pipeline); err != nil {
+			return err
+		}
+		// remove trigger filters if any
+		return tx.delete(getFilterKey(event, pipeline))
+	})
+	if err != nil {
+		lg.WithError(err).Error("failed to delete trigger")
+	}
+	return err
+}
+
+// DeleteAllTriggersByPipeline delete all account and public triggers linked to the pipeline in single transaction
+func (s *kvStore) DeleteAllTriggersByPipeline(ctx context.Context, pipeline string) error {
+	account := getAccount(ctx)
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.WithFields(log.Fields{
+		"pipeline": pipeline,
+		"account":  account,
+	}).Debug("deleting all triggers for pipeline")
+	// record NewRelic segment
+	if txn := getNewRelicTransaction(ctx); txn != nil {
+		seg := newrelic.StartSegment(txn, util.GetCurrentFuncName())
+		defer seg.End()
+	}
+
+	// check Codefresh pipeline match; ignore all errors beside "no match"
+	if _, err := s.pipelineSvc.GetPipeline(ctx, account, pipeline); err == codefresh.ErrPipelineNoMatch {
+		lg.WithError(err).Error("attempt to remove pipeline from another account")
+		return err
+	}
+
+	err := s.db.update(func(tx kvTx) error {
+		pipelineKey := getPipelineKey(pipeline)
+		events, err := tx.getMembers(pipelineKey)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			if !model.MatchAccount(account, event) && !model.MatchPublicAccount(event) {
+				continue
+			}
+			if err := tx.removeMember(getTriggerKey(account, event), pipeline); err != nil {
+				return err
+			}
+			if err := tx.removeMember(pipelineKey, event); err != nil {
+				return err
+			}
+			if err := tx.removeMember(getPausedKey(account, event), pipeline); err != nil {
+				return err
+			}
+			if err := tx.delete(getFilterKey(event, pipeline)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		lg.WithError(err).Error("failed to delete triggers for pipeline")
+	}
+	return err
+}
+
+// SetTriggerPaused pause or resume trigger; paused trigger keeps its filters, but does not run pipeline
+func (s *kvStore) SetTriggerPaused(ctx context.Context, event, pipeline string, paused bool) error {
+	account := getAccount(ctx)
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.WithFields(log.Fields{
+		"event":    event,
+		"pipeline": pipeline,
+		"account":  account,
+		"paused":   paused,
+	}).Debug("setting trigger paused state")
+	// record NewRelic segment
+	if txn := getNewRelicTransaction(ctx); txn != nil {
+		seg := newrelic.StartSegment(txn, util.GetCurrentFuncName())
+		defer seg.End()
+	}
+
+	// check event account match: public or private
+	if !model.MatchPublicAccount(event) && !model.MatchAccount(account, event) {
+		lg.WithField("event", event).Error("failed to match trigger for trigger-event")
+		return model.ErrTriggerNotFound
+	}
+
+	// check Codefresh pipeline match; ignore all errors beside "no match"
+	if _, err := s.pipelineSvc.GetPipeline(ctx, account, pipeline); err == codefresh.ErrPipelineNoMatch {
+		lg.WithError(err).Error("attempt to change pipeline from another account")
+		return err
+	}
+
+	err := s.db.update(func(tx kvTx) error {
+		// change only existing trigger
+		pipelines, err := tx.getMembers(getTriggerKey(account, event))
+		if err != nil {
+			return err
+		}
+		if !containsString(pipelines, pipeline) {
+			return model.ErrTriggerNotFound
+		}
+		if paused {
+			return tx.addMember(getPausedKey(account, event), pipeline)
+		}
+		return tx.removeMember(getPausedKey(account, event), pipeline)
+	})
+	if err != nil {
+		lg.WithError(err).Error("failed to set trigger paused state")
+	}
+	return err
+}
+
+// CreateTrigger create trigger: link event <-> multiple pipelines
+func (s *kvStore) CreateTrigger(ctx context.Context, event, pipeline string, filters map[string]string) error {
+	account := getAccount(ctx)
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.WithFields(log.Fields{
+		"event":    event,
+		"pipeline": pipeline,
+		"account":  account,
+		"filters":  filters,
+	}).Debug("Creating triggers")
+	// record NewRelic segment
+	if txn := getNewRelicTransaction(ctx); txn != nil {
+		seg := newrelic.StartSegment(txn, util.GetCurrentFuncName())
+		defer seg.End()
+	}
+
+	// check event account match: public or private
+	if !model.MatchPublicAccount(event) && !model.MatchAccount(account, event) {
+		lg.WithField("event", event).Error("failed to match trigger for trigger-event")
+		return model.ErrTriggerNotFound
+	}
+
+	// check Codefresh pipeline existence
+	_, err := s.pipelineSvc.GetPipeline(ctx, account, pipeline)
+	if err != nil {
+		lg.WithError(err).Error("failed to get pipelines")
+		return err
+	}
+
+	err = s.db.update(func(tx kvTx) error {
+		// create trigger only for existing trigger event
+		exists, err := tx.exists(getEventKey(account, event))
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return model.ErrEventNotFound
+		}
+		// add trigger to Pipelines
+		if err := tx.addMember(getPipelineKey(pipeline), event); err != nil {
+			return err
+		}
+		// add pipeline to Triggers
+		if err := tx.addMember(getTriggerKey(account, event), pipeline); err != nil {
+			return err
+		}
+		// add trigger filters to Filters
+		if len(filters) > 0 {
+			return tx.setHash(getFilterKey(event, pipeline), filters)
+		}
+		return nil
+	})
+	if err != nil {
+		lg.WithError(err).Error("failed to create trigger")
+	}
+	return err
+}
+
+// GetTriggerPipelines get pipelines that have trigger defined with filter applied
+func (s *kvStore) GetTriggerPipelines(ctx context.Context, event string, vars map[string]string) ([]string, error) {
+	pipelines, _, err := s.MatchTriggerPipelines(ctx, event, vars)
+	return pipelines, err
+}
+
+// MatchTriggerPipelines get pipelines that have trigger defined with filter applied, and pipelines of paused triggers
+// and triggers with filters not matching vars
+func (s *kvStore) MatchTriggerPipelines(ctx context.Context, event string, vars map[string]string) ([]string, []model.SkippedPipeline, error) {
+	account := getAccount(ctx)
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.WithFields(log.Fields{
+		"event":   event,
+		"account": account,
+		"vars":    vars,
+	}).Debug("getting pipelines for trigger event")
+	// record NewRelic segment
+	if txn := getNewRelicTransaction(ctx); txn != nil {
+		seg := newrelic.StartSegment(txn, util.GetCurrentFuncName())
+		defer seg.End()
+	}
+
+	var pipelines []string
+	var skipped []model.SkippedPipeline
+	err := s.db.view(func(tx kvTx) error {
+		triggerKey := getTriggerKey(account, event)
+		// check trigger existence
+		exists, err := tx.exists(triggerKey)
+		if err != nil || !exists {
+			return err
+		}
+		// get pipelines from Triggers
+		all, err := tx.getMembers(triggerKey)
+		if err != nil {
+			return err
+		}
+		paused, err := tx.getMembers(getPausedKey(account, event))
+		if err != nil {
+			return err
+		}
+		// scan through pipelines and filter out paused pipelines and pipelines that do not match filter
+		pipelines = make([]string, 0, len(all))
+		for _, pipeline := range all {
+			if containsString(paused, pipeline) {
+				lg.WithField("pipeline", pipeline).Debug("skipping paused trigger")
+				skipped = append(skipped, model.SkippedPipeline{Pipeline: pipeline, Reason: model.SkipReasonPaused})
+				continue
+			}
+			if len(vars) > 0 {
+				filters, err := tx.getHash(getFilterKey(event, pipeline))
+				if err != nil {
+					return err
+				}
+				if !matchFilters(filters, vars, pipeline, lg) {
+					skipped = append(skipped, model.SkippedPipeline{Pipeline: pipeline, Reason: model.SkipReasonFilter})
+					continue
+				}
+			}
+			pipelines = append(pipelines, pipeline)
+		}
+		return nil
+	})
+	if err != nil {
+		lg.WithError(err).Error("error getting pipelines")
+		return nil, nil, err
+	}
+	if pipelines == nil {
+		lg.Warn("trigger not found")
+		return nil, nil, nil
+	}
+	if len(pipelines) == 0 {
+		lg.Warn("no pipelines found or all skipped")
+	}
+	return pipelines, skipped, nil
+}
+
+//-------------------------- TriggerEventReaderWriter Interface -------------------------
+
+// CreateEvent new trigger event
+func (s *kvStore) CreateEvent(ctx context.Context, eventType, kind, secret, context string, values map[string]string) (*model.Event, error) {
+	account := getAccount(ctx)
+	// replace account to public account for public event creation
+	if getPublicFlag(ctx) {
+		account = model.PublicAccount
+	}
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.WithFields(log.Fields{
+		"type":    eventType,
+		"kind":    kind,
+		"values":  values,
+		"account": account,
+	}).Debug("Creating a new trigger event")
+	// record NewRelic segment
+	if txn := getNewRelicTransaction(ctx); txn != nil {
+		seg := newrelic.StartSegment(txn, util.GetCurrentFuncName())
+		defer seg.End()
+	}
+
+	// construct event URI
+	eventURI, err := s.eventProvider.ConstructEventURI(eventType, kind, account, values)
+	if err != nil {
+		lg.WithError(err).Error("failed to create valid event uri")
+		return nil, err
+	}
+
+	// first, try to get existing event, continue on error
+	if event, e := s.GetEvent(ctx, eventURI); e == nil {
+		lg.WithField("event-uri", eventURI).Debug("event already exists, reusing trigger-event")
+		return checkEventSecret(event, secret)
+	}
+
+	// generate random secret if required
+	if secret == model.GenerateKeyword {
+		lg.Debug("auto generating trigger secret")
+		secret = util.RandomString(16)
+	}
+
+	// try subscribing to event - create event in remote system through event provider
+	eventInfo, err := subscribeToEvent(ctx, s.eventProvider, eventURI, secret, getCredentials(context, lg), lg)
+	if err != nil {
+		return nil, err
+	}
+
+	event := model.Event{
+		URI:       eventURI,
+		Type:      eventType,
+		Kind:      kind,
+		Account:   account,
+		Secret:    secret,
+		EventInfo: *eventInfo,
+	}
+
+	var stored *model.Event
+	err = s.db.update(func(tx kvTx) error {
+		eventKey := getEventKey(account, eventURI)
+		existing, err := tx.getHash(eventKey)
+		if err != nil {
+			return err
+		}
+		// trigger event created concurrently: keep stored one
+		if len(existing) > 0 {
+			if err = s.cipher.decryptFields(existing); err != nil {
+				return err
+			}
+			stored = model.StringsMapToEvent(eventURI, existing)
+			return nil
+		}
+		fields := map[string]string{
+			"type":        eventType,
+			"kind":        kind,
+			"account":     account,
+			"secret":      secret,
+			"description": eventInfo.Description,
+			"endpoint":    eventInfo.Endpoint,
+			"help":        eventInfo.Help,
+			"status":      eventInfo.Status,
+		}
+		if err := s.cipher.encryptFields(fields); err != nil {
+			return err
+		}
+		if err := tx.setHash(eventKey, fields); err != nil {
+			return err
+		}
+		// add event to account and type indexes
+		if err := tx.addMember(getAccountIndexKey(account), eventURI); err != nil {
+			return err
+		}
+		return tx.addMember(getTypeIndexKey(eventType, kind), eventURI)
+	})
+	if err != nil {
+		lg.WithError(err).Error("failed to store trigger event")
+		return nil, err
+	}
+	if stored != nil {
+		lg.WithField("event-uri", eventURI).Debug("event created concurrently, reusing trigger-event")
+		return checkEventSecret(stored, secret)
+	}
+	return &event, nil
+}
+
+// UpdateEvent update trigger event in place: replace secret, refresh event info or change description
+func (s *kvStore) UpdateEvent(ctx context.Context, event string, update model.EventUpdate) (*model.Event, error) {
+	account := getAccount(ctx)
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.WithFields(log.Fields{
+		"event-uri": event,
+		"account":   account,
+		"refresh":   update.Refresh,
+	}).Debug("updating trigger event")
+	// record NewRelic segment
+	if txn := getNewRelicTransaction(ctx); txn != nil {
+		seg := newrelic.StartSegment(txn, util.GetCurrentFuncName())
+		defer seg.End()
+	}
+	stored, err := s.GetEvent(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+	// if not public and belongs to different account - return not exists error
+	if stored.Account != model.PublicAccount && stored.Account != account {
+		return nil, model.ErrEventNotFound
+	}
+	fields, err := updateEventFields(ctx, s.eventProvider, stored, update, lg)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.cipher.encryptFields(fields); err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return stored, nil
+	}
+
+	// update trigger event fields, unless it was deleted concurrently
+	err = s.db.update(func(tx kvTx) error {
+		eventKey := getEventKey(account, event)
+		exists, err := tx.exists(eventKey)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return model.ErrEventNotFound
+		}
+		return tx.setHash(eventKey, fields)
+	})
+	if err != nil {
+		lg.WithError(err).Error("failed to update trigger event")
+		return nil, err
+	}
+	return stored, nil
+}
+
+// RotateEventSecret generate new trigger event secret, keeping current secret valid for grace period
+func (s *kvStore) RotateEventSecret(ctx context.Context, event string, rotation model.SecretRotation) (*model.Event, error) {
+	account := getAccount(ctx)
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.WithFields(log.Fields{
+		"event-uri": event,
+		"account":   account,
+		"grace":     rotation.Grace,
+		"push":      rotation.Push,
+	}).Debug("rotating trigger event secret")
+	// record NewRelic segment
+	if txn := getNewRelicTransaction(ctx); txn != nil {
+		seg := newrelic.StartSegment(txn, util.GetCurrentFuncName())
+		defer seg.End()
+	}
+	stored, err := s.GetEvent(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+	// if not public and belongs to different account - return not exists error
+	if stored.Account != model.PublicAccount && stored.Account != account {
+		return nil, model.ErrEventNotFound
+	}
+	return rotateEventSecret(ctx, s, s.eventProvider, account, stored, rotation, lg)
+}
+
+// set trigger event fields, unless secret was rotated or trigger event deleted concurrently
+func (s *kvStore) swapEventSecret(ctx context.Context, account, event, current string, fields map[string]string, rotating bool) error {
+	if err := s.cipher.encryptFields(fields); err != nil {
+		return err
+	}
+	return s.db.update(func(tx kvTx) error {
+		eventKey := getEventKey(account, event)
+		existing, err := tx.getHash(eventKey)
+		if err != nil {
+			return err
+		}
+		if len(existing) == 0 {
+			return model.ErrEventNotFound
+		}
+		if secret, err := s.cipher.decrypt(existing["secret"]); err != nil || secret != current {
+			return model.ErrStoreConflict
+		}
+		if err := tx.setHash(eventKey, fields); err != nil {
+			return err
+		}
+		// purge previous secret after grace period
+		if rotating {
+			return tx.addMember(rotationIndexKey, event)
+		}
+		return nil
+	})
+}
+
+// GetEvent get event by event URI
+func (s *kvStore) GetEvent(ctx context.Context, event string) (*model.Event, error) {
+	account := getAccount(ctx)
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.WithFields(log.Fields{
+		"event-uri": event,
+		"account":   account,
+	}).Debug("getting trigger event")
+	// record NewRelic segment
+	if txn := getNewRelicTransaction(ctx); txn != nil {
+		seg := newrelic.StartSegment(txn, util.GetCurrentFuncName())
+		defer seg.End()
+	}
+
+	var result *model.Event
+	err := s.db.view(func(tx kvTx) (err error) {
+		result, err = s.getEvent(tx, account, event)
+		return err
+	})
+	if err != nil {
+		lg.WithError(err).Error("failed to get trigger event")
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetEvents get events by event type, kind and filter (can be URI or part of URI)
+func (s *kvStore) GetEvents(ctx context.Context, eventType, kind, filter string) ([]model.Event, error) {
+	account := getAccount(ctx)
+	public := getPublicFlag(ctx)
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.WithFields(log.Fields{
+		"type":    eventType,
+		"kind":    kind,
+		"account": account,
+		"filter":  filter,
+		"public":  public,
+	}).Debug("getting trigger events")
+	// record NewRelic segment
+	if txn := getNewRelicTransaction(ctx); txn != nil {
+		seg := newrelic.StartSegment(txn, util.GetCurrentFuncName())
+		defer seg.End()
+	}
+
+	events := make([]model.Event, 0)
+	err := s.db.view(func(tx kvTx) error {
+		// get all events URIs for account
+		uris, err := s.findEvents(tx, account, eventType, kind, filter)
+		if err != nil {
+			return err
+		}
+		// get public trigger events, if asked (through context)
+		if public && account != model.PublicAccount {
+			publicURIs, err := s.findEvents(tx, model.PublicAccount, eventType, kind, filter)
+			if err != nil {
+				return err
+			}
+			uris = append(uris, publicURIs...)
+		}
+		// scan through all events and select matching to non-empty type and kind
+		for _, uri := range uris {
+			event, err := s.getEvent(tx, account, uri)
+			if err != nil {
+				return err
+			}
+			if (eventType == "" || event.Type == eventType) &&
+				(kind == "" || event.Kind == kind) {
+				events = append(events, *event)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		lg.WithError(err).Error("failed to get trigger events")
+		return nil, err
+	}
+	return events, nil
+}
+
+// GetEventsPage get page of events by event type, kind and filter (can be URI or part of URI)
+func (s *kvStore) GetEventsPage(ctx context.Context, eventType, kind, filter string, page model.PageOptions) (*model.EventPage, error) {
+	account := getAccount(ctx)
+	public := getPublicFlag(ctx)
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.WithFields(log.Fields{
+		"type":    eventType,
+		"kind":    kind,
+		"account": account,
+		"filter":  filter,
+		"public":  public,
+		"limit":   page.Limit,
+		"sort":    page.Sort,
+	}).Debug("getting trigger events page")
+	// record NewRelic segment
+	if txn := getNewRelicTransaction(ctx); txn != nil {
+		seg := newrelic.StartSegment(txn, util.GetCurrentFuncName())
+		defer seg.End()
+	}
+
+	var events *model.EventPage
+	err := s.db.view(func(tx kvTx) error {
+		// get all events URIs for account
+		uris, err := s.findEvents(tx, account, eventType, kind, filter)
+		if err != nil {
+			return err
+		}
+		// get public trigger events, if asked (through context)
+		if public && account != model.PublicAccount {
+			publicURIs, err := s.findEvents(tx, model.PublicAccount, eventType, kind, filter)
+			if err != nil {
+				return err
+			}
+			uris = append(uris, publicURIs...)
+		}
+		events, err = eventsPage(&kvPageReader{s, tx, account}, uris, eventType, kind, page)
+		return err
+	})
+	if err != nil {
+		lg.WithError(err).Error("failed to get trigger events page")
+		return nil, err
+	}
+	return events, nil
+}
+
+// DeleteEvent delete trigger event
+func (s *kvStore) DeleteEvent(ctx context.Context, event, context string) error {
+	_, err := s.deleteEvent(ctx, event, context, false)
+	return err
+}
+
+// ForceDeleteEvent delete trigger event together with all its triggers and filters
+func (s *kvStore) ForceDeleteEvent(ctx context.Context, event, context string) (*model.EventDeletion, error) {
+	return s.deleteEvent(ctx, event, context, true)
+}
+
+// delete trigger event in transaction and unsubscribe from event
+// force: delete linked triggers, filters and pipeline references too; fail with ErrEventDeleteWithTriggers otherwise
+func (s *kvStore) deleteEvent(ctx context.Context, event, context string, force bool) (*model.EventDeletion, error) {
+	account := getAccount(ctx)
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.WithFields(log.Fields{
+		"event-uri": event,
+		"account":   account,
+		"force":     force,
+	}).Debug("deleting trigger event")
+	// record NewRelic segment
+	if txn := getNewRelicTransaction(ctx); txn != nil {
+		seg := newrelic.StartSegment(txn, util.GetCurrentFuncName())
+		defer seg.End()
+	}
+
+	var deletion *model.EventDeletion
+	err := s.db.update(func(tx kvTx) error {
+		eventKey := getEventKey(account, event)
+		triggerKey := getTriggerKey(account, event)
+		uri := strings.TrimPrefix(eventKey, "event:")
+		fields, err := tx.getHash(eventKey)
+		if err != nil {
+			return err
+		}
+		if len(fields) == 0 {
+			return model.ErrEventNotFound
+		}
+		// if not public and belongs to different account - return not exists error
+		if a := fields["account"]; a != model.PublicAccount && a != account {
+			return model.ErrEventNotFound
+		}
+		// abort delete operation if trigger event has linked pipelines
+		pipelines, err := tx.getMembers(triggerKey)
+		if err != nil {
+			return err
+		}
+		if len(pipelines) > 0 && !force {
+			return model.ErrEventDeleteWithTriggers
+		}
+		deletion = &model.EventDeletion{URI: uri, Pipelines: pipelines, Filters: make([]string, 0)}
+		// remove trigger event from Pipelines and trigger filters (forced delete)
+		for _, pipeline := range pipelines {
+			if err := tx.removeMember(getPipelineKey(pipeline), uri); err != nil {
+				return err
+			}
+			filterKey := getFilterKey(uri, pipeline)
+			exists, err := tx.exists(filterKey)
+			if err != nil {
+				return err
+			}
+			if exists {
+				if err := tx.delete(filterKey); err != nil {
+					return err
+				}
+				deletion.Filters = append(deletion.Filters, pipeline)
+			}
+		}
+		// delete event hash and trigger event from Triggers and paused triggers
+		if err := tx.delete(eventKey); err != nil {
+			return err
+		}
+		if err := tx.delete(triggerKey); err != nil {
+			return err
+		}
+		if err := tx.delete(getPausedKey(account, event)); err != nil {
+			return err
+		}
+		// remove trigger event from account and type indexes
+		if err := tx.removeMember(getAccountIndexKey(fields["account"]), uri); err != nil {
+			return err
+		}
+		return tx.removeMember(getTypeIndexKey(fields["type"], fields["kind"]), uri)
+	})
+	if err != nil {
+		lg.WithError(err).Error("failed to delete trigger event")
+		return nil, err
+	}
+
+	// try unsubscribing from event - delete event in remote system through event provider
+	return deletion, unsubscribeFromEvent(ctx, s.eventProvider, event, getCredentials(context, lg), lg)
+}
+
+//-------------------------- SecretPurger Interface -------------------------
+
+// PurgeExpiredSecrets remove previous trigger event secrets after rotation grace period
+func (s *kvStore) PurgeExpiredSecrets(ctx context.Context) (int, error) {
+	lg := log.WithFields(getContextLogFields(ctx))
+	purged := 0
+	err := s.db.update(func(tx kvTx) error {
+		purged = 0
+		uris, err := tx.getMembers(rotationIndexKey)
+		if err != nil {
+			return err
+		}
+		for _, uri := range uris {
+			eventKey := getEventKey("-", uri)
+			fields, err := tx.getHash(eventKey)
+			if err != nil {
+				return err
+			}
+			if !secretExpired(fields, time.Now()) {
+				continue
+			}
+			// rewrite trigger event hash without previous secret fields
+			if len(fields) > 0 {
+				delete(fields, "previous_secret")
+				delete(fields, "previous_secret_expires")
+				if err := tx.delete(eventKey); err != nil {
+					return err
+				}
+				if err := tx.setHash(eventKey, fields); err != nil {
+					return err
+				}
+			}
+			if err := tx.removeMember(rotationIndexKey, uri); err != nil {
+				return err
+			}
+			purged++
+		}
+		return nil
+	})
+	if err != nil {
+		lg.WithError(err).Error("failed to purge trigger event secrets")
+		return 0, err
+	}
+	return purged, nil
+}
+
+//-------------------------- SecretEncrypter Interface -------------------------
+
+// ReEncrypt encrypt plaintext secrets and secrets encrypted with previous keys with current encryption key
+func (s *kvStore) ReEncrypt(ctx context.Context) (int, error) {
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.Debug("re-encrypting trigger event secrets")
+	if s.cipher == nil {
+		return 0, ErrNoEncryptionKey
+	}
+	updated := 0
+	err := s.db.update(func(tx kvTx) error {
+		updated = 0
+		keys, err := tx.keys("event:*")
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			fields, err := tx.getHash(key)
+			if err != nil {
+				return err
+			}
+			changed, err := s.cipher.reencryptFields(fields)
+			if err != nil {
+				lg.WithField("key", key).WithError(err).Error("failed to re-encrypt trigger event secrets")
+				return err
+			}
+			if len(changed) == 0 {
+				continue
+			}
+			if err := tx.setHash(key, changed); err != nil {
+				return err
+			}
+			updated++
+		}
+		return nil
+	})
+	if err != nil {
+		lg.WithError(err).Error("failed to re-encrypt trigger event secrets")
+		return 0, err
+	}
+	lg.WithField("events", updated).Debug("trigger event secrets re-encrypted")
+	return updated, nil
+}
+
+//-------------------------- DispatchQueue Interface -------------------------
+
+// PauseDispatch pause dispatch for scope; pausing paused scope keeps its pause time
+func (s *kvStore) PauseDispatch(ctx context.Context, scope string) error {
+	lg := log.WithFields(getContextLogFields(ctx)).WithField("scope", scope)
+	lg.Debug("pausing dispatch")
+	err := s.db.update(func(tx kvTx) error {
+		dispatchKey := getDispatchKey(scope)
+		exists, err := tx.exists(dispatchKey)
+		if err != nil || exists {
+			return err
+		}
+		return tx.setHash(dispatchKey, map[string]string{"since": time.Now().UTC().Format(time.RFC3339Nano)})
+	})
+	if err != nil {
+		lg.WithError(err).Error("failed to pause dispatch")
+	}
+	return err
+}
+
+// ResumeDispatch resume dispatch for scope; fails when scope has queued events
+func (s *kvStore) ResumeDispatch(ctx context.Context, scope string) error {
+	lg := log.WithFields(getContextLogFields(ctx)).WithField("scope", scope)
+	lg.Debug("resuming dispatch")
+	return s.db.update(func(tx kvTx) error {
+		ids, err := tx.getMembers(getQueueKey(scope))
+		if err != nil {
+			return err
+		}
+		if len(ids) > 0 {
+			return model.ErrDispatchQueueNotEmpty
+		}
+		return tx.delete(getDispatchKey(scope))
+	})
+}
+
+// GetDispatchStates list paused scopes
+func (s *kvStore) GetDispatchStates(ctx context.Context) ([]model.DispatchState, error) {
+	var states []model.DispatchState
+	err := s.db.view(func(tx kvTx) error {
+		keys, err := tx.keys(getDispatchKey("*"))
+		if err != nil {
+			return err
+		}
+		states = make([]model.DispatchState, 0, len(keys))
+		for _, key := range keys {
+			scope := trimKeyPrefix(key, "dispatch")
+			fields, err := tx.getHash(key)
+			if err != nil {
+				return err
+			}
+			ids, err := tx.getMembers(getQueueKey(scope))
+			if err != nil {
+				return err
+			}
+			states = append(states, newDispatchState(scope, fields["since"], len(ids)))
+		}
+		return nil
+	})
+	if err != nil {
+		log.WithFields(getContextLogFields(ctx)).WithError(err).Error("failed to get dispatch states")
+		return nil, err
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Scope < states[j].Scope })
+	return states, nil
+}
+
+// QueueEvent append event to scope queue, only when dispatch is paused for scope
+func (s *kvStore) QueueEvent(ctx context.Context, scope string, event *model.QueuedEvent) (bool, error) {
+	lg := log.WithFields(getContextLogFields(ctx)).WithFields(log.Fields{"scope": scope, "queued-event": event.ID})
+	fields, err := queuedEventToFields(s.cipher, event)
+	if err != nil {
+		lg.WithError(err).Error("failed to encode queued event")
+		return false, err
+	}
+	queued := false
+	err = s.db.update(func(tx kvTx) error {
+		paused, err := tx.exists(getDispatchKey(scope))
+		if err != nil || !paused {
+			return err
+		}
+		if err = tx.setHash(getQueuedEventKey(event.ID), fields); err != nil {
+			return err
+		}
+		queued = true
+		return tx.addMember(getQueueKey(scope), event.ID)
+	})
+	if err != nil {
+		lg.WithError(err).Error("failed to queue event")
+		return false, err
+	}
+	if queued {
+		lg.WithField("event", event.Event).Info("dispatch is paused, event queued")
+	}
+	return queued, nil
+}
+
+// PeekQueuedEvent return the oldest queued event of scope, without removing it
+func (s *kvStore) PeekQueuedEvent(ctx context.Context, scope string) (*model.QueuedEvent, error) {
+	var id string
+	var fields map[string]string
+	err := s.db.view(func(tx kvTx) error {
+		ids, err := tx.getMembers(getQueueKey(scope))
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		id = ids[0]
+		fields, err = tx.getHash(getQueuedEventKey(id))
+		return err
+	})
+	if err != nil {
+		log.WithFields(getContextLogFields(ctx)).WithField("scope", scope).WithError(err).Error("failed to get queued event")
+		return nil, err
+	}
+	if id == "" {
+		return nil, nil
+	}
+	return fieldsToQueuedEvent(s.cipher, id, fields)
+}
+
+// AckQueuedEvent remove queued event from scope queue
+func (s *kvStore) AckQueuedEvent(ctx context.Context, scope, id string) error {
+	err := s.db.update(func(tx kvTx) error {
+		if err := tx.removeMember(getQueueKey(scope), id); err != nil {
+			return err
+		}
+		return tx.delete(getQueuedEventKey(id))
+	})
+	if err != nil {
+		log.WithFields(getContextLogFields(ctx)).WithFields(log.Fields{"scope": scope, "queued-event": id}).WithError(err).Error("failed to remove queued event")
+	}
+	return err
+}
+
+// RequeueEvent replace queued event with event (new ID: queue tail)
+func (s *kvStore) RequeueEvent(ctx context.Context, scope, id string, event *model.QueuedEvent) error {
+	fields, err := queuedEventToFields(s.cipher, event)
+	if err != nil {
+		return err
+	}
+	err = s.db.update(func(tx kvTx) error {
+		queueKey := getQueueKey(scope)
+		if err := tx.removeMember(queueKey, id); err != nil {
+			return err
+		}
+		if err := tx.delete(getQueuedEventKey(id)); err != nil {
+			return err
+		}
+		if err := tx.setHash(getQueuedEventKey(event.ID), fields); err != nil {
+			return err
+		}
+		return tx.addMember(queueKey, event.ID)
+	})
+	if err != nil {
+		log.WithFields(getContextLogFields(ctx)).WithFields(log.Fields{"scope": scope, "queued-event": id}).WithError(err).Error("failed to requeue event")
+	}
+	return err
+}
+
+//-------------------------- Locker Interface -------------------------
+
+// AcquireLock acquire lock for owner until released or ttl expires; false when locked by another owner
+func (s *kvStore) AcquireLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	locked := false
+	err := s.db.update(func(tx kvTx) error {
+		lockKey := getLockKey(name)
+		fields, err := tx.getHash(lockKey)
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		if expires, err := time.Parse(time.RFC3339Nano, fields["expires"]); err == nil && fields["owner"] != owner && now.Before(expires) {
+			return nil
+		}
+		locked = true
+		return tx.setHash(lockKey, map[string]string{"owner": owner, "expires": now.Add(ttl).Format(time.RFC3339Nano)})
+	})
+	if err != nil {
+		log.WithFields(getContextLogFields(ctx)).WithFields(log.Fields{"lock": name, "owner": owner}).WithError(err).Error("failed to acquire lock")
+		return false, err
+	}
+	return locked, nil
+}
+
+// ExtendLock extend lock held by owner for ttl; false when lock expired or was acquired by another owner
+func (s *kvStore) ExtendLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	extended := false
+	err := s.db.update(func(tx kvTx) error {
+		lockKey := getLockKey(name)
+		fields, err := tx.getHash(lockKey)
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		if expires, err := time.Parse(time.RFC3339Nano, fields["expires"]); err != nil || fields["owner"] != owner || !now.Before(expires) {
+			return nil
+		}
+		extended = true
+		return tx.setHash(lockKey, map[string]string{"owner": owner, "expires": now.Add(ttl).Format(time.RFC3339Nano)})
+	})
+	if err != nil {
+		log.WithFields(getContextLogFields(ctx)).WithFields(log.Fields{"lock": name, "owner": owner}).WithError(err).Error("failed to extend lock")
+		return false, err
+	}
+	return extended, nil
+}
+
+// ReleaseLock release lock, if still held by owner
+func (s *kvStore) ReleaseLock(ctx context.Context, name, owner string) error {
+	return s.db.update(func(tx kvTx) error {
+		lockKey := getLockKey(name)
+		fields, err := tx.getHash(lockKey)
+		if err != nil || fields["owner"] != owner {
+			return err
+		}
+		return tx.delete(lockKey)
+	})
+}
+
+//-------------------------- ReconcileRunStore Interface -------------------------
+
+// SaveReconcileRun store run result, removing runs older than last reconcileRunsLimit runs
+func (s *kvStore) SaveReconcileRun(ctx context.Context, run *model.ReconcileRun) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	err = s.db.update(func(tx kvTx) error {
+		if err := tx.setHash(getReconcileRunKey(run.ID), map[string]string{"run": string(data)}); err != nil {
+			return err
+		}
+		keys, err := tx.keys(getReconcileRunKey("*"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < len(keys)-reconcileRunsLimit; i++ {
+			if err = tx.delete(keys[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.WithFields(getContextLogFields(ctx)).WithField("run", run.ID).WithError(err).Error("failed to store reconciliation run")
+	}
+	return err
+}
+
+// GetReconcileRuns list recent runs, latest first
+func (s *kvStore) GetReconcileRuns(ctx context.Context) ([]model.ReconcileRun, error) {
+	var keys, values []string
+	err := s.db.view(func(tx kvTx) error {
+		var err error
+		if keys, err = tx.keys(getReconcileRunKey("*")); err != nil {
+			return err
+		}
+		for _, key := range keys {
+			fields, err := tx.getHash(key)
+			if err != nil {
+				return err
+			}
+			values = append(values, fields["run"])
+		}
+		return nil
+	})
+	if err != nil {
+		log.WithFields(getContextLogFields(ctx)).WithError(err).Error("failed to get reconciliation runs")
+		return nil, err
+	}
+	return decodeReconcileRuns(keys, values)
+}
+
+//-------------------------- TriggerCollector Interface -------------------------
+
+// GetPipelineEvents get all pipelines with triggers and their linked trigger events
+func (s *kvStore) GetPipelineEvents(ctx context.Context) (map[string][]string, error) {
+	pipelines := make(map[string][]string)
+	err := s.db.view(func(tx kvTx) error {
+		keys, err := tx.keys(getPipelineKey("*"))
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			events, err := tx.getMembers(key)
+			if err != nil {
+				return err
+			}
+			if len(events) > 0 {
+				pipelines[trimKeyPrefix(key, "pipeline")] = events
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.WithFields(getContextLogFields(ctx)).WithError(err).Error("failed to get pipelines")
+		return nil, err
+	}
+	return pipelines, nil
+}
+
+// DeletePipelineTriggers unlink trigger events from pipeline, without Codefresh pipeline check
+// skip triggers deleted meanwhile and triggers of events deleted or not matching context account
+func (s *kvStore) DeletePipelineTriggers(ctx context.Context, pipeline string, events []string) ([]string, error) {
+	account := getAccount(ctx)
+	var deleted []string
+	err := s.db.update(func(tx kvTx) error {
+		deleted = nil
+		for _, event := range events {
+			stale, err := isStaleTrigger(tx, account, event, pipeline)
+			if err != nil {
+				return err
+			}
+			if !stale {
+				continue
+			}
+			if err = tx.removeMember(getTriggerKey("-", event), pipeline); err != nil {
+				return err
+			}
+			if err = tx.removeMember(getPipelineKey(pipeline), event); err != nil {
+				return err
+			}
+			if err = tx.removeMember(getPausedKey("-", event), pipeline); err != nil {
+				return err
+			}
+			if err = tx.delete(getFilterKey(event, pipeline)); err != nil {
+				return err
+			}
+			deleted = append(deleted, event)
+		}
+		return nil
+	})
+	if err != nil {
+		log.WithFields(getContextLogFields(ctx)).WithField("pipeline", pipeline).WithError(err).Error("failed to delete pipeline triggers")
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// trigger still links existing account (or public) event to pipeline
+func isStaleTrigger(tx kvTx, account, event, pipeline string) (bool, error) {
+	if !model.MatchAccount(account, event) && !model.MatchPublicAccount(event) {
+		return false, nil
+	}
+	exists, err := tx.exists(getEventKey("-", event))
+	if err != nil || !exists {
+		return false, err
+	}
+	pipelines, err := tx.getMembers(getTriggerKey("-", event))
+	if err != nil {
+		return false, err
+	}
+	return containsString(pipelines, pipeline), nil
+}
+
+//-------------------------- RunHistoryStore Interface -------------------------
+
+// SaveRun store run record, removing account records older than retention
+func (s *kvStore) SaveRun(ctx context.Context, record *model.RunRecord, retention time.Duration) error {
+	fields, err := runRecordToFields(record)
+	if err != nil {
+		return err
+	}
+	bound := getIDBound(time.Now().Add(-retention))
+	err = s.db.update(func(tx kvTx) error {
+		if err := tx.setHash(getRunKey(record.ID), fields); err != nil {
+			return err
+		}
+		if err := tx.addMember(getRunsKey(record.Account), record.ID); err != nil {
+			return err
+		}
+		ids, err := tx.getMembers(getRunsKey(record.Account))
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if id >= bound {
+				break
+			}
+			if err = tx.removeMember(getRunsKey(record.Account), id); err != nil {
+				return err
+			}
+			if err = tx.delete(getRunKey(id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.WithFields(getContextLogFields(ctx)).WithField("run", record.ID).WithError(err).Error("failed to store run record")
+	}
+	return err
+}
+
+// GetRuns list account run records matching filter, latest first
+func (s *kvStore) GetRuns(ctx context.Context, filter model.RunFilter) ([]model.RunRecord, error) {
+	records := make([]model.RunRecord, 0)
+	err := s.db.view(func(tx kvTx) error {
+		ids, err := tx.getMembers(getRunsKey(filter.Account))
+		if err != nil {
+			return err
+		}
+		for _, id := range filterIDs(ids, filter.From, filter.To) {
+			fields, err := tx.getHash(getRunKey(id))
+			if err != nil {
+				return err
+			}
+			var record model.RunRecord
+			if err = json.Unmarshal([]byte(fields["run"]), &record); err != nil {
+				return err
+			}
+			if !filter.Match(&record) {
+				continue
+			}
+			records = append(records, record)
+			if filter.Limit > 0 && len(records) == filter.Limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.WithFields(getContextLogFields(ctx)).WithField("account", filter.Account).WithError(err).Error("failed to get run records")
+		return nil, err
+	}
+	return records, nil
+}
+
+// GetRun get run record; record of another account is not found
+func (s *kvStore) GetRun(ctx context.Context, id string) (*model.RunRecord, error) {
+	var fields map[string]string
+	err := s.db.view(func(tx kvTx) error {
+		var err error
+		fields, err = tx.getHash(getRunKey(id))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fieldsToRunRecord(ctx, fields)
+}
+
+//-------------------------- DeliveryStore Interface -------------------------
+
+// AddDelivery remember delivery for TTL; returns first delivery (nil when added) if delivery ID was already seen
+func (s *kvStore) AddDelivery(ctx context.Context, delivery *model.Delivery, ttl time.Duration) (*model.Delivery, error) {
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		return nil, err
+	}
+	var first *model.Delivery
+	err = s.db.update(func(tx kvTx) error {
+		key := getDeliveriesKey(delivery.Event)
+		fields, err := tx.getHash(key)
+		if err != nil {
+			return err
+		}
+		// keep deliveries not expired yet
+		now := time.Now()
+		current := make(map[string]string, len(fields)+1)
+		for id, value := range fields {
+			var d model.Delivery
+			if err = json.Unmarshal([]byte(value), &d); err != nil || !now.Before(d.Expires) {
+				continue
+			}
+			if id == delivery.ID {
+				first = &d
+			}
+			current[id] = value
+		}
+		if first != nil {
+			return nil
+		}
+		current[delivery.ID] = string(data)
+		if len(current) < len(fields)+1 {
+			// remove expired deliveries
+			if err = tx.delete(key); err != nil {
+				return err
+			}
+		}
+		return tx.setHash(key, current)
+	})
+	if err != nil {
+		log.WithFields(getContextLogFields(ctx)).WithFields(log.Fields{"event": delivery.Event, "delivery": delivery.ID}).WithError(err).Error("failed to add event delivery")
+		return nil, err
+	}
+	return first, nil
+}
+
+// RemoveDelivery forget delivery ID, if it was delivered with run record ID
+func (s *kvStore) RemoveDelivery(ctx context.Context, event, id, run string) error {
+	err := s.db.update(func(tx kvTx) error {
+		key := getDeliveriesKey(event)
+		fields, err := tx.getHash(key)
+		if err != nil {
+			return err
+		}
+		var d model.Delivery
+		if value, ok := fields[id]; !ok || json.Unmarshal([]byte(value), &d) != nil || d.Run != run {
+			return nil
+		}
+		delete(fields, id)
+		if err = tx.delete(key); err != nil || len(fields) == 0 {
+			return err
+		}
+		return tx.setHash(key, fields)
+	})
+	if err != nil {
+		log.WithFields(getContextLogFields(ctx)).WithFields(log.Fields{"event": event, "delivery": id}).WithError(err).Error("failed to remove event delivery")
+	}
+	return err
+}
+
+//-------------------------- RateLimitStore Interface -------------------------
+
+// GetRateLimits list account rate limits
+func (s *kvStore) GetRateLimits(ctx context.Context, account string) ([]model.RateLimit, error) {
+	var fields map[string]string
+	err := s.db.view(func(tx kvTx) error {
+		var err error
+		fields, err = tx.getHash(getRateLimitKey(account))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fieldsToRateLimits(fields)
+}
+
+// SetRateLimit add or replace rate limit
+func (s *kvStore) SetRateLimit(ctx context.Context, limit model.RateLimit) error {
+	data, err := json.Marshal(limit)
+	if err != nil {
+		return err
+	}
+	return s.db.update(func(tx kvTx) error {
+		// changed limit starts with full bucket
+		if err := tx.delete(getBucketKey(limit)); err != nil {
+			return err
+		}
+		return tx.setHash(getRateLimitKey(limit.Account), map[string]string{getRateLimitField(limit): string(data)})
+	})
+}
+
+// DeleteRateLimit delete rate limit and its token bucket
+func (s *kvStore) DeleteRateLimit(ctx context.Context, limit model.RateLimit) error {
+	return s.db.update(func(tx kvTx) error {
+		key, field := getRateLimitKey(limit.Account), getRateLimitField(limit)
+		fields, err := tx.getHash(key)
+		if err != nil {
+			return err
+		}
+		if _, ok := fields[field]; !ok {
+			return model.ErrRateLimitNotFound
+		}
+		delete(fields, field)
+		if err = tx.delete(key); err != nil {
+			return err
+		}
+		if len(fields) > 0 {
+			if err = tx.setHash(key, fields); err != nil {
+				return err
+			}
+		}
+		return tx.delete(getBucketKey(limit))
+	})
+}
+
+// TakeTokens take token from every rate limit bucket in one transaction, or no token when any token cannot be taken
+func (s *kvStore) TakeTokens(ctx context.Context, limits []model.RateLimit) ([]time.Duration, int, error) {
+	var waits []time.Duration
+	failed := -1
+	err := s.db.update(func(tx kvTx) error {
+		tokens := make([]float64, len(limits))
+		updated := make([]time.Time, len(limits))
+		for i, limit := range limits {
+			fields, err := tx.getHash(getBucketKey(limit))
+			if err != nil {
+				return err
+			}
+			tokens[i], updated[i] = fieldsToBucket(fields)
+		}
+		now := time.Now()
+		var taken []float64
+		taken, waits, failed = takeTokens(limits, tokens, updated, now)
+		if failed >= 0 {
+			return nil
+		}
+		for i, limit := range limits {
+			if err := tx.setHash(getBucketKey(limit), bucketToFields(taken[i], now)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.WithFields(getContextLogFields(ctx)).WithError(err).Error("failed to take rate limit tokens")
+		return nil, -1, err
+	}
+	return waits, failed, nil
+}
+
+// DelayRun keep pipeline run queued by rate limit until it is due
+func (s *kvStore) DelayRun(ctx context.Context, run *model.DelayedRun) error {
+	lg := log.WithFields(getContextLogFields(ctx)).WithFields(log.Fields{"delayed-run": run.ID, "pipeline": run.Pipeline})
+	fields, err := delayedRunToFields(s.cipher, run)
+	if err != nil {
+		lg.WithError(err).Error("failed to encode delayed run")
+		return err
+	}
+	err = s.db.update(func(tx kvTx) error {
+		if err := tx.setHash(getDelayedRunKey(run.ID), fields); err != nil {
+			return err
+		}
+		return tx.addMember(delayQueueKey, run.ID)
+	})
+	if err != nil {
+		lg.WithError(err).Error("failed to delay pipeline run")
+	}
+	return err
+}
+
+// ClaimDelayedRun replace the oldest due run with the same run with claimed ID; nil when no run is due
+func (s *kvStore) ClaimDelayedRun(ctx context.Context, now time.Time, claimed string) (*model.DelayedRun, error) {
+	var id string
+	var fields map[string]string
+	err := s.db.update(func(tx kvTx) error {
+		ids, err := tx.getMembers(delayQueueKey)
+		if err != nil || len(ids) == 0 || ids[0] >= getIDBound(now) {
+			return err
+		}
+		id = ids[0]
+		key := getDelayedRunKey(id)
+		if fields, err = tx.getHash(key); err != nil {
+			return err
+		}
+		if err = tx.removeMember(delayQueueKey, id); err != nil {
+			return err
+		}
+		if err = tx.delete(key); err != nil {
+			return err
+		}
+		if err = tx.setHash(getDelayedRunKey(claimed), fields); err != nil {
+			return err
+		}
+		return tx.addMember(delayQueueKey, claimed)
+	})
+	if err != nil {
+		log.WithFields(getContextLogFields(ctx)).WithField("delayed-run", id).WithError(err).Error("failed to claim delayed run")
+		return nil, err
+	}
+	if id == "" {
+		return nil, nil
+	}
+	return fieldsToDelayedRun(s.cipher, claimed, fields)
+}
+
+// RemoveDelayedRun remove delayed run
+func (s *kvStore) RemoveDelayedRun(ctx context.Context, id string) error {
+	err := s.db.update(func(tx kvTx) error {
+		if err := tx.removeMember(delayQueueKey, id); err != nil {
+			return err
+		}
+		return tx.delete(getDelayedRunKey(id))
+	})
+	if err != nil {
+		log.WithFields(getContextLogFields(ctx)).WithField("delayed-run", id).WithError(err).Error("failed to remove delayed run")
+	}
+	return err
+}
+
+//-------------------------- AuditLog Interface -------------------------
+
+// AppendAudit add entry to account audit log, removing account entries older than retention
+func (s *kvStore) AppendAudit(ctx context.Context, entry *model.AuditEntry, retention time.Duration) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	bound := getIDBound(time.Now().Add(-retention))
+	err = s.db.update(func(tx kvTx) error {
+		if err := tx.setHash(getAuditEntryKey(entry.ID), map[string]string{"entry": string(data)}); err != nil {
+			return err
+		}
+		if err := tx.addMember(getAuditKey(entry.Account), entry.ID); err != nil {
+			return err
+		}
+		ids, err := tx.getMembers(getAuditKey(entry.Account))
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if id >= bound {
+				break
+			}
+			if err = tx.removeMember(getAuditKey(entry.Account), id); err != nil {
+				return err
+			}
+			if err = tx.delete(getAuditEntryKey(id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.WithFields(getContextLogFields(ctx)).WithField("entry", entry.ID).WithError(err).Error("failed to add audit entry")
+	}
+	return err
+}
+
+// GetAudit list account audit entries matching filter, latest first
+func (s *kvStore) GetAudit(ctx context.Context, filter model.AuditFilter) ([]model.AuditEntry, error) {
+	entries := make([]model.AuditEntry, 0)
+	err := s.db.view(func(tx kvTx) error {
+		ids, err := tx.getMembers(getAuditKey(filter.Account))
+		if err != nil {
+			return err
+		}
+		for _, id := range filterIDs(ids, filter.From, filter.To) {
+			fields, err := tx.getHash(getAuditEntryKey(id))
+			if err != nil {
+				return err
+			}
+			var entry model.AuditEntry
+			if err = json.Unmarshal([]byte(fields["entry"]), &entry); err != nil {
+				return err
+			}
+			if !filter.Match(&entry) {
+				continue
+			}
+			entries = append(entries, entry)
+			if filter.Limit > 0 && len(entries) == filter.Limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.WithFields(getContextLogFields(ctx)).WithField("account", filter.Account).WithError(err).Error("failed to get audit entries")
+		return nil, err
+	}
+	return entries, nil
+}
+
+//-------------------------- Indexer Interface -------------------------
+
+// Reindex add all existing trigger events to account and type indexes
+func (s *kvStore) Reindex(ctx context.Context) (int, error) {
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.Debug("rebuilding trigger event indexes")
+
+	var count int
+	err := s.db.update(func(tx kvTx) error {
+		keys, err := tx.keys("event:*")
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			fields, err := tx.getHash(key)
+			if err != nil {
+				return err
+			}
+			uri := strings.TrimPrefix(key, "event:")
+			if err := tx.addMember(getAccountIndexKey(fields["account"]), uri); err != nil {
+				return err
+			}
+			if err := tx.addMember(getTypeIndexKey(fields["type"], fields["kind"]), uri); err != nil {
+				return err
+			}
+		}
+		count = len(keys)
+		return nil
+	})
+	if err != nil {
+		lg.WithError(err).Error("failed to rebuild trigger event indexes")
+		return 0, err
+	}
+	lg.WithField("events", count).Debug("trigger event indexes rebuilt")
+	return count, nil
+}
+
+// rewrite legacy filter keys with current key encoding (see RedisStore.encodeFilterKeys)
+func (s *kvStore) encodeFilterKeys(ctx context.Context) (int, error) {
+	count := 0
+	err := s.db.update(func(tx kvTx) error {
+		keys, err := tx.keys(getPrefixKey("trigger", "*"))
+		if err != nil {
+			return err
+		}
+		legacy := make(map[string]map[string]string)
+		filters := make(map[string]map[string]string)
+		for _, key := range keys {
+			event := strings.TrimPrefix(key, "trigger:")
+			pipelines, err := tx.getMembers(key)
+			if err != nil {
+				return err
+			}
+			for _, pipeline := range pipelines {
+				legacyKey := getLegacyFilterKey(event, pipeline)
+				if _, ok := legacy[legacyKey]; !ok {
+					if legacy[legacyKey], err = tx.getHash(legacyKey); err != nil {
+						return err
+					}
+				}
+				if len(legacy[legacyKey]) > 0 {
+					filters[getFilterKey(event, pipeline)] = legacy[legacyKey]
+				}
+			}
+		}
+		for key, fields := range filters {
+			if err := tx.setHash(key, fields); err != nil {
+				return err
+			}
+		}
+		for key, fields := range legacy {
+			if _, ok := filters[key]; ok || len(fields) == 0 {
+				continue
+			}
+			if err := tx.delete(key); err != nil {
+				return err
+			}
+		}
+		count = len(filters)
+		return nil
+	})
+	if err != nil {
+		log.WithFields(getContextLogFields(ctx)).WithError(err).Error("failed to rewrite trigger filter keys")
+		return 0, err
+	}
+	return count, nil
+}
+
+//-------------------------- StoreChecker Interface -------------------------
+
+// load all trigger events, triggers, pipelines, paused triggers, filters and indexes
+func (s *kvStore) loadSnapshot(tx kvTx) (*storeSnapshot, error) {
+	snapshot := newStoreSnapshot()
+	keys, err := tx.keys("event:*")
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		fields, err := tx.getHash(key)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.events[trimKeyPrefix(key, "event")] = map[string]string{"account": fields["account"], "type": fields["type"], "kind": fields["kind"]}
+	}
+	for prefix, sets := range map[string]map[string][]string{"trigger": snapshot.triggers, "pipeline": snapshot.pipelines} {
+		if keys, err = tx.keys(getPrefixKey(prefix, "*")); err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			if sets[trimKeyPrefix(key, prefix)], err = tx.getMembers(key); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, pattern := range []string{"account:*:events", getTypeIndexKey("*", "*")} {
+		if keys, err = tx.keys(pattern); err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			if snapshot.indexes[key], err = tx.getMembers(key); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if keys, err = tx.keys(getPrefixKey("paused", "*")); err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if snapshot.paused[trimKeyPrefix(key, "paused")], err = tx.getMembers(key); err != nil {
+			return nil, err
+		}
+	}
+	snapshot.filters, err = tx.keys(getPrefixKey("filter", "*"))
+	return snapshot, err
+}
+
+// CheckStore check consistency of trigger events, triggers, pipelines, filters and indexes
+// check and repair run inside single transaction
+func (s *kvStore) CheckStore(ctx context.Context, repair bool) (*model.StoreReport, error) {
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.WithField("repair", repair).Debug("checking store consistency")
+
+	var report *model.StoreReport
+	check := func(tx kvTx) error {
+		snapshot, err := s.loadSnapshot(tx)
+		if err != nil {
+			return err
+		}
+		var fixes []*storeFix
+		report, fixes = snapshot.check()
+		if !repair {
+			return nil
+		}
+		for i, fix := range fixes {
+			if fix == nil {
+				continue
+			}
+			err = fix.applyKV(tx)
+			if err != nil {
+				return err
+			}
+			report.Issues[i].Repaired = true
+		}
+		return nil
+	}
+	var err error
+	if repair {
+		err = s.db.update(check)
+	} else {
+		err = s.db.view(check)
+	}
+	if err != nil {
+		lg.WithError(err).Error("failed to check store consistency")
+		return nil, err
+	}
+	return report, nil
+}
+
+//-------------------------- Schema Interface -------------------------
+
+// GetSchemaVersion get stored data model version
+func (s *kvStore) GetSchemaVersion(ctx context.Context) (int, error) {
+	version := 0
+	err := s.db.view(func(tx kvTx) error {
+		fields, err := tx.getHash(schemaKey)
+		if err != nil {
+			return err
+		}
+		if v, ok := fields["version"]; ok {
+			version, err = strconv.Atoi(v)
+			return err
+		}
+		// no schema version: empty store, or data stored before versioning was introduced
+		keys, err := tx.keys("*")
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			version = SchemaVersion
+		}
+		return nil
+	})
+	if err != nil {
+		log.WithFields(getContextLogFields(ctx)).WithError(err).Error("failed to get schema version")
+		return 0, err
+	}
+	return version, nil
+}
+
+// SetSchemaVersion store data model version
+func (s *kvStore) SetSchemaVersion(ctx context.Context, version int) error {
+	err := s.db.update(func(tx kvTx) error {
+		return tx.setHash(schemaKey, map[string]string{"version": strconv.Itoa(version)})
+	})
+	if err != nil {
+		log.WithFields(getContextLogFields(ctx)).WithError(err).Error("failed to set schema version")
+	}
+	return err
+}
@@ -0,0 +1,221 @@
+package backend
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/codefresh-io/hermes/pkg/codefresh"
+	"github.com/codefresh-io/hermes/pkg/provider"
+)
+
+type (
+	// memoryDB thread-safe in-memory key/value database
+	memoryDB struct {
+		sync.RWMutex
+		hashes map[string]map[string]string
+		sets   map[string]map[string]struct{}
+	}
+
+	// memoryTx in-memory transaction; keeps original values of modified keys for rollback
+	memoryTx struct {
+		db       *memoryDB
+		writable bool
+		undo     map[string]memorySnapshot
+	}
+
+	// memorySnapshot original key value (nil if key did not exist)
+	memorySnapshot struct {
+		hash map[string]string
+		set  map[string]struct{}
+	}
+
+	// MemoryStore thread-safe in-memory storage backend
+	// all data is lost on process exit; use for local runs and tests
+	MemoryStore struct {
+		kvStore
+	}
+)
+
+// errReadOnlyTx error when trying to modify data inside read-only transaction
+var errReadOnlyTx = errors.New("read-only transaction")
+
+func init() {
+	RegisterStore("memory", func(config StoreConfig, pipelineSvc codefresh.PipelineService, eventProvider provider.EventProvider) (Store, error) {
+		cipher, err := newSecretCipher(config)
+		if err != nil {
+			return nil, err
+		}
+		store := NewMemoryStore(pipelineSvc, eventProvider)
+		store.cipher = cipher
+		return store, nil
+	})
+}
+
+// NewMemoryStore create new in-memory storage backend
+func NewMemoryStore(pipelineSvc codefresh.PipelineService, eventProvider provider.EventProvider) *MemoryStore {
+	db := &memoryDB{
+		hashes: make(map[string]map[string]string),
+		sets:   make(map[string]map[string]struct{}),
+	}
+	return &MemoryStore{kvStore{db: db, pipelineSvc: pipelineSvc, eventProvider: eventProvider}}
+}
+
+// Ping in-memory store is always available
+func (m *MemoryStore) Ping() (string, error) {
+	return "PONG", nil
+}
+
+func (db *memoryDB) view(fn func(tx kvTx) error) error {
+	db.RLock()
+	defer db.RUnlock()
+	return fn(&memoryTx{db: db})
+}
+
+func (db *memoryDB) update(fn func(tx kvTx) error) error {
+	db.Lock()
+	defer db.Unlock()
+	tx := &memoryTx{db: db, writable: true, undo: make(map[string]memorySnapshot)}
+	if err := fn(tx); err != nil {
+		tx.rollback()
+		return err
+	}
+	return nil
+}
+
+// save original key value before first modification inside transaction
+func (tx *memoryTx) snapshot(key string) error {
+	if !tx.writable {
+		return errReadOnlyTx
+	}
+	if _, ok := tx.undo[key]; ok {
+		return nil
+	}
+	var s memorySnapshot
+	if h, ok := tx.db.hashes[key]; ok {
+		s.hash = make(map[string]string, len(h))
+		for k, v := range h {
+			s.hash[k] = v
+		}
+	}
+	if m, ok := tx.db.sets[key]; ok {
+		s.set = make(map[string]struct{}, len(m))
+		for k := range m {
+			s.set[k] = struct{}{}
+		}
+	}
+	tx.undo[key] = s
+	return nil
+}
+
+// restore original values of all modified keys
+func (tx *memoryTx) rollback() {
+	for key, s := range tx.undo {
+		delete(tx.db.hashes, key)
+		delete(tx.db.sets, key)
+		if s.hash != nil {
+			tx.db.hashes[key] = s.hash
+		}
+		if s.set != nil {
+			tx.db.sets[key] = s.set
+		}
+	}
+}
+
+func (tx *memoryTx) exists(key string) (bool, error) {
+	_, hash := tx.db.hashes[key]
+	_, set := tx.db.sets[key]
+	return hash || set, nil
+}
+
+func (tx *memoryTx) keys(pattern string) ([]string, error) {
+	re, err := compileKeyPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0)
+	for k := range tx.db.hashes {
+		if re.MatchString(k) {
+			keys = append(keys, k)
+		}
+	}
+	for k := range tx.db.sets {
+		if re.MatchString(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (tx *memoryTx) getHash(key string) (map[string]string, error) {
+	h := tx.db.hashes[key]
+	fields := make(map[string]string, len(h))
+	for k, v := range h {
+		fields[k] = v
+	}
+	return fields, nil
+}
+
+func (tx *memoryTx) setHash(key string, fields map[string]string) error {
+	if err := tx.snapshot(key); err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	h, ok := tx.db.hashes[key]
+	if !ok {
+		h = make(map[string]string, len(fields))
+		tx.db.hashes[key] = h
+	}
+	for k, v := range fields {
+		h[k] = v
+	}
+	return nil
+}
+
+func (tx *memoryTx) getMembers(key string) ([]string, error) {
+	m := tx.db.sets[key]
+	members := make([]string, 0, len(m))
+	for member := range m {
+		members = append(members, member)
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+func (tx *memoryTx) addMember(key, member string) error {
+	if err := tx.snapshot(key); err != nil {
+		return err
+	}
+	m, ok := tx.db.sets[key]
+	if !ok {
+		m = make(map[string]struct{})
+		tx.db.sets[key] = m
+	}
+	m[member] = struct{}{}
+	return nil
+}
+
+func (tx *memoryTx) removeMember(key, member string) error {
+	if err := tx.snapshot(key); err != nil {
+		return err
+	}
+	if m, ok := tx.db.sets[key]; ok {
+		delete(m, member)
+		if len(m) == 0 {
+			delete(tx.db.sets, key)
+		}
+	}
+	return nil
+}
+
+func (tx *memoryTx) delete(key string) error {
+	if err := tx.snapshot(key); err != nil {
+		return err
+	}
+	delete(tx.db.hashes, key)
+	delete(tx.db.sets, key)
+	return nil
+}
@@ -0,0 +1,166 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SchemaVersion storage backend data model version expected by this binary
+const SchemaVersion = 2
+
+type (
+	// Schema versioned storage backend data model
+	Schema interface {
+		// GetSchemaVersion get stored data model version
+		// returns 0 for data stored before versioning was introduced and SchemaVersion for empty store
+		GetSchemaVersion(ctx context.Context) (int, error)
+		// SetSchemaVersion store data model version
+		SetSchemaVersion(ctx context.Context, version int) error
+	}
+
+	// filterKeyEncoder rewrites legacy (schema version < 2) trigger filter keys
+	filterKeyEncoder interface {
+		encodeFilterKeys(ctx context.Context) (int, error)
+	}
+
+	// Migration single data model upgrade step
+	Migration struct {
+		// Version schema version after migration
+		Version int
+		// Description what migration does
+		Description string
+		// Layout migration changes key layout: server cannot run on store before migration
+		Layout bool
+		// Apply migration; must be idempotent: interrupted migration is applied again on next run
+		Apply func(ctx context.Context, store Store) error
+	}
+)
+
+var (
+	// ErrSchemaTooNew error when stored schema is newer than supported by this binary
+	ErrSchemaTooNew = errors.New("store schema is newer than supported")
+	// ErrSchemaTooOld error when stored schema should be migrated
+	ErrSchemaTooOld = errors.New("store schema is older than expected, run 'hermes store migrate'")
+	// ErrSchemaIncompatible error when stored schema has older key layout: server would read missing keys
+	ErrSchemaIncompatible = errors.New("store schema has incompatible key layout, run 'hermes store migrate'")
+	// ErrSchemaDowngrade error when trying to migrate to older schema
+	ErrSchemaDowngrade = errors.New("schema downgrade is not supported")
+)
+
+// ordered list of migrations: migration N upgrades schema from version N-1 to N
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "add trigger events to account and type indexes",
+		Layout:      true,
+		Apply: func(ctx context.Context, store Store) error {
+			_, err := store.Reindex(ctx)
+			return err
+		},
+	},
+	{
+		Version:     2,
+		Description: "rewrite trigger filter keys with unambiguous key encoding",
+		Layout:      true,
+		Apply: func(ctx context.Context, store Store) error {
+			encoder, ok := store.(filterKeyEncoder)
+			if !ok {
+				return fmt.Errorf("%T does not support filter key encoding", store)
+			}
+			_, err := encoder.encodeFilterKeys(ctx)
+			return err
+		},
+	},
+}
+
+// PendingMigrations returns migrations required to upgrade store schema to version 'to'
+// use SchemaVersion, when 'to' is 0
+func PendingMigrations(ctx context.Context, store Store, to int) (int, []Migration, error) {
+	if to == 0 {
+		to = SchemaVersion
+	}
+	if to > SchemaVersion {
+		return 0, nil, fmt.Errorf("unknown schema version %d, latest is %d", to, SchemaVersion)
+	}
+	current, err := store.GetSchemaVersion(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	if current > SchemaVersion {
+		return current, nil, ErrSchemaTooNew
+	}
+	if to < current {
+		return current, nil, ErrSchemaDowngrade
+	}
+	pending := make([]Migration, 0)
+	for _, m := range migrations {
+		if m.Version > current && m.Version <= to {
+			pending = append(pending, m)
+		}
+	}
+	return current, pending, nil
+}
+
+// Migrate upgrade store schema to version 'to' (SchemaVersion, when 0)
+// progress is called before each migration; schema version is updated after each successful migration
+func Migrate(ctx context.Context, store Store, to int, progress func(m Migration)) error {
+	current, pending, err := PendingMigrations(ctx, store, to)
+	if err != nil {
+		return err
+	}
+	lg := log.WithFields(getContextLogFields(ctx))
+	for _, m := range pending {
+		if progress != nil {
+			progress(m)
+		}
+		lg.WithFields(log.Fields{
+			"version":     m.Version,
+			"description": m.Description,
+		}).Debug("applying migration")
+		if err := m.Apply(ctx, store); err != nil {
+			lg.WithError(err).WithField("version", m.Version).Error("failed to apply migration")
+			return err
+		}
+		if err := store.SetSchemaVersion(ctx, m.Version); err != nil {
+			return err
+		}
+		current = m.Version
+	}
+	// store version for empty (new) store
+	if len(pending) == 0 && current == SchemaVersion {
+		return store.SetSchemaVersion(ctx, current)
+	}
+	return nil
+}
+
+// CheckSchema check stored schema version matches binary schema version
+// returns ErrSchemaTooNew or ErrSchemaTooOld on mismatch, and ErrSchemaIncompatible when any pending migration changes
+// key layout; new empty store is marked with the current version
+func CheckSchema(ctx context.Context, store Store) error {
+	current, err := store.GetSchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	lg := log.WithFields(getContextLogFields(ctx)).WithFields(log.Fields{
+		"stored":   current,
+		"expected": SchemaVersion,
+	})
+	switch {
+	case current > SchemaVersion:
+		lg.Error(ErrSchemaTooNew)
+		return ErrSchemaTooNew
+	case current < SchemaVersion:
+		for _, m := range migrations {
+			if m.Version > current && m.Layout {
+				lg.WithField("migration", m.Version).Error(ErrSchemaIncompatible)
+				return ErrSchemaIncompatible
+			}
+		}
+		lg.Warn(ErrSchemaTooOld)
+		return ErrSchemaTooOld
+	}
+	return store.SetSchemaVersion(ctx, current)
+}
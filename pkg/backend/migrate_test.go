@@ -0,0 +1,163 @@
+package backend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+)
+
+// create memory store with trigger event stored before versioning and indexes were introduced
+func newLegacyStore(t *testing.T) (*MemoryStore, string) {
+	store := NewMemoryStore(nil, nil)
+	uri := "registry:dockerhub:repo:" + model.CalculateAccountHash("A")
+	err := store.db.update(func(tx kvTx) error {
+		return tx.setHash(getEventKey("A", uri), map[string]string{"type": "registry", "kind": "dockerhub", "account": "A", "secret": "s"})
+	})
+	if err != nil {
+		t.Fatalf("failed to create legacy store: %v", err)
+	}
+	return store, uri
+}
+
+func Test_migrations(t *testing.T) {
+	// migrations are ordered and cover all versions
+	for i, m := range migrations {
+		assert.Equal(t, i+1, m.Version)
+		assert.NotEmpty(t, m.Description)
+		assert.NotNil(t, m.Apply)
+	}
+	assert.Equal(t, SchemaVersion, len(migrations))
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	store, uri := newLegacyStore(t)
+	version, err := store.GetSchemaVersion(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, version)
+	// unindexed store: listings would be empty
+	assert.Equal(t, ErrSchemaIncompatible, CheckSchema(ctx, store))
+
+	// dry run: list pending migrations without changes
+	current, pending, err := PendingMigrations(ctx, store, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, current)
+	assert.Equal(t, SchemaVersion, len(pending))
+	events, err := store.GetEvents(storeContext("A", false), "", "", "")
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+
+	// apply migrations
+	var applied []int
+	assert.NoError(t, Migrate(ctx, store, 0, func(m Migration) { applied = append(applied, m.Version) }))
+	assert.Equal(t, []int{1, 2}, applied)
+	version, err = store.GetSchemaVersion(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, SchemaVersion, version)
+	assert.NoError(t, CheckSchema(ctx, store))
+	events, err = store.GetEvents(storeContext("A", false), "", "", "")
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(events)) {
+		assert.Equal(t, uri, events[0].URI)
+	}
+
+	// nothing to apply second time
+	applied = nil
+	assert.NoError(t, Migrate(ctx, store, 0, func(m Migration) { applied = append(applied, m.Version) }))
+	assert.Empty(t, applied)
+}
+
+func TestMigrate_filterKeys(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(nil, nil)
+	// legacy filter keys of colliding triggers: 'a-b' -> 'c' and 'a' -> 'b-c' share single filter
+	err := store.db.update(func(tx kvTx) error {
+		for _, t := range [][2]string{{"a-b", "c"}, {"a", "b-c"}, {"x", "p"}} {
+			if err := tx.addMember(getTriggerKey("-", t[0]), t[1]); err != nil {
+				return err
+			}
+		}
+		if err := tx.setHash(getLegacyFilterKey("a-b", "c"), map[string]string{"tag": "v1"}); err != nil {
+			return err
+		}
+		return tx.setHash(getLegacyFilterKey("x", "p"), map[string]string{"tag": "v2"})
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, store.SetSchemaVersion(ctx, 1))
+	// legacy filters would not apply: server refuses to start
+	assert.Equal(t, ErrSchemaIncompatible, CheckSchema(ctx, store))
+
+	assert.NoError(t, Migrate(ctx, store, 0, nil))
+	assert.NoError(t, CheckSchema(ctx, store))
+	err = store.db.view(func(tx kvTx) error {
+		keys, err := tx.keys("filter:*")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{getFilterKey("a", "b-c"), getFilterKey("x", "p"), getFilterKey("a-b", "c")}, keys)
+		fields, err := tx.getHash(getFilterKey("x", "p"))
+		assert.Equal(t, map[string]string{"tag": "v2"}, fields)
+		return err
+	})
+	assert.NoError(t, err)
+
+	// migration step is idempotent
+	count, err := store.encodeFilterKeys(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestRedisStore_encodeFilterKeys(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t, "redis", nil, nil).(*RedisStore)
+	con := store.redisPool.GetConn()
+	for _, cmd := range [][]interface{}{
+		{"ZADD", getTriggerKey("-", "a-b"), 0, "c"},
+		{"ZADD", getTriggerKey("-", "a"), 0, "b-c"},
+		{"HSET", getLegacyFilterKey("a-b", "c"), "tag", "v1"},
+	} {
+		if _, err := con.Do(cmd[0].(string), cmd[1:]...); err != nil {
+			t.Fatalf("failed to create legacy data: %v", err)
+		}
+	}
+	count, err := store.encodeFilterKeys(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	keys, err := redis.Strings(con.Do("KEYS", "filter:*"))
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{getFilterKey("a-b", "c"), getFilterKey("a", "b-c")}, keys)
+	fields, err := redis.StringMap(con.Do("HGETALL", getFilterKey("a", "b-c")))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"tag": "v1"}, fields)
+	// nothing to rewrite second time
+	count, err = store.encodeFilterKeys(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestMigrate_errors(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newLegacyStore(t)
+	// unknown target version
+	_, _, err := PendingMigrations(ctx, store, SchemaVersion+1)
+	assert.Error(t, err)
+	// newer schema
+	assert.NoError(t, store.SetSchemaVersion(ctx, SchemaVersion+1))
+	assert.Equal(t, ErrSchemaTooNew, Migrate(ctx, store, 0, nil))
+	assert.Equal(t, ErrSchemaTooNew, CheckSchema(ctx, store))
+}
+
+func TestCheckSchema_empty(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(nil, nil)
+	// new empty store is marked with current schema version
+	assert.NoError(t, CheckSchema(ctx, store))
+	err := store.db.view(func(tx kvTx) error {
+		fields, err := tx.getHash(schemaKey)
+		assert.Equal(t, map[string]string{"version": "2"}, fields)
+		return err
+	})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,257 @@
+package backend
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+)
+
+/*  Listing Pages
+
+	Trigger events and triggers are listed in pages with keyset pagination: trigger event URIs are read from
+	indexes and sorted, and only events (or triggers) of requested page are loaded. Page cursor keeps sort
+	order and keys of the last returned item, so pages stay consistent when items are added or removed.
+
+	trigger events sort: uri (default)
+	triggers sort:       event (default), pipeline (loads pipelines of all matching trigger events)
+
+*/
+
+// listing page size
+const (
+	// DefaultPageSize page size when limit is not set
+	DefaultPageSize = 100
+	// MaxPageSize maximum page size
+	MaxPageSize = 1000
+)
+
+// sort fields
+const (
+	sortURI      = "uri"
+	sortEvent    = "event"
+	sortPipeline = "pipeline"
+)
+
+type (
+	// pageCursor decoded page cursor: sort order and sort keys of the last item on previous page
+	pageCursor struct {
+		Sort string   `json:"s"`
+		Keys []string `json:"k"`
+	}
+
+	// pageReader storage backend reader used to load listing page items
+	pageReader interface {
+		// event get trigger event; nil when trigger event does not exist
+		event(uri string) (*model.Event, error)
+		// pipelines get pipelines linked to trigger event
+		pipelines(uri string) ([]string, error)
+		// filters get trigger filters
+		filters(uri, pipeline string) (map[string]string, error)
+		// paused check trigger is paused
+		paused(uri, pipeline string) (bool, error)
+	}
+)
+
+func encodeCursor(order string, keys ...string) string {
+	data, _ := json.Marshal(pageCursor{Sort: order, Keys: keys})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decode cursor created for the same sort order; nil keys for empty cursor (first page)
+func decodeCursor(cursor, order string, n int) ([]string, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, model.ErrInvalidCursor
+	}
+	var c pageCursor
+	if err = json.Unmarshal(data, &c); err != nil || c.Sort != order || len(c.Keys) != n {
+		return nil, model.ErrInvalidCursor
+	}
+	return c.Keys, nil
+}
+
+// page size: default for unset limit, no more than maximum
+func pageLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		return MaxPageSize
+	}
+	return limit
+}
+
+// parse sort order: one of allowed fields (first is default), optionally with "-" prefix for descending order
+// returns field, descending flag and normalized sort order
+func parseSort(order string, allowed ...string) (string, bool, string, error) {
+	desc := strings.HasPrefix(order, "-")
+	field := strings.TrimPrefix(order, "-")
+	if field == "" {
+		field = allowed[0]
+	}
+	if !containsString(allowed, field) {
+		return "", false, "", model.ErrInvalidSort
+	}
+	if desc {
+		return field, desc, "-" + field, nil
+	}
+	return field, desc, field, nil
+}
+
+// a goes after b in sort order
+func sortsAfter(a, b string, desc bool) bool {
+	if desc {
+		return a < b
+	}
+	return a > b
+}
+
+// sort strings removing duplicates
+func sortUnique(list []string, desc bool) []string {
+	sorted := make([]string, 0, len(list))
+	seen := make(map[string]bool, len(list))
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			sorted = append(sorted, s)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sortsAfter(sorted[j], sorted[i], desc) })
+	return sorted
+}
+
+// load page of trigger events from trigger event URIs matching type and kind
+func eventsPage(r pageReader, uris []string, eventType, kind string, opts model.PageOptions) (*model.EventPage, error) {
+	_, desc, order, err := parseSort(opts.Sort, sortURI)
+	if err != nil {
+		return nil, err
+	}
+	after, err := decodeCursor(opts.Cursor, order, 1)
+	if err != nil {
+		return nil, err
+	}
+	uris = sortUnique(uris, desc)
+	start := 0
+	if after != nil {
+		start = sort.Search(len(uris), func(i int) bool { return sortsAfter(uris[i], after[0], desc) })
+	}
+	limit := pageLimit(opts.Limit)
+	page := &model.EventPage{Events: make([]model.Event, 0)}
+	for _, uri := range uris[start:] {
+		if len(page.Events) == limit {
+			page.NextCursor = encodeCursor(order, page.Events[limit-1].URI)
+			break
+		}
+		event, err := r.event(uri)
+		if err != nil {
+			return nil, err
+		}
+		if event != nil && (eventType == "" || event.Type == eventType) && (kind == "" || event.Kind == kind) {
+			page.Events = append(page.Events, *event)
+		}
+	}
+	return page, nil
+}
+
+// load page of triggers of trigger events
+func triggersPage(r pageReader, uris []string, opts model.PageOptions) (*model.TriggerPage, error) {
+	field, desc, order, err := parseSort(opts.Sort, sortEvent, sortPipeline)
+	if err != nil {
+		return nil, err
+	}
+	after, err := decodeCursor(opts.Cursor, order, 2)
+	if err != nil {
+		return nil, err
+	}
+	uris = sortUnique(uris, desc)
+	limit := pageLimit(opts.Limit)
+	page := &model.TriggerPage{Triggers: make([]model.Trigger, 0)}
+	// add trigger to page; false when page is full (next cursor is set)
+	add := func(uri, pipeline string) (bool, error) {
+		if len(page.Triggers) == limit {
+			last := page.Triggers[limit-1]
+			if field == sortPipeline {
+				page.NextCursor = encodeCursor(order, last.Pipeline, last.Event)
+			} else {
+				page.NextCursor = encodeCursor(order, last.Event, last.Pipeline)
+			}
+			return false, nil
+		}
+		filters, err := r.filters(uri, pipeline)
+		if err != nil {
+			return false, err
+		}
+		paused, err := r.paused(uri, pipeline)
+		if err != nil {
+			return false, err
+		}
+		page.Triggers = append(page.Triggers, model.Trigger{Event: uri, Pipeline: pipeline, Filters: filters, Paused: paused})
+		return true, nil
+	}
+
+	if field == sortEvent {
+		// skip trigger events before cursor: load only triggers of page trigger events
+		start := 0
+		if after != nil {
+			start = sort.Search(len(uris), func(i int) bool { return uris[i] == after[0] || sortsAfter(uris[i], after[0], desc) })
+		}
+		for _, uri := range uris[start:] {
+			pipelines, err := r.pipelines(uri)
+			if err != nil {
+				return nil, err
+			}
+			for _, pipeline := range sortUnique(pipelines, desc) {
+				if after != nil && uri == after[0] && !sortsAfter(pipeline, after[1], desc) {
+					continue
+				}
+				ok, err := add(uri, pipeline)
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					return page, nil
+				}
+			}
+		}
+		return page, nil
+	}
+
+	// sort by pipeline: get pipelines of all trigger events
+	type triggerRef struct{ pipeline, event string }
+	var refs []triggerRef
+	for _, uri := range uris {
+		pipelines, err := r.pipelines(uri)
+		if err != nil {
+			return nil, err
+		}
+		for _, pipeline := range pipelines {
+			refs = append(refs, triggerRef{pipeline, uri})
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].pipeline != refs[j].pipeline {
+			return sortsAfter(refs[j].pipeline, refs[i].pipeline, desc)
+		}
+		return sortsAfter(refs[j].event, refs[i].event, desc)
+	})
+	for _, ref := range refs {
+		if after != nil && (ref.pipeline == after[0] && !sortsAfter(ref.event, after[1], desc) ||
+			ref.pipeline != after[0] && !sortsAfter(ref.pipeline, after[0], desc)) {
+			continue
+		}
+		ok, err := add(ref.event, ref.pipeline)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return page, nil
+		}
+	}
+	return page, nil
+}
@@ -0,0 +1,435 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/codefresh-io/hermes/pkg/util"
+	log "github.com/sirupsen/logrus"
+)
+
+/*  Rate Limits
+
+	rate-limit:{account} (Hash)             -> {limit-field}: rate limit (JSON)
+	bucket:{account}:{limit-field} (Hash)   -> tokens, updated: token bucket state; Redis key expires when bucket is full
+
+	delay:runs (Set)                        -> {run-id}: delayed run IDs (ULID with due time), sorted by due time
+	delayed:{run-id} (Hash)                 -> account, event, pipeline, run, secret (encrypted), original, variables
+
+	* limit-field - "account", "event:{event-uri}" or "trigger:{event-uri}:{pipeline}"
+
+*/
+
+// account rate limits key
+func getRateLimitKey(account string) string {
+	return getPrefixKey("rate-limit", account)
+}
+
+// rate limit hash field
+func getRateLimitField(limit model.RateLimit) string {
+	switch limit.Scope() {
+	case model.RateLimitScopeAccount:
+		return model.RateLimitScopeAccount
+	case model.RateLimitScopeEvent:
+		return fmt.Sprintf("%s:%s", model.RateLimitScopeEvent, limit.Event)
+	default:
+		return fmt.Sprintf("%s:%s:%s", model.RateLimitScopeTrigger, limit.Event, limit.Pipeline)
+	}
+}
+
+// rate limit token bucket key
+func getBucketKey(limit model.RateLimit) string {
+	return getPrefixKey("bucket", fmt.Sprintf("%s:%s", limit.Account, getRateLimitField(limit)))
+}
+
+// delayed runs key
+const delayQueueKey = "delay:runs"
+
+// delayed run key
+func getDelayedRunKey(id string) string {
+	return getPrefixKey("delayed", id)
+}
+
+// convert delayed run to stored hash fields; secret is encrypted with store cipher
+func delayedRunToFields(c *secretCipher, run *model.DelayedRun) (map[string]string, error) {
+	variables, err := json.Marshal(run.Data.Variables)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]string{
+		"account":   run.Account,
+		"event":     run.Event,
+		"pipeline":  run.Pipeline,
+		"run":       run.Run,
+		"secret":    run.Data.Secret,
+		"original":  run.Data.Original,
+		"variables": string(variables),
+	}
+	return fields, c.encryptFields(fields)
+}
+
+// convert stored hash fields to delayed run
+func fieldsToDelayedRun(c *secretCipher, id string, fields map[string]string) (*model.DelayedRun, error) {
+	if err := c.decryptFields(fields); err != nil {
+		return nil, err
+	}
+	run := &model.DelayedRun{
+		ID:       id,
+		Account:  fields["account"],
+		Event:    fields["event"],
+		Pipeline: fields["pipeline"],
+		Run:      fields["run"],
+		Data:     model.NormalizedEvent{Secret: fields["secret"], Original: fields["original"]},
+	}
+	if fields["variables"] != "" {
+		if err := json.Unmarshal([]byte(fields["variables"]), &run.Data.Variables); err != nil {
+			return nil, err
+		}
+	}
+	return run, nil
+}
+
+// decode rate limits stored as JSON, sorted by limit field: account, event and trigger limits
+func fieldsToRateLimits(fields map[string]string) ([]model.RateLimit, error) {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	limits := make([]model.RateLimit, 0, len(names))
+	for _, name := range names {
+		var limit model.RateLimit
+		if err := json.Unmarshal([]byte(fields[name]), &limit); err != nil {
+			log.WithField("limit", name).WithError(err).Error("failed to decode rate limit")
+			return nil, err
+		}
+		limits = append(limits, limit)
+	}
+	return limits, nil
+}
+
+// convert stored bucket hash fields to tokens and last update time (zero time for new bucket)
+func fieldsToBucket(fields map[string]string) (float64, time.Time) {
+	tokens, _ := strconv.ParseFloat(fields["tokens"], 64)
+	updated, _ := time.Parse(time.RFC3339Nano, fields["updated"])
+	return tokens, updated
+}
+
+// convert bucket tokens and update time to stored hash fields
+func bucketToFields(tokens float64, updated time.Time) map[string]string {
+	return map[string]string{
+		"tokens":  strconv.FormatFloat(tokens, 'f', -1, 64),
+		"updated": updated.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// take token from bucket refilled since last update; queued run may take token ahead, down to -burst tokens
+// returns bucket tokens, wait until taken token is available (or until token is available, when not taken) and
+// false when token cannot be taken
+func takeToken(limit model.RateLimit, tokens float64, updated, now time.Time, queue bool) (float64, time.Duration, bool) {
+	perSecond := limit.Rate / 60
+	burst := float64(limit.Burst)
+	if updated.IsZero() {
+		tokens = burst
+	} else {
+		tokens = math.Min(burst, tokens+now.Sub(updated).Seconds()*perSecond)
+	}
+	if tokens >= 1 {
+		return tokens - 1, 0, true
+	}
+	if queue && tokens-1 >= -burst {
+		tokens--
+		return tokens, time.Duration(-tokens / perSecond * float64(time.Second)), true
+	}
+	return tokens, time.Duration((1 - tokens) / perSecond * float64(time.Second)), false
+}
+
+// take token from every limit bucket, or no token when token of any limit cannot be taken; queue action limit may take
+// token ahead (see takeToken); returns buckets tokens, wait of every limit and index of first limit with token that
+// cannot be taken (-1: all tokens taken)
+func takeTokens(limits []model.RateLimit, tokens []float64, updated []time.Time, now time.Time) ([]float64, []time.Duration, int) {
+	taken := make([]float64, len(limits))
+	waits := make([]time.Duration, len(limits))
+	failed := -1
+	for i, limit := range limits {
+		var ok bool
+		taken[i], waits[i], ok = takeToken(limit, tokens[i], updated[i], now, limit.Action == model.RateLimitQueue)
+		if !ok && failed < 0 {
+			failed = i
+		}
+	}
+	return taken, waits, failed
+}
+
+// time until bucket is full: idle bucket state can be removed
+func bucketTTL(limit model.RateLimit, tokens float64) time.Duration {
+	return time.Duration((float64(limit.Burst)-tokens)/(limit.Rate/60)*float64(time.Second)) + time.Second
+}
+
+// delayedRunClaimTTL time a claimed delayed run is kept: run claimed by stopped replica is run again after it
+const delayedRunClaimTTL = 5 * time.Minute
+
+// RateLimiter limits pipeline runs with token bucket rate limits per account, trigger event and trigger;
+// buckets and delayed runs are kept in store, so limits hold and delayed runs are run across replicas and restarts
+type RateLimiter struct {
+	// run counters (first: 64-bit aligned for atomic access)
+	dropped  int64
+	queued   int64
+	rejected int64
+	store    Store
+	runner   model.Runner
+	history  model.RunHistory
+}
+
+// NewRateLimiter create new rate limiter; delayed runs are run with runner and recorded in run history
+func NewRateLimiter(store Store, runner model.Runner, history model.RunHistory) *RateLimiter {
+	return &RateLimiter{store: store, runner: runner, history: history}
+}
+
+// RateLimitStats number of pipeline runs dropped, queued and rejected by rate limits since start
+func (l *RateLimiter) RateLimitStats() model.RateLimitStats {
+	return model.RateLimitStats{
+		Dropped:  atomic.LoadInt64(&l.dropped),
+		Queued:   atomic.LoadInt64(&l.queued),
+		Rejected: atomic.LoadInt64(&l.rejected),
+	}
+}
+
+// count limited pipeline run by applied action
+func (l *RateLimiter) count(limited model.LimitedPipeline) {
+	switch limited.Action {
+	case model.RateLimitDrop:
+		atomic.AddInt64(&l.dropped, 1)
+	case model.RateLimitQueue:
+		atomic.AddInt64(&l.queued, 1)
+	case model.RateLimitReject:
+		atomic.AddInt64(&l.rejected, 1)
+	}
+}
+
+// Take take token from every rate limit bucket of every pipeline run: trigger, trigger event and account buckets
+// pipeline run exceeding any limit is dropped, queued (delayed) or rejected by exceeded limit action
+func (l *RateLimiter) Take(ctx context.Context, account, event string, pipelines []string) (*model.RateLimitResult, error) {
+	limits, err := l.store.GetRateLimits(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	result := &model.RateLimitResult{}
+	if len(limits) == 0 {
+		result.Allowed = pipelines
+		return result, nil
+	}
+	for _, pipeline := range pipelines {
+		limited := model.LimitedPipeline{Pipeline: pipeline}
+		allowed := true
+		// take tokens of all pipeline run limits, or none
+		pipelineLimits := getPipelineLimits(limits, event, pipeline)
+		waits, failed, err := l.store.TakeTokens(ctx, pipelineLimits)
+		if err != nil {
+			return nil, err
+		}
+		if failed >= 0 {
+			limit := pipelineLimits[failed]
+			allowed = false
+			limited.Scope, limited.Action = limit.Scope(), limit.Action
+			if limit.Action == model.RateLimitQueue {
+				// queue is full
+				limited.Action = model.RateLimitDrop
+			}
+			if limit.Action == model.RateLimitReject && waits[failed] > result.RetryAfter {
+				result.RetryAfter = waits[failed]
+			}
+		} else {
+			for i, limit := range pipelineLimits {
+				if waits[i] > limited.Delay {
+					limited.Scope, limited.Action, limited.Delay = limit.Scope(), model.RateLimitQueue, waits[i]
+				}
+			}
+		}
+		if allowed && limited.Delay == 0 {
+			result.Allowed = append(result.Allowed, pipeline)
+			continue
+		}
+		log.WithFields(getContextLogFields(ctx)).WithFields(log.Fields{
+			"account":  account,
+			"event":    event,
+			"pipeline": pipeline,
+			"scope":    limited.Scope,
+			"action":   limited.Action,
+			"delay":    limited.Delay,
+		}).Warn("pipeline run exceeds rate limit")
+		l.count(limited)
+		result.Limited = append(result.Limited, limited)
+	}
+	return result, nil
+}
+
+// Delay keep pipeline runs queued by rate limits in store until their delay passes (see RunDelayedRuns)
+func (l *RateLimiter) Delay(ctx context.Context, account, event, run string, limited []model.LimitedPipeline, data model.NormalizedEvent) error {
+	now := time.Now()
+	for _, limit := range limited {
+		if limit.Action != model.RateLimitQueue {
+			continue
+		}
+		// delayed run is ordered by due time
+		id, err := util.GenerateULIDAt(now.Add(limit.Delay))
+		if err != nil {
+			return err
+		}
+		delayed := &model.DelayedRun{ID: id, Account: account, Event: event, Pipeline: limit.Pipeline, Run: run, Data: data}
+		if err = l.store.DelayRun(ctx, delayed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunDue run all delayed runs due now; every run is recorded in run history
+// delayed run is claimed before it is run and removed after: run claimed by stopped replica is run again later
+func (l *RateLimiter) RunDue(ctx context.Context) (int, error) {
+	count := 0
+	for {
+		now := time.Now()
+		claimed, err := util.GenerateULIDAt(now.Add(delayedRunClaimTTL))
+		if err != nil {
+			return count, err
+		}
+		run, err := l.store.ClaimDelayedRun(ctx, now, claimed)
+		if err != nil || run == nil {
+			return count, err
+		}
+		l.run(ctx, run)
+		if err = l.store.RemoveDelayedRun(ctx, run.ID); err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+// run delayed pipeline run; run failure is recorded in run history (delayed run can be replayed from there)
+func (l *RateLimiter) run(ctx context.Context, run *model.DelayedRun) {
+	lg := log.WithFields(log.Fields{
+		"account":     run.Account,
+		"event":       run.Event,
+		"pipeline":    run.Pipeline,
+		"delayed-run": run.ID,
+	})
+	record := &model.RunRecord{
+		Account:     run.Account,
+		Event:       run.Event,
+		Received:    time.Now().UTC(),
+		SecretValid: true,
+		Matched:     []string{run.Pipeline},
+		Delayed:     run.Run,
+		Data:        &model.NormalizedEvent{Original: run.Data.Original, Variables: run.Data.Variables},
+	}
+	defer func() {
+		if err := l.history.AddRun(ctx, record); err != nil {
+			lg.WithError(err).Error("failed to record run history")
+		}
+	}()
+	// add original payload to variables
+	vars := make(map[string]string)
+	for k, v := range run.Data.Variables {
+		vars[k] = v
+	}
+	vars["EVENT_PAYLOAD"] = run.Data.Original
+	runs, err := l.runner.Run(run.Account, record.Matched, vars, run.Data)
+	if err != nil {
+		record.Status, record.Error = model.RunStatusFailed, err.Error()
+		lg.WithError(err).Error("failed to run pipeline queued by rate limit")
+		return
+	}
+	record.Status = model.RunStatusStarted
+	record.Runs = model.PipelineRunRecords(record.Matched, runs)
+	lg.WithField("runs", runs).Info("pipeline queued by rate limit is running")
+}
+
+// RunDelayedRuns run due delayed runs every interval, until context is canceled; every replica runs delayed runs,
+// each delayed run is claimed by one replica
+func RunDelayedRuns(ctx context.Context, l *RateLimiter, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := l.RunDue(ctx); err != nil {
+				log.WithError(err).Error("failed to run delayed pipeline runs")
+			}
+		}
+	}
+}
+
+// apply rate limits to pipeline runs of run record, like /run does: queued runs are delayed (see RateLimiter.Delay)
+// returns pipelines to run now, or model.ErrRateLimitExceeded when runs are rejected; rate limits check failure is
+// logged and all pipelines are run
+func limitRuns(ctx context.Context, limiter model.RateLimiter, record *model.RunRecord, pipelines []string, data model.NormalizedEvent) ([]string, error) {
+	limits, err := limiter.Take(ctx, record.Account, record.Event, pipelines)
+	if err != nil {
+		log.WithFields(getContextLogFields(ctx)).WithError(err).Error("failed to check rate limits")
+		return pipelines, nil
+	}
+	if len(limits.Limited) == 0 {
+		return pipelines, nil
+	}
+	record.Limited = limits.Limited
+	if limits.Rejected() {
+		return nil, model.ErrRateLimitExceeded
+	}
+	if err = limiter.Delay(ctx, record.Account, record.Event, record.ID, limits.Limited, data); err != nil {
+		return nil, err
+	}
+	return limits.Allowed, nil
+}
+
+// rate limits of pipeline run, most specific first: trigger, trigger event and account limits
+func getPipelineLimits(limits []model.RateLimit, event, pipeline string) []model.RateLimit {
+	var matched []model.RateLimit
+	for _, scope := range []string{model.RateLimitScopeTrigger, model.RateLimitScopeEvent, model.RateLimitScopeAccount} {
+		for _, limit := range limits {
+			if limit.Scope() != scope || (limit.Event != "" && limit.Event != event) || (limit.Pipeline != "" && limit.Pipeline != pipeline) {
+				continue
+			}
+			matched = append(matched, limit)
+		}
+	}
+	return matched
+}
+
+// GetLimits list account rate limits
+func (l *RateLimiter) GetLimits(ctx context.Context, account string) ([]model.RateLimit, error) {
+	return l.store.GetRateLimits(ctx, account)
+}
+
+// SetLimit add or replace rate limit; burst defaults to rate (at least 1) and action to drop
+func (l *RateLimiter) SetLimit(ctx context.Context, limit model.RateLimit) error {
+	if limit.Burst == 0 {
+		limit.Burst = int(math.Max(1, math.Ceil(limit.Rate)))
+	}
+	if limit.Action == "" {
+		limit.Action = model.RateLimitDrop
+	}
+	if limit.Account == "" || (limit.Pipeline != "" && limit.Event == "") || limit.Rate <= 0 || limit.Burst < 0 {
+		return model.ErrInvalidRateLimit
+	}
+	switch limit.Action {
+	case model.RateLimitDrop, model.RateLimitQueue, model.RateLimitReject:
+	default:
+		return model.ErrInvalidRateLimit
+	}
+	return l.store.SetRateLimit(ctx, limit)
+}
+
+// DeleteLimit delete rate limit and its token bucket
+func (l *RateLimiter) DeleteLimit(ctx context.Context, limit model.RateLimit) error {
+	return l.store.DeleteRateLimit(ctx, limit)
+}
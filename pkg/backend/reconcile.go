@@ -0,0 +1,204 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/codefresh-io/hermes/pkg/provider"
+	"github.com/codefresh-io/hermes/pkg/util"
+	log "github.com/sirupsen/logrus"
+)
+
+/*  Reconciliation
+
+	lock:{name} (String)       -> lock owner; expires after lock TTL, extended while job runs (kv stores: Hash with
+	                              owner and expires fields)
+	reconcile:{run-id} (Hash)  -> run: reconciliation run result (JSON); only last reconcileRunsLimit runs are kept
+
+*/
+
+const (
+	// reconcileLock reconciliation lock name
+	reconcileLock = "reconcile"
+	// reconcileLockTTL reconciliation lock expiration: lock of crashed replica is released after TTL
+	reconcileLockTTL = 15 * time.Minute
+	// reconcileRunsLimit number of kept reconciliation runs
+	reconcileRunsLimit = 10
+)
+
+// lock key
+func getLockKey(name string) string {
+	return getPrefixKey("lock", name)
+}
+
+// ErrLockLost error when lock of running job expired or was acquired by another owner: job is stopped
+var ErrLockLost = errors.New("lock expired or acquired by another owner")
+
+// extend lock held by long running job before its next step, so lock does not expire while job runs;
+// ErrLockLost when job does not hold lock anymore
+func extendLock(ctx context.Context, locker Locker, name, owner string, ttl time.Duration) error {
+	extended, err := locker.ExtendLock(ctx, name, owner, ttl)
+	if err != nil {
+		return err
+	}
+	if !extended {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// reconciliation run key
+func getReconcileRunKey(id string) string {
+	return getPrefixKey("reconcile", id)
+}
+
+// decode reconciliation runs stored as JSON, latest first; keys are sorted by run ID
+func decodeReconcileRuns(keys []string, values []string) ([]model.ReconcileRun, error) {
+	runs := make([]model.ReconcileRun, 0, len(values))
+	for i := len(values) - 1; i >= 0; i-- {
+		var run model.ReconcileRun
+		if err := json.Unmarshal([]byte(values[i]), &run); err != nil {
+			log.WithField("key", keys[i]).WithError(err).Error("failed to decode reconciliation run")
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// Reconciler refreshes stored trigger event info from event providers
+type Reconciler struct {
+	store         Store
+	eventProvider provider.EventProvider
+	owner         string
+}
+
+// NewReconciler create new reconciler; owner identifies replica holding reconciliation lock
+func NewReconciler(store Store, eventProvider provider.EventProvider, owner string) *Reconciler {
+	return &Reconciler{store: store, eventProvider: eventProvider, owner: owner}
+}
+
+// GetReconcileRuns list recent reconciliation runs, latest first
+func (r *Reconciler) GetReconcileRuns(ctx context.Context) ([]model.ReconcileRun, error) {
+	return r.store.GetReconcileRuns(ctx)
+}
+
+// Reconcile get event info for every stored trigger event from event provider and update changed provider owned fields
+// trigger events with type, that does not match any configured event type, are reported as unmatched
+func (r *Reconciler) Reconcile(ctx context.Context) (*model.ReconcileRun, error) {
+	lg := log.WithFields(getContextLogFields(ctx)).WithField("owner", r.owner)
+	locked, err := r.store.AcquireLock(ctx, reconcileLock, r.owner, reconcileLockTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		lg.Debug("reconciliation is running on another replica")
+		return nil, model.ErrReconcileLocked
+	}
+	defer func() {
+		if err := r.store.ReleaseLock(ctx, reconcileLock, r.owner); err != nil {
+			lg.WithError(err).Error("failed to release reconciliation lock")
+		}
+	}()
+	id, err := util.GenerateMonotonicULID()
+	if err != nil {
+		return nil, err
+	}
+	run := &model.ReconcileRun{ID: id, Owner: r.owner, Started: time.Now().UTC()}
+	lg = lg.WithField("run", id)
+	lg.Debug("reconciling trigger events")
+	// get trigger events of all accounts
+	events, err := r.store.GetEvents(context.WithValue(ctx, model.ContextKeyAccount, "-"), "", "", "")
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		if err = extendLock(ctx, r.store, reconcileLock, r.owner, reconcileLockTTL); err != nil {
+			lg.WithError(err).Error("stopping reconciliation")
+			return nil, err
+		}
+		run.Events++
+		elg := lg.WithField("event-uri", event.URI)
+		if _, err = r.eventProvider.MatchType(event.URI); err != nil {
+			elg.Warn("trigger event does not match any event type")
+			run.Unmatched = append(run.Unmatched, event.URI)
+			continue
+		}
+		info, err := r.eventProvider.GetEventInfo(ctx, event.URI, event.Secret)
+		if err != nil {
+			elg.WithError(err).Error("failed to get event info from event provider")
+			run.Failed = append(run.Failed, model.ReconciledEvent{URI: event.URI, Error: err.Error()})
+			continue
+		}
+		fields := changedEventInfoFields(event.EventInfo, *info)
+		if len(fields) == 0 {
+			continue
+		}
+		// update trigger event in its own account
+		accountCtx := context.WithValue(ctx, model.ContextKeyAccount, event.Account)
+		if _, err = r.store.UpdateEvent(accountCtx, event.URI, model.EventUpdate{Info: info}); err != nil {
+			// trigger event deleted meanwhile is not a failure
+			if err == model.ErrEventNotFound || err == model.ErrTriggerNotFound {
+				continue
+			}
+			run.Failed = append(run.Failed, model.ReconciledEvent{URI: event.URI, Error: err.Error()})
+			continue
+		}
+		elg.WithField("fields", fields).Info("trigger event info refreshed from event provider")
+		run.Updated = append(run.Updated, model.ReconciledEvent{URI: event.URI, Fields: fields})
+	}
+	run.Finished = time.Now().UTC()
+	if err = r.store.SaveReconcileRun(ctx, run); err != nil {
+		return run, err
+	}
+	lg.WithFields(log.Fields{
+		"events":    run.Events,
+		"updated":   len(run.Updated),
+		"unmatched": len(run.Unmatched),
+		"failed":    len(run.Failed),
+	}).Info("trigger events reconciled")
+	return run, nil
+}
+
+// names of changed provider owned event info fields; description is owned by user (see UpdateEvent)
+func changedEventInfoFields(stored, current model.EventInfo) []string {
+	var fields []string
+	if stored.Endpoint != current.Endpoint {
+		fields = append(fields, "endpoint")
+	}
+	if stored.Status != current.Status {
+		fields = append(fields, "status")
+	}
+	if stored.Help != current.Help {
+		fields = append(fields, "help")
+	}
+	return fields
+}
+
+// RunReconciler reconcile trigger events every interval, until context is canceled
+// run is skipped, when any replica ran reconciliation during last half interval
+func RunReconciler(ctx context.Context, r *Reconciler, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			runs, err := r.GetReconcileRuns(ctx)
+			if err != nil {
+				log.WithError(err).Error("failed to get reconciliation runs")
+				continue
+			}
+			if len(runs) > 0 && time.Since(runs[0].Started) < interval/2 {
+				continue
+			}
+			if _, err = r.Reconcile(ctx); err != nil && err != model.ErrReconcileLocked {
+				log.WithError(err).Error("failed to reconcile trigger events")
+			}
+		}
+	}
+}
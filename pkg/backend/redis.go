@@ -25,15 +25,15 @@ package backend
 
 				Filters (Hash)
 
-    +------------------------------------------------------------+
-    |                                                            |
-    | +-----------------------------------+      +-------------+ |
-    | |                                   |      |             | |
-    | | filter:{event-uri}-{pipeline-uid} +------> filter+     | |
-    | |                                   |      |             | |
-    | +-----------------------------------+      +-------------+ |
-    |                                                            |
-	+------------------------------------------------------------+
+    +-------------------------------------------------------------------------+
+    |                                                                         |
+    | +------------------------------------------------+      +-------------+ |
+    | |                                                |      |             | |
+    | | filter:{uri-length}:{event-uri}:{pipeline-uid} +------> filter+     | |
+    | |                                                |      |             | |
+    | +------------------------------------------------+      +-------------+ |
+    |                                                                         |
+	+-------------------------------------------------------------------------+
 
 				Triggers (Sorted Set)
 
@@ -54,6 +54,18 @@ package backend
 	+-------------------------------------------------+
 
 
+				Paused Triggers (Set)
+
+	+-------------------------------------------------+
+	|                                                 |
+	| +---------------------+     +-----------------+ |
+	| |                     |     |                 | |
+	| | paused:{event-uri}  +-----> {pipeline-uid}  | |
+	| |                     |     | ...             | |
+	| +---------------------+     +-----------------+ |
+	|                                                 |
+	+-------------------------------------------------+
+
 				Pipelines (Sorted Set)
 
 	+---------------------------------------------------+
@@ -72,21 +84,46 @@ package backend
 	|                                                   |
 	+---------------------------------------------------+
 
+				Indexes (Set)
+
+	+--------------------------------------------------------+
+	|                                                        |
+	| +-------------------------------+      +-------------+ |
+	| |                               |      |             | |
+	| | account:{account-hash}:events +------> {event-uri} | |
+	| |                               |      | ...         | |
+	| +-------------------------------+      +-------------+ |
+	|                                                        |
+	| +-------------------------------+      +-------------+ |
+	| |                               |      |             | |
+	| | type:{type}:{kind}:events     +------> {event-uri} | |
+	| |                               |      | ...         | |
+	| +-------------------------------+      +-------------+ |
+	|                                                        |
+	+--------------------------------------------------------+
+
+				Schema (Hash)
+
+	meta:schema -> version: data model version (see migrate.go)
+
 	* event-uri     - URI unique identifier for event (specified by event provider)
     * pipeline-uid  - Codefresh pipeline UID
+    * account-hash  - first 12 chars of account SHA1 hash (see model.CalculateAccountHash)
+    * uri-length    - event URI length in bytes: makes filter key unambiguous
 
 */
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
-	"github.com/codefresh-io/go-infra/pkg/logger"
 	"github.com/codefresh-io/hermes/pkg/codefresh"
 	"github.com/codefresh-io/hermes/pkg/model"
 	"github.com/codefresh-io/hermes/pkg/provider"
@@ -96,124 +133,56 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-func getAccount(ctx context.Context) string {
-	v := ctx.Value(model.ContextKeyAccount)
-	if str, ok := v.(string); ok {
-		return str
-	}
-	return model.PublicAccount
-}
-
-func getNewRelicTransaction(c context.Context) newrelic.Transaction {
-	if v := c.Value(model.ContextNewRelicTxn); v != nil {
-		if txn, ok := v.(newrelic.Transaction); ok {
-			return txn
-		}
-	}
-	return nil
-}
-
-// construct Logrus fields from context (requestID and auth context)
-func getContextLogFields(ctx context.Context) log.Fields {
-	fields := make(log.Fields)
-	// get correlation ID
-	if requestID, ok := ctx.Value(model.ContextRequestID).(string); ok {
-		fields[logger.FieldCorrelationID] = requestID
-	}
-	// get NewRelic transaction
-	if txn, ok := ctx.Value(model.ContextNewRelicTxn).(newrelic.Transaction); ok {
-		fields[logger.FieldNewRelicTxn] = txn
-	}
-	// get auth entity
-	if authEntity, ok := ctx.Value(model.ContextAuthEntity).(string); ok {
-		data, err := base64.StdEncoding.DecodeString(authEntity)
-		if err != nil {
-			log.WithError(err).Error("failed to decode authenticated entity")
-			return fields
-		}
-		// auth entity can be user {_id, name} or service {serviceName, type}
-		type _auth struct {
-			// user fields
-			Name string `json:"name,omitempty"`
-			ID   string `json:"_id,omitempty"`
-			// service fields
-			ServiceName string `json:"serviceName,omitempty"`
-			Type        string `json:"type,omitempty"`
-		}
-		auth := new(_auth)
-		err = json.Unmarshal(data, auth)
-		if err != nil {
-			log.WithError(err).Error("failed to load authenticated entity JSON")
-			return fields
-
-		}
-		// set type to user if empty
-		if auth.Type == "" {
-			auth.Type = "user"
-		}
-		// set id to none if empty
-		if auth.ID == "" {
-			auth.ID = "none"
-		}
-		// set name to serviceName
-		if auth.Name == "" {
-			auth.Name = auth.ServiceName
-		}
-		// set log fields
-		fields[logger.FieldAuthName] = auth.Name
-		fields[logger.FieldAuthID] = auth.ID
-		fields[logger.FieldAuthType] = auth.Type
-	}
-	return fields
-}
-
-func getPublicFlag(ctx context.Context) bool {
-	v := ctx.Value(model.ContextKeyPublic)
-	if flag, ok := v.(bool); ok {
-		return flag
-	}
-	return false
-}
-
-// Redis connection pool
-func newPool(server string, port int, db int, password string) *redis.Pool {
-	return &redis.Pool{
-		MaxIdle:     3,
-		IdleTimeout: 240 * time.Second,
-		Dial: func() (redis.Conn, error) {
-			c, err := redis.Dial("tcp", fmt.Sprintf("%s:%d", server, port), redis.DialDatabase(db), redis.DialPassword(password))
-			if err != nil {
-				if err.Error() == "ERR Client sent AUTH, but no password is set" {
-					c, err = redis.Dial("tcp", fmt.Sprintf("%s:%d", server, port), redis.DialDatabase(db))
-				}
-				if err != nil {
-					log.WithError(err).Fatal("failed to connect to the Redis store")
-					return nil, err
-				}
-			}
-			return c, nil
-		},
-	}
-}
-
 // RedisPool redis pool
 type RedisPool struct {
-	pool *redis.Pool
+	// connection wait statistics: number of waits and total wait time (ns); updated concurrently by GetConn
+	// callers, so accessed only with sync/atomic (first fields: 64-bit aligned on 32-bit platforms too)
+	waitCount    int64
+	waitDuration int64
+	pool         *redis.Pool
 }
 
 // RedisPoolService interface for getting Redis connection from pool or test mock
+// caller must close connection to return it to pool
 type RedisPoolService interface {
 	GetConn() redis.Conn
 }
 
 // GetConn helper function: get Redis connection from pool; override in test
+// dial error is returned by connection methods
 func (rp *RedisPool) GetConn() redis.Conn {
-	return rp.pool.Get()
+	stats := rp.pool.Stats()
+	if !rp.pool.Wait || rp.pool.MaxActive == 0 || stats.ActiveCount-stats.IdleCount < rp.pool.MaxActive {
+		return rp.pool.Get()
+	}
+	// pool exhausted: wait for connection
+	start := time.Now()
+	con := rp.pool.Get()
+	atomic.AddInt64(&rp.waitCount, 1)
+	atomic.AddInt64(&rp.waitDuration, int64(time.Since(start)))
+	return con
+}
+
+// Stats get pool statistics
+func (rp *RedisPool) Stats() model.PoolStats {
+	stats := rp.pool.Stats()
+	return model.PoolStats{
+		Active:       stats.ActiveCount,
+		Idle:         stats.IdleCount,
+		WaitCount:    atomic.LoadInt64(&rp.waitCount),
+		WaitDuration: time.Duration(atomic.LoadInt64(&rp.waitDuration)),
+	}
+}
+
+// Close close pool and all its connections
+func (rp *RedisPool) Close() error {
+	return rp.pool.Close()
 }
 
 // RedisEventGetter implements GetEvent used internally in RedisStore
 type RedisEventGetter struct {
 	redisPool RedisPoolService
+	cipher    *secretCipher
 }
 
 // RedisStore in memory trigger map store
@@ -222,6 +191,7 @@ type RedisStore struct {
 	pipelineSvc   codefresh.PipelineService
 	eventProvider provider.EventProvider
 	eventGetter   model.TriggerEventGetter
+	cipher        *secretCipher
 }
 
 // helper function - discard Redis transaction and return error
@@ -234,31 +204,74 @@ func discardOnError(con redis.Conn, err error, log *log.Entry) error {
 	return err
 }
 
-// construct prefixes - for trigger, secret and pipeline
-func getPrefixKey(prefix, id string) string {
-	// set * for empty id
-	if id == "" {
-		id = "*"
-	}
-	if strings.HasPrefix(id, prefix+":") {
-		return id
+// errEventExists trigger event is already stored
+var errEventExists = errors.New("trigger event already exists")
+
+// errNothingToDo returned by transaction check, when there is nothing to change
+var errNothingToDo = errors.New("nothing to do")
+
+// maximum number of optimistic transaction attempts
+const maxTxAttempts = 10
+
+// helper function - run optimistic Redis transaction: WATCH keys, check store state with check and queue
+// writes with queue inside MULTI; retry when watched keys were changed before EXEC
+// check and queue errors are returned as is (transaction is discarded)
+func watchTx(con redis.Conn, keys []string, check, queue func() error, lg *log.Entry) error {
+	for attempt := 0; attempt < maxTxAttempts; attempt++ {
+		if _, err := con.Do("WATCH", redis.Args{}.AddFlat(keys)...); err != nil {
+			lg.WithError(err).Error("failed to watch Redis keys")
+			return err
+		}
+		if err := check(); err != nil {
+			if _, e := con.Do("UNWATCH"); e != nil {
+				lg.WithError(e).Error("failed to unwatch Redis keys")
+			}
+			return err
+		}
+		if _, err := con.Do("MULTI"); err != nil {
+			lg.WithError(err).Error("failed to start Redis transaction")
+			return err
+		}
+		if err := queue(); err != nil {
+			return discardOnError(con, err, lg)
+		}
+		reply, err := con.Do("EXEC")
+		if err != nil {
+			lg.WithError(err).Error("failed to execute transaction")
+			return err
+		}
+		// nil reply: watched key was changed, transaction aborted
+		if reply != nil {
+			return nil
+		}
+		lg.WithField("attempt", attempt+1).Debug("watched keys changed, retrying transaction")
 	}
+	lg.Error("too many concurrent updates, transaction aborted")
+	return model.ErrStoreConflict
+}
+
+// construct key from prefix and id: {prefix}:{id}
+// prefix is always added, so id that looks like a key (starts with 'prefix:') gets its own key
+func getPrefixKey(prefix, id string) string {
 	return fmt.Sprintf("%s:%s", prefix, id)
 }
 
 func getAccountSuffixKey(account, id string) string {
-	suffix := model.CalculateAccountHash(account)
-	// if id already has private ot public account suffix, return it as is
+	// if id already has private or public account suffix (last URI segment), return it as is
 	// also skip adding suffix to id for "-" account
-	if account == "-" || strings.HasSuffix(id, suffix) || strings.HasSuffix(id, model.PublicAccountHash) {
+	if account == "-" || model.MatchAccount(account, id) || model.MatchPublicAccount(id) {
 		return id
 	}
-	return fmt.Sprintf("%s:%s", id, suffix)
+	return fmt.Sprintf("%s:%s", id, model.CalculateAccountHash(account))
 }
 
 func getTriggerKey(account, id string) string {
-	key := getPrefixKey("trigger", id)
-	return getAccountSuffixKey(account, key)
+	return getPrefixKey("trigger", getAccountSuffixKey(account, id))
+}
+
+// paused triggers key: paused:{event-uri} -> set of pipelines
+func getPausedKey(account, id string) string {
+	return getPrefixKey("paused", getAccountSuffixKey(account, id))
 }
 
 // pipeline key is not account aware
@@ -266,26 +279,178 @@ func getPipelineKey(id string) string {
 	return getPrefixKey("pipeline", id)
 }
 
-// filter key is not account aware
+// filter key is not account aware: filter:{event-length}:{event}:{pipeline}
+// event length makes key unambiguous for event URIs and pipelines containing any characters
 func getFilterKey(event, pipeline string) string {
+	return getPrefixKey("filter", fmt.Sprintf("%d:%s:%s", len(event), event, pipeline))
+}
+
+// split filter key into event URI and pipeline
+func parseFilterKey(key string) (string, string, error) {
+	id := strings.TrimPrefix(key, "filter:")
+	i := strings.IndexByte(id, ':')
+	if id == key || i <= 0 {
+		return "", "", fmt.Errorf("invalid filter key: %s", key)
+	}
+	n, err := strconv.Atoi(id[:i])
+	if err != nil || n < 0 || len(id) < i+1+n+1 || id[i+1+n] != ':' {
+		return "", "", fmt.Errorf("invalid filter key: %s", key)
+	}
+	return id[i+1 : i+1+n], id[i+1+n+1:], nil
+}
+
+// old (schema version < 2) filter key: filter:{event}-{pipeline}; ambiguous
+func getLegacyFilterKey(event, pipeline string) string {
 	return getPrefixKey("filter", fmt.Sprintf("%s-%s", event, pipeline))
 }
 
 func getEventKey(account, id string) string {
-	key := getPrefixKey("event", id)
-	return getAccountSuffixKey(account, key)
+	return getPrefixKey("event", getAccountSuffixKey(account, id))
+}
+
+// schema key: keeps data model version
+const schemaKey = "meta:schema"
+
+// event URI pattern (Redis glob-style) for filter; used to scan indexes
+func getEventPattern(account, filter string) string {
+	// set * for empty filter
+	if filter == "" {
+		filter = "*"
+	}
+	return getAccountSuffixKey(account, filter)
+}
+
+// account index: all trigger events of account (public events for public account)
+func getAccountIndexKey(account string) string {
+	return fmt.Sprintf("account:%s:events", model.CalculateAccountHash(account))
+}
+
+// type index: all trigger events of type and kind (for all accounts)
+func getTypeIndexKey(eventType, kind string) string {
+	return fmt.Sprintf("type:%s:%s:events", eventType, kind)
+}
+
+// rotation index: trigger events with previous secret valid during rotation grace period
+const rotationIndexKey = "rotation:events"
+
+// number of elements to return by single SCAN/SSCAN call
+const scanCount = 1000
+
+// iterate SCAN (empty key) or SSCAN (set key) cursor and collect all elements matching pattern
+// SCAN may return the same element more than once; duplicates are removed
+func scanAll(con redis.Conn, key, pattern string) ([]string, error) {
+	command := "SCAN"
+	if key != "" {
+		command = "SSCAN"
+	}
+	seen := make(map[string]bool)
+	result := make([]string, 0)
+	cursor := 0
+	for {
+		args := redis.Args{}
+		if key != "" {
+			args = args.Add(key)
+		}
+		args = args.Add(cursor, "MATCH", pattern, "COUNT", scanCount)
+		values, err := redis.Values(con.Do(command, args...))
+		if err != nil {
+			return nil, err
+		}
+		var page []interface{}
+		if _, err = redis.Scan(values, &cursor, &page); err != nil {
+			return nil, err
+		}
+		members, err := redis.Strings(page, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range members {
+			if !seen[v] {
+				seen[v] = true
+				result = append(result, v)
+			}
+		}
+		if cursor == 0 {
+			return result, nil
+		}
+	}
+}
+
+// find trigger event URIs matching filter
+// use account index; for "-" account use type index (if type and kind are set) or scan all event keys
+func findEvents(con redis.Conn, account, eventType, kind, filter string) ([]string, error) {
+	pattern := getEventPattern(account, filter)
+	if account != "-" {
+		return scanAll(con, getAccountIndexKey(account), pattern)
+	}
+	if eventType != "" && kind != "" {
+		return scanAll(con, getTypeIndexKey(eventType, kind), pattern)
+	}
+	keys, err := scanAll(con, "", getPrefixKey("event", pattern))
+	if err != nil {
+		return nil, err
+	}
+	for i, k := range keys {
+		keys[i] = strings.TrimPrefix(k, "event:")
+	}
+	return keys, nil
+}
+
+// redisPageReader reads listing page items of account over single connection
+type redisPageReader struct {
+	con     redis.Conn
+	account string
+	cipher  *secretCipher
+}
+
+func (p *redisPageReader) event(uri string) (*model.Event, error) {
+	fields, err := redis.StringMap(p.con.Do("HGETALL", getEventKey(p.account, uri)))
+	if err != nil || len(fields) == 0 {
+		return nil, err
+	}
+	if err = p.cipher.decryptFields(fields); err != nil {
+		return nil, err
+	}
+	return model.StringsMapToEvent(uri, fields), nil
+}
+
+func (p *redisPageReader) pipelines(uri string) ([]string, error) {
+	return redis.Strings(p.con.Do("ZRANGE", getTriggerKey(p.account, uri), 0, -1))
+}
+
+func (p *redisPageReader) filters(uri, pipeline string) (map[string]string, error) {
+	return redis.StringMap(p.con.Do("HGETALL", getFilterKey(uri, pipeline)))
+}
+
+func (p *redisPageReader) paused(uri, pipeline string) (bool, error) {
+	return redis.Bool(p.con.Do("SISMEMBER", getPausedKey(p.account, uri), pipeline))
+}
+
+func init() {
+	RegisterStore("redis", func(config StoreConfig, pipelineSvc codefresh.PipelineService, eventProvider provider.EventProvider) (Store, error) {
+		return NewRedisStore(config, pipelineSvc, eventProvider)
+	})
 }
 
 // NewRedisStore create new Redis DB for storing trigger map
-func NewRedisStore(server string, port int, db int, password string, pipelineSvc codefresh.PipelineService, eventProvider provider.EventProvider) *RedisStore {
+func NewRedisStore(config StoreConfig, pipelineSvc codefresh.PipelineService, eventProvider provider.EventProvider) (*RedisStore, error) {
+	cipher, err := newSecretCipher(config)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := newPool(config)
+	if err != nil {
+		return nil, err
+	}
 	r := new(RedisStore)
-	r.redisPool = &RedisPool{newPool(server, port, db, password)}
+	r.cipher = cipher
+	r.redisPool = &RedisPool{pool: pool}
 	r.pipelineSvc = pipelineSvc
 	r.eventProvider = eventProvider
 	// create
-	r.eventGetter = &RedisEventGetter{r.redisPool}
+	r.eventGetter = &RedisEventGetter{redisPool: r.redisPool, cipher: cipher}
 	// return RedisStore
-	return r
+	return r, nil
 }
 
 //-------------------------- TriggerReaderWriter Interface -------------------------
@@ -305,21 +470,28 @@ func (r *RedisStore) GetEventTriggers(ctx context.Context, event string) ([]mode
 	}
 	// get redis connection
 	con := r.redisPool.GetConn()
+	defer con.Close()
 
-	// get trigger keys for events
-	keys, err := redis.Strings(con.Do("KEYS", getTriggerKey(account, event)))
+	// get trigger events for account
+	uris, err := findEvents(con, account, "", "", event)
 	if err != nil {
 		lg.WithField("event", event).WithError(err).Error("failed to find triggers")
 		return nil, err
 	}
-	// get trigger keys for matching public events
-	publicKeys, err := redis.Strings(con.Do("KEYS", getTriggerKey(model.PublicAccount, event)))
-	if err != nil {
-		lg.WithField("event", event).WithError(err).Error("failed to find triggers")
-		return nil, err
+	// get matching public trigger events
+	if account != model.PublicAccount {
+		publicURIs, err := findEvents(con, model.PublicAccount, "", "", event)
+		if err != nil {
+			lg.WithField("event", event).WithError(err).Error("failed to find triggers")
+			return nil, err
+		}
+		uris = util.MergeStrings(publicURIs, uris)
+	}
+	// construct trigger keys
+	keys := make([]string, len(uris))
+	for i, uri := range uris {
+		keys[i] = getTriggerKey(account, uri)
 	}
-	// merge keys
-	keys = util.MergeStrings(publicKeys, keys)
 
 	// Iterate through all trigger keys and get linked pipelines
 	triggers := make([]model.Trigger, 0)
@@ -331,6 +503,11 @@ func (r *RedisStore) GetEventTriggers(ctx context.Context, event string) ([]mode
 		}
 		// for all linked pipelines ...
 		uri := strings.TrimPrefix(k, "trigger:")
+		paused, err := redis.Strings(con.Do("SMEMBERS", getPausedKey(account, uri)))
+		if err != nil {
+			lg.WithField("key", k).WithError(err).Error("failed to get paused pipelines")
+			return nil, err
+		}
 		for _, pipeline := range res {
 			// get filters
 			filters, err := redis.StringMap(con.Do("HGETALL", getFilterKey(uri, pipeline)))
@@ -343,6 +520,7 @@ func (r *RedisStore) GetEventTriggers(ctx context.Context, event string) ([]mode
 				Event:    uri,
 				Pipeline: pipeline,
 				Filters:  filters,
+				Paused:   containsString(paused, pipeline),
 			}
 			triggers = append(triggers, trigger)
 		}
@@ -350,6 +528,47 @@ func (r *RedisStore) GetEventTriggers(ctx context.Context, event string) ([]mode
 	return triggers, nil
 }
 
+// GetEventTriggersPage get page of triggers for specified event
+func (r *RedisStore) GetEventTriggersPage(ctx context.Context, event string, page model.PageOptions) (*model.TriggerPage, error) {
+	account := getAccount(ctx)
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.WithFields(log.Fields{
+		"event":   event,
+		"account": account,
+		"limit":   page.Limit,
+		"sort":    page.Sort,
+	}).Debug("get triggers page for event")
+	// record NewRelic segment
+	if txn := getNewRelicTransaction(ctx); txn != nil {
+		s := newrelic.StartSegment(txn, util.GetCurrentFuncName())
+		defer s.End()
+	}
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+
+	// get trigger events for account and matching public trigger events
+	uris, err := findEvents(con, account, "", "", event)
+	if err != nil {
+		lg.WithField("event", event).WithError(err).Error("failed to find triggers")
+		return nil, err
+	}
+	if account != model.PublicAccount {
+		publicURIs, err := findEvents(con, model.PublicAccount, "", "", event)
+		if err != nil {
+			lg.WithField("event", event).WithError(err).Error("failed to find triggers")
+			return nil, err
+		}
+		uris = append(uris, publicURIs...)
+	}
+	triggers, err := triggersPage(&redisPageReader{con, account, r.cipher}, uris, page)
+	if err != nil {
+		lg.WithError(err).Error("failed to get triggers page")
+		return nil, err
+	}
+	return triggers, nil
+}
+
 // GetPipelineTriggers get list of defined triggers for specified pipeline
 func (r *RedisStore) GetPipelineTriggers(ctx context.Context, pipeline string, withEvent bool) ([]model.Trigger, error) {
 	account := getAccount(ctx)
@@ -365,6 +584,7 @@ func (r *RedisStore) GetPipelineTriggers(ctx context.Context, pipeline string, w
 	}
 	// get redis connection
 	con := r.redisPool.GetConn()
+	defer con.Close()
 	pipelineKey := getPipelineKey(pipeline)
 	n, err := redis.Int(con.Do("EXISTS", pipelineKey))
 	if err != nil {
@@ -377,7 +597,6 @@ func (r *RedisStore) GetPipelineTriggers(ctx context.Context, pipeline string, w
 	}
 
 	// Iterate through all pipelines keys and get trigger events (public) and per account
-	suffix := model.CalculateAccountHash(account)
 	triggers := make([]model.Trigger, 0)
 
 	res, err := redis.Strings(con.Do("ZRANGE", pipelineKey, 0, -1))
@@ -387,32 +606,41 @@ func (r *RedisStore) GetPipelineTriggers(ctx context.Context, pipeline string, w
 	}
 	// for all linked trigger events, check if event belongs to context account of it's a public event
 	for _, event := range res {
-		if strings.HasSuffix(event, suffix) || strings.HasSuffix(event, model.PublicAccountHash) {
+		if model.MatchAccount(account, event) || model.MatchPublicAccount(event) {
 			// get filters
 			filters, err := redis.StringMap(con.Do("HGETALL", getFilterKey(event, pipeline)))
 			if err != nil && err != redis.ErrNil {
 				lg.WithError(err).Error("error getting trigger filter")
 				return nil, err
 			}
+			paused, err := redis.Bool(con.Do("SISMEMBER", getPausedKey(account, event), pipeline))
+			if err != nil {
+				lg.WithError(err).Error("error getting trigger paused state")
+				return nil, err
+			}
 			// populate trigger object
 			trigger := model.Trigger{
 				Event:    event,
 				Pipeline: pipeline,
 				Filters:  filters,
-			}
-			// get event object, if asked
-			if withEvent {
-				eventData, err := r.GetEvent(ctx, event)
-				if err != nil {
-					lg.WithField("event-uri", event).WithError(err).Error("error getting event details")
-					return nil, err
-				}
-				trigger.EventData = *eventData
+				Paused:   paused,
 			}
 			// add trigger to result list
 			triggers = append(triggers, trigger)
 		}
 	}
+	// get event objects, if asked; release connection first: GetEvent uses its own connection
+	if withEvent {
+		con.Close()
+		for i := range triggers {
+			eventData, err := r.GetEvent(ctx, triggers[i].Event)
+			if err != nil {
+				lg.WithField("event-uri", triggers[i].Event).WithError(err).Error("error getting event details")
+				return nil, err
+			}
+			triggers[i].EventData = *eventData
+		}
+	}
 	if len(triggers) == 0 {
 		lg.WithField("pipeline", pipeline).Warn("failed to find triggers for pipeline")
 	}
@@ -435,6 +663,7 @@ func (r *RedisStore) DeleteTrigger(ctx context.Context, event, pipeline string)
 	}
 	// get redis connection
 	con := r.redisPool.GetConn()
+	defer con.Close()
 
 	// check event account match: public or private
 	if !model.MatchPublicAccount(event) && !model.MatchAccount(account, event) {
@@ -474,6 +703,12 @@ func (r *RedisStore) DeleteTrigger(ctx context.Context, event, pipeline string)
 		return discardOnError(con, err, lg)
 	}
 
+	// remove pipeline from paused triggers
+	_, err = con.Do("SREM", getPausedKey(account, event), pipeline)
+	if err != nil {
+		return discardOnError(con, err, lg)
+	}
+
 	// submit transaction
 	_, err = con.Do("EXEC")
 	if err != nil {
@@ -482,21 +717,67 @@ func (r *RedisStore) DeleteTrigger(ctx context.Context, event, pipeline string)
 	return err
 }
 
+// DeleteAllTriggersByPipeline delete all account and public triggers linked to the pipeline in single transaction
 func (r *RedisStore) DeleteAllTriggersByPipeline(ctx context.Context, pipeline string) error {
-	triggers, err := r.GetPipelineTriggers(ctx, pipeline, true)
+	account := getAccount(ctx)
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.WithFields(log.Fields{
+		"pipeline": pipeline,
+		"account":  account,
+	}).Debug("deleting all triggers for pipeline")
+	// record NewRelic segment
+	if txn := getNewRelicTransaction(ctx); txn != nil {
+		s := newrelic.StartSegment(txn, util.GetCurrentFuncName())
+		defer s.End()
+	}
 
-	if err != nil {
+	// check Codefresh pipeline match; ignore all errors beside "no match"
+	if _, err := r.pipelineSvc.GetPipeline(ctx, account, pipeline); err == codefresh.ErrPipelineNoMatch {
+		lg.WithError(err).Error("attempt to remove pipeline from another account")
 		return err
 	}
 
-	for _, trigger := range triggers {
-		err = r.DeleteTrigger(ctx, trigger.Event, pipeline)
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+
+	// delete triggers of trigger events linked to pipeline; retry if trigger is added concurrently
+	pipelineKey := getPipelineKey(pipeline)
+	var events []string
+	return watchTx(con, []string{pipelineKey}, func() error {
+		all, err := redis.Strings(con.Do("ZRANGE", pipelineKey, 0, -1))
 		if err != nil {
-			log.Debug("Can`t delete trigger", err)
+			lg.WithField("key", pipelineKey).WithError(err).Error("failed to get trigger events")
+			return err
 		}
-	}
-
-	return nil
+		events = events[:0]
+		for _, event := range all {
+			if model.MatchAccount(account, event) || model.MatchPublicAccount(event) {
+				events = append(events, event)
+			}
+		}
+		return nil
+	}, func() error {
+		for _, event := range events {
+			// remove pipeline from Triggers
+			if _, err := con.Do("ZREM", getTriggerKey(account, event), pipeline); err != nil {
+				return err
+			}
+			// remove trigger from Pipelines
+			if _, err := con.Do("ZREM", pipelineKey, event); err != nil {
+				return err
+			}
+			// remove trigger filters if any
+			if _, err := con.Do("DEL", getFilterKey(event, pipeline)); err != nil {
+				return err
+			}
+			// remove pipeline from paused triggers
+			if _, err := con.Do("SREM", getPausedKey(account, event), pipeline); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, lg)
 }
 
 // CreateTrigger create trigger: link event <-> multiple pipelines
@@ -516,6 +797,7 @@ func (r *RedisStore) CreateTrigger(ctx context.Context, event, pipeline string,
 	}
 	// get redis connection
 	con := r.redisPool.GetConn()
+	defer con.Close()
 
 	// check event account match: public or private
 	if !model.MatchPublicAccount(event) && !model.MatchAccount(account, event) {
@@ -530,46 +812,49 @@ func (r *RedisStore) CreateTrigger(ctx context.Context, event, pipeline string,
 		return err
 	}
 
-	// start Redis transaction
-	_, err = con.Do("MULTI")
-	if err != nil {
-		lg.WithError(err).Error("failed to start Redis transaction")
-		return err
-	}
-
-	// add trigger to Pipelines
-	_, err = con.Do("ZADD", getPipelineKey(pipeline), 0, event)
-	if err != nil {
-		return discardOnError(con, err, lg)
-	}
-
-	// add pipeline to Triggers
-	_, err = con.Do("ZADD", getTriggerKey(account, event), 0, pipeline)
-	if err != nil {
-		return discardOnError(con, err, lg)
-	}
-
-	// add trigger filters to Filters
-	if filters != nil {
+	// create trigger only for existing trigger event: fail if trigger event is deleted concurrently
+	eventKey := getEventKey(account, event)
+	return watchTx(con, []string{eventKey}, func() error {
+		n, err := redis.Int(con.Do("EXISTS", eventKey))
+		if err != nil {
+			lg.WithError(err).Error("failed to check trigger event existence")
+			return err
+		}
+		if n == 0 {
+			lg.WithField("event", event).Error("trigger event does not exist")
+			return model.ErrEventNotFound
+		}
+		return nil
+	}, func() error {
+		// add trigger to Pipelines
+		if _, err := con.Do("ZADD", getPipelineKey(pipeline), 0, event); err != nil {
+			return err
+		}
+		// add pipeline to Triggers
+		if _, err := con.Do("ZADD", getTriggerKey(account, event), 0, pipeline); err != nil {
+			return err
+		}
+		// add trigger filters to Filters
 		filterKey := getFilterKey(event, pipeline)
 		for k, v := range filters {
-			if _, err = con.Do("HSET", filterKey, k, v); err != nil {
-				return discardOnError(con, err, lg)
+			if _, err := con.Do("HSET", filterKey, k, v); err != nil {
+				return err
 			}
 		}
-	}
-
-	// submit transaction
-	_, err = con.Do("EXEC")
-	if err != nil {
-		lg.WithError(err).Error("failed to execute transaction")
-	}
-	return err
+		return nil
+	}, lg)
 }
 
 // GetTriggerPipelines get pipelines that have trigger defined with filter applied
 // can be filtered by event-uri(s)
 func (r *RedisStore) GetTriggerPipelines(ctx context.Context, event string, vars map[string]string) ([]string, error) {
+	pipelines, _, err := r.MatchTriggerPipelines(ctx, event, vars)
+	return pipelines, err
+}
+
+// MatchTriggerPipelines get pipelines that have trigger defined with filter applied, and pipelines of paused triggers
+// and triggers with filters not matching vars; reads only trigger event triggers, paused triggers and filters
+func (r *RedisStore) MatchTriggerPipelines(ctx context.Context, event string, vars map[string]string) ([]string, []model.SkippedPipeline, error) {
 	account := getAccount(ctx)
 	lg := log.WithFields(getContextLogFields(ctx))
 	lg.WithFields(log.Fields{
@@ -584,6 +869,7 @@ func (r *RedisStore) GetTriggerPipelines(ctx context.Context, event string, vars
 	}
 	// get redis connection
 	con := r.redisPool.GetConn()
+	defer con.Close()
 
 	triggerKey := getTriggerKey(account, event)
 
@@ -591,72 +877,109 @@ func (r *RedisStore) GetTriggerPipelines(ctx context.Context, event string, vars
 	exists, err := redis.Int(con.Do("EXISTS", triggerKey))
 	if err != nil {
 		lg.WithError(err).Error("failed to check trigger existence")
-		return nil, err
+		return nil, nil, err
 	}
 	// if trigger does not exists
 	if exists == 0 {
 		lg.Warn("trigger not found")
-		return nil, nil
+		return nil, nil, nil
 	}
 	// get pipelines from Triggers
 	pipelines, err := redis.Strings(con.Do("ZRANGE", getTriggerKey(account, event), 0, -1))
 	if err != nil {
 		lg.WithError(err).Error("error getting pipelines")
-		return nil, err
+		return nil, nil, err
 	}
-
-	// scan through pipelines and filter out pipelines that match filter
-	if vars != nil && len(vars) > 0 {
-		skipPipelines := make([]string, 0)
-		for _, pipeline := range pipelines {
+	// skip paused triggers
+	paused, err := redis.Strings(con.Do("SMEMBERS", getPausedKey(account, event)))
+	if err != nil {
+		lg.WithError(err).Error("error getting paused pipelines")
+		return nil, nil, err
+	}
+
+	// scan through pipelines and filter out paused pipelines and pipelines that do not match filter
+	matched := make([]string, 0, len(pipelines))
+	var skipped []model.SkippedPipeline
+	for _, pipeline := range pipelines {
+		if containsString(paused, pipeline) {
+			lg.WithField("pipeline", pipeline).Debug("skipping paused trigger")
+			skipped = append(skipped, model.SkippedPipeline{Pipeline: pipeline, Reason: model.SkipReasonPaused})
+			continue
+		}
+		if len(vars) > 0 {
 			// get hash values
 			filters, err := redis.StringMap(con.Do("HGETALL", getFilterKey(event, pipeline)))
 			if err != nil && err != redis.ErrNil {
 				lg.WithError(err).Error("error getting trigger filter")
-				return nil, err
+				return nil, nil, err
 			}
-			// filter out pipelines: for each filter condition find match
-			for name, exp := range filters {
-				// get matched value from vars
-				val := vars[name]
-				// for non-empty value validate
-				if val != "" {
-					lg.WithFields(log.Fields{
-						"name":       name,
-						"value":      val,
-						"expression": exp,
-					}).Debug("filtering pipeline based on value")
-					// handle NOT on regex
-					skipMatch := false
-					if strings.HasPrefix(exp, "SKIP:") {
-						exp = strings.TrimPrefix(exp, "SKIP:")
-						skipMatch = true
-					}
-					r, err := regexp.Compile(exp)
-					if err != nil {
-						lg.WithFields(log.Fields{
-							"name":       name,
-							"expression": exp,
-						}).Error("bad regex expression for filter")
-						continue // skip
-					}
-					// skip matching values (or not)
-					if r.MatchString(val) == skipMatch {
-						lg.WithField("pipeline", pipeline).Debug("skipping pipeline with filter")
-						skipPipelines = append(skipPipelines, pipeline)
-					}
-				}
+			if !matchFilters(filters, vars, pipeline, lg) {
+				skipped = append(skipped, model.SkippedPipeline{Pipeline: pipeline, Reason: model.SkipReasonFilter})
+				continue
 			}
 		}
-		// remove pipelines that match filter
-		pipelines = util.DiffStrings(pipelines, skipPipelines)
+		matched = append(matched, pipeline)
 	}
+	pipelines = matched
 
 	if len(pipelines) == 0 {
 		lg.Warn("no pipelines found or all skipped")
 	}
 
-	return pipelines, nil
+	return pipelines, skipped, nil
+}
+
+// SetTriggerPaused pause or resume trigger; paused trigger keeps its filters, but does not run pipeline
+func (r *RedisStore) SetTriggerPaused(ctx context.Context, event, pipeline string, paused bool) error {
+	account := getAccount(ctx)
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.WithFields(log.Fields{
+		"event":    event,
+		"pipeline": pipeline,
+		"account":  account,
+		"paused":   paused,
+	}).Debug("setting trigger paused state")
+	// record NewRelic segment
+	if txn := getNewRelicTransaction(ctx); txn != nil {
+		s := newrelic.StartSegment(txn, util.GetCurrentFuncName())
+		defer s.End()
+	}
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+
+	// check event account match: public or private
+	if !model.MatchPublicAccount(event) && !model.MatchAccount(account, event) {
+		lg.WithField("event", event).Error("failed to match trigger for trigger-event")
+		return model.ErrTriggerNotFound
+	}
+
+	// check Codefresh pipeline match; ignore all errors beside "no match"
+	if _, err := r.pipelineSvc.GetPipeline(ctx, account, pipeline); err == codefresh.ErrPipelineNoMatch {
+		lg.WithError(err).Error("attempt to change pipeline from another account")
+		return err
+	}
+
+	// change only existing trigger: fail if trigger is deleted concurrently
+	triggerKey := getTriggerKey(account, event)
+	return watchTx(con, []string{triggerKey}, func() error {
+		_, err := redis.Float64(con.Do("ZSCORE", triggerKey, pipeline))
+		if err == redis.ErrNil {
+			lg.Error("trigger does not exist")
+			return model.ErrTriggerNotFound
+		}
+		if err != nil {
+			lg.WithError(err).Error("failed to check trigger existence")
+		}
+		return err
+	}, func() error {
+		command := "SREM"
+		if paused {
+			command = "SADD"
+		}
+		_, err := con.Do(command, getPausedKey(account, event), pipeline)
+		return err
+	}, lg)
 }
 
 // CreateEvent new trigger event
@@ -679,8 +1002,6 @@ func (r *RedisStore) CreateEvent(ctx context.Context, eventType, kind, secret, c
 		s := newrelic.StartSegment(txn, util.GetCurrentFuncName())
 		defer s.End()
 	}
-	// get redis connection
-	con := r.redisPool.GetConn()
 	// construct event URI
 	eventURI, err := r.eventProvider.ConstructEventURI(eventType, kind, account, values)
 	if err != nil {
@@ -691,7 +1012,7 @@ func (r *RedisStore) CreateEvent(ctx context.Context, eventType, kind, secret, c
 	// first, try to get existing event, continue on error
 	if event, e := r.GetEvent(ctx, eventURI); e == nil {
 		lg.WithField("event-uri", eventURI).Debug("event already exists, reusing trigger-event")
-		return event, nil
+		return checkEventSecret(event, secret)
 	}
 
 	// generate random secret if required
@@ -701,30 +1022,12 @@ func (r *RedisStore) CreateEvent(ctx context.Context, eventType, kind, secret, c
 	}
 
 	// get credentials from Codefresh context (simple key:value map)
-	var credentials map[string]string
-	if context != "" {
-		err = json.Unmarshal([]byte(context), &credentials)
-		if err != nil {
-			lg.WithError(err).WithField("context", context).Warning("failed to get credentials from context")
-		}
-	}
+	credentials := getCredentials(context, lg)
 
 	// try subscribing to event - create event in remote system through event provider
-	eventInfo, err := r.eventProvider.SubscribeToEvent(ctx, eventURI, secret, credentials)
+	eventInfo, err := subscribeToEvent(ctx, r.eventProvider, eventURI, secret, credentials, lg)
 	if err != nil {
-		if err == provider.ErrNotImplemented {
-			lg.Warn("event-provider does not implement SubscribeToEvent method")
-			lg.Debug("fallback to GetEventInfo method")
-			// try to get event info (required method)
-			eventInfo, err = r.eventProvider.GetEventInfo(ctx, eventURI, secret)
-			if err != nil {
-				lg.WithError(err).Error("failed to get event info from event provider")
-				return nil, err
-			}
-		} else {
-			lg.WithError(err).Error("failed to subscribe to event in event provider")
-			return nil, err
-		}
+		return nil, err
 	}
 
 	event := model.Event{
@@ -736,50 +1039,189 @@ func (r *RedisStore) CreateEvent(ctx context.Context, eventType, kind, secret, c
 		EventInfo: *eventInfo,
 	}
 
-	// start Redis transaction
+	// encrypt secret at rest
+	storedSecret := secret
+	if r.cipher != nil {
+		if storedSecret, err = r.cipher.encrypt(secret); err != nil {
+			lg.WithError(err).Error("failed to encrypt trigger event secret")
+			return nil, err
+		}
+	}
+
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	// store trigger event in transaction, unless it was created concurrently
 	eventKey := getEventKey(account, eventURI)
-	if _, err := con.Do("MULTI"); err != nil {
-		lg.WithError(err).Error("failed to start Redis transaction")
+	err = watchTx(con, []string{eventKey}, func() error {
+		n, err := redis.Int(con.Do("EXISTS", eventKey))
+		if err != nil {
+			lg.WithError(err).Error("failed to check trigger event existence")
+			return err
+		}
+		if n != 0 {
+			return errEventExists
+		}
+		return nil
+	}, func() error {
+		// store event fields: type, kind, account, secret and event provider info
+		for _, field := range [][2]string{
+			{"type", eventType},
+			{"kind", kind},
+			{"account", account},
+			{"secret", storedSecret},
+			{"description", eventInfo.Description},
+			{"endpoint", eventInfo.Endpoint},
+			{"help", eventInfo.Help},
+			{"status", eventInfo.Status},
+		} {
+			if _, err := con.Do("HSETNX", eventKey, field[0], field[1]); err != nil {
+				return err
+			}
+		}
+		// add event to account index
+		if _, err := con.Do("SADD", getAccountIndexKey(account), eventURI); err != nil {
+			return err
+		}
+		// add event to type index
+		_, err := con.Do("SADD", getTypeIndexKey(eventType, kind), eventURI)
+		return err
+	}, lg)
+	if err == errEventExists {
+		// release connection: GetEvent uses its own connection
+		con.Close()
+		lg.WithField("event-uri", eventURI).Debug("event created concurrently, reusing trigger-event")
+		stored, err := r.GetEvent(ctx, eventURI)
+		if err != nil {
+			return nil, err
+		}
+		return checkEventSecret(stored, secret)
+	}
+	if err != nil {
 		return nil, err
 	}
-	// store event type
-	if _, err := con.Do("HSETNX", eventKey, "type", eventType); err != nil {
-		return nil, discardOnError(con, err, lg)
+	return &event, nil
+}
+
+// UpdateEvent update trigger event in place: replace secret, refresh event info or change description
+func (r *RedisStore) UpdateEvent(ctx context.Context, event string, update model.EventUpdate) (*model.Event, error) {
+	account := getAccount(ctx)
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.WithFields(log.Fields{
+		"event-uri": event,
+		"account":   account,
+		"refresh":   update.Refresh,
+	}).Debug("updating trigger event")
+	// record NewRelic segment
+	if txn := getNewRelicTransaction(ctx); txn != nil {
+		s := newrelic.StartSegment(txn, util.GetCurrentFuncName())
+		defer s.End()
 	}
-	// store event kind
-	if _, err := con.Do("HSETNX", eventKey, "kind", kind); err != nil {
-		return nil, discardOnError(con, err, lg)
+	stored, err := r.GetEvent(ctx, event)
+	if err != nil {
+		return nil, err
 	}
-	// store event account, if not empty
-	if _, err := con.Do("HSETNX", eventKey, "account", account); err != nil {
-		return nil, discardOnError(con, err, lg)
+	// if not public and belongs to different account - return not exists error
+	if stored.Account != model.PublicAccount && stored.Account != account {
+		return nil, model.ErrEventNotFound
 	}
-	// store event secret
-	if _, err := con.Do("HSETNX", eventKey, "secret", secret); err != nil {
-		return nil, discardOnError(con, err, lg)
+	fields, err := updateEventFields(ctx, r.eventProvider, stored, update, lg)
+	if err != nil {
+		return nil, err
 	}
-	// store event description (from event provider)
-	if _, err := con.Do("HSETNX", eventKey, "description", eventInfo.Description); err != nil {
-		return nil, discardOnError(con, err, lg)
+	if err = r.cipher.encryptFields(fields); err != nil {
+		return nil, err
 	}
-	// store event endpoint (from event provider)
-	if _, err := con.Do("HSETNX", eventKey, "endpoint", eventInfo.Endpoint); err != nil {
-		return nil, discardOnError(con, err, lg)
+	if len(fields) == 0 {
+		return stored, nil
 	}
-	// store event help (from event provider)
-	if _, err := con.Do("HSETNX", eventKey, "help", eventInfo.Help); err != nil {
-		return nil, discardOnError(con, err, lg)
+
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	// update trigger event fields, unless it was deleted concurrently
+	eventKey := getEventKey(account, event)
+	err = watchTx(con, []string{eventKey}, func() error {
+		n, err := redis.Int(con.Do("EXISTS", eventKey))
+		if err != nil {
+			lg.WithError(err).Error("failed to check trigger event existence")
+			return err
+		}
+		if n == 0 {
+			return model.ErrEventNotFound
+		}
+		return nil
+	}, func() error {
+		_, err := con.Do("HMSET", redis.Args{}.Add(eventKey).AddFlat(fields)...)
+		return err
+	}, lg)
+	if err != nil {
+		lg.WithError(err).Error("failed to update trigger event")
+		return nil, err
 	}
-	// store event status (from event provider)
-	if _, err := con.Do("HSETNX", eventKey, "status", eventInfo.Status); err != nil {
-		return nil, discardOnError(con, err, lg)
+	return stored, nil
+}
+
+// RotateEventSecret generate new trigger event secret, keeping current secret valid for grace period
+func (r *RedisStore) RotateEventSecret(ctx context.Context, event string, rotation model.SecretRotation) (*model.Event, error) {
+	account := getAccount(ctx)
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.WithFields(log.Fields{
+		"event-uri": event,
+		"account":   account,
+		"grace":     rotation.Grace,
+		"push":      rotation.Push,
+	}).Debug("rotating trigger event secret")
+	// record NewRelic segment
+	if txn := getNewRelicTransaction(ctx); txn != nil {
+		s := newrelic.StartSegment(txn, util.GetCurrentFuncName())
+		defer s.End()
 	}
-	// submit transaction
-	if _, err := con.Do("EXEC"); err != nil {
-		lg.WithError(err).Error("failed to execute transaction")
+	stored, err := r.GetEvent(ctx, event)
+	if err != nil {
 		return nil, err
 	}
-	return &event, nil
+	// if not public and belongs to different account - return not exists error
+	if stored.Account != model.PublicAccount && stored.Account != account {
+		return nil, model.ErrEventNotFound
+	}
+	return rotateEventSecret(ctx, r, r.eventProvider, account, stored, rotation, lg)
+}
+
+// set trigger event fields, unless secret was rotated or trigger event deleted concurrently
+func (r *RedisStore) swapEventSecret(ctx context.Context, account, event, current string, fields map[string]string, rotating bool) error {
+	lg := log.WithFields(getContextLogFields(ctx)).WithField("event-uri", event)
+	if err := r.cipher.encryptFields(fields); err != nil {
+		return err
+	}
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	eventKey := getEventKey(account, event)
+	return watchTx(con, []string{eventKey}, func() error {
+		secret, err := redis.String(con.Do("HGET", eventKey, "secret"))
+		if err == redis.ErrNil {
+			return model.ErrEventNotFound
+		}
+		if err != nil {
+			lg.WithError(err).Error("failed to get trigger event secret")
+			return err
+		}
+		if secret, err = r.cipher.decrypt(secret); err != nil || secret != current {
+			return model.ErrStoreConflict
+		}
+		return nil
+	}, func() error {
+		if _, err := con.Do("HMSET", redis.Args{}.Add(eventKey).AddFlat(fields)...); err != nil {
+			return err
+		}
+		// purge previous secret after grace period
+		if rotating {
+			_, err := con.Do("SADD", rotationIndexKey, event)
+			return err
+		}
+		return nil
+	}, lg)
 }
 
 // GetEvent get event by event URI
@@ -802,6 +1244,7 @@ func (r *RedisEventGetter) GetEvent(ctx context.Context, event string) (*model.E
 	}
 	// get redis connection
 	con := r.redisPool.GetConn()
+	defer con.Close()
 	// prepare key
 	eventKey := getEventKey(account, event)
 	// check event URI is a single event key
@@ -825,6 +1268,11 @@ func (r *RedisEventGetter) GetEvent(ctx context.Context, event string) (*model.E
 		lg.Error("failed to find trigger event")
 		return nil, model.ErrEventNotFound
 	}
+	// transparently decrypt secrets encrypted at rest
+	if err = r.cipher.decryptFields(fields); err != nil {
+		lg.WithError(err).Error("failed to decrypt trigger event secrets")
+		return nil, err
+	}
 	return model.StringsMapToEvent(event, fields), nil
 }
 
@@ -847,24 +1295,24 @@ func (r *RedisStore) GetEvents(ctx context.Context, eventType, kind, filter stri
 	}
 	// get redis connection
 	con := r.redisPool.GetConn()
+	defer con.Close()
 	// get all events URIs for account
-	uris, err := redis.Strings(con.Do("KEYS", getEventKey(account, filter)))
+	uris, err := findEvents(con, account, eventType, kind, filter)
 	if err != nil {
 		lg.WithError(err).Error("failed to get trigger events")
 		return nil, err
 	}
 	// get public trigger events, if asked (through context)
-	if public {
-		// get all events URIs for account
-		publicURIs, err := redis.Strings(con.Do("KEYS", getEventKey(model.PublicAccount, filter)))
-		if err != nil && err != redis.ErrNil {
+	if public && account != model.PublicAccount {
+		publicURIs, err := findEvents(con, model.PublicAccount, eventType, kind, filter)
+		if err != nil {
 			lg.WithError(err).Error("failed to get public trigger events")
 			return nil, err
 		}
-		if len(publicURIs) > 0 {
-			uris = append(uris, publicURIs...)
-		}
+		uris = append(uris, publicURIs...)
 	}
+	// release connection: GetEvent uses its own connection
+	con.Close()
 	// scan through all events and select matching to non-empty type and kind
 	events := make([]model.Event, 0)
 	for _, uri := range uris {
@@ -881,13 +1329,71 @@ func (r *RedisStore) GetEvents(ctx context.Context, eventType, kind, filter stri
 	return events, nil
 }
 
+// GetEventsPage get page of events by event type, kind and filter (can be URI or part of URI)
+func (r *RedisStore) GetEventsPage(ctx context.Context, eventType, kind, filter string, page model.PageOptions) (*model.EventPage, error) {
+	account := getAccount(ctx)
+	public := getPublicFlag(ctx)
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.WithFields(log.Fields{
+		"type":    eventType,
+		"kind":    kind,
+		"account": account,
+		"filter":  filter,
+		"public":  public,
+		"limit":   page.Limit,
+		"sort":    page.Sort,
+	}).Debug("getting trigger events page")
+	// record NewRelic segment
+	if txn := getNewRelicTransaction(ctx); txn != nil {
+		s := newrelic.StartSegment(txn, util.GetCurrentFuncName())
+		defer s.End()
+	}
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	// get all events URIs for account
+	uris, err := findEvents(con, account, eventType, kind, filter)
+	if err != nil {
+		lg.WithError(err).Error("failed to get trigger events")
+		return nil, err
+	}
+	// get public trigger events, if asked (through context)
+	if public && account != model.PublicAccount {
+		publicURIs, err := findEvents(con, model.PublicAccount, eventType, kind, filter)
+		if err != nil {
+			lg.WithError(err).Error("failed to get public trigger events")
+			return nil, err
+		}
+		uris = append(uris, publicURIs...)
+	}
+	events, err := eventsPage(&redisPageReader{con, account, r.cipher}, uris, eventType, kind, page)
+	if err != nil {
+		lg.WithError(err).Error("failed to get trigger events page")
+		return nil, err
+	}
+	return events, nil
+}
+
 // DeleteEvent delete trigger event
 func (r *RedisStore) DeleteEvent(ctx context.Context, event, context string) error {
+	_, err := r.deleteEvent(ctx, event, context, false)
+	return err
+}
+
+// ForceDeleteEvent delete trigger event together with all its triggers and filters
+func (r *RedisStore) ForceDeleteEvent(ctx context.Context, event, context string) (*model.EventDeletion, error) {
+	return r.deleteEvent(ctx, event, context, true)
+}
+
+// delete trigger event in transaction and unsubscribe from event
+// force: delete linked triggers, filters and pipeline references too; fail with ErrEventDeleteWithTriggers otherwise
+func (r *RedisStore) deleteEvent(ctx context.Context, event, context string, force bool) (*model.EventDeletion, error) {
 	account := getAccount(ctx)
 	lg := log.WithFields(getContextLogFields(ctx))
 	log.WithFields(log.Fields{
 		"event-uri": event,
 		"account":   account,
+		"force":     force,
 	}).Debug("deleting trigger event")
 	// record NewRelic segment
 	if txn := getNewRelicTransaction(ctx); txn != nil {
@@ -896,92 +1402,1307 @@ func (r *RedisStore) DeleteEvent(ctx context.Context, event, context string) err
 	}
 	// get redis connection
 	con := r.redisPool.GetConn()
+	defer con.Close()
 	// prepare keys
 	eventKey := getEventKey(account, event)
 	triggerKey := getTriggerKey(account, event)
-	// check event URI is a single event key
-	n, err := redis.Int(con.Do("EXISTS", eventKey))
-	if err != nil {
-		lg.WithError(err).Error("failed to check trigger event existence")
-		return err
-	}
-	if n == 0 {
-		lg.Error("trigger event key does not exist")
-		return model.ErrEventNotFound
-	}
-	// check trigger event account vs passed account; skip 'public' events
-	a, err := redis.String(con.Do("HGET", eventKey, "account"))
-	if err != nil && err != redis.ErrNil {
-		lg.WithError(err).Error("failed to get trigger event account")
+	uri := strings.TrimPrefix(eventKey, "event:")
+	// check trigger event and delete it in transaction: abort (or retry forced) delete if trigger is added concurrently
+	var a, eventType, kind string
+	var deletion *model.EventDeletion
+	err := watchTx(con, []string{eventKey, triggerKey}, func() error {
+		// check event URI is a single event key
+		n, err := redis.Int(con.Do("EXISTS", eventKey))
+		if err != nil {
+			lg.WithError(err).Error("failed to check trigger event existence")
+			return err
+		}
+		if n == 0 {
+			lg.Error("trigger event key does not exist")
+			return model.ErrEventNotFound
+		}
+		// check trigger event account vs passed account; skip 'public' events
+		// also get event type and kind to update indexes
+		fields, err := redis.Strings(con.Do("HMGET", eventKey, "account", "type", "kind"))
+		if err != nil {
+			lg.WithError(err).Error("failed to get trigger event account")
+			return err
+		}
+		a, eventType, kind = fields[0], fields[1], fields[2]
+		// if not public and belongs to different account - return not exists error
+		if a != model.PublicAccount && a != account {
+			lg.Error("trigger event account does not match")
+			return model.ErrEventNotFound
+		}
+		// get pipelines linked to the trigger event
+		pipelines, err := redis.Strings(con.Do("ZRANGE", triggerKey, 0, -1))
+		if err != nil {
+			lg.WithError(err).Error("failed to get pipelines for the trigger event")
+			return err
+		}
+		// abort delete operation if trigger event has linked pipelines
+		if len(pipelines) > 0 && !force {
+			lg.Error("there are triggers linked to this trigger-event, first delete triggers")
+			return model.ErrEventDeleteWithTriggers
+		}
+		deletion = &model.EventDeletion{URI: uri, Pipelines: pipelines, Filters: make([]string, 0)}
+		// find trigger filters
+		for _, pipeline := range pipelines {
+			n, err := redis.Int(con.Do("EXISTS", getFilterKey(uri, pipeline)))
+			if err != nil {
+				lg.WithError(err).Error("failed to check trigger filters existence")
+				return err
+			}
+			if n != 0 {
+				deletion.Filters = append(deletion.Filters, pipeline)
+			}
+		}
+		return nil
+	}, func() error {
+		// delete event hash for key
+		lg.Debug("removing trigger event")
+		if _, err := con.Do("DEL", eventKey); err != nil {
+			return err
+		}
+		// delete trigger event from Triggers and paused triggers
+		lg.Debug("removing trigger event from Triggers")
+		if _, err := con.Do("DEL", triggerKey, getPausedKey(account, event)); err != nil {
+			return err
+		}
+		// remove trigger event from Pipelines and trigger filters (forced delete)
+		for _, pipeline := range deletion.Pipelines {
+			if _, err := con.Do("ZREM", getPipelineKey(pipeline), uri); err != nil {
+				return err
+			}
+		}
+		for _, pipeline := range deletion.Filters {
+			if _, err := con.Do("DEL", getFilterKey(uri, pipeline)); err != nil {
+				return err
+			}
+		}
+		// remove trigger event from account index
+		if _, err := con.Do("SREM", getAccountIndexKey(a), uri); err != nil {
+			return err
+		}
+		// remove trigger event from type index
+		_, err := con.Do("SREM", getTypeIndexKey(eventType, kind), uri)
 		return err
-	}
-	// if not public and belongs to different account - return not exists error
-	if a != model.PublicAccount && a != account {
-		lg.Error("trigger event account does not match")
-		return model.ErrEventNotFound
-	}
-
-	// get pipelines linked to the trigger event
-	pipelines, err := redis.Strings(con.Do("ZRANGE", triggerKey, 0, -1))
+	}, lg)
 	if err != nil {
-		lg.WithError(err).Error("failed to get pipelines for the trigger event")
-		return err
-	}
-
-	// abort delete operation if trigger event has linked pipelines
-	if len(pipelines) > 0 {
-		lg.Error("there are triggers linked to this trigger-event, first delete triggers")
-		return model.ErrEventDeleteWithTriggers
+		return nil, err
 	}
 
-	// start Redis transaction
-	_, err = con.Do("MULTI")
-	if err != nil {
-		lg.WithError(err).Error("failed to start Redis transaction")
-		return err
-	}
+	// get credentials from Codefresh context (simple key:value map)
+	credentials := getCredentials(context, lg)
 
-	// delete event hash for key
-	lg.Debug("removing trigger event")
-	_, err = con.Do("DEL", eventKey)
-	if err != nil {
-		lg.WithError(err).Error("failed to delete trigger event")
-		return discardOnError(con, err, lg)
-	}
+	// try unsubscribing from event - delete event in remote system through event provider
+	return deletion, unsubscribeFromEvent(ctx, r.eventProvider, event, credentials, lg)
+}
 
-	// delete trigger event from Triggers
-	lg.Debug("removing trigger event from Triggers")
-	_, err = con.Do("DEL", triggerKey)
-	if err != nil {
-		return discardOnError(con, err, lg)
-	}
+//-------------------------- SecretPurger Interface -------------------------
 
-	// submit transaction
-	_, err = con.Do("EXEC")
+// PurgeExpiredSecrets remove previous trigger event secrets after rotation grace period
+func (r *RedisStore) PurgeExpiredSecrets(ctx context.Context) (int, error) {
+	lg := log.WithFields(getContextLogFields(ctx))
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	uris, err := redis.Strings(con.Do("SMEMBERS", rotationIndexKey))
 	if err != nil {
-		lg.WithError(err).Error("failed to execute transaction")
-		return err
+		lg.WithError(err).Error("failed to get rotated trigger events")
+		return 0, err
 	}
-
-	// get credentials from Codefresh context (simple key:value map)
-	var credentials map[string]string
-	if context != "" {
-		err = json.Unmarshal([]byte(context), &credentials)
+	purged := 0
+	for _, uri := range uris {
+		eventKey := getEventKey("-", uri)
+		expired := false
+		err = watchTx(con, []string{eventKey, rotationIndexKey}, func() error {
+			fields, err := redis.StringMap(con.Do("HGETALL", eventKey))
+			if err != nil {
+				return err
+			}
+			expired = secretExpired(fields, time.Now())
+			if !expired {
+				return errNothingToDo
+			}
+			return nil
+		}, func() error {
+			if _, err := con.Do("HDEL", eventKey, "previous_secret", "previous_secret_expires"); err != nil {
+				return err
+			}
+			_, err := con.Do("SREM", rotationIndexKey, uri)
+			return err
+		}, lg)
+		if err == errNothingToDo {
+			continue
+		}
 		if err != nil {
-			lg.WithError(err).WithField("context", context).Warning("failed to get credentials from context")
+			lg.WithField("event-uri", uri).WithError(err).Error("failed to purge trigger event secret")
+			return purged, err
 		}
+		purged++
 	}
+	return purged, nil
+}
 
-	// try unsubscribing from event - delete event in remote system through event provider
-	err = r.eventProvider.UnsubscribeFromEvent(ctx, event, credentials)
-	if err != nil {
-		if err != provider.ErrNotImplemented {
-			lg.WithError(err).Error("failed to UnsubscribeFromEven")
-			return err
+//-------------------------- SecretEncrypter Interface -------------------------
+
+// ReEncrypt encrypt plaintext secrets and secrets encrypted with previous keys with current encryption key
+// each trigger event is updated in its own transaction; safe to run multiple times
+func (r *RedisStore) ReEncrypt(ctx context.Context) (int, error) {
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.Debug("re-encrypting trigger event secrets")
+	if r.cipher == nil {
+		return 0, ErrNoEncryptionKey
+	}
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	// scan through all trigger events (without blocking Redis)
+	keys, err := scanAll(con, "", "event:*")
+	if err != nil {
+		lg.WithError(err).Error("failed to scan trigger events")
+		return 0, err
+	}
+	updated := 0
+	for _, key := range keys {
+		var changed map[string]string
+		err = watchTx(con, []string{key}, func() error {
+			values, err := redis.Strings(con.Do("HMGET", redis.Args{}.Add(key).AddFlat(encryptedFields)...))
+			if err != nil {
+				return err
+			}
+			fields := make(map[string]string, len(values))
+			for i, name := range encryptedFields {
+				fields[name] = values[i]
+			}
+			if changed, err = r.cipher.reencryptFields(fields); err != nil {
+				return err
+			}
+			if len(changed) == 0 {
+				return errNothingToDo
+			}
+			return nil
+		}, func() error {
+			_, err := con.Do("HMSET", redis.Args{}.Add(key).AddFlat(changed)...)
+			return err
+		}, lg)
+		if err == errNothingToDo {
+			continue
+		}
+		if err != nil {
+			lg.WithField("key", key).WithError(err).Error("failed to re-encrypt trigger event secrets")
+			return updated, err
+		}
+		updated++
+	}
+	lg.WithField("events", updated).Debug("trigger event secrets re-encrypted")
+	return updated, nil
+}
+
+//-------------------------- DispatchQueue Interface -------------------------
+
+// PauseDispatch pause dispatch for scope; pausing paused scope keeps its pause time
+func (r *RedisStore) PauseDispatch(ctx context.Context, scope string) error {
+	lg := log.WithFields(getContextLogFields(ctx)).WithField("scope", scope)
+	lg.Debug("pausing dispatch")
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	if _, err := con.Do("HSETNX", getDispatchKey(scope), "since", time.Now().UTC().Format(time.RFC3339Nano)); err != nil {
+		lg.WithError(err).Error("failed to pause dispatch")
+		return err
+	}
+	return nil
+}
+
+// ResumeDispatch resume dispatch for scope; fails when scope has queued events
+func (r *RedisStore) ResumeDispatch(ctx context.Context, scope string) error {
+	lg := log.WithFields(getContextLogFields(ctx)).WithField("scope", scope)
+	lg.Debug("resuming dispatch")
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	// abort resume if event is queued concurrently
+	queueKey := getQueueKey(scope)
+	return watchTx(con, []string{queueKey}, func() error {
+		n, err := redis.Int(con.Do("ZCARD", queueKey))
+		if err != nil {
+			lg.WithError(err).Error("failed to get dispatch queue length")
+			return err
+		}
+		if n > 0 {
+			return model.ErrDispatchQueueNotEmpty
+		}
+		return nil
+	}, func() error {
+		_, err := con.Do("DEL", getDispatchKey(scope))
+		return err
+	}, lg)
+}
+
+// GetDispatchStates list paused scopes
+func (r *RedisStore) GetDispatchStates(ctx context.Context) ([]model.DispatchState, error) {
+	lg := log.WithFields(getContextLogFields(ctx))
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	keys, err := scanAll(con, "", getDispatchKey("*"))
+	if err != nil {
+		lg.WithError(err).Error("failed to scan paused dispatch scopes")
+		return nil, err
+	}
+	states := make([]model.DispatchState, 0, len(keys))
+	for _, key := range keys {
+		scope := trimKeyPrefix(key, "dispatch")
+		since, err := redis.String(con.Do("HGET", key, "since"))
+		if err == redis.ErrNil {
+			continue
+		}
+		if err != nil {
+			lg.WithError(err).Error("failed to get dispatch state")
+			return nil, err
+		}
+		queued, err := redis.Int(con.Do("ZCARD", getQueueKey(scope)))
+		if err != nil {
+			lg.WithError(err).Error("failed to get dispatch queue length")
+			return nil, err
+		}
+		states = append(states, newDispatchState(scope, since, queued))
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Scope < states[j].Scope })
+	return states, nil
+}
+
+// QueueEvent append event to scope queue, only when dispatch is paused for scope
+func (r *RedisStore) QueueEvent(ctx context.Context, scope string, event *model.QueuedEvent) (bool, error) {
+	lg := log.WithFields(getContextLogFields(ctx)).WithFields(log.Fields{"scope": scope, "queued-event": event.ID})
+	fields, err := queuedEventToFields(r.cipher, event)
+	if err != nil {
+		lg.WithError(err).Error("failed to encode queued event")
+		return false, err
+	}
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	// queue event only while scope is paused: retry if scope is resumed concurrently
+	dispatchKey := getDispatchKey(scope)
+	err = watchTx(con, []string{dispatchKey}, func() error {
+		n, err := redis.Int(con.Do("EXISTS", dispatchKey))
+		if err != nil {
+			lg.WithError(err).Error("failed to get dispatch state")
+			return err
+		}
+		if n == 0 {
+			return errNothingToDo
+		}
+		return nil
+	}, func() error {
+		if _, err := con.Do("HMSET", redis.Args{}.Add(getQueuedEventKey(event.ID)).AddFlat(fields)...); err != nil {
+			return err
+		}
+		_, err := con.Do("ZADD", getQueueKey(scope), 0, event.ID)
+		return err
+	}, lg)
+	if err == errNothingToDo {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	lg.WithField("event", event.Event).Info("dispatch is paused, event queued")
+	return true, nil
+}
+
+// PeekQueuedEvent return the oldest queued event of scope, without removing it
+func (r *RedisStore) PeekQueuedEvent(ctx context.Context, scope string) (*model.QueuedEvent, error) {
+	lg := log.WithFields(getContextLogFields(ctx)).WithField("scope", scope)
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	ids, err := redis.Strings(con.Do("ZRANGE", getQueueKey(scope), 0, 0))
+	if err != nil {
+		lg.WithError(err).Error("failed to get queued events")
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	fields, err := redis.StringMap(con.Do("HGETALL", getQueuedEventKey(ids[0])))
+	if err != nil {
+		lg.WithError(err).Error("failed to get queued event")
+		return nil, err
+	}
+	return fieldsToQueuedEvent(r.cipher, ids[0], fields)
+}
+
+// AckQueuedEvent remove queued event from scope queue
+func (r *RedisStore) AckQueuedEvent(ctx context.Context, scope, id string) error {
+	lg := log.WithFields(getContextLogFields(ctx)).WithFields(log.Fields{"scope": scope, "queued-event": id})
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	if _, err := con.Do("MULTI"); err != nil {
+		lg.WithError(err).Error("failed to start Redis transaction")
+		return err
+	}
+	if _, err := con.Do("ZREM", getQueueKey(scope), id); err != nil {
+		return discardOnError(con, err, lg)
+	}
+	if _, err := con.Do("DEL", getQueuedEventKey(id)); err != nil {
+		return discardOnError(con, err, lg)
+	}
+	if _, err := con.Do("EXEC"); err != nil {
+		lg.WithError(err).Error("failed to remove queued event")
+		return err
+	}
+	return nil
+}
+
+// RequeueEvent replace queued event with event (new ID: queue tail)
+func (r *RedisStore) RequeueEvent(ctx context.Context, scope, id string, event *model.QueuedEvent) error {
+	lg := log.WithFields(getContextLogFields(ctx)).WithFields(log.Fields{"scope": scope, "queued-event": id})
+	fields, err := queuedEventToFields(r.cipher, event)
+	if err != nil {
+		lg.WithError(err).Error("failed to encode queued event")
+		return err
+	}
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	if _, err = con.Do("MULTI"); err != nil {
+		lg.WithError(err).Error("failed to start Redis transaction")
+		return err
+	}
+	queueKey := getQueueKey(scope)
+	if _, err = con.Do("ZREM", queueKey, id); err != nil {
+		return discardOnError(con, err, lg)
+	}
+	if _, err = con.Do("DEL", getQueuedEventKey(id)); err != nil {
+		return discardOnError(con, err, lg)
+	}
+	if _, err = con.Do("HMSET", redis.Args{}.Add(getQueuedEventKey(event.ID)).AddFlat(fields)...); err != nil {
+		return discardOnError(con, err, lg)
+	}
+	if _, err = con.Do("ZADD", queueKey, 0, event.ID); err != nil {
+		return discardOnError(con, err, lg)
+	}
+	if _, err = con.Do("EXEC"); err != nil {
+		lg.WithError(err).Error("failed to requeue event")
+		return err
+	}
+	return nil
+}
+
+//-------------------------- Locker Interface -------------------------
+
+// AcquireLock acquire lock for owner until released or ttl expires; false when locked by another owner
+func (r *RedisStore) AcquireLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	lg := log.WithFields(getContextLogFields(ctx)).WithFields(log.Fields{"lock": name, "owner": owner})
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	// set lock key only if not exists: nil reply when locked
+	reply, err := con.Do("SET", getLockKey(name), owner, "NX", "PX", int64(ttl/time.Millisecond))
+	if err != nil {
+		lg.WithError(err).Error("failed to acquire lock")
+		return false, err
+	}
+	return reply != nil, nil
+}
+
+// ExtendLock extend lock held by owner for ttl; false when lock expired or was acquired by another owner
+func (r *RedisStore) ExtendLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	lg := log.WithFields(getContextLogFields(ctx)).WithFields(log.Fields{"lock": name, "owner": owner})
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	// extend only lock still held by owner
+	lockKey := getLockKey(name)
+	err := watchTx(con, []string{lockKey}, func() error {
+		current, err := redis.String(con.Do("GET", lockKey))
+		if err == redis.ErrNil || (err == nil && current != owner) {
+			return errNothingToDo
+		}
+		return err
+	}, func() error {
+		_, err := con.Do("PEXPIRE", lockKey, int64(ttl/time.Millisecond))
+		return err
+	}, lg)
+	if err == errNothingToDo {
+		return false, nil
+	}
+	if err != nil {
+		lg.WithError(err).Error("failed to extend lock")
+		return false, err
+	}
+	return true, nil
+}
+
+// ReleaseLock release lock, if still held by owner
+func (r *RedisStore) ReleaseLock(ctx context.Context, name, owner string) error {
+	lg := log.WithFields(getContextLogFields(ctx)).WithFields(log.Fields{"lock": name, "owner": owner})
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	// do not release lock acquired by another owner after expiration
+	lockKey := getLockKey(name)
+	err := watchTx(con, []string{lockKey}, func() error {
+		current, err := redis.String(con.Do("GET", lockKey))
+		if err == redis.ErrNil || (err == nil && current != owner) {
+			return errNothingToDo
+		}
+		return err
+	}, func() error {
+		_, err := con.Do("DEL", lockKey)
+		return err
+	}, lg)
+	if err == errNothingToDo {
+		return nil
+	}
+	return err
+}
+
+//-------------------------- ReconcileRunStore Interface -------------------------
+
+// SaveReconcileRun store run result, removing runs older than last reconcileRunsLimit runs
+func (r *RedisStore) SaveReconcileRun(ctx context.Context, run *model.ReconcileRun) error {
+	lg := log.WithFields(getContextLogFields(ctx)).WithField("run", run.ID)
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	if _, err = con.Do("HSET", getReconcileRunKey(run.ID), "run", string(data)); err != nil {
+		lg.WithError(err).Error("failed to store reconciliation run")
+		return err
+	}
+	keys, err := scanAll(con, "", getReconcileRunKey("*"))
+	if err != nil {
+		lg.WithError(err).Error("failed to scan reconciliation runs")
+		return err
+	}
+	if len(keys) <= reconcileRunsLimit {
+		return nil
+	}
+	sort.Strings(keys)
+	if _, err = con.Do("DEL", redis.Args{}.AddFlat(keys[:len(keys)-reconcileRunsLimit])...); err != nil {
+		lg.WithError(err).Error("failed to delete old reconciliation runs")
+		return err
+	}
+	return nil
+}
+
+// GetReconcileRuns list recent runs, latest first
+func (r *RedisStore) GetReconcileRuns(ctx context.Context) ([]model.ReconcileRun, error) {
+	lg := log.WithFields(getContextLogFields(ctx))
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	keys, err := scanAll(con, "", getReconcileRunKey("*"))
+	if err != nil {
+		lg.WithError(err).Error("failed to scan reconciliation runs")
+		return nil, err
+	}
+	sort.Strings(keys)
+	values := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value, err := redis.String(con.Do("HGET", key, "run"))
+		if err != nil {
+			lg.WithField("key", key).WithError(err).Error("failed to get reconciliation run")
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return decodeReconcileRuns(keys, values)
+}
+
+//-------------------------- TriggerCollector Interface -------------------------
+
+// GetPipelineEvents get all pipelines with triggers and their linked trigger events
+func (r *RedisStore) GetPipelineEvents(ctx context.Context) (map[string][]string, error) {
+	lg := log.WithFields(getContextLogFields(ctx))
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	keys, err := scanAll(con, "", getPipelineKey("*"))
+	if err != nil {
+		lg.WithError(err).Error("failed to scan pipelines")
+		return nil, err
+	}
+	pipelines := make(map[string][]string, len(keys))
+	for _, key := range keys {
+		events, err := redis.Strings(con.Do("ZRANGE", key, 0, -1))
+		if err != nil {
+			lg.WithField("key", key).WithError(err).Error("failed to get trigger events")
+			return nil, err
+		}
+		if len(events) > 0 {
+			pipelines[trimKeyPrefix(key, "pipeline")] = events
+		}
+	}
+	return pipelines, nil
+}
+
+// DeletePipelineTriggers unlink trigger events from pipeline, without Codefresh pipeline check
+// skip triggers deleted meanwhile and triggers of events deleted or not matching context account
+func (r *RedisStore) DeletePipelineTriggers(ctx context.Context, pipeline string, events []string) ([]string, error) {
+	account := getAccount(ctx)
+	lg := log.WithFields(getContextLogFields(ctx)).WithField("pipeline", pipeline)
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	pipelineKey := getPipelineKey(pipeline)
+	// watch pipeline, triggers and trigger events: retry if any of them is changed concurrently
+	keys := []string{pipelineKey}
+	for _, event := range events {
+		keys = append(keys, getTriggerKey("-", event), getEventKey("-", event))
+	}
+	var deleted []string
+	err := watchTx(con, keys, func() error {
+		deleted = nil
+		for _, event := range events {
+			if !model.MatchAccount(account, event) && !model.MatchPublicAccount(event) {
+				continue
+			}
+			n, err := redis.Int(con.Do("EXISTS", getEventKey("-", event)))
+			if err != nil {
+				lg.WithError(err).Error("failed to check trigger event existence")
+				return err
+			}
+			if n == 0 {
+				continue
+			}
+			_, err = redis.Int64(con.Do("ZSCORE", getTriggerKey("-", event), pipeline))
+			if err == redis.ErrNil {
+				continue
+			}
+			if err != nil {
+				lg.WithError(err).Error("failed to check trigger existence")
+				return err
+			}
+			deleted = append(deleted, event)
+		}
+		if len(deleted) == 0 {
+			return errNothingToDo
+		}
+		return nil
+	}, func() error {
+		for _, event := range deleted {
+			if _, err := con.Do("ZREM", getTriggerKey("-", event), pipeline); err != nil {
+				return err
+			}
+			if _, err := con.Do("ZREM", pipelineKey, event); err != nil {
+				return err
+			}
+			if _, err := con.Do("DEL", getFilterKey(event, pipeline)); err != nil {
+				return err
+			}
+			if _, err := con.Do("SREM", getPausedKey("-", event), pipeline); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, lg)
+	if err == errNothingToDo {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+//-------------------------- RunHistoryStore Interface -------------------------
+
+// SaveRun store run record, removing account records older than retention
+func (r *RedisStore) SaveRun(ctx context.Context, record *model.RunRecord, retention time.Duration) error {
+	lg := log.WithFields(getContextLogFields(ctx)).WithField("run", record.ID)
+	fields, err := runRecordToFields(record)
+	if err != nil {
+		return err
+	}
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	if _, err = con.Do("HMSET", redis.Args{}.Add(getRunKey(record.ID)).AddFlat(fields)...); err != nil {
+		lg.WithError(err).Error("failed to store run record")
+		return err
+	}
+	// all record IDs have same score: sorted set is ordered by ID (time)
+	if _, err = con.Do("ZADD", getRunsKey(record.Account), 0, record.ID); err != nil {
+		lg.WithError(err).Error("failed to add run record")
+		return err
+	}
+	// remove records older than retention
+	bound := getIDBound(time.Now().Add(-retention))
+	ids, err := redis.Strings(con.Do("ZRANGEBYLEX", getRunsKey(record.Account), "-", "("+bound))
+	if err != nil {
+		lg.WithError(err).Error("failed to get expired run records")
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, getRunKey(id))
+	}
+	if _, err = con.Do("ZREM", redis.Args{}.Add(getRunsKey(record.Account)).AddFlat(ids)...); err != nil {
+		lg.WithError(err).Error("failed to remove expired run records")
+		return err
+	}
+	if _, err = con.Do("DEL", redis.Args{}.AddFlat(keys)...); err != nil {
+		lg.WithError(err).Error("failed to delete expired run records")
+		return err
+	}
+	return nil
+}
+
+// GetRuns list account run records matching filter, latest first
+func (r *RedisStore) GetRuns(ctx context.Context, filter model.RunFilter) ([]model.RunRecord, error) {
+	lg := log.WithFields(getContextLogFields(ctx)).WithField("account", filter.Account)
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	ids, err := redis.Strings(con.Do("ZRANGE", getRunsKey(filter.Account), 0, -1))
+	if err != nil {
+		lg.WithError(err).Error("failed to get run records")
+		return nil, err
+	}
+	records := make([]model.RunRecord, 0)
+	for _, id := range filterIDs(ids, filter.From, filter.To) {
+		value, err := redis.String(con.Do("HGET", getRunKey(id), "run"))
+		if err != nil {
+			lg.WithField("run", id).WithError(err).Error("failed to get run record")
+			return nil, err
+		}
+		var record model.RunRecord
+		if err = json.Unmarshal([]byte(value), &record); err != nil {
+			lg.WithField("run", id).WithError(err).Error("failed to decode run record")
+			return nil, err
+		}
+		if !filter.Match(&record) {
+			continue
+		}
+		records = append(records, record)
+		if filter.Limit > 0 && len(records) == filter.Limit {
+			break
+		}
+	}
+	return records, nil
+}
+
+// GetRun get run record; record of another account is not found
+func (r *RedisStore) GetRun(ctx context.Context, id string) (*model.RunRecord, error) {
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	fields, err := redis.StringMap(con.Do("HGETALL", getRunKey(id)))
+	if err != nil {
+		return nil, err
+	}
+	return fieldsToRunRecord(ctx, fields)
+}
+
+//-------------------------- DeliveryStore Interface -------------------------
+
+// AddDelivery remember delivery for TTL; returns first delivery (nil when added) if delivery ID was already seen
+func (r *RedisStore) AddDelivery(ctx context.Context, delivery *model.Delivery, ttl time.Duration) (*model.Delivery, error) {
+	lg := log.WithFields(getContextLogFields(ctx)).WithFields(log.Fields{"event": delivery.Event, "delivery": delivery.ID})
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		return nil, err
+	}
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	// set delivery key only if not exists: nil reply when already delivered
+	key := getDeliveryKey(delivery.Event, delivery.ID)
+	reply, err := con.Do("SET", key, string(data), "NX", "PX", int64(ttl/time.Millisecond))
+	if err != nil {
+		lg.WithError(err).Error("failed to add event delivery")
+		return nil, err
+	}
+	if reply != nil {
+		return nil, nil
+	}
+	value, err := redis.String(con.Do("GET", key))
+	if err == redis.ErrNil {
+		// first delivery expired meanwhile
+		return nil, nil
+	}
+	if err != nil {
+		lg.WithError(err).Error("failed to get first event delivery")
+		return nil, err
+	}
+	first := new(model.Delivery)
+	if err = json.Unmarshal([]byte(value), first); err != nil {
+		lg.WithError(err).Error("failed to decode first event delivery")
+		return nil, err
+	}
+	return first, nil
+}
+
+// RemoveDelivery forget delivery ID, if it was delivered with run record ID
+func (r *RedisStore) RemoveDelivery(ctx context.Context, event, id, run string) error {
+	lg := log.WithFields(getContextLogFields(ctx)).WithFields(log.Fields{"event": event, "delivery": id})
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	// remove delivery only if it is still delivery of run
+	key := getDeliveryKey(event, id)
+	err := watchTx(con, []string{key}, func() error {
+		value, err := redis.String(con.Do("GET", key))
+		if err == redis.ErrNil {
+			return errNothingToDo
+		}
+		if err != nil {
+			lg.WithError(err).Error("failed to get event delivery")
+			return err
+		}
+		var d model.Delivery
+		if err = json.Unmarshal([]byte(value), &d); err != nil || d.Run != run {
+			return errNothingToDo
+		}
+		return nil
+	}, func() error {
+		_, err := con.Do("DEL", key)
+		return err
+	}, lg)
+	if err == errNothingToDo {
+		return nil
+	}
+	return err
+}
+
+//-------------------------- RateLimitStore Interface -------------------------
+
+// GetRateLimits list account rate limits
+func (r *RedisStore) GetRateLimits(ctx context.Context, account string) ([]model.RateLimit, error) {
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	fields, err := redis.StringMap(con.Do("HGETALL", getRateLimitKey(account)))
+	if err != nil {
+		log.WithFields(getContextLogFields(ctx)).WithField("account", account).WithError(err).Error("failed to get rate limits")
+		return nil, err
+	}
+	return fieldsToRateLimits(fields)
+}
+
+// SetRateLimit add or replace rate limit; changed limit starts with full bucket
+func (r *RedisStore) SetRateLimit(ctx context.Context, limit model.RateLimit) error {
+	lg := log.WithFields(getContextLogFields(ctx)).WithField("limit", getRateLimitField(limit))
+	data, err := json.Marshal(limit)
+	if err != nil {
+		return err
+	}
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	if _, err = con.Do("HSET", getRateLimitKey(limit.Account), getRateLimitField(limit), string(data)); err != nil {
+		lg.WithError(err).Error("failed to set rate limit")
+		return err
+	}
+	if _, err = con.Do("DEL", getBucketKey(limit)); err != nil {
+		lg.WithError(err).Error("failed to reset rate limit bucket")
+		return err
+	}
+	return nil
+}
+
+// DeleteRateLimit delete rate limit and its token bucket
+func (r *RedisStore) DeleteRateLimit(ctx context.Context, limit model.RateLimit) error {
+	lg := log.WithFields(getContextLogFields(ctx)).WithField("limit", getRateLimitField(limit))
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	n, err := redis.Int(con.Do("HDEL", getRateLimitKey(limit.Account), getRateLimitField(limit)))
+	if err != nil {
+		lg.WithError(err).Error("failed to delete rate limit")
+		return err
+	}
+	if n == 0 {
+		return model.ErrRateLimitNotFound
+	}
+	if _, err = con.Do("DEL", getBucketKey(limit)); err != nil {
+		lg.WithError(err).Error("failed to delete rate limit bucket")
+		return err
+	}
+	return nil
+}
+
+// TakeTokens take token from every rate limit bucket in one transaction, or no token when any token cannot be taken
+// bucket keys are watched: transaction is retried when any bucket is changed concurrently; bucket key expires when
+// bucket is full again
+func (r *RedisStore) TakeTokens(ctx context.Context, limits []model.RateLimit) ([]time.Duration, int, error) {
+	keys := make([]string, len(limits))
+	for i, limit := range limits {
+		keys[i] = getBucketKey(limit)
+	}
+	lg := log.WithFields(getContextLogFields(ctx)).WithField("buckets", keys)
+	if len(limits) == 0 {
+		return nil, -1, nil
+	}
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	var taken []float64
+	var waits []time.Duration
+	var failed int
+	var now time.Time
+	check := func() error {
+		tokens := make([]float64, len(limits))
+		updated := make([]time.Time, len(limits))
+		for i, key := range keys {
+			fields, err := redis.StringMap(con.Do("HGETALL", key))
+			if err != nil {
+				lg.WithError(err).Error("failed to get rate limit bucket")
+				return err
+			}
+			// expired (missing) bucket is full
+			tokens[i], updated[i] = fieldsToBucket(fields)
+		}
+		now = time.Now()
+		taken, waits, failed = takeTokens(limits, tokens, updated, now)
+		if failed >= 0 {
+			return errNothingToDo
+		}
+		return nil
+	}
+	queueCmds := func() error {
+		for i, key := range keys {
+			if _, err := con.Do("HMSET", redis.Args{}.Add(key).AddFlat(bucketToFields(taken[i], now))...); err != nil {
+				return err
+			}
+			if _, err := con.Do("PEXPIRE", key, int64(bucketTTL(limits[i], taken[i])/time.Millisecond)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := watchTx(con, keys, check, queueCmds, lg); err != nil && err != errNothingToDo {
+		return nil, -1, err
+	}
+	return waits, failed, nil
+}
+
+// DelayRun keep pipeline run queued by rate limit until it is due
+func (r *RedisStore) DelayRun(ctx context.Context, run *model.DelayedRun) error {
+	lg := log.WithFields(getContextLogFields(ctx)).WithFields(log.Fields{"delayed-run": run.ID, "pipeline": run.Pipeline})
+	fields, err := delayedRunToFields(r.cipher, run)
+	if err != nil {
+		lg.WithError(err).Error("failed to encode delayed run")
+		return err
+	}
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	if _, err = con.Do("MULTI"); err != nil {
+		lg.WithError(err).Error("failed to start Redis transaction")
+		return err
+	}
+	if _, err = con.Do("HMSET", redis.Args{}.Add(getDelayedRunKey(run.ID)).AddFlat(fields)...); err != nil {
+		return discardOnError(con, err, lg)
+	}
+	if _, err = con.Do("ZADD", delayQueueKey, 0, run.ID); err != nil {
+		return discardOnError(con, err, lg)
+	}
+	if _, err = con.Do("EXEC"); err != nil {
+		lg.WithError(err).Error("failed to delay pipeline run")
+		return err
+	}
+	return nil
+}
+
+// ClaimDelayedRun replace the oldest due run with the same run with claimed ID; nil when no run is due
+// delayed runs key is watched: run is claimed by one replica only
+func (r *RedisStore) ClaimDelayedRun(ctx context.Context, now time.Time, claimed string) (*model.DelayedRun, error) {
+	lg := log.WithFields(getContextLogFields(ctx)).WithField("claimed-run", claimed)
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	var id string
+	var fields map[string]string
+	check := func() error {
+		ids, err := redis.Strings(con.Do("ZRANGEBYLEX", delayQueueKey, "-", "("+getIDBound(now), "LIMIT", 0, 1))
+		if err != nil {
+			lg.WithError(err).Error("failed to get delayed runs")
+			return err
+		}
+		if len(ids) == 0 {
+			return errNothingToDo
+		}
+		id = ids[0]
+		if fields, err = redis.StringMap(con.Do("HGETALL", getDelayedRunKey(id))); err != nil {
+			lg.WithError(err).Error("failed to get delayed run")
+			return err
+		}
+		return nil
+	}
+	queueCmds := func() error {
+		if _, err := con.Do("ZREM", delayQueueKey, id); err != nil {
+			return err
+		}
+		if _, err := con.Do("DEL", getDelayedRunKey(id)); err != nil {
+			return err
+		}
+		if _, err := con.Do("HMSET", redis.Args{}.Add(getDelayedRunKey(claimed)).AddFlat(fields)...); err != nil {
+			return err
+		}
+		_, err := con.Do("ZADD", delayQueueKey, 0, claimed)
+		return err
+	}
+	err := watchTx(con, []string{delayQueueKey}, check, queueCmds, lg)
+	if err == errNothingToDo {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fieldsToDelayedRun(r.cipher, claimed, fields)
+}
+
+// RemoveDelayedRun remove delayed run
+func (r *RedisStore) RemoveDelayedRun(ctx context.Context, id string) error {
+	lg := log.WithFields(getContextLogFields(ctx)).WithField("delayed-run", id)
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	if _, err := con.Do("MULTI"); err != nil {
+		lg.WithError(err).Error("failed to start Redis transaction")
+		return err
+	}
+	if _, err := con.Do("ZREM", delayQueueKey, id); err != nil {
+		return discardOnError(con, err, lg)
+	}
+	if _, err := con.Do("DEL", getDelayedRunKey(id)); err != nil {
+		return discardOnError(con, err, lg)
+	}
+	if _, err := con.Do("EXEC"); err != nil {
+		lg.WithError(err).Error("failed to remove delayed run")
+		return err
+	}
+	return nil
+}
+
+//-------------------------- AuditLog Interface -------------------------
+
+// AppendAudit add entry to account audit log, removing account entries older than retention
+func (r *RedisStore) AppendAudit(ctx context.Context, entry *model.AuditEntry, retention time.Duration) error {
+	lg := log.WithFields(getContextLogFields(ctx)).WithField("entry", entry.ID)
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	if _, err = con.Do("HSET", getAuditEntryKey(entry.ID), "entry", string(data)); err != nil {
+		lg.WithError(err).Error("failed to store audit entry")
+		return err
+	}
+	// all entry IDs have same score: sorted set is ordered by ID (time)
+	if _, err = con.Do("ZADD", getAuditKey(entry.Account), 0, entry.ID); err != nil {
+		lg.WithError(err).Error("failed to add audit entry")
+		return err
+	}
+	// remove entries older than retention
+	bound := getIDBound(time.Now().Add(-retention))
+	ids, err := redis.Strings(con.Do("ZRANGEBYLEX", getAuditKey(entry.Account), "-", "("+bound))
+	if err != nil {
+		lg.WithError(err).Error("failed to get expired audit entries")
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, getAuditEntryKey(id))
+	}
+	if _, err = con.Do("ZREM", redis.Args{}.Add(getAuditKey(entry.Account)).AddFlat(ids)...); err != nil {
+		lg.WithError(err).Error("failed to remove expired audit entries")
+		return err
+	}
+	if _, err = con.Do("DEL", redis.Args{}.AddFlat(keys)...); err != nil {
+		lg.WithError(err).Error("failed to delete expired audit entries")
+		return err
+	}
+	return nil
+}
+
+// GetAudit list account audit entries matching filter, latest first
+func (r *RedisStore) GetAudit(ctx context.Context, filter model.AuditFilter) ([]model.AuditEntry, error) {
+	lg := log.WithFields(getContextLogFields(ctx)).WithField("account", filter.Account)
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	ids, err := redis.Strings(con.Do("ZRANGE", getAuditKey(filter.Account), 0, -1))
+	if err != nil {
+		lg.WithError(err).Error("failed to get audit entries")
+		return nil, err
+	}
+	entries := make([]model.AuditEntry, 0)
+	for _, id := range filterIDs(ids, filter.From, filter.To) {
+		value, err := redis.String(con.Do("HGET", getAuditEntryKey(id), "entry"))
+		if err != nil {
+			lg.WithField("entry", id).WithError(err).Error("failed to get audit entry")
+			return nil, err
+		}
+		var entry model.AuditEntry
+		if err = json.Unmarshal([]byte(value), &entry); err != nil {
+			lg.WithField("entry", id).WithError(err).Error("failed to decode audit entry")
+			return nil, err
+		}
+		if !filter.Match(&entry) {
+			continue
+		}
+		entries = append(entries, entry)
+		if filter.Limit > 0 && len(entries) == filter.Limit {
+			break
+		}
+	}
+	return entries, nil
+}
+
+//-------------------------- Indexer Interface -------------------------
+
+// Reindex add all existing trigger events to account and type indexes
+// one time backfill for trigger events created before indexes were introduced; safe to run multiple times
+func (r *RedisStore) Reindex(ctx context.Context) (int, error) {
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.Debug("rebuilding trigger event indexes")
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	// scan through all trigger events (without blocking Redis)
+	keys, err := scanAll(con, "", "event:*")
+	if err != nil {
+		lg.WithError(err).Error("failed to scan trigger events")
+		return 0, err
+	}
+	for _, key := range keys {
+		fields, err := redis.Strings(con.Do("HMGET", key, "account", "type", "kind"))
+		if err != nil {
+			lg.WithField("key", key).WithError(err).Error("failed to get trigger event fields")
+			return 0, err
+		}
+		uri := strings.TrimPrefix(key, "event:")
+		// start Redis transaction
+		if _, err = con.Do("MULTI"); err != nil {
+			lg.WithError(err).Error("failed to start Redis transaction")
+			return 0, err
+		}
+		if _, err = con.Do("SADD", getAccountIndexKey(fields[0]), uri); err != nil {
+			return 0, discardOnError(con, err, lg)
+		}
+		if _, err = con.Do("SADD", getTypeIndexKey(fields[1], fields[2]), uri); err != nil {
+			return 0, discardOnError(con, err, lg)
+		}
+		// submit transaction
+		if _, err = con.Do("EXEC"); err != nil {
+			lg.WithError(err).Error("failed to execute transaction")
+			return 0, err
+		}
+	}
+	lg.WithField("events", len(keys)).Debug("trigger event indexes rebuilt")
+	return len(keys), nil
+}
+
+// rewrite legacy filter keys (filter:{event}-{pipeline}) with current key encoding
+// legacy key is found for each trigger (event, pipeline) pair; returns number of rewritten filters
+func (r *RedisStore) encodeFilterKeys(ctx context.Context) (int, error) {
+	lg := log.WithFields(getContextLogFields(ctx))
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	keys, err := scanAll(con, "", getPrefixKey("trigger", "*"))
+	if err != nil {
+		lg.WithError(err).Error("failed to scan triggers")
+		return 0, err
+	}
+	// collect legacy filters; ambiguous legacy key is copied to all matching triggers
+	legacy := make(map[string]map[string]string)
+	filters := make(map[string]map[string]string)
+	for _, key := range keys {
+		event := strings.TrimPrefix(key, "trigger:")
+		pipelines, err := redis.Strings(con.Do("ZRANGE", key, 0, -1))
+		if err != nil {
+			lg.WithField("key", key).WithError(err).Error("failed to get pipelines")
+			return 0, err
+		}
+		for _, pipeline := range pipelines {
+			legacyKey := getLegacyFilterKey(event, pipeline)
+			if _, ok := legacy[legacyKey]; !ok {
+				fields, err := redis.StringMap(con.Do("HGETALL", legacyKey))
+				if err != nil {
+					lg.WithField("key", legacyKey).WithError(err).Error("failed to get trigger filter")
+					return 0, err
+				}
+				legacy[legacyKey] = fields
+			}
+			if len(legacy[legacyKey]) > 0 {
+				filters[getFilterKey(event, pipeline)] = legacy[legacyKey]
+			}
+		}
+	}
+	// store filters with new keys
+	for key, fields := range filters {
+		if _, err = con.Do("HMSET", redis.Args{}.Add(key).AddFlat(fields)...); err != nil {
+			lg.WithField("key", key).WithError(err).Error("failed to store trigger filter")
+			return 0, err
+		}
+	}
+	// delete legacy keys
+	for key, fields := range legacy {
+		if _, ok := filters[key]; ok || len(fields) == 0 {
+			continue
+		}
+		if _, err = con.Do("DEL", key); err != nil {
+			lg.WithField("key", key).WithError(err).Error("failed to delete legacy trigger filter")
+			return 0, err
+		}
+	}
+	return len(filters), nil
+}
+
+//-------------------------- StoreChecker Interface -------------------------
+
+// load all trigger events, triggers, pipelines, paused triggers, filters and indexes
+func (r *RedisStore) loadSnapshot(con redis.Conn) (*storeSnapshot, error) {
+	s := newStoreSnapshot()
+	keys, err := scanAll(con, "", "event:*")
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		fields, err := redis.Strings(con.Do("HMGET", key, "account", "type", "kind"))
+		if err != nil {
+			return nil, err
+		}
+		s.events[trimKeyPrefix(key, "event")] = map[string]string{"account": fields[0], "type": fields[1], "kind": fields[2]}
+	}
+	// triggers and pipelines (sorted sets)
+	for prefix, sets := range map[string]map[string][]string{"trigger": s.triggers, "pipeline": s.pipelines} {
+		keys, err = scanAll(con, "", getPrefixKey(prefix, "*"))
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			if sets[trimKeyPrefix(key, prefix)], err = redis.Strings(con.Do("ZRANGE", key, 0, -1)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// account and type indexes (sets)
+	for _, pattern := range []string{"account:*:events", getTypeIndexKey("*", "*")} {
+		keys, err = scanAll(con, "", pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			if s.indexes[key], err = redis.Strings(con.Do("SMEMBERS", key)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// paused triggers (sets)
+	keys, err = scanAll(con, "", getPrefixKey("paused", "*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if s.paused[trimKeyPrefix(key, "paused")], err = redis.Strings(con.Do("SMEMBERS", key)); err != nil {
+			return nil, err
+		}
+	}
+	s.filters, err = scanAll(con, "", getPrefixKey("filter", "*"))
+	return s, err
+}
+
+// CheckStore check consistency of trigger events, triggers, pipelines, filters and indexes
+// store is scanned without locking (run on idle store); repair fixes all issues in a single transaction
+func (r *RedisStore) CheckStore(ctx context.Context, repair bool) (*model.StoreReport, error) {
+	lg := log.WithFields(getContextLogFields(ctx))
+	lg.WithField("repair", repair).Debug("checking store consistency")
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	s, err := r.loadSnapshot(con)
+	if err != nil {
+		lg.WithError(err).Error("failed to load store data")
+		return nil, err
+	}
+	report, fixes := s.check()
+	if !repair || len(report.Issues) == 0 {
+		return report, nil
+	}
+	// start Redis transaction
+	if _, err = con.Do("MULTI"); err != nil {
+		lg.WithError(err).Error("failed to start Redis transaction")
+		return nil, err
+	}
+	for _, fix := range fixes {
+		if fix == nil {
+			continue
+		}
+		err = fix.applyRedis(con)
+		if err != nil {
+			return nil, discardOnError(con, err, lg)
 		}
-		lg.Warn("event provider does not implement UnsubscribeFromEvent method")
 	}
+	// submit transaction
+	if _, err = con.Do("EXEC"); err != nil {
+		lg.WithError(err).Error("failed to execute transaction")
+		return nil, err
+	}
+	for i, fix := range fixes {
+		report.Issues[i].Repaired = fix != nil
+	}
+	return report, nil
+}
+
+//-------------------------- Schema Interface -------------------------
+
+// GetSchemaVersion get stored data model version
+func (r *RedisStore) GetSchemaVersion(ctx context.Context) (int, error) {
+	lg := log.WithFields(getContextLogFields(ctx))
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	version, err := redis.Int(con.Do("HGET", schemaKey, "version"))
+	if err == nil {
+		return version, nil
+	}
+	if err != redis.ErrNil {
+		lg.WithError(err).Error("failed to get schema version")
+		return 0, err
+	}
+	// no schema version: empty store, or data stored before versioning was introduced
+	_, err = redis.String(con.Do("RANDOMKEY"))
+	if err == redis.ErrNil {
+		return SchemaVersion, nil
+	}
+	if err != nil {
+		lg.WithError(err).Error("failed to check store is empty")
+		return 0, err
+	}
+	return 0, nil
+}
 
+// SetSchemaVersion store data model version
+func (r *RedisStore) SetSchemaVersion(ctx context.Context, version int) error {
+	// get redis connection
+	con := r.redisPool.GetConn()
+	defer con.Close()
+	if _, err := con.Do("HSET", schemaKey, "version", version); err != nil {
+		log.WithFields(getContextLogFields(ctx)).WithError(err).Error("failed to set schema version")
+		return err
+	}
 	return nil
 }
 
@@ -990,6 +2711,7 @@ func (r *RedisStore) DeleteEvent(ctx context.Context, event, context string) err
 // Ping Redis services
 func (r *RedisStore) Ping() (string, error) {
 	con := r.redisPool.GetConn()
+	defer con.Close()
 	// get pong from Redis
 	pong, err := redis.String(con.Do("PING"))
 	if err != nil {
@@ -997,3 +2719,19 @@ func (r *RedisStore) Ping() (string, error) {
 	}
 	return pong, err
 }
+
+// PoolStats Redis connection pool statistics
+func (r *RedisStore) PoolStats() model.PoolStats {
+	if pool, ok := r.redisPool.(*RedisPool); ok {
+		return pool.Stats()
+	}
+	return model.PoolStats{}
+}
+
+// Close close Redis connection pool
+func (r *RedisStore) Close() error {
+	if pool, ok := r.redisPool.(*RedisPool); ok {
+		return pool.Close()
+	}
+	return nil
+}
@@ -0,0 +1,522 @@
+package backend
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	log "github.com/sirupsen/logrus"
+)
+
+/*  Redis Connection Modes
+
+	standalone - single Redis server (host:port)
+	sentinel   - discover current master through Redis Sentinel; connections to old master are dropped on failover
+	cluster    - Redis Cluster: all keys share single hash tag ({hermes}event:..., {hermes}trigger:...), so all keys
+	             are kept in one slot and multi-key transactions keep working; connection follows slot master
+	             LIMITATION: all Hermes data lives on the single master node owning the hash tag slot (and its
+	             replicas): cluster mode gives failover, not sharding; store size and load are bounded by one node
+
+	TLS and ACL username (AUTH username password) are supported in all modes.
+
+*/
+
+// Redis connection modes
+const (
+	RedisStandalone = "standalone"
+	RedisSentinel   = "sentinel"
+	RedisCluster    = "cluster"
+)
+
+// DefaultHashTag hash tag for all keys in Redis Cluster mode
+const DefaultHashTag = "hermes"
+
+// default connection pool settings
+const (
+	defaultMaxIdle     = 3
+	defaultIdleTimeout = 240 * time.Second
+	// idle connection check interval
+	borrowCheckInterval = time.Minute
+)
+
+// number of Redis Cluster slots
+const clusterSlots = 16384
+
+var (
+	errNotMaster     = errors.New("redis server is not master")
+	errNoRedisServer = errors.New("no Redis server address")
+)
+
+// Redis connection pool
+func newPool(config StoreConfig) (*redis.Pool, error) {
+	options, err := redisDialOptions(config)
+	if err != nil {
+		return nil, err
+	}
+	addrs := config.Addrs
+	if len(addrs) == 0 {
+		addrs = []string{net.JoinHostPort(config.Host, strconv.Itoa(config.Port))}
+	}
+	pool := &redis.Pool{
+		MaxIdle:     config.MaxIdle,
+		MaxActive:   config.MaxActive,
+		Wait:        config.Wait,
+		IdleTimeout: config.IdleTimeout,
+	}
+	if pool.MaxIdle == 0 {
+		pool.MaxIdle = defaultMaxIdle
+	}
+	if pool.IdleTimeout == 0 {
+		pool.IdleTimeout = defaultIdleTimeout
+	}
+	// check connections idle for a while before use
+	pool.TestOnBorrow = func(c redis.Conn, t time.Time) error {
+		if time.Since(t) < borrowCheckInterval {
+			return nil
+		}
+		_, err := c.Do("PING")
+		return err
+	}
+	lg := log.WithFields(log.Fields{"mode": config.Mode, "addrs": addrs})
+	switch config.Mode {
+	case "", RedisStandalone:
+		pool.Dial = func() (redis.Conn, error) {
+			c, err := dialRedis(addrs[0], config.Username, config.Password, config.DB, options)
+			if err != nil {
+				lg.WithError(err).Error("failed to connect to the Redis store")
+				return nil, err
+			}
+			return c, nil
+		}
+	case RedisSentinel:
+		if config.MasterName == "" {
+			return nil, errors.New("redis sentinel mode requires master name")
+		}
+		dialSentinel := func(addr string) (redis.Conn, error) {
+			return dialRedis(addr, "", config.SentinelPassword, 0, options)
+		}
+		pool.Dial = func() (redis.Conn, error) {
+			addr, err := sentinelMasterAddr(addrs, config.MasterName, dialSentinel)
+			if err != nil {
+				lg.WithError(err).Error("failed to discover Redis master")
+				return nil, err
+			}
+			c, err := dialRedis(addr, config.Username, config.Password, config.DB, options)
+			if err == nil {
+				err = checkMasterRole(c)
+			}
+			if err != nil {
+				lg.WithField("master", addr).WithError(err).Error("failed to connect to Redis master")
+				return nil, err
+			}
+			return &sentinelConn{Conn: c}, nil
+		}
+		// drop connections to old master after failover: check role of idle connections;
+		// connections in use are dropped on READONLY error
+		pool.TestOnBorrow = func(c redis.Conn, t time.Time) error {
+			if time.Since(t) < borrowCheckInterval {
+				return nil
+			}
+			return checkMasterRole(c)
+		}
+	case RedisCluster:
+		if config.DB != 0 {
+			return nil, errors.New("redis cluster mode supports database 0 only")
+		}
+		tag := config.HashTag
+		if tag == "" {
+			tag = DefaultHashTag
+		}
+		prefix := "{" + tag + "}"
+		lg.WithField("hash-tag", tag).Warn("Redis cluster mode keeps all keys in single hash slot: data is not sharded across cluster nodes")
+		dialNode := func(addr string) (redis.Conn, error) {
+			return dialRedis(addr, config.Username, config.Password, 0, options)
+		}
+		pool.Dial = func() (redis.Conn, error) {
+			addr, err := clusterMasterAddr(addrs, clusterSlot(prefix), dialNode)
+			if err != nil {
+				lg.WithError(err).Error("failed to discover Redis cluster slot master")
+				return nil, err
+			}
+			c, err := dialNode(addr)
+			if err != nil {
+				lg.WithField("master", addr).WithError(err).Error("failed to connect to Redis cluster node")
+				return nil, err
+			}
+			return &clusterConn{Conn: c, prefix: prefix}, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown Redis connection mode: %s", config.Mode)
+	}
+	return pool, nil
+}
+
+// timeout and TLS dial options
+func redisDialOptions(config StoreConfig) ([]redis.DialOption, error) {
+	options := []redis.DialOption{
+		redis.DialConnectTimeout(config.ConnectTimeout),
+		redis.DialReadTimeout(config.ReadTimeout),
+		redis.DialWriteTimeout(config.WriteTimeout),
+	}
+	if !config.TLS {
+		return options, nil
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.TLSSkipVerify}
+	if config.TLSCACert != "" {
+		pem, err := ioutil.ReadFile(config.TLSCACert)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("failed to load CA certificate: %s", config.TLSCACert)
+		}
+	}
+	return append(options,
+		redis.DialUseTLS(true),
+		redis.DialTLSConfig(tlsConfig),
+		redis.DialTLSSkipVerify(config.TLSSkipVerify),
+	), nil
+}
+
+// dial Redis server, authenticate and select database
+func dialRedis(addr, username, password string, db int, options []redis.DialOption) (redis.Conn, error) {
+	c, err := redis.Dial("tcp", addr, options...)
+	if err != nil {
+		return nil, err
+	}
+	if err = redisAuth(c, username, password); err == nil && db != 0 {
+		_, err = c.Do("SELECT", db)
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// authenticate with password or ACL username and password
+// password is ignored when Redis server does not require it
+func redisAuth(c redis.Conn, username, password string) error {
+	if password == "" {
+		return nil
+	}
+	args := []interface{}{password}
+	if username != "" {
+		args = []interface{}{username, password}
+	}
+	if _, err := c.Do("AUTH", args...); err != nil {
+		if strings.Contains(err.Error(), "no password is set") || strings.Contains(err.Error(), "without any password configured") {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// ask sentinels for current master address; first responding sentinel wins
+func sentinelMasterAddr(addrs []string, masterName string, dial func(addr string) (redis.Conn, error)) (string, error) {
+	err := errNoRedisServer
+	for _, addr := range addrs {
+		var c redis.Conn
+		if c, err = dial(addr); err != nil {
+			continue
+		}
+		var master []string
+		master, err = redis.Strings(c.Do("SENTINEL", "get-master-addr-by-name", masterName))
+		c.Close()
+		if err == redis.ErrNil {
+			err = fmt.Errorf("sentinel %s does not know master %s", addr, masterName)
+		}
+		if err == nil && len(master) != 2 {
+			err = fmt.Errorf("sentinel %s: unexpected master address %v", addr, master)
+		}
+		if err != nil {
+			continue
+		}
+		return net.JoinHostPort(master[0], master[1]), nil
+	}
+	return "", err
+}
+
+// check connected Redis server is master
+func checkMasterRole(c redis.Conn) error {
+	reply, err := redis.Values(c.Do("ROLE"))
+	if err != nil {
+		return err
+	}
+	if len(reply) == 0 {
+		return errNotMaster
+	}
+	if role, err := redis.String(reply[0], nil); err != nil || role != "master" {
+		return errNotMaster
+	}
+	return nil
+}
+
+// sentinelConn Redis master connection discovered through Sentinel
+// connection is dropped after READONLY error (master demoted to replica), so pool reconnects to new master
+type sentinelConn struct {
+	redis.Conn
+	err error
+}
+
+func (c *sentinelConn) Err() error {
+	if c.err != nil {
+		return c.err
+	}
+	return c.Conn.Err()
+}
+
+func (c *sentinelConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	reply, err := c.Conn.Do(cmd, args...)
+	if err != nil && strings.HasPrefix(err.Error(), "READONLY ") {
+		c.err = err
+	}
+	return reply, err
+}
+
+// find master node serving cluster slot; ask startup nodes in order
+func clusterMasterAddr(addrs []string, slot int, dial func(addr string) (redis.Conn, error)) (string, error) {
+	err := errNoRedisServer
+	for _, addr := range addrs {
+		var c redis.Conn
+		if c, err = dial(addr); err != nil {
+			continue
+		}
+		var ranges []interface{}
+		ranges, err = redis.Values(c.Do("CLUSTER", "SLOTS"))
+		c.Close()
+		if err != nil {
+			continue
+		}
+		// [[start, end, [ip, port, id], replicas...], ...]
+		for _, r := range ranges {
+			values, e := redis.Values(r, nil)
+			if e != nil || len(values) < 3 {
+				continue
+			}
+			start, _ := redis.Int(values[0], nil)
+			end, _ := redis.Int(values[1], nil)
+			master, e := redis.Values(values[2], nil)
+			if e != nil || len(master) < 2 {
+				continue
+			}
+			if slot < start || slot > end {
+				continue
+			}
+			host, _ := redis.String(master[0], nil)
+			port, _ := redis.Int(master[1], nil)
+			if host == "" {
+				// empty host: same as queried node
+				host, _, _ = net.SplitHostPort(addr)
+			}
+			return net.JoinHostPort(host, strconv.Itoa(port)), nil
+		}
+		err = fmt.Errorf("cluster node %s: no master for slot %d", addr, slot)
+	}
+	return "", err
+}
+
+// cluster key slot: CRC16 of key (or hash tag in {}) modulo 16384
+func clusterSlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key) % clusterSlots)
+}
+
+// CRC16 XMODEM (used by Redis Cluster)
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// clusterConn Redis Cluster node connection: adds hash tag prefix to all keys and removes it from returned keys
+// connection is dropped after MOVED/ASK redirect, so pool reconnects to new slot master
+type clusterConn struct {
+	redis.Conn
+	prefix string
+	err    error
+}
+
+// Redis commands by key arguments; commands not listed here are rejected in cluster mode
+var (
+	clusterNoKeyCommands = map[string]bool{
+		"MULTI": true, "EXEC": true, "DISCARD": true, "UNWATCH": true, "PING": true, "ROLE": true,
+		"ECHO": true, "INFO": true, "SCRIPT": true, "TIME": true,
+	}
+	clusterAllKeysCommands = map[string]bool{
+		"DEL": true, "UNLINK": true, "EXISTS": true, "WATCH": true, "MGET": true,
+	}
+	clusterFirstKeyCommands = map[string]bool{
+		"GET": true, "SET": true, "SETNX": true, "SETEX": true, "PSETEX": true, "GETSET": true,
+		"INCR": true, "INCRBY": true, "DECR": true, "DECRBY": true,
+		"TYPE": true, "EXPIRE": true, "PEXPIRE": true, "EXPIREAT": true, "PEXPIREAT": true, "TTL": true, "PTTL": true, "PERSIST": true,
+		"HGET": true, "HMGET": true, "HSET": true, "HMSET": true, "HSETNX": true, "HGETALL": true, "HDEL": true,
+		"HEXISTS": true, "HINCRBY": true, "HLEN": true, "HKEYS": true, "HVALS": true, "HSCAN": true,
+		"SADD": true, "SREM": true, "SMEMBERS": true, "SISMEMBER": true, "SCARD": true, "SSCAN": true,
+		"ZADD": true, "ZREM": true, "ZRANGE": true, "ZREVRANGE": true, "ZRANGEBYSCORE": true, "ZREVRANGEBYSCORE": true,
+		"ZRANGEBYLEX": true, "ZREMRANGEBYSCORE": true, "ZREMRANGEBYRANK": true, "ZSCORE": true, "ZCARD": true,
+		"ZCOUNT": true, "ZINCRBY": true, "ZRANK": true, "ZSCAN": true,
+		"LPUSH": true, "RPUSH": true, "LPOP": true, "RPOP": true, "LRANGE": true, "LTRIM": true, "LLEN": true, "LREM": true, "LINDEX": true,
+	}
+)
+
+func (c *clusterConn) Err() error {
+	if c.err != nil {
+		return c.err
+	}
+	return c.Conn.Err()
+}
+
+func (c *clusterConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	name := strings.ToUpper(cmd)
+	if name == "RANDOMKEY" {
+		return c.randomKey()
+	}
+	args, err := c.tagKeys(name, args)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.Conn.Do(cmd, args...)
+	if err != nil {
+		if msg := err.Error(); strings.HasPrefix(msg, "MOVED ") || strings.HasPrefix(msg, "ASK ") {
+			c.err = err
+		}
+		return reply, err
+	}
+	return c.untagReply(name, reply)
+}
+
+func (c *clusterConn) Send(cmd string, args ...interface{}) error {
+	args, err := c.tagKeys(strings.ToUpper(cmd), args)
+	if err != nil {
+		return err
+	}
+	return c.Conn.Send(cmd, args...)
+}
+
+func (c *clusterConn) tag(key interface{}) string {
+	return c.prefix + fmt.Sprint(key)
+}
+
+// add hash tag prefix to key arguments
+func (c *clusterConn) tagKeys(name string, args []interface{}) ([]interface{}, error) {
+	tagged := make([]interface{}, len(args))
+	copy(tagged, args)
+	switch {
+	case name == "" || clusterNoKeyCommands[name]:
+	case clusterAllKeysCommands[name]:
+		for i := range tagged {
+			tagged[i] = c.tag(tagged[i])
+		}
+	case clusterFirstKeyCommands[name]:
+		if len(tagged) > 0 {
+			tagged[0] = c.tag(tagged[0])
+		}
+	case name == "EVAL" || name == "EVALSHA":
+		// EVAL script numkeys key [key ...] arg [arg ...]
+		if len(tagged) < 2 {
+			return nil, fmt.Errorf("wrong number of arguments for %s", name)
+		}
+		n, err := strconv.Atoi(fmt.Sprint(tagged[1]))
+		if err != nil || n < 0 || len(tagged) < 2+n {
+			return nil, fmt.Errorf("invalid number of keys for %s", name)
+		}
+		for i := 2; i < 2+n; i++ {
+			tagged[i] = c.tag(tagged[i])
+		}
+	case name == "SCAN":
+		// SCAN cursor [MATCH pattern] [COUNT count]: scan only tagged keys
+		match := false
+		for i := 1; i < len(tagged)-1; i++ {
+			if strings.ToUpper(fmt.Sprint(tagged[i])) == "MATCH" {
+				tagged[i+1] = c.tag(tagged[i+1])
+				match = true
+				break
+			}
+		}
+		if !match {
+			tagged = append(tagged, "MATCH", c.prefix+"*")
+		}
+	case name == "KEYS":
+		if len(tagged) > 0 {
+			tagged[0] = c.tag(tagged[0])
+		}
+	default:
+		return nil, fmt.Errorf("command %s is not supported in Redis cluster mode", name)
+	}
+	return tagged, nil
+}
+
+// remove hash tag prefix from returned keys (SCAN and KEYS)
+func (c *clusterConn) untagReply(name string, reply interface{}) (interface{}, error) {
+	switch name {
+	case "SCAN":
+		values, err := redis.Values(reply, nil)
+		if err != nil || len(values) != 2 {
+			return reply, err
+		}
+		keys, err := c.untagKeys(values[1])
+		return []interface{}{values[0], keys}, err
+	case "KEYS":
+		return c.untagKeys(reply)
+	}
+	return reply, nil
+}
+
+func (c *clusterConn) untagKeys(reply interface{}) (interface{}, error) {
+	keys, err := redis.Strings(reply, nil)
+	if err != nil {
+		return nil, err
+	}
+	untagged := make([]interface{}, len(keys))
+	for i, key := range keys {
+		untagged[i] = []byte(strings.TrimPrefix(key, c.prefix))
+	}
+	return untagged, nil
+}
+
+// RANDOMKEY returns any key of the node: return tagged key instead (nil if there are no tagged keys)
+func (c *clusterConn) randomKey() (interface{}, error) {
+	cursor := "0"
+	for {
+		values, err := redis.Values(c.Conn.Do("SCAN", cursor, "MATCH", c.prefix+"*", "COUNT", scanCount))
+		if err != nil {
+			return nil, err
+		}
+		if len(values) != 2 {
+			return nil, fmt.Errorf("unexpected SCAN reply: %v", values)
+		}
+		keys, err := redis.Strings(values[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(keys) > 0 {
+			return []byte(strings.TrimPrefix(keys[0], c.prefix)), nil
+		}
+		if cursor, err = redis.String(values[0], nil); err != nil || cursor == "0" {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package backend
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/garyburd/redigo/redis"
+	"github.com/rafaeljusto/redigomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/codefresh-io/hermes/pkg/codefresh"
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/codefresh-io/hermes/pkg/provider"
+)
+
+// run in-process Redis master that reports its replication role
+func runRedisMaster(t *testing.T, role string) *miniredis.Miniredis {
+	s := miniredis.RunT(t)
+	s.Server().Register("ROLE", func(c *server.Peer, cmd string, args []string) {
+		c.WriteLen(3)
+		c.WriteBulk(role)
+		c.WriteInt(0)
+		c.WriteLen(0)
+	})
+	return s
+}
+
+// run in-process Redis sentinel that knows "mymaster"
+func runRedisSentinel(t *testing.T, master *miniredis.Miniredis) *miniredis.Miniredis {
+	s := miniredis.RunT(t)
+	s.Server().Register("SENTINEL", func(c *server.Peer, cmd string, args []string) {
+		if len(args) != 2 || strings.ToLower(args[0]) != "get-master-addr-by-name" || args[1] != "mymaster" {
+			c.WriteNull()
+			return
+		}
+		c.WriteStrings([]string{master.Host(), master.Port()})
+	})
+	return s
+}
+
+// create storage backend fixture for Redis store configuration
+func newRedisTestStore(t *testing.T, config StoreConfig) *storeFixture {
+	f := &storeFixture{
+		provider:  provider.NewEventProviderMock(),
+		codefresh: &codefresh.MockPipelineService{},
+	}
+	f.codefresh.On("GetPipeline", mock.Anything, mock.Anything, mock.Anything).Return(&codefresh.Pipeline{}, nil)
+	f.provider.On("UnsubscribeFromEvent", mock.Anything, mock.Anything, mock.Anything).Return(provider.ErrNotImplemented)
+	store, err := NewStore("redis", config, f.codefresh, f.provider)
+	if err != nil {
+		t.Fatalf("failed to create redis store: %v", err)
+	}
+	f.Store = store
+	return f
+}
+
+func TestRedisStore_modes(t *testing.T) {
+	tests := []struct {
+		name   string
+		config func(t *testing.T, s *miniredis.Miniredis) StoreConfig
+		prefix string
+	}{
+		{
+			name: "sentinel",
+			config: func(t *testing.T, s *miniredis.Miniredis) StoreConfig {
+				sentinel := runRedisSentinel(t, s)
+				return StoreConfig{Mode: RedisSentinel, Addrs: []string{"127.0.0.1:1", sentinel.Addr()}, MasterName: "mymaster"}
+			},
+		},
+		{
+			name: "single connection pool",
+			config: func(t *testing.T, s *miniredis.Miniredis) StoreConfig {
+				// blocks if connection is not returned or two connections are used at once
+				return StoreConfig{Addrs: []string{s.Addr()}, MaxActive: 1, Wait: true}
+			},
+		},
+		{
+			name: "cluster",
+			config: func(t *testing.T, s *miniredis.Miniredis) StoreConfig {
+				return StoreConfig{Mode: RedisCluster, Addrs: []string{s.Addr()}}
+			},
+			prefix: "{hermes}",
+		},
+		{
+			name: "cluster with hash tag",
+			config: func(t *testing.T, s *miniredis.Miniredis) StoreConfig {
+				return StoreConfig{Mode: RedisCluster, Addrs: []string{s.Addr()}, HashTag: "ci"}
+			},
+			prefix: "{ci}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, ct := range storeConformanceTests {
+				t.Run(ct.name, func(t *testing.T) {
+					s := runRedisMaster(t, "master")
+					ct.run(t, newRedisTestStore(t, tt.config(t, s)))
+					for _, key := range s.Keys() {
+						assert.True(t, strings.HasPrefix(key, tt.prefix), key)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestRedisStore_sentinelReplica(t *testing.T) {
+	s := runRedisMaster(t, "slave")
+	sentinel := runRedisSentinel(t, s)
+	f := newRedisTestStore(t, StoreConfig{Mode: RedisSentinel, Addrs: []string{sentinel.Addr()}, MasterName: "mymaster"})
+	_, err := f.Ping()
+	assert.Error(t, err)
+	// unknown master
+	f = newRedisTestStore(t, StoreConfig{Mode: RedisSentinel, Addrs: []string{sentinel.Addr()}, MasterName: "other"})
+	_, err = f.Ping()
+	assert.Error(t, err)
+}
+
+func TestRedisStore_sentinelRoleCheck(t *testing.T) {
+	var mu sync.Mutex
+	roleChecks := 0
+	s := miniredis.RunT(t)
+	s.Server().Register("ROLE", func(c *server.Peer, cmd string, args []string) {
+		mu.Lock()
+		roleChecks++
+		mu.Unlock()
+		c.WriteLen(3)
+		c.WriteBulk("master")
+		c.WriteInt(0)
+		c.WriteLen(0)
+	})
+	sentinel := runRedisSentinel(t, s)
+	store, err := NewRedisStore(StoreConfig{Mode: RedisSentinel, Addrs: []string{sentinel.Addr()}, MasterName: "mymaster"}, nil, nil)
+	assert.NoError(t, err)
+	defer store.Close()
+	for i := 0; i < 3; i++ {
+		_, err = store.Ping()
+		assert.NoError(t, err)
+	}
+	// role is checked on dial only, not on every borrow of recently used connection
+	mu.Lock()
+	assert.Equal(t, 1, roleChecks)
+	mu.Unlock()
+}
+
+func Test_sentinelConn(t *testing.T) {
+	r := redigomock.NewConn()
+	c := &sentinelConn{Conn: r}
+	r.Command("GET", "key").ExpectError(redis.Error("ERR wrong type"))
+	_, err := c.Do("GET", "key")
+	assert.Error(t, err)
+	assert.NoError(t, c.Err())
+	// master demoted to replica drops connection
+	r.Command("SET", "key", "value").ExpectError(redis.Error("READONLY You can't write against a read only replica."))
+	_, err = c.Do("SET", "key", "value")
+	assert.Error(t, err)
+	assert.Error(t, c.Err())
+}
+
+func TestRedisStore_dialError(t *testing.T) {
+	s := miniredis.RunT(t)
+	addr := s.Addr()
+	s.Close()
+	store, err := NewRedisStore(StoreConfig{Addrs: []string{addr}, ConnectTimeout: time.Second}, nil, nil)
+	assert.NoError(t, err)
+	// dial error is returned to caller
+	_, err = store.Ping()
+	assert.Error(t, err)
+	assert.Equal(t, 0, store.PoolStats().Active)
+}
+
+func TestRedisStore_PoolStats(t *testing.T) {
+	s := miniredis.RunT(t)
+	store, err := NewRedisStore(StoreConfig{Addrs: []string{s.Addr()}, MaxActive: 1, Wait: true}, nil, nil)
+	assert.NoError(t, err)
+	defer store.Close()
+	_, err = store.Ping()
+	assert.NoError(t, err)
+	assert.Equal(t, model.PoolStats{Active: 1, Idle: 1}, store.PoolStats())
+	// wait for connection in use
+	pool := store.redisPool.(*RedisPool)
+	con := pool.GetConn()
+	_, err = con.Do("PING")
+	assert.NoError(t, err)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		con.Close()
+	}()
+	_, err = store.Ping()
+	assert.NoError(t, err)
+	stats := store.PoolStats()
+	assert.Equal(t, int64(1), stats.WaitCount)
+	assert.True(t, stats.WaitDuration > 0)
+}
+
+func TestRedisStore_PoolStatsConcurrent(t *testing.T) {
+	s := miniredis.RunT(t)
+	store, err := NewRedisStore(StoreConfig{Addrs: []string{s.Addr()}, MaxActive: 2, Wait: true}, nil, nil)
+	assert.NoError(t, err)
+	defer store.Close()
+	// concurrent callers wait for connections and read stats meanwhile (run with -race)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := store.Ping()
+			assert.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			store.PoolStats()
+		}()
+	}
+	wg.Wait()
+	stats := store.PoolStats()
+	assert.True(t, stats.WaitCount >= 0 && stats.WaitCount <= 20)
+	assert.True(t, stats.Active <= 2)
+}
+
+func TestNewRedisStore_invalidConfig(t *testing.T) {
+	for _, config := range []StoreConfig{
+		{Mode: "unknown"},
+		{Mode: RedisSentinel},
+		{Mode: RedisCluster, DB: 1},
+		{TLS: true, TLSCACert: "missing.pem"},
+	} {
+		_, err := NewRedisStore(config, nil, nil)
+		assert.Error(t, err, config.Mode)
+	}
+}
+
+func Test_redisAuth(t *testing.T) {
+	s := miniredis.RunT(t)
+	s.RequireUserAuth("hermes", "secret")
+	_, err := dialRedis(s.Addr(), "hermes", "wrong", 0, nil)
+	assert.Error(t, err)
+	c, err := dialRedis(s.Addr(), "hermes", "secret", 0, nil)
+	if assert.NoError(t, err) {
+		_, err = c.Do("PING")
+		assert.NoError(t, err)
+		c.Close()
+	}
+	// password ignored when not required
+	c, err = dialRedis(miniredis.RunT(t).Addr(), "", "redisPassword", 0, nil)
+	if assert.NoError(t, err) {
+		c.Close()
+	}
+}
+
+func Test_clusterSlot(t *testing.T) {
+	tests := []struct {
+		key  string
+		slot int
+	}{
+		{"foo", 12182},
+		{"123456789", 12739},
+		{"{user1000}.following", 3443},
+		{"{user1000}.followers", 3443},
+		{"{hermes}event:uri", clusterSlot("hermes")},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.slot, clusterSlot(tt.key), tt.key)
+	}
+	assert.NotEqual(t, clusterSlot("foo{}{bar}"), clusterSlot("bar"))
+}
+
+func Test_clusterConn(t *testing.T) {
+	r := redigomock.NewConn()
+	c := &clusterConn{Conn: r, prefix: "{hermes}"}
+
+	r.Command("HGETALL", "{hermes}event:uri").Expect([]interface{}{})
+	r.Command("DEL", "{hermes}event:uri", "{hermes}trigger:uri").Expect(int64(2))
+	r.Command("ZADD", "{hermes}pipeline:p1", 0, "uri").Expect(int64(1))
+	r.Command("MULTI").Expect("OK")
+	r.Command("EVAL", "script", 2, "{hermes}a", "{hermes}b", "arg").Expect(int64(1))
+	r.Command("SCAN", "0", "MATCH", "{hermes}event:*", "COUNT", 10).Expect([]interface{}{
+		[]byte("0"), []interface{}{[]byte("{hermes}event:uri")},
+	})
+	r.Command("SCAN", "0", "MATCH", "{hermes}*", "COUNT", scanCount).Expect([]interface{}{[]byte("0"), []interface{}{}})
+
+	_, err := c.Do("HGETALL", "event:uri")
+	assert.NoError(t, err)
+	_, err = c.Do("DEL", "event:uri", "trigger:uri")
+	assert.NoError(t, err)
+	_, err = c.Do("ZADD", "pipeline:p1", 0, "uri")
+	assert.NoError(t, err)
+	_, err = c.Do("MULTI")
+	assert.NoError(t, err)
+	_, err = c.Do("EVAL", "script", 2, "a", "b", "arg")
+	assert.NoError(t, err)
+	reply, err := redis.Values(c.Do("SCAN", "0", "MATCH", "event:*", "COUNT", 10))
+	assert.NoError(t, err)
+	keys, err := redis.Strings(reply[1], nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"event:uri"}, keys)
+	_, err = redis.String(c.Do("RANDOMKEY"))
+	assert.Equal(t, redis.ErrNil, err)
+	assert.NoError(t, r.ExpectationsWereMet())
+
+	// unsupported command and invalid number of keys
+	_, err = c.Do("FLUSHALL")
+	assert.Error(t, err)
+	_, err = c.Do("EVAL", "script", 3, "a")
+	assert.Error(t, err)
+	// redirect drops connection
+	assert.NoError(t, c.Err())
+	r.Command("GET", "{hermes}key").ExpectError(redis.Error("MOVED 3999 127.0.0.1:6381"))
+	_, err = c.Do("GET", "key")
+	assert.Error(t, err)
+	assert.Error(t, c.Err())
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -23,6 +24,16 @@ func setContext(account string) context.Context {
 	return context.WithValue(context.Background(), model.ContextKeyAccount, account)
 }
 
+// mock SSCAN command for single page scan
+func mockScan(con redis.Conn, key, pattern string) *redigomock.Cmd {
+	return con.(*redigomock.Conn).Command("SSCAN", key, 0, "MATCH", pattern, "COUNT", scanCount)
+}
+
+// SCAN/SSCAN reply: last page (zero cursor) with members
+func scanReply(members []string) []interface{} {
+	return []interface{}{int64(0), util.InterfaceSlice(members)}
+}
+
 type RedisPoolMock struct {
 	conn *redigomock.Conn
 }
@@ -66,7 +77,7 @@ func Test_getTriggerKey(t *testing.T) {
 				account: "test-account",
 				id:      "trigger:github.com:project:test",
 			},
-			want: "trigger:github.com:project:test:" + model.CalculateAccountHash("test-account"),
+			want: "trigger:trigger:github.com:project:test:" + model.CalculateAccountHash("test-account"),
 		},
 		{
 			name: "with prefix and suffix",
@@ -74,7 +85,7 @@ func Test_getTriggerKey(t *testing.T) {
 				account: "test-account",
 				id:      "trigger:github.com:project:test:" + model.CalculateAccountHash("test-account"),
 			},
-			want: "trigger:github.com:project:test:" + model.CalculateAccountHash("test-account"),
+			want: "trigger:trigger:github.com:project:test:" + model.CalculateAccountHash("test-account"),
 		},
 		{
 			name: "empty",
@@ -82,7 +93,7 @@ func Test_getTriggerKey(t *testing.T) {
 				account: "test-account",
 				id:      "",
 			},
-			want: "trigger:*:" + model.CalculateAccountHash("test-account"),
+			want: "trigger::" + model.CalculateAccountHash("test-account"),
 		},
 		{
 			name: "any account",
@@ -100,6 +111,22 @@ func Test_getTriggerKey(t *testing.T) {
 			},
 			want: "trigger:*:" + model.CalculateAccountHash("test-account"),
 		},
+		{
+			name: "account hash inside last segment",
+			args: args{
+				account: "test-account",
+				id:      "github.com:project:test-" + model.PublicAccountHash,
+			},
+			want: "trigger:github.com:project:test-" + model.PublicAccountHash + ":" + model.CalculateAccountHash("test-account"),
+		},
+		{
+			name: "last segment of other account hash",
+			args: args{
+				account: "test-account",
+				id:      "github.com:project:" + model.CalculateAccountHash("other-account"),
+			},
+			want: "trigger:github.com:project:" + model.CalculateAccountHash("other-account") + ":" + model.CalculateAccountHash("test-account"),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -110,6 +137,107 @@ func Test_getTriggerKey(t *testing.T) {
 	}
 }
 
+func Test_matchEventAccount(t *testing.T) {
+	hash := model.CalculateAccountHash("test-account")
+	tests := []struct {
+		uri     string
+		account bool
+		public  bool
+	}{
+		{"registry:dockerhub:repo:" + hash, true, false},
+		{"registry:dockerhub:repo:" + model.PublicAccountHash, false, true},
+		// hash is not a separate URI segment
+		{"registry:dockerhub:repo" + hash, false, false},
+		{"registry:dockerhub:repo-" + model.PublicAccountHash, false, false},
+		// account hash followed by another segment
+		{"registry:dockerhub:" + hash + ":push", false, false},
+		{"registry:dockerhub:repo:" + strings.ToUpper(hash), false, false},
+		{hash, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			assert.Equal(t, tt.account, model.MatchAccount("test-account", tt.uri))
+			assert.Equal(t, tt.public, model.MatchPublicAccount(tt.uri))
+		})
+	}
+}
+
+func Test_getEventPattern(t *testing.T) {
+	tests := []struct {
+		account string
+		filter  string
+		want    string
+	}{
+		{"test-account", "", "*:" + model.CalculateAccountHash("test-account")},
+		{"test-account", "*", "*:" + model.CalculateAccountHash("test-account")},
+		{"test-account", "registry:*", "registry:*:" + model.CalculateAccountHash("test-account")},
+		{"-", "", "*"},
+		{"-", "registry:*", "registry:*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.account+"/"+tt.filter, func(t *testing.T) {
+			assert.Equal(t, tt.want, getEventPattern(tt.account, tt.filter))
+		})
+	}
+}
+
+func Test_getFilterKey(t *testing.T) {
+	// pairs that shared the same legacy key 'filter:{event}-{pipeline}'
+	tests := []struct {
+		event1, pipeline1 string
+		event2, pipeline2 string
+	}{
+		{"a-b", "c", "a", "b-c"},
+		{"registry:dockerhub:x:h-y:h", "p", "registry:dockerhub:x:h", "y:h-p"},
+		{"event:x-", "1", "event:x", "-1"},
+		{"x-pipeline", "1", "x", "pipeline-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.event1+"/"+tt.pipeline1, func(t *testing.T) {
+			assert.Equal(t, getLegacyFilterKey(tt.event1, tt.pipeline1), getLegacyFilterKey(tt.event2, tt.pipeline2))
+			assert.NotEqual(t, getFilterKey(tt.event1, tt.pipeline1), getFilterKey(tt.event2, tt.pipeline2))
+		})
+	}
+}
+
+func Test_getFilterKey_injective(t *testing.T) {
+	// enumerate all strings up to 4 characters from alphabet of key separators
+	alphabet := []string{"a", "-", ":", "1"}
+	values := []string{""}
+	for i, n := 0, 0; i < 4; i++ {
+		next := len(values)
+		for _, v := range values[n:] {
+			for _, c := range alphabet {
+				values = append(values, v+c)
+			}
+		}
+		n = next
+	}
+	seen := make(map[string][2]string)
+	for _, event := range values {
+		for _, pipeline := range values {
+			key := getFilterKey(event, pipeline)
+			if prev, ok := seen[key]; ok {
+				t.Fatalf("filter key %s collision: %v and %v", key, prev, [2]string{event, pipeline})
+			}
+			seen[key] = [2]string{event, pipeline}
+			e, p, err := parseFilterKey(key)
+			if err != nil || e != event || p != pipeline {
+				t.Fatalf("parseFilterKey(%s) = %s, %s, %v", key, e, p, err)
+			}
+		}
+	}
+}
+
+func Test_parseFilterKey_invalid(t *testing.T) {
+	for _, key := range []string{"", "filter", "filter:a-b", "trigger:1:a:b", "filter:x:a:b", "filter:5:a:b", "filter:1:ab", "filter:-1:a:b"} {
+		t.Run(key, func(t *testing.T) {
+			_, _, err := parseFilterKey(key)
+			assert.Error(t, err)
+		})
+	}
+}
+
 func TestMain(m *testing.M) {
 	util.TestMode = true
 	os.Exit(m.Run())
@@ -167,6 +295,7 @@ func TestRedisStore_GetTriggerPipelines(t *testing.T) {
 		name      string
 		args      args
 		pipelines []string
+		paused    []string
 		filters   map[string]filter
 		exists    int64
 		want      []string
@@ -185,6 +314,17 @@ func TestRedisStore_GetTriggerPipelines(t *testing.T) {
 			pipelines: []string{"pipeline1", "pipeline2", "pipeline3"},
 			want:      []string{"pipeline1", "pipeline2", "pipeline3"},
 		},
+		{
+			name: "skip paused pipelines",
+			args: args{
+				account: model.PublicAccount,
+				event:   "uri:test:" + model.PublicAccountHash,
+			},
+			exists:    1,
+			pipelines: []string{"pipeline1", "pipeline2", "pipeline3"},
+			paused:    []string{"pipeline2"},
+			want:      []string{"pipeline1", "pipeline3"},
+		},
 		{
 			name: "get filtered pipelines for event",
 			args: args{
@@ -333,6 +473,7 @@ func TestRedisStore_GetTriggerPipelines(t *testing.T) {
 			} else {
 				cmd.Expect(util.InterfaceSlice(tt.pipelines))
 			}
+			r.redisPool.GetConn().(*redigomock.Conn).Command("SMEMBERS", getPausedKey(tt.args.account, tt.args.event)).Expect(util.InterfaceSlice(tt.paused))
 			if len(tt.args.vars) > 0 {
 				for _, pipeline := range tt.pipelines {
 					cmd = r.redisPool.GetConn().(*redigomock.Conn).Command("HGETALL", getFilterKey(tt.args.event, pipeline))
@@ -528,6 +669,9 @@ func TestRedisStore_DeleteTrigger(t *testing.T) {
 				cmd.ExpectError(errors.New("DEL error"))
 			}
 
+			// remove pipeline from paused triggers
+			r.redisPool.GetConn().(*redigomock.Conn).Command("SREM", getPausedKey(tt.args.account, tt.args.event), tt.args.pipeline).Expect("QUEUED")
+
 		EndTransaction:
 			// discard transaction on error
 			if tt.wantErr && !tt.errs.exec {
@@ -559,6 +703,7 @@ func TestRedisStore_CreateTrigger(t *testing.T) {
 		mismatch         bool
 		nonexisting      bool
 		pipelinemismatch bool
+		noevent          bool
 		multi            bool
 		zadd1            bool
 		zadd2            bool
@@ -632,6 +777,16 @@ func TestRedisStore_CreateTrigger(t *testing.T) {
 			errs:    Errors{nonexisting: true},
 			wantErr: true,
 		},
+		{
+			name: "non-existing trigger event",
+			args: args{
+				account:  "A",
+				event:    "uri:test:" + model.CalculateAccountHash("A"),
+				pipeline: "owner:repo:test",
+			},
+			wantErr: true,
+			errs:    Errors{noevent: true},
+		},
 		{
 			name: "fail start transaction",
 			args: args{
@@ -711,6 +866,15 @@ func TestRedisStore_CreateTrigger(t *testing.T) {
 					Account: tt.args.account,
 				}, nil)
 			}
+			// watch trigger event and check its existence
+			r.redisPool.GetConn().(*redigomock.Conn).Command("WATCH", getEventKey(tt.args.account, tt.args.event)).Expect("OK!")
+			cmd = r.redisPool.GetConn().(*redigomock.Conn).Command("EXISTS", getEventKey(tt.args.account, tt.args.event))
+			if tt.errs.noevent {
+				cmd.Expect(int64(0))
+				r.redisPool.GetConn().(*redigomock.Conn).Command("UNWATCH").Expect("OK!")
+				goto Invoke
+			}
+			cmd.Expect(int64(1))
 			// expect Redis transaction open
 			cmd = r.redisPool.GetConn().(*redigomock.Conn).Command("MULTI")
 			if tt.errs.multi {
@@ -1023,28 +1187,32 @@ func TestRedisStore_GetEventTriggers(t *testing.T) {
 			triggers = append(triggers, tt.expected.public...)
 			// keep pipelines
 			var pipelines []string
-			// get keys from Triggers Set
+			// get trigger keys from account and public indexes
 			keys := make([]string, 0)
-			cmd := r.redisPool.GetConn().(*redigomock.Conn).Command("KEYS", getTriggerKey(tt.args.account, tt.args.event))
+			var uris, publicURIs []string
+			for _, tr := range tt.expected.private {
+				uris = append(uris, tr.event)
+			}
+			for _, tr := range tt.expected.public {
+				publicURIs = append(publicURIs, tr.event)
+			}
+			if tt.args.account == model.PublicAccount {
+				uris = publicURIs
+			}
+			cmd := mockScan(r.redisPool.GetConn(), getAccountIndexKey(tt.args.account), getEventPattern(tt.args.account, tt.args.event))
 			if tt.errs.keys {
-				cmd.ExpectError(errors.New("KEYS error"))
+				cmd.ExpectError(errors.New("SSCAN error"))
 				goto Invoke
 			} else {
-				for _, tr := range tt.expected.private {
-					keys = util.MergeStrings(keys, []string{getTriggerKey(tt.args.account, tr.event)})
-				}
-				cmd.Expect(util.InterfaceSlice(keys))
+				cmd.Expect(scanReply(uris))
 			}
 			// get public triggers matching event
-			cmd = r.redisPool.GetConn().(*redigomock.Conn).Command("KEYS", getTriggerKey(model.PublicAccount, tt.args.event))
-			if tt.errs.keys {
-				cmd.ExpectError(errors.New("KEYS error"))
-				goto Invoke
-			} else {
-				for _, tr := range tt.expected.public {
-					keys = util.MergeStrings(keys, []string{getTriggerKey(model.PublicAccount, tr.event)})
-				}
-				cmd.Expect(util.InterfaceSlice(keys))
+			if tt.args.account != model.PublicAccount {
+				cmd = mockScan(r.redisPool.GetConn(), getAccountIndexKey(model.PublicAccount), getEventPattern(model.PublicAccount, tt.args.event))
+				cmd.Expect(scanReply(publicURIs))
+			}
+			for _, uri := range util.MergeStrings(publicURIs, uris) {
+				keys = append(keys, getTriggerKey(tt.args.account, uri))
 			}
 
 			// get pipelines from Triggers Set
@@ -1063,6 +1231,8 @@ func TestRedisStore_GetEventTriggers(t *testing.T) {
 						}
 					}
 				}
+				// no paused triggers
+				r.redisPool.GetConn().(*redigomock.Conn).Command("SMEMBERS", "paused:"+strings.TrimPrefix(k, "trigger:")).Expect([]interface{}{})
 			}
 
 			// get filters for pipelines
@@ -1352,6 +1522,7 @@ func TestRedisStore_GetPipelineTriggers(t *testing.T) {
 					goto Invoke
 				} else {
 					cmd.ExpectMap(tt.expected.filters[event])
+					r.redisPool.GetConn().(*redigomock.Conn).Command("SISMEMBER", getPausedKey(tt.args.account, event), tt.args.pipeline).Expect(int64(0))
 					// get event
 					if tt.args.withEvent {
 						mockEventGetter.On("GetEvent", ctx, event).Return(&tt.want[i].EventData, nil)
@@ -1489,7 +1660,7 @@ func TestRedisStore_GetEvent(t *testing.T) {
 			redisMock := &RedisPoolMock{}
 			r := &RedisStore{
 				redisPool:   redisMock,
-				eventGetter: &RedisEventGetter{redisMock},
+				eventGetter: &RedisEventGetter{redisPool: redisMock},
 			}
 			eventKey := getEventKey(tt.args.account, tt.args.event)
 			cmd := r.redisPool.GetConn().(*redigomock.Conn).Command("EXISTS", eventKey)
@@ -1524,10 +1695,9 @@ func TestRedisStore_GetEvent(t *testing.T) {
 
 func TestRedisStore_GetEvents(t *testing.T) {
 	type Errors struct {
-		keys       bool
-		pubKeys    bool
-		pubKeysNil bool
-		getEvent   bool
+		keys     bool
+		pubKeys  bool
+		getEvent bool
 	}
 	type expect struct {
 		keys    []string
@@ -1629,7 +1799,6 @@ func TestRedisStore_GetEvents(t *testing.T) {
 				{URI: "uri:1:" + model.CalculateAccountHash("A"), Type: "t1", Kind: "k1", Secret: "s1", Account: "A"},
 				{URI: "uri:2:" + model.CalculateAccountHash("A"), Type: "t1", Kind: "k2", Secret: "s2", Account: "A"},
 			},
-			errs: Errors{pubKeysNil: true},
 		},
 		{
 			name: "get trigger events by filter",
@@ -1682,21 +1851,21 @@ func TestRedisStore_GetEvents(t *testing.T) {
 			},
 		},
 		{
-			name:    "keys error",
+			name:    "scan error",
 			args:    args{},
 			expect:  expect{},
 			errs:    Errors{keys: true},
 			wantErr: true,
 		},
 		{
-			name:    "public keys error",
+			name:    "public scan error",
 			args:    args{account: "A", public: true},
 			expect:  expect{},
 			errs:    Errors{pubKeys: true},
 			wantErr: true,
 		},
 		{
-			name: "public keys Nil error",
+			name: "public index is empty",
 			args: args{account: "test-account", public: true},
 			expect: expect{
 				keys: []string{
@@ -1709,8 +1878,6 @@ func TestRedisStore_GetEvents(t *testing.T) {
 			want: []model.Event{
 				{URI: "uri:1:" + model.CalculateAccountHash("test-account"), Type: "t1", Kind: "k1", Secret: "s1", Account: "test-account"},
 			},
-			errs:    Errors{pubKeysNil: true},
-			wantErr: false,
 		},
 		{
 			name: "fail to get event",
@@ -1739,24 +1906,22 @@ func TestRedisStore_GetEvents(t *testing.T) {
 			}
 			// keys includes both private and public keys
 			keys := append(tt.expect.keys, tt.expect.pubKeys...)
-			// mock getting trigger event keys
-			cmd := r.redisPool.GetConn().(*redigomock.Conn).Command("KEYS", getEventKey(tt.args.account, tt.args.filter))
+			// mock scanning account index
+			cmd := mockScan(r.redisPool.GetConn(), getAccountIndexKey(tt.args.account), getEventPattern(tt.args.account, tt.args.filter))
 			if tt.errs.keys {
-				cmd.ExpectError(errors.New("KEYS error"))
+				cmd.ExpectError(errors.New("SSCAN error"))
 				goto Invoke
 			} else {
-				cmd.Expect(util.InterfaceSlice(tt.expect.keys))
+				cmd.Expect(scanReply(tt.expect.keys))
 			}
 			// add public keys
 			if tt.args.public {
-				cmd = r.redisPool.GetConn().(*redigomock.Conn).Command("KEYS", getEventKey(model.PublicAccount, tt.args.filter))
+				cmd = mockScan(r.redisPool.GetConn(), getAccountIndexKey(model.PublicAccount), getEventPattern(model.PublicAccount, tt.args.filter))
 				if tt.errs.pubKeys {
-					cmd.ExpectError(errors.New("Public KEYS error"))
+					cmd.ExpectError(errors.New("Public SSCAN error"))
 					goto Invoke
-				} else if tt.errs.pubKeysNil {
-					cmd.ExpectError(redis.ErrNil)
 				} else {
-					cmd.Expect(util.InterfaceSlice(tt.expect.pubKeys))
+					cmd.Expect(scanReply(tt.expect.pubKeys))
 				}
 			}
 			// mock scanning trough all trigger events
@@ -1789,11 +1954,12 @@ func TestRedisStore_GetEvents(t *testing.T) {
 func TestRedisStore_DeleteEvent(t *testing.T) {
 	type redisErrors struct {
 		exists     bool
-		hget       bool
+		hmget      bool
 		multi      bool
 		zrange     bool
 		delEvent   bool
 		delTrigger bool
+		srem       bool
 		exec       bool
 	}
 	type expected struct {
@@ -1903,13 +2069,13 @@ func TestRedisStore_DeleteEvent(t *testing.T) {
 			errs:      redisErrors{exists: true},
 		},
 		{
-			name: "hget error",
+			name: "hmget error",
 			args: args{
 				account: "test-account",
 				event:   "uri:test",
 			},
 			wantErr: errors.New("REDIS error"),
-			errs:    redisErrors{hget: true},
+			errs:    redisErrors{hmget: true},
 		},
 		{
 			name: "zrange error",
@@ -1941,6 +2107,12 @@ func TestRedisStore_DeleteEvent(t *testing.T) {
 			wantErr: errors.New("REDIS error"),
 			errs:    redisErrors{delTrigger: true},
 		},
+		{
+			name:    "srem error",
+			args:    args{event: "uri:test"},
+			wantErr: errors.New("REDIS error"),
+			errs:    redisErrors{srem: true},
+		},
 		{
 			name:    "exec error",
 			args:    args{event: "uri:test"},
@@ -1961,8 +2133,10 @@ func TestRedisStore_DeleteEvent(t *testing.T) {
 			// mock Redis
 			eventKey := getEventKey(tt.args.account, tt.args.event)
 			triggerKey := getTriggerKey(tt.args.account, tt.args.event)
-			// expect Redis transaction open
 			var cmd *redigomock.Cmd
+			// watch trigger event and triggers; unwatch on failed check
+			r.redisPool.GetConn().(*redigomock.Conn).Command("WATCH", eventKey, triggerKey).Expect("OK!")
+			r.redisPool.GetConn().(*redigomock.Conn).Command("UNWATCH").Expect("OK!")
 			// check existence
 			cmd = r.redisPool.GetConn().(*redigomock.Conn).Command("EXISTS", eventKey)
 			if tt.notExists {
@@ -1975,13 +2149,13 @@ func TestRedisStore_DeleteEvent(t *testing.T) {
 			} else {
 				cmd.Expect(int64(1))
 			}
-			// get account
-			cmd = r.redisPool.GetConn().(*redigomock.Conn).Command("HGET", eventKey, "account")
-			if tt.errs.hget {
+			// get account, type and kind
+			cmd = r.redisPool.GetConn().(*redigomock.Conn).Command("HMGET", eventKey, "account", "type", "kind")
+			if tt.errs.hmget {
 				cmd.ExpectError(tt.wantErr)
 				goto Invoke
 			} else {
-				cmd.Expect(tt.expected.account)
+				cmd.Expect([]interface{}{tt.expected.account, "type", "kind"})
 			}
 			if tt.anotherAccount {
 				goto Invoke
@@ -2013,17 +2187,27 @@ func TestRedisStore_DeleteEvent(t *testing.T) {
 				cmd.Expect("QUEUED")
 			}
 			// delete trigger
-			cmd = r.redisPool.GetConn().(*redigomock.Conn).Command("DEL", triggerKey)
+			cmd = r.redisPool.GetConn().(*redigomock.Conn).Command("DEL", triggerKey, "paused:"+strings.TrimPrefix(triggerKey, "trigger:"))
 			if tt.errs.delTrigger {
 				cmd.ExpectError(tt.wantErr)
 				goto EndTransaction
 			} else {
 				cmd.Expect("QUEUED")
 			}
+			// remove from account index
+			cmd = r.redisPool.GetConn().(*redigomock.Conn).Command("SREM", getAccountIndexKey(tt.expected.account), strings.TrimPrefix(eventKey, "event:"))
+			if tt.errs.srem {
+				cmd.ExpectError(tt.wantErr)
+				goto EndTransaction
+			} else {
+				cmd.Expect("QUEUED")
+			}
+			// remove from type index
+			r.redisPool.GetConn().(*redigomock.Conn).Command("SREM", getTypeIndexKey("type", "kind"), strings.TrimPrefix(eventKey, "event:")).Expect("QUEUED")
 
 		EndTransaction:
 			// discard transaction on error
-			if (tt.errs.delEvent || tt.errs.delTrigger) && !tt.errs.exec {
+			if (tt.errs.delEvent || tt.errs.delTrigger || tt.errs.srem) && !tt.errs.exec {
 				// expect transaction discard on error
 				r.redisPool.GetConn().(*redigomock.Conn).Command("DISCARD").Expect("OK!")
 			} else {
@@ -2067,6 +2251,7 @@ func TestRedisStore_CreateEvent(t *testing.T) {
 		hsetnxEndpoint bool
 		hsetnxHelp     bool
 		hsetnxStatus   bool
+		sadd           bool
 		exec           bool
 	}
 	type eventErrors struct {
@@ -2288,6 +2473,16 @@ func TestRedisStore_CreateEvent(t *testing.T) {
 			wantErr: true,
 			errs:    redisErrors{hsetnxStatus: true},
 		},
+		{
+			name: "fail update account index",
+			args: args{account: "A", eventType: "type", kind: "kind", secret: "XXX"},
+			expected: expected{
+				eventURI: "type:kind:test:" + model.CalculateAccountHash("A"),
+				info:     &model.EventInfo{Endpoint: "test-endpoint", Description: "test-desc", Help: "test-help", Status: "test-status"},
+			},
+			wantErr: true,
+			errs:    redisErrors{sadd: true},
+		},
 		{
 			name: "fail exec transaction",
 			args: args{account: "A", eventType: "type", kind: "kind", secret: "XXX"},
@@ -2356,6 +2551,9 @@ func TestRedisStore_CreateEvent(t *testing.T) {
 				call.Return(tt.expected.info, nil)
 			}
 			// mock Redis
+			// watch trigger event key and check it does not exist
+			r.redisPool.GetConn().(*redigomock.Conn).Command("WATCH", eventKey).Expect("OK!")
+			r.redisPool.GetConn().(*redigomock.Conn).Command("EXISTS", eventKey).Expect(int64(0))
 			// expect Redis transaction open
 			cmd = r.redisPool.GetConn().(*redigomock.Conn).Command("MULTI")
 			if tt.errs.multi {
@@ -2412,6 +2610,14 @@ func TestRedisStore_CreateEvent(t *testing.T) {
 				cmd.ExpectError(errors.New("HSETNX error"))
 				goto EndTransaction
 			}
+			// add Event to account index
+			cmd = r.redisPool.GetConn().(*redigomock.Conn).Command("SADD", getAccountIndexKey(account), tt.expected.eventURI)
+			if tt.errs.sadd {
+				cmd.ExpectError(errors.New("SADD error"))
+				goto EndTransaction
+			}
+			// add Event to type index
+			r.redisPool.GetConn().(*redigomock.Conn).Command("SADD", getTypeIndexKey(tt.args.eventType, tt.args.kind), tt.expected.eventURI)
 
 		EndTransaction:
 			// discard transaction on error
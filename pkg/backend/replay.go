@@ -0,0 +1,145 @@
+package backend
+
+import (
+	"context"
+	"time"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/codefresh-io/hermes/pkg/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// Replayer replays normalized events kept in run history
+type Replayer struct {
+	store      Store
+	runner     model.Runner
+	dispatcher model.Dispatcher
+	history    model.RunHistory
+	limiter    model.RateLimiter
+}
+
+// NewReplayer create new replayer; replayed pipeline runs are rate limited like /run
+func NewReplayer(store Store, runner model.Runner, dispatcher model.Dispatcher, history model.RunHistory, limiter model.RateLimiter) *Replayer {
+	return &Replayer{store: store, runner: runner, dispatcher: dispatcher, history: history, limiter: limiter}
+}
+
+// Replay run pipelines currently matching event of context account run record (original: run pipelines originally
+// matched); replay is marked with EVENT_REPLAY variable and recorded in run history
+func (r *Replayer) Replay(ctx context.Context, id string, original bool) (*model.RunRecord, error) {
+	replayed, err := r.history.GetRun(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if replayed.Data == nil || !replayed.SecretValid {
+		return nil, model.ErrRunNotReplayable
+	}
+	lg := log.WithFields(getContextLogFields(ctx)).WithFields(log.Fields{
+		"event":    replayed.Event,
+		"replay":   id,
+		"original": original,
+	})
+	// mark replay in event variables
+	data := model.NormalizedEvent{Original: replayed.Data.Original, Variables: make(map[string]string, len(replayed.Data.Variables)+1)}
+	for k, v := range replayed.Data.Variables {
+		data.Variables[k] = v
+	}
+	data.Variables[model.ReplayVariable] = id
+	record := &model.RunRecord{
+		Account:     replayed.Account,
+		Event:       replayed.Event,
+		Received:    time.Now().UTC(),
+		SecretValid: true,
+		Replay:      id,
+		Data:        &data,
+	}
+	// record ID is known before run: pipeline runs delayed by rate limits refer to it
+	if record.ID, err = util.GenerateMonotonicULID(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := r.history.AddRun(ctx, record); err != nil {
+			lg.WithError(err).Error("failed to record run history")
+		}
+	}()
+	// skip account check
+	allCtx := context.WithValue(ctx, model.ContextKeyAccount, "-")
+	// queue event while dispatch is paused (maintenance mode)
+	queued, err := r.dispatcher.Queue(allCtx, record.Account, record.Event, data)
+	if err != nil {
+		record.Status, record.Error = model.RunStatusFailed, err.Error()
+		return nil, err
+	}
+	if queued != nil {
+		record.Status = model.RunStatusQueued
+		return record, nil
+	}
+	// add original payload to variables
+	vars := make(map[string]string)
+	for k, v := range data.Variables {
+		vars[k] = v
+	}
+	vars["EVENT_PAYLOAD"] = data.Original
+	// get pipelines: currently matching event, or originally matched with trigger still active (filters are ignored)
+	pipelines, skipped, err := r.store.MatchTriggerPipelines(allCtx, record.Event, vars)
+	if err != nil && err != model.ErrPipelineNotFound && err != model.ErrTriggerNotFound {
+		record.Status, record.Error = model.RunStatusFailed, err.Error()
+		return nil, err
+	}
+	record.Skipped = skipped
+	if original {
+		pipelines, record.Skipped = activeOriginalPipelines(replayed.Matched, pipelines, skipped)
+	}
+	record.Matched = pipelines
+	if len(pipelines) == 0 {
+		lg.Warn("there are no pipelines to replay trigger event")
+		record.Status = model.RunStatusSkipped
+		return record, nil
+	}
+	// apply account, trigger event and trigger rate limits
+	if pipelines, err = limitRuns(allCtx, r.limiter, record, pipelines, data); err != nil {
+		record.Status, record.Error = model.RunStatusFailed, err.Error()
+		if err == model.ErrRateLimitExceeded {
+			record.Status = model.RunStatusRateLimited
+		}
+		return nil, err
+	}
+	if len(pipelines) == 0 {
+		lg.Warn("all replayed pipeline runs exceed rate limits")
+		record.Status = model.RunStatusRateLimited
+		return record, nil
+	}
+	runs, err := r.runner.Run(record.Account, pipelines, vars, data)
+	if err != nil {
+		record.Status, record.Error = model.RunStatusFailed, err.Error()
+		return nil, err
+	}
+	record.Status = model.RunStatusStarted
+	record.Runs = model.PipelineRunRecords(pipelines, runs)
+	lg.WithFields(log.Fields{
+		"pipelines": pipelines,
+		"runs":      runs,
+	}).Info("pipelines for replayed trigger event are running")
+	return record, nil
+}
+
+// originally matched pipelines with trigger still active: matching event now or skipped by filters only;
+// pipelines of paused and deleted triggers are skipped
+func activeOriginalPipelines(original, matched []string, skipped []model.SkippedPipeline) ([]string, []model.SkippedPipeline) {
+	reasons := make(map[string]string, len(skipped))
+	for _, s := range skipped {
+		reasons[s.Pipeline] = s.Reason
+	}
+	var active []string
+	var inactive []model.SkippedPipeline
+	for _, pipeline := range original {
+		switch {
+		case containsString(matched, pipeline) || reasons[pipeline] == model.SkipReasonFilter:
+			active = append(active, pipeline)
+		case reasons[pipeline] == model.SkipReasonPaused:
+			inactive = append(inactive, model.SkippedPipeline{Pipeline: pipeline, Reason: model.SkipReasonPaused})
+		default:
+			inactive = append(inactive, model.SkippedPipeline{Pipeline: pipeline, Reason: model.SkipReasonDeleted})
+		}
+	}
+	return active, inactive
+}
@@ -0,0 +1,549 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codefresh-io/go-infra/pkg/logger"
+	"github.com/codefresh-io/hermes/pkg/codefresh"
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/codefresh-io/hermes/pkg/provider"
+	"github.com/codefresh-io/hermes/pkg/util"
+	newrelic "github.com/newrelic/go-agent"
+	log "github.com/sirupsen/logrus"
+)
+
+type (
+	// Store storage backend: keeps trigger events and triggers
+	Store interface {
+		model.TriggerEventReaderWriter
+		model.TriggerReaderWriter
+		model.Pinger
+		model.StoreChecker
+		Indexer
+		Schema
+		SecretPurger
+		SecretEncrypter
+		DispatchQueue
+		Locker
+		ReconcileRunStore
+		TriggerCollector
+		AuditLog
+		RunHistoryStore
+		DeliveryStore
+		RateLimitStore
+	}
+
+	// RateLimitStore keeps account rate limits and their token buckets
+	RateLimitStore interface {
+		// GetRateLimits list account rate limits
+		GetRateLimits(ctx context.Context, account string) ([]model.RateLimit, error)
+		// SetRateLimit add or replace rate limit
+		SetRateLimit(ctx context.Context, limit model.RateLimit) error
+		// DeleteRateLimit delete rate limit and its token bucket
+		DeleteRateLimit(ctx context.Context, limit model.RateLimit) error
+		// TakeTokens take token from every rate limit bucket in one transaction, or no token when any token cannot be
+		// taken; run queued by limit with queue action may take token ahead (see takeTokens)
+		// returns wait of every limit and index of first limit with token that cannot be taken (-1: tokens taken)
+		TakeTokens(ctx context.Context, limits []model.RateLimit) ([]time.Duration, int, error)
+		// DelayRun keep pipeline run queued by rate limit until it is due (run ID time)
+		DelayRun(ctx context.Context, run *model.DelayedRun) error
+		// ClaimDelayedRun replace the oldest run due at time now with the same run with claimed ID, in one transaction;
+		// claimed run is due again at claimed ID time, unless removed before; nil when no run is due
+		ClaimDelayedRun(ctx context.Context, now time.Time, claimed string) (*model.DelayedRun, error)
+		// RemoveDelayedRun remove delayed run, after it was run
+		RemoveDelayedRun(ctx context.Context, id string) error
+	}
+
+	// DeliveryStore remembers event deliveries per trigger event URI
+	DeliveryStore interface {
+		// AddDelivery remember delivery for TTL; returns first delivery (nil when added) if delivery ID was already seen
+		AddDelivery(ctx context.Context, delivery *model.Delivery, ttl time.Duration) (*model.Delivery, error)
+		// RemoveDelivery forget delivery ID, if it was delivered with run record ID
+		RemoveDelivery(ctx context.Context, event, id, run string) error
+	}
+
+	// RunHistoryStore keeps execution records of /run calls, per account
+	RunHistoryStore interface {
+		// SaveRun store run record, removing account records older than retention
+		SaveRun(ctx context.Context, record *model.RunRecord, retention time.Duration) error
+		// GetRuns list account run records matching filter, latest first
+		GetRuns(ctx context.Context, filter model.RunFilter) ([]model.RunRecord, error)
+		// GetRun get run record; record of another account is not found
+		GetRun(ctx context.Context, id string) (*model.RunRecord, error)
+	}
+
+	// AuditLog keeps audit entries of trigger event and trigger changes, per account
+	AuditLog interface {
+		model.AuditReader
+		// AppendAudit add entry to account audit log, removing account entries older than retention
+		AppendAudit(ctx context.Context, entry *model.AuditEntry, retention time.Duration) error
+	}
+
+	// TriggerCollector lists pipelines linked to trigger events and removes their triggers (trigger garbage collection)
+	TriggerCollector interface {
+		// GetPipelineEvents get all pipelines with triggers and their linked trigger events
+		GetPipelineEvents(ctx context.Context) (map[string][]string, error)
+		// DeletePipelineTriggers unlink trigger events from pipeline, without Codefresh pipeline check; triggers are
+		// re-checked in transaction: only existing triggers of existing context account (or public) events are removed
+		// returns events unlinked from pipeline
+		DeletePipelineTriggers(ctx context.Context, pipeline string, events []string) ([]string, error)
+	}
+
+	// Locker expiring named lock: only one replica (lock owner) runs background job at a time
+	Locker interface {
+		// AcquireLock acquire lock for owner until released or ttl expires; false when locked by another owner
+		AcquireLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error)
+		// ExtendLock extend lock held by owner for ttl; false when lock expired or was acquired by another owner
+		ExtendLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error)
+		// ReleaseLock release lock, if still held by owner
+		ReleaseLock(ctx context.Context, name, owner string) error
+	}
+
+	// ReconcileRunStore keeps recent reconciliation run results
+	ReconcileRunStore interface {
+		// SaveReconcileRun store run result, removing runs older than last reconcileRunsLimit runs
+		SaveReconcileRun(ctx context.Context, run *model.ReconcileRun) error
+		// GetReconcileRuns list recent runs, latest first
+		GetReconcileRuns(ctx context.Context) ([]model.ReconcileRun, error)
+	}
+
+	// DispatchQueue keeps dispatch pause state and normalized events queued while dispatch is paused
+	// scope is account ID or model.DispatchGlobal
+	DispatchQueue interface {
+		// PauseDispatch pause dispatch for scope; pausing paused scope keeps its pause time
+		PauseDispatch(ctx context.Context, scope string) error
+		// ResumeDispatch resume dispatch for scope; fails with model.ErrDispatchQueueNotEmpty when scope has queued events
+		ResumeDispatch(ctx context.Context, scope string) error
+		// GetDispatchStates list paused scopes
+		GetDispatchStates(ctx context.Context) ([]model.DispatchState, error)
+		// QueueEvent append event to scope queue, only when dispatch is paused for scope; false when it is not
+		QueueEvent(ctx context.Context, scope string, event *model.QueuedEvent) (bool, error)
+		// PeekQueuedEvent return the oldest queued event of scope, without removing it; nil when queue is empty
+		PeekQueuedEvent(ctx context.Context, scope string) (*model.QueuedEvent, error)
+		// AckQueuedEvent remove queued event from scope queue, after it was run (or discarded)
+		AckQueuedEvent(ctx context.Context, scope, id string) error
+		// RequeueEvent replace queued event with event (with new ID: moves it to queue tail), in one transaction
+		RequeueEvent(ctx context.Context, scope, id string, event *model.QueuedEvent) error
+	}
+
+	// SecretEncrypter re-encrypts trigger event secrets stored at rest
+	SecretEncrypter interface {
+		// ReEncrypt encrypt all trigger event secrets with current encryption key; returns number of updated events
+		ReEncrypt(ctx context.Context) (int, error)
+	}
+
+	// SecretPurger removes previous trigger event secrets after secret rotation grace period
+	SecretPurger interface {
+		// PurgeExpiredSecrets remove expired previous secrets; returns number of purged secrets
+		PurgeExpiredSecrets(ctx context.Context) (int, error)
+	}
+
+	// Indexer maintains storage backend secondary indexes
+	Indexer interface {
+		// Reindex add all existing trigger events to indexes; returns number of indexed events
+		Reindex(ctx context.Context) (int, error)
+	}
+
+	// StoreConfig storage backend configuration; each driver uses relevant fields only
+	StoreConfig struct {
+		// Host store server host name
+		Host string
+		// Port store server port
+		Port int
+		// DB store database number
+		DB int
+		// Password store password
+		Password string
+		// Username store ACL user name (Redis 6+)
+		Username string
+		// Mode Redis connection mode: standalone (default), sentinel or cluster
+		Mode string
+		// Addrs Redis sentinel or cluster startup node addresses (host:port); defaults to Host:Port
+		Addrs []string
+		// MasterName Redis sentinel master name
+		MasterName string
+		// SentinelPassword Redis sentinel password
+		SentinelPassword string
+		// HashTag Redis cluster hash tag shared by all keys (default: hermes)
+		HashTag string
+		// TLS connect to store with TLS
+		TLS bool
+		// TLSSkipVerify skip store TLS certificate verification
+		TLSSkipVerify bool
+		// TLSCACert store TLS CA certificate file
+		TLSCACert string
+		// MaxIdle maximum number of idle connections in pool (default: 3)
+		MaxIdle int
+		// MaxActive maximum number of connections in pool (0: unlimited)
+		MaxActive int
+		// Wait wait for connection when pool is at MaxActive limit, instead of failing
+		Wait bool
+		// IdleTimeout close connections idle for this duration (default: 240s)
+		IdleTimeout time.Duration
+		// ConnectTimeout, ReadTimeout and WriteTimeout store network timeouts (0: no timeout)
+		ConnectTimeout time.Duration
+		ReadTimeout    time.Duration
+		WriteTimeout   time.Duration
+		// Path store file path (embedded stores)
+		Path string
+		// EncryptionKeys master keys encrypting secrets at rest: "key-id=base64-key" list, the first key is current
+		EncryptionKeys string
+		// EncryptionKeyFile file with master keys, one "key-id=base64-key" per line (before EncryptionKeys)
+		EncryptionKeyFile string
+	}
+
+	// StoreDriver creates a new storage backend from configuration
+	StoreDriver func(config StoreConfig, pipelineSvc codefresh.PipelineService, eventProvider provider.EventProvider) (Store, error)
+)
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]StoreDriver)
+)
+
+// ErrUnknownStore error when storage driver is not registered
+var ErrUnknownStore = errors.New("unknown storage backend")
+
+// RegisterStore makes storage driver available by the provided name
+// panics if driver is nil or registered twice
+func RegisterStore(name string, driver StoreDriver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if driver == nil {
+		panic("backend: RegisterStore driver is nil")
+	}
+	if _, dup := drivers[name]; dup {
+		panic("backend: RegisterStore called twice for driver " + name)
+	}
+	drivers[name] = driver
+}
+
+// StoreDrivers returns sorted list of registered storage driver names
+func StoreDrivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewStore create new storage backend using registered driver
+func NewStore(name string, config StoreConfig, pipelineSvc codefresh.PipelineService, eventProvider provider.EventProvider) (Store, error) {
+	driversMu.RLock()
+	driver, ok := drivers[name]
+	driversMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%v: %s (available: %s)", ErrUnknownStore, name, strings.Join(StoreDrivers(), ", "))
+	}
+	return driver(config, pipelineSvc, eventProvider)
+}
+
+func getAccount(ctx context.Context) string {
+	v := ctx.Value(model.ContextKeyAccount)
+	if str, ok := v.(string); ok {
+		return str
+	}
+	return model.PublicAccount
+}
+
+func getNewRelicTransaction(c context.Context) newrelic.Transaction {
+	if v := c.Value(model.ContextNewRelicTxn); v != nil {
+		if txn, ok := v.(newrelic.Transaction); ok {
+			return txn
+		}
+	}
+	return nil
+}
+
+// construct Logrus fields from context (requestID and auth context)
+func getContextLogFields(ctx context.Context) log.Fields {
+	fields := make(log.Fields)
+	// get correlation ID
+	if requestID, ok := ctx.Value(model.ContextRequestID).(string); ok {
+		fields[logger.FieldCorrelationID] = requestID
+	}
+	// get NewRelic transaction
+	if txn, ok := ctx.Value(model.ContextNewRelicTxn).(newrelic.Transaction); ok {
+		fields[logger.FieldNewRelicTxn] = txn
+	}
+	// get auth entity
+	auth, err := model.GetAuthEntity(ctx)
+	if err != nil {
+		log.WithError(err).Error("failed to load authenticated entity")
+		return fields
+	}
+	if auth != nil {
+		fields[logger.FieldAuthName] = auth.Name
+		fields[logger.FieldAuthID] = auth.ID
+		fields[logger.FieldAuthType] = auth.Type
+	}
+	return fields
+}
+
+func getPublicFlag(ctx context.Context) bool {
+	v := ctx.Value(model.ContextKeyPublic)
+	if flag, ok := v.(bool); ok {
+		return flag
+	}
+	return false
+}
+
+// get credentials from Codefresh context (simple key:value map)
+func getCredentials(context string, lg *log.Entry) map[string]string {
+	var credentials map[string]string
+	if context != "" {
+		err := json.Unmarshal([]byte(context), &credentials)
+		if err != nil {
+			lg.WithError(err).WithField("context", context).Warning("failed to get credentials from context")
+		}
+	}
+	return credentials
+}
+
+// try subscribing to event - create event in remote system through event provider
+// fallback to GetEventInfo, if event provider does not implement SubscribeToEvent
+func subscribeToEvent(ctx context.Context, eventProvider provider.EventProvider, eventURI, secret string, credentials map[string]string, lg *log.Entry) (*model.EventInfo, error) {
+	eventInfo, err := eventProvider.SubscribeToEvent(ctx, eventURI, secret, credentials)
+	if err != nil {
+		if err == provider.ErrNotImplemented {
+			lg.Warn("event-provider does not implement SubscribeToEvent method")
+			lg.Debug("fallback to GetEventInfo method")
+			// try to get event info (required method)
+			eventInfo, err = eventProvider.GetEventInfo(ctx, eventURI, secret)
+			if err != nil {
+				lg.WithError(err).Error("failed to get event info from event provider")
+				return nil, err
+			}
+		} else {
+			lg.WithError(err).Error("failed to subscribe to event in event provider")
+			return nil, err
+		}
+	}
+	return eventInfo, nil
+}
+
+// check secret passed to create trigger event against existing trigger event secret
+// returns existing trigger event with ErrEventSecretConflict error on mismatch
+func checkEventSecret(event *model.Event, secret string) (*model.Event, error) {
+	if secret != "" && secret != model.GenerateKeyword && secret != event.Secret {
+		return event, model.ErrEventSecretConflict
+	}
+	return event, nil
+}
+
+// apply update to trigger event: replace secret, refresh event info through event provider, apply drifted provider
+// owned event info and set description
+// returns changed trigger event fields
+func updateEventFields(ctx context.Context, eventProvider provider.EventProvider, event *model.Event, update model.EventUpdate, lg *log.Entry) (map[string]string, error) {
+	fields := make(map[string]string)
+	if update.Secret != nil {
+		event.Secret = *update.Secret
+		if event.Secret == model.GenerateKeyword {
+			lg.Debug("auto generating trigger secret")
+			secret, err := util.SecureRandomString(secretLength)
+			if err != nil {
+				lg.WithError(err).Error("failed to generate trigger secret")
+				return nil, err
+			}
+			event.Secret = secret
+		}
+		fields["secret"] = event.Secret
+	}
+	if update.Refresh {
+		eventInfo, err := eventProvider.GetEventInfo(ctx, event.URI, event.Secret)
+		if err != nil {
+			lg.WithError(err).Error("failed to get event info from event provider")
+			return nil, err
+		}
+		event.EventInfo = *eventInfo
+		fields["endpoint"] = eventInfo.Endpoint
+		fields["description"] = eventInfo.Description
+		fields["status"] = eventInfo.Status
+		fields["help"] = eventInfo.Help
+	}
+	if update.Info != nil {
+		// provider owned fields only, when drifted: description may be changed by user
+		if event.Endpoint != update.Info.Endpoint {
+			event.Endpoint = update.Info.Endpoint
+			fields["endpoint"] = event.Endpoint
+		}
+		if event.Status != update.Info.Status {
+			event.Status = update.Info.Status
+			fields["status"] = event.Status
+		}
+		if event.Help != update.Info.Help {
+			event.Help = update.Info.Help
+			fields["help"] = event.Help
+		}
+	}
+	if update.Description != nil {
+		event.Description = *update.Description
+		fields["description"] = event.Description
+	}
+	return fields, nil
+}
+
+// length of generated trigger event secret
+const secretLength = 16
+
+// rotate trigger event secret: generate new secret and keep current secret valid for grace period;
+// returns changed trigger event fields
+func rotateEventFields(event *model.Event, rotation model.SecretRotation, now time.Time, lg *log.Entry) (map[string]string, error) {
+	secret, err := util.SecureRandomString(secretLength)
+	if err != nil {
+		lg.WithError(err).Error("failed to generate trigger secret")
+		return nil, err
+	}
+	fields := map[string]string{"secret": secret, "previous_secret": "", "previous_secret_expires": ""}
+	event.PreviousSecret, event.PreviousSecretExpires = "", nil
+	if rotation.Grace > 0 {
+		expires := now.Add(rotation.Grace).UTC().Truncate(time.Second)
+		event.PreviousSecret, event.PreviousSecretExpires = event.Secret, &expires
+		fields["previous_secret"] = event.PreviousSecret
+		fields["previous_secret_expires"] = expires.Format(time.RFC3339)
+	}
+	event.Secret = secret
+	return fields, nil
+}
+
+// secret fields of trigger event: restore secrets on rotation rollback
+func eventSecretFields(event *model.Event) map[string]string {
+	fields := map[string]string{"secret": event.Secret, "previous_secret": event.PreviousSecret, "previous_secret_expires": ""}
+	if event.PreviousSecretExpires != nil {
+		fields["previous_secret_expires"] = event.PreviousSecretExpires.UTC().Format(time.RFC3339)
+	}
+	return fields
+}
+
+// eventSecretSwapper replaces trigger event secret fields, unless secret was changed concurrently
+type eventSecretSwapper interface {
+	// swapEventSecret set trigger event fields, when stored secret equals current (ErrStoreConflict otherwise);
+	// rotating adds trigger event to rotation index, to purge previous secret after grace period
+	swapEventSecret(ctx context.Context, account, event, current string, fields map[string]string, rotating bool) error
+}
+
+// rotate stored trigger event secret (see rotateEventFields) and optionally push new secret to event provider
+// new secret is pushed only after rotation is stored; on push failure rotation is rolled back, so event provider and
+// store keep the same secrets
+func rotateEventSecret(ctx context.Context, swapper eventSecretSwapper, eventProvider provider.EventProvider, account string, stored *model.Event, rotation model.SecretRotation, lg *log.Entry) (*model.Event, error) {
+	original := *stored
+	fields, err := rotateEventFields(stored, rotation, time.Now(), lg)
+	if err != nil {
+		return nil, err
+	}
+	if err = swapper.swapEventSecret(ctx, account, stored.URI, original.Secret, fields, stored.PreviousSecret != ""); err != nil {
+		lg.WithError(err).Error("failed to rotate trigger event secret")
+		return nil, err
+	}
+	if !rotation.Push {
+		return stored, nil
+	}
+	eventInfo, err := subscribeToEvent(ctx, eventProvider, stored.URI, stored.Secret, getCredentials(rotation.Context, lg), lg)
+	if err != nil {
+		// event provider keeps current secret: roll back rotation
+		if e := swapper.swapEventSecret(ctx, account, stored.URI, stored.Secret, eventSecretFields(&original), false); e != nil {
+			lg.WithError(e).Error("failed to roll back trigger event secret rotation")
+		}
+		return nil, err
+	}
+	stored.EventInfo = *eventInfo
+	info := map[string]string{
+		"endpoint":    eventInfo.Endpoint,
+		"description": eventInfo.Description,
+		"status":      eventInfo.Status,
+		"help":        eventInfo.Help,
+	}
+	if err = swapper.swapEventSecret(ctx, account, stored.URI, stored.Secret, info, false); err != nil {
+		// secret is rotated and pushed: only event info is stale
+		lg.WithError(err).Warn("failed to store event info after trigger event secret rotation")
+	}
+	return stored, nil
+}
+
+// previous secret of trigger event fields expired (or missing)
+func secretExpired(fields map[string]string, now time.Time) bool {
+	expires, err := time.Parse(time.RFC3339, fields["previous_secret_expires"])
+	return err != nil || !now.Before(expires)
+}
+
+// RunSecretPurger purge expired previous trigger event secrets periodically, until context is done
+func RunSecretPurger(ctx context.Context, purger SecretPurger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := purger.PurgeExpiredSecrets(ctx)
+			if err != nil {
+				log.WithError(err).Error("failed to purge expired trigger event secrets")
+			} else if n > 0 {
+				log.WithField("secrets", n).Info("purged expired trigger event secrets")
+			}
+		}
+	}
+}
+
+// try unsubscribing from event - delete event in remote system through event provider
+// ignore event provider that does not implement UnsubscribeFromEvent
+func unsubscribeFromEvent(ctx context.Context, eventProvider provider.EventProvider, eventURI string, credentials map[string]string, lg *log.Entry) error {
+	err := eventProvider.UnsubscribeFromEvent(ctx, eventURI, credentials)
+	if err != nil {
+		if err != provider.ErrNotImplemented {
+			lg.WithError(err).Error("failed to UnsubscribeFromEven")
+			return err
+		}
+		lg.Warn("event provider does not implement UnsubscribeFromEvent method")
+	}
+	return nil
+}
+
+// check trigger filters against event variables; return false if pipeline should be skipped
+func matchFilters(filters map[string]string, vars map[string]string, pipeline string, lg *log.Entry) bool {
+	// filter out pipelines: for each filter condition find match
+	match := true
+	for name, exp := range filters {
+		// get matched value from vars
+		val := vars[name]
+		// for non-empty value validate
+		if val != "" {
+			lg.WithFields(log.Fields{
+				"name":       name,
+				"value":      val,
+				"expression": exp,
+			}).Debug("filtering pipeline based on value")
+			// handle NOT on regex
+			skipMatch := false
+			if strings.HasPrefix(exp, "SKIP:") {
+				exp = strings.TrimPrefix(exp, "SKIP:")
+				skipMatch = true
+			}
+			r, err := regexp.Compile(exp)
+			if err != nil {
+				lg.WithFields(log.Fields{
+					"name":       name,
+					"expression": exp,
+				}).Error("bad regex expression for filter")
+				continue // skip
+			}
+			// skip matching values (or not)
+			if r.MatchString(val) == skipMatch {
+				lg.WithField("pipeline", pipeline).Debug("skipping pipeline with filter")
+				match = false
+			}
+		}
+	}
+	return match
+}
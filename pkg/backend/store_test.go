@@ -0,0 +1,1831 @@
+package backend
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/codefresh-io/hermes/pkg/codefresh"
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/codefresh-io/hermes/pkg/provider"
+	"github.com/codefresh-io/hermes/pkg/util"
+)
+
+// failingAuditStore storage backend failing to add audit entries
+type failingAuditStore struct {
+	Store
+}
+
+func (s *failingAuditStore) AppendAudit(context.Context, *model.AuditEntry, time.Duration) error {
+	return errors.New("TEST ERROR")
+}
+
+// lockLosingStore storage backend losing held locks after number of lock extensions
+type lockLosingStore struct {
+	Store
+	extensions int
+}
+
+func (s *lockLosingStore) ExtendLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	if s.extensions == 0 {
+		return false, nil
+	}
+	s.extensions--
+	return s.Store.ExtendLock(ctx, name, owner, ttl)
+}
+
+// storeFixture storage backend under test with event provider and Codefresh mocks
+type storeFixture struct {
+	Store
+	provider  *provider.Mock
+	codefresh *codefresh.MockPipelineService
+}
+
+// newTestStore create a new clean storage backend for the driver
+func newTestStore(t *testing.T, driver string) *storeFixture {
+	f := &storeFixture{
+		provider:  provider.NewEventProviderMock(),
+		codefresh: &codefresh.MockPipelineService{},
+	}
+	f.codefresh.On("GetPipeline", mock.Anything, mock.Anything, mock.Anything).Return(&codefresh.Pipeline{}, nil)
+	f.provider.On("UnsubscribeFromEvent", mock.Anything, mock.Anything, mock.Anything).Return(provider.ErrNotImplemented)
+	f.Store = openTestStore(t, driver, f.codefresh, f.provider)
+	return f
+}
+
+// openTestStore open a new clean storage backend; closed on test end
+func openTestStore(tb testing.TB, driver string, pipelineSvc codefresh.PipelineService, eventProvider provider.EventProvider) Store {
+	var config StoreConfig
+	switch driver {
+	case "redis":
+		// run in-process Redis server
+		s := miniredis.RunT(tb)
+		port, _ := strconv.Atoi(s.Port())
+		config = StoreConfig{Host: s.Host(), Port: port}
+	case "bolt":
+		config = StoreConfig{Path: filepath.Join(tb.TempDir(), "hermes.db")}
+	}
+	store, err := NewStore(driver, config, pipelineSvc, eventProvider)
+	if err != nil {
+		tb.Fatalf("failed to create %s store: %v", driver, err)
+	}
+	if closer, ok := store.(io.Closer); ok {
+		tb.Cleanup(func() { closer.Close() })
+	}
+	return store
+}
+
+func storeContext(account string, public bool) context.Context {
+	ctx := context.WithValue(context.Background(), model.ContextKeyAccount, account)
+	if public {
+		ctx = context.WithValue(ctx, model.ContextKeyPublic, true)
+	}
+	return ctx
+}
+
+// create registry:dockerhub:{name} trigger event
+func (f *storeFixture) createEvent(t *testing.T, account, name, secret string, public bool) *model.Event {
+	owner := account
+	if public {
+		owner = model.PublicAccount
+	}
+	values := map[string]string{"name": name}
+	uri := fmt.Sprintf("registry:dockerhub:%s:%s", name, model.CalculateAccountHash(owner))
+	f.provider.On("ConstructEventURI", "registry", "dockerhub", owner, values).Return(uri, nil)
+	f.provider.On("SubscribeToEvent", mock.Anything, uri, secret, map[string]string(nil)).Return(&model.EventInfo{Endpoint: "http://endpoint/" + name, Status: "active"}, nil).Once()
+	event, err := f.CreateEvent(storeContext(account, public), "registry", "dockerhub", secret, "", values)
+	if err != nil {
+		t.Fatalf("failed to create trigger event: %v", err)
+	}
+	return event
+}
+
+var storeConformanceTests = []struct {
+	name string
+	run  func(t *testing.T, f *storeFixture)
+}{
+	{
+		name: "ping",
+		run: func(t *testing.T, f *storeFixture) {
+			_, err := f.Ping()
+			assert.NoError(t, err)
+		},
+	},
+	{
+		name: "create and get event",
+		run: func(t *testing.T, f *storeFixture) {
+			created := f.createEvent(t, "A", "repo", "secret", false)
+			assert.Equal(t, "A", created.Account)
+			assert.Equal(t, "secret", created.Secret)
+			got, err := f.GetEvent(storeContext("A", false), created.URI)
+			assert.NoError(t, err)
+			assert.Equal(t, created, got)
+			// skip account check
+			got, err = f.GetEvent(storeContext("-", false), created.URI)
+			assert.NoError(t, err)
+			assert.Equal(t, created, got)
+		},
+	},
+	{
+		name: "create existing event returns stored event",
+		run: func(t *testing.T, f *storeFixture) {
+			created := f.createEvent(t, "A", "repo", "secret", false)
+			again, err := f.CreateEvent(storeContext("A", false), "registry", "dockerhub", model.GenerateKeyword, "", map[string]string{"name": "repo"})
+			assert.NoError(t, err)
+			assert.Equal(t, created, again)
+			again, err = f.CreateEvent(storeContext("A", false), "registry", "dockerhub", "secret", "", map[string]string{"name": "repo"})
+			assert.NoError(t, err)
+			assert.Equal(t, created, again)
+		},
+	},
+	{
+		name: "create existing event with another secret",
+		run: func(t *testing.T, f *storeFixture) {
+			created := f.createEvent(t, "A", "repo", "secret", false)
+			existing, err := f.CreateEvent(storeContext("A", false), "registry", "dockerhub", "another", "", map[string]string{"name": "repo"})
+			assert.Equal(t, model.ErrEventSecretConflict, err)
+			assert.Equal(t, created, existing)
+		},
+	},
+	{
+		name: "update event",
+		run: func(t *testing.T, f *storeFixture) {
+			created := f.createEvent(t, "A", "repo", "secret", false)
+			ctx := storeContext("A", false)
+			// change description and replace secret
+			description, secret := "my repo", "new-secret"
+			updated, err := f.UpdateEvent(ctx, created.URI, model.EventUpdate{Description: &description, Secret: &secret})
+			assert.NoError(t, err)
+			assert.Equal(t, "my repo", updated.Description)
+			assert.Equal(t, "new-secret", updated.Secret)
+			assert.Equal(t, created.Endpoint, updated.Endpoint)
+			got, err := f.GetEvent(ctx, created.URI)
+			assert.NoError(t, err)
+			assert.Equal(t, updated, got)
+			// generate secret and refresh event info from event provider
+			secret = model.GenerateKeyword
+			f.provider.On("GetEventInfo", mock.Anything, created.URI, mock.Anything).Return(&model.EventInfo{Endpoint: "http://new", Status: "error"}, nil)
+			updated, err = f.UpdateEvent(ctx, created.URI, model.EventUpdate{Secret: &secret, Refresh: true})
+			assert.NoError(t, err)
+			assert.Equal(t, 16, len(updated.Secret))
+			// secure generator ignores test mode constant
+			assert.NotEqual(t, util.TestRandomString, updated.Secret)
+			assert.Equal(t, model.EventInfo{Endpoint: "http://new", Status: "error"}, updated.EventInfo)
+			f.provider.AssertCalled(t, "GetEventInfo", mock.Anything, created.URI, updated.Secret)
+			got, err = f.GetEvent(ctx, created.URI)
+			assert.NoError(t, err)
+			assert.Equal(t, updated, got)
+			// new secret is used for event pipelines
+			_, err = f.CreateEvent(ctx, "registry", "dockerhub", updated.Secret, "", map[string]string{"name": "repo"})
+			assert.NoError(t, err)
+		},
+	},
+	{
+		name: "update missing event or event of another account",
+		run: func(t *testing.T, f *storeFixture) {
+			created := f.createEvent(t, "A", "repo", "secret", false)
+			description := "changed"
+			_, err := f.UpdateEvent(storeContext("B", false), created.URI, model.EventUpdate{Description: &description})
+			assert.Equal(t, model.ErrEventNotFound, err)
+			_, err = f.UpdateEvent(storeContext("A", false), "registry:dockerhub:missing", model.EventUpdate{Description: &description})
+			assert.Equal(t, model.ErrEventNotFound, err)
+			got, err := f.GetEvent(storeContext("A", false), created.URI)
+			assert.NoError(t, err)
+			assert.Equal(t, created, got)
+		},
+	},
+	{
+		name: "get event of another account",
+		run: func(t *testing.T, f *storeFixture) {
+			created := f.createEvent(t, "A", "repo", "secret", false)
+			_, err := f.GetEvent(storeContext("B", false), created.URI)
+			assert.Equal(t, model.ErrEventNotFound, err)
+		},
+	},
+	{
+		name: "get missing event",
+		run: func(t *testing.T, f *storeFixture) {
+			_, err := f.GetEvent(storeContext("A", false), "registry:dockerhub:missing:"+model.CalculateAccountHash("A"))
+			assert.Equal(t, model.ErrEventNotFound, err)
+		},
+	},
+	{
+		name: "public event is shared by all accounts",
+		run: func(t *testing.T, f *storeFixture) {
+			public := f.createEvent(t, "A", "public", "secret", true)
+			private := f.createEvent(t, "B", "private", "secret", false)
+			assert.Equal(t, model.PublicAccount, public.Account)
+			got, err := f.GetEvent(storeContext("B", false), public.URI)
+			assert.NoError(t, err)
+			assert.Equal(t, public, got)
+			// list without public events
+			events, err := f.GetEvents(storeContext("B", false), "", "", "")
+			assert.NoError(t, err)
+			assert.Equal(t, []model.Event{*private}, events)
+			// list with public events
+			events, err = f.GetEvents(storeContext("B", true), "", "", "")
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, []model.Event{*public, *private}, events)
+		},
+	},
+	{
+		name: "list events by type, kind and filter",
+		run: func(t *testing.T, f *storeFixture) {
+			e1 := f.createEvent(t, "A", "repo1", "secret", false)
+			e2 := f.createEvent(t, "A", "repo2", "secret", false)
+			f.createEvent(t, "B", "repo3", "secret", false)
+			events, err := f.GetEvents(storeContext("A", false), "registry", "dockerhub", "")
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, []model.Event{*e1, *e2}, events)
+			events, err = f.GetEvents(storeContext("A", false), "git", "", "")
+			assert.NoError(t, err)
+			assert.Empty(t, events)
+			events, err = f.GetEvents(storeContext("A", false), "", "", "registry:dockerhub:repo2*")
+			assert.NoError(t, err)
+			assert.Equal(t, []model.Event{*e2}, events)
+			events, err = f.GetEvents(storeContext("A", false), "", "", e1.URI)
+			assert.NoError(t, err)
+			assert.Equal(t, []model.Event{*e1}, events)
+		},
+	},
+	{
+		name: "get pipelines for event with filters",
+		run: func(t *testing.T, f *storeFixture) {
+			event := f.createEvent(t, "A", "repo", "secret", false)
+			ctx := storeContext("A", false)
+			assert.NoError(t, f.CreateTrigger(ctx, event.URI, "p1", map[string]string{"tag": "^master$"}))
+			assert.NoError(t, f.CreateTrigger(ctx, event.URI, "p2", map[string]string{"tag": "SKIP:^master$"}))
+			assert.NoError(t, f.CreateTrigger(ctx, event.URI, "p3", nil))
+			runCtx := storeContext("-", false)
+			pipelines, err := f.GetTriggerPipelines(runCtx, event.URI, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"p1", "p2", "p3"}, pipelines)
+			pipelines, err = f.GetTriggerPipelines(runCtx, event.URI, map[string]string{"tag": "master"})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"p1", "p3"}, pipelines)
+			pipelines, err = f.GetTriggerPipelines(runCtx, event.URI, map[string]string{"tag": "dev"})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"p2", "p3"}, pipelines)
+		},
+	},
+	{
+		name: "colliding event and pipeline names keep separate filters",
+		run: func(t *testing.T, f *storeFixture) {
+			// 'registry:dockerhub:x:{hash}' + 'y:{hash}-p' and 'registry:dockerhub:x:{hash}-y:{hash}' + 'p'
+			hash := model.CalculateAccountHash("A")
+			e1 := f.createEvent(t, "A", "x", "secret", false)
+			e2 := f.createEvent(t, "A", "x:"+hash+"-y", "secret", false)
+			p1, p2 := "y:"+hash+"-p", "p"
+			ctx := storeContext("A", false)
+			assert.NoError(t, f.CreateTrigger(ctx, e1.URI, p1, map[string]string{"tag": "^v1$"}))
+			assert.NoError(t, f.CreateTrigger(ctx, e2.URI, p2, map[string]string{"tag": "^v2$"}))
+			runCtx := storeContext("-", false)
+			pipelines, err := f.GetTriggerPipelines(runCtx, e1.URI, map[string]string{"tag": "v1"})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{p1}, pipelines)
+			pipelines, err = f.GetTriggerPipelines(runCtx, e2.URI, map[string]string{"tag": "v2"})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{p2}, pipelines)
+			pipelines, err = f.GetTriggerPipelines(runCtx, e2.URI, map[string]string{"tag": "v1"})
+			assert.NoError(t, err)
+			assert.Empty(t, pipelines)
+		},
+	},
+	{
+		name: "get pipelines for event without triggers",
+		run: func(t *testing.T, f *storeFixture) {
+			event := f.createEvent(t, "A", "repo", "secret", false)
+			pipelines, err := f.GetTriggerPipelines(storeContext("-", false), event.URI, nil)
+			assert.NoError(t, err)
+			assert.Nil(t, pipelines)
+		},
+	},
+	{
+		name: "create trigger for event of another account",
+		run: func(t *testing.T, f *storeFixture) {
+			event := f.createEvent(t, "A", "repo", "secret", false)
+			err := f.CreateTrigger(storeContext("B", false), event.URI, "p1", nil)
+			assert.Equal(t, model.ErrTriggerNotFound, err)
+			err = f.DeleteTrigger(storeContext("B", false), event.URI, "p1")
+			assert.Equal(t, model.ErrTriggerNotFound, err)
+		},
+	},
+	{
+		name: "get event and pipeline triggers",
+		run: func(t *testing.T, f *storeFixture) {
+			private := f.createEvent(t, "A", "repo", "secret", false)
+			public := f.createEvent(t, "A", "public", "secret", true)
+			ctx := storeContext("A", false)
+			assert.NoError(t, f.CreateTrigger(ctx, private.URI, "p1", map[string]string{"tag": "^master$"}))
+			assert.NoError(t, f.CreateTrigger(ctx, public.URI, "p1", nil))
+			assert.NoError(t, f.CreateTrigger(ctx, public.URI, "p2", nil))
+			// triggers for single event
+			triggers, err := f.GetEventTriggers(ctx, private.URI)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(triggers))
+			assert.Equal(t, "p1", triggers[0].Pipeline)
+			assert.Equal(t, map[string]string{"tag": "^master$"}, triggers[0].Filters)
+			// all triggers: private and public
+			triggers, err = f.GetEventTriggers(ctx, "*")
+			assert.NoError(t, err)
+			assert.Equal(t, 3, len(triggers))
+			// another account can see public triggers only
+			triggers, err = f.GetEventTriggers(storeContext("B", false), "*")
+			assert.NoError(t, err)
+			assert.Equal(t, 2, len(triggers))
+			// pipeline triggers with event data
+			triggers, err = f.GetPipelineTriggers(ctx, "p1", true)
+			assert.NoError(t, err)
+			assert.Equal(t, 2, len(triggers))
+			for _, trigger := range triggers {
+				assert.Equal(t, trigger.Event, trigger.EventData.URI)
+			}
+			// pipeline triggers for another account
+			triggers, err = f.GetPipelineTriggers(storeContext("B", false), "p1", false)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(triggers))
+			assert.Equal(t, public.URI, triggers[0].Event)
+			// unknown pipeline
+			triggers, err = f.GetPipelineTriggers(ctx, "unknown", false)
+			assert.NoError(t, err)
+			assert.Empty(t, triggers)
+		},
+	},
+	{
+		name: "delete event linked to triggers",
+		run: func(t *testing.T, f *storeFixture) {
+			event := f.createEvent(t, "A", "repo", "secret", false)
+			ctx := storeContext("A", false)
+			assert.NoError(t, f.CreateTrigger(ctx, event.URI, "p1", map[string]string{"tag": "^master$"}))
+			assert.Equal(t, model.ErrEventDeleteWithTriggers, f.DeleteEvent(ctx, event.URI, ""))
+			assert.NoError(t, f.DeleteTrigger(ctx, event.URI, "p1"))
+			triggers, err := f.GetPipelineTriggers(ctx, "p1", false)
+			assert.NoError(t, err)
+			assert.Empty(t, triggers)
+			assert.NoError(t, f.DeleteEvent(ctx, event.URI, ""))
+			_, err = f.GetEvent(ctx, event.URI)
+			assert.Equal(t, model.ErrEventNotFound, err)
+			f.provider.AssertCalled(t, "UnsubscribeFromEvent", mock.Anything, event.URI, map[string]string(nil))
+			// trigger filters are removed with trigger
+			f.createEvent(t, "A", "repo", "secret", false)
+			assert.NoError(t, f.CreateTrigger(ctx, event.URI, "p1", nil))
+			pipelines, err := f.GetTriggerPipelines(storeContext("-", false), event.URI, map[string]string{"tag": "dev"})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"p1"}, pipelines)
+		},
+	},
+	{
+		name: "force delete event linked to triggers",
+		run: func(t *testing.T, f *storeFixture) {
+			event := f.createEvent(t, "A", "repo", "secret", false)
+			other := f.createEvent(t, "A", "other", "secret", false)
+			ctx := storeContext("A", false)
+			assert.NoError(t, f.CreateTrigger(ctx, event.URI, "p1", map[string]string{"tag": "^master$"}))
+			assert.NoError(t, f.CreateTrigger(ctx, event.URI, "p2", nil))
+			assert.NoError(t, f.CreateTrigger(ctx, other.URI, "p1", nil))
+			deletion, err := f.ForceDeleteEvent(ctx, event.URI, "")
+			assert.NoError(t, err)
+			assert.Equal(t, &model.EventDeletion{URI: event.URI, Pipelines: []string{"p1", "p2"}, Filters: []string{"p1"}}, deletion)
+			_, err = f.GetEvent(ctx, event.URI)
+			assert.Equal(t, model.ErrEventNotFound, err)
+			f.provider.AssertCalled(t, "UnsubscribeFromEvent", mock.Anything, event.URI, map[string]string(nil))
+			// pipeline references are removed, other trigger events are kept
+			triggers, err := f.GetPipelineTriggers(ctx, "p1", false)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(triggers))
+			assert.Equal(t, other.URI, triggers[0].Event)
+			triggers, err = f.GetPipelineTriggers(ctx, "p2", false)
+			assert.NoError(t, err)
+			assert.Empty(t, triggers)
+			// no orphan triggers, filters or index entries
+			report, err := f.CheckStore(ctx, false)
+			assert.NoError(t, err)
+			assert.Empty(t, report.Issues)
+			// event without triggers and missing event
+			assert.NoError(t, f.DeleteTrigger(ctx, other.URI, "p1"))
+			deletion, err = f.ForceDeleteEvent(ctx, other.URI, "")
+			assert.NoError(t, err)
+			assert.Empty(t, deletion.Pipelines)
+			_, err = f.ForceDeleteEvent(ctx, event.URI, "")
+			assert.Equal(t, model.ErrEventNotFound, err)
+			// another account event
+			_, err = f.ForceDeleteEvent(storeContext("B", false), f.createEvent(t, "A", "repo", "secret", false).URI, "")
+			assert.Equal(t, model.ErrEventNotFound, err)
+		},
+	},
+	{
+		name: "delete missing event",
+		run: func(t *testing.T, f *storeFixture) {
+			err := f.DeleteEvent(storeContext("A", false), "registry:dockerhub:missing:"+model.CalculateAccountHash("A"), "")
+			assert.Equal(t, model.ErrEventNotFound, err)
+		},
+	},
+	{
+		name: "delete all triggers by pipeline",
+		run: func(t *testing.T, f *storeFixture) {
+			e1 := f.createEvent(t, "A", "repo1", "secret", false)
+			e2 := f.createEvent(t, "A", "repo2", "secret", false)
+			ctx := storeContext("A", false)
+			assert.NoError(t, f.CreateTrigger(ctx, e1.URI, "p1", nil))
+			assert.NoError(t, f.CreateTrigger(ctx, e2.URI, "p1", nil))
+			assert.NoError(t, f.CreateTrigger(ctx, e2.URI, "p2", nil))
+			assert.NoError(t, f.DeleteAllTriggersByPipeline(ctx, "p1"))
+			triggers, err := f.GetPipelineTriggers(ctx, "p1", false)
+			assert.NoError(t, err)
+			assert.Empty(t, triggers)
+			pipelines, err := f.GetTriggerPipelines(storeContext("-", false), e1.URI, nil)
+			assert.NoError(t, err)
+			assert.Nil(t, pipelines)
+			pipelines, err = f.GetTriggerPipelines(storeContext("-", false), e2.URI, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"p2"}, pipelines)
+		},
+	},
+	{
+		name: "pause and resume trigger",
+		run: func(t *testing.T, f *storeFixture) {
+			event := f.createEvent(t, "A", "repo", "secret", false)
+			ctx := storeContext("A", false)
+			runCtx := storeContext("-", false)
+			assert.NoError(t, f.CreateTrigger(ctx, event.URI, "p1", map[string]string{"tag": "^master$"}))
+			assert.NoError(t, f.CreateTrigger(ctx, event.URI, "p2", nil))
+			assert.NoError(t, f.SetTriggerPaused(ctx, event.URI, "p1", true))
+			// paused trigger is skipped, but listed with its state and filters
+			pipelines, err := f.GetTriggerPipelines(runCtx, event.URI, map[string]string{"tag": "master"})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"p2"}, pipelines)
+			triggers, err := f.GetEventTriggers(ctx, event.URI)
+			assert.NoError(t, err)
+			assert.Equal(t, []model.Trigger{
+				{Event: event.URI, Pipeline: "p1", Filters: map[string]string{"tag": "^master$"}, Paused: true},
+				{Event: event.URI, Pipeline: "p2", Filters: map[string]string{}},
+			}, triggers)
+			page, err := f.GetEventTriggersPage(ctx, event.URI, model.PageOptions{})
+			assert.NoError(t, err)
+			assert.True(t, page.Triggers[0].Paused)
+			assert.False(t, page.Triggers[1].Paused)
+			triggers, err = f.GetPipelineTriggers(ctx, "p1", false)
+			assert.NoError(t, err)
+			assert.True(t, triggers[0].Paused)
+			// pausing twice is not an error
+			assert.NoError(t, f.SetTriggerPaused(ctx, event.URI, "p1", true))
+			// resume
+			assert.NoError(t, f.SetTriggerPaused(ctx, event.URI, "p1", false))
+			pipelines, err = f.GetTriggerPipelines(runCtx, event.URI, map[string]string{"tag": "master"})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"p1", "p2"}, pipelines)
+			// skipped pipelines with reason
+			assert.NoError(t, f.SetTriggerPaused(ctx, event.URI, "p2", true))
+			pipelines, skipped, err := f.MatchTriggerPipelines(runCtx, event.URI, map[string]string{"tag": "dev"})
+			assert.NoError(t, err)
+			assert.Empty(t, pipelines)
+			assert.Equal(t, []model.SkippedPipeline{
+				{Pipeline: "p1", Reason: model.SkipReasonFilter},
+				{Pipeline: "p2", Reason: model.SkipReasonPaused},
+			}, skipped)
+			assert.NoError(t, f.SetTriggerPaused(ctx, event.URI, "p2", false))
+			// missing trigger and trigger of another account
+			assert.Equal(t, model.ErrTriggerNotFound, f.SetTriggerPaused(ctx, event.URI, "p3", true))
+			assert.Equal(t, model.ErrTriggerNotFound, f.SetTriggerPaused(storeContext("B", false), event.URI, "p1", true))
+			// paused state is removed with trigger
+			assert.NoError(t, f.SetTriggerPaused(ctx, event.URI, "p2", true))
+			assert.NoError(t, f.DeleteTrigger(ctx, event.URI, "p2"))
+			assert.NoError(t, f.CreateTrigger(ctx, event.URI, "p2", nil))
+			pipelines, err = f.GetTriggerPipelines(runCtx, event.URI, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"p1", "p2"}, pipelines)
+			assert.NoError(t, f.SetTriggerPaused(ctx, event.URI, "p1", true))
+			_, err = f.ForceDeleteEvent(ctx, event.URI, "")
+			assert.NoError(t, err)
+			report, err := f.CheckStore(ctx, false)
+			assert.NoError(t, err)
+			assert.Empty(t, report.Issues)
+		},
+	},
+	{
+		name: "pause dispatch and queue events",
+		run: func(t *testing.T, f *storeFixture) {
+			ctx := context.Background()
+			event := &model.QueuedEvent{ID: "01A", Account: "A", Event: "uri:1", Queued: time.Now().UTC(),
+				Data: model.NormalizedEvent{Secret: "secret", Original: "payload", Variables: map[string]string{"tag": "master"}}}
+			// dispatch is not paused: event is not queued
+			queued, err := f.QueueEvent(ctx, "A", event)
+			assert.NoError(t, err)
+			assert.False(t, queued)
+			assert.NoError(t, f.PauseDispatch(ctx, "A"))
+			states, err := f.GetDispatchStates(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(states))
+			since := states[0].Since
+			// pausing twice keeps pause time
+			assert.NoError(t, f.PauseDispatch(ctx, "A"))
+			for _, id := range []string{"01C", "01A", "01B"} {
+				e := *event
+				e.ID = id
+				queued, err = f.QueueEvent(ctx, "A", &e)
+				assert.NoError(t, err)
+				assert.True(t, queued)
+			}
+			states, err = f.GetDispatchStates(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, []model.DispatchState{{Scope: "A", Since: since, Queued: 3}}, states)
+			// cannot resume with queued events
+			assert.Equal(t, model.ErrDispatchQueueNotEmpty, f.ResumeDispatch(ctx, "A"))
+			// peek keeps event queued
+			peeked, err := f.PeekQueuedEvent(ctx, "A")
+			assert.NoError(t, err)
+			if assert.NotNil(t, peeked) {
+				assert.Equal(t, "01A", peeked.ID)
+			}
+			// requeue moves event to queue tail with new ID
+			retry := *peeked
+			retry.ID = "01D"
+			assert.NoError(t, f.RequeueEvent(ctx, "A", "01A", &retry))
+			states, err = f.GetDispatchStates(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, 3, states[0].Queued)
+			// peek and ack in queue (ID) order
+			for _, id := range []string{"01B", "01C", "01D"} {
+				peeked, err := f.PeekQueuedEvent(ctx, "A")
+				assert.NoError(t, err)
+				if assert.NotNil(t, peeked) {
+					assert.Equal(t, id, peeked.ID)
+					assert.Equal(t, event.Data, peeked.Data)
+					assert.Equal(t, event.Queued, peeked.Queued)
+					assert.NoError(t, f.AckQueuedEvent(ctx, "A", peeked.ID))
+				}
+			}
+			peeked, err = f.PeekQueuedEvent(ctx, "A")
+			assert.NoError(t, err)
+			assert.Nil(t, peeked)
+			assert.NoError(t, f.ResumeDispatch(ctx, "A"))
+			states, err = f.GetDispatchStates(ctx)
+			assert.NoError(t, err)
+			assert.Empty(t, states)
+		},
+	},
+	{
+		name: "resume dispatch runs queued events in order",
+		run: func(t *testing.T, f *storeFixture) {
+			ctx := context.Background()
+			event := f.createEvent(t, "A", "repo", "secret", false)
+			assert.NoError(t, f.CreateTrigger(storeContext("A", false), event.URI, "p1", nil))
+			runner := &model.MockRunner{}
+			history := NewRunHistory(f.Store, time.Hour)
+			d := NewDispatcher(f.Store, runner, history, NewRateLimiter(f.Store, runner, history))
+			// not paused
+			queued, err := d.Queue(ctx, "A", event.URI, model.NormalizedEvent{Original: "0"})
+			assert.NoError(t, err)
+			assert.Nil(t, queued)
+			// global and account pause
+			assert.NoError(t, d.Pause(ctx, model.DispatchGlobal))
+			assert.NoError(t, d.Pause(ctx, "A"))
+			for _, payload := range []string{"1", "2"} {
+				queued, err = d.Queue(ctx, "A", event.URI, model.NormalizedEvent{Original: payload})
+				assert.NoError(t, err)
+				assert.NotNil(t, queued)
+			}
+			// global resume moves events of paused account to account queue
+			result, err := d.Resume(ctx, model.DispatchGlobal, false)
+			assert.NoError(t, err)
+			assert.Equal(t, &model.DispatchResult{Scope: model.DispatchGlobal, Requeued: 2}, result)
+			states, err := d.GetStates(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(states))
+			assert.Equal(t, 2, states[0].Queued)
+			// account resume runs queued events in order
+			var payloads []string
+			runner.On("Run", "A", []string{"p1"}, mock.Anything, mock.Anything).Return(nil, nil).Run(func(args mock.Arguments) {
+				payloads = append(payloads, args.Get(3).(model.NormalizedEvent).Original)
+			})
+			result, err = d.Resume(ctx, "A", false)
+			assert.NoError(t, err)
+			assert.Equal(t, &model.DispatchResult{Scope: "A", Dispatched: 2}, result)
+			assert.Equal(t, []string{"1", "2"}, payloads)
+			// runs of queued events are recorded in run history
+			records, err := history.GetRuns(ctx, model.RunFilter{Account: "A"})
+			assert.NoError(t, err)
+			if assert.Equal(t, 2, len(records)) {
+				assert.Equal(t, model.RunStatusStarted, records[0].Status)
+				assert.NotEmpty(t, records[0].Queued)
+				assert.Equal(t, []string{"p1"}, records[0].Matched)
+			}
+			// discard queued events
+			assert.NoError(t, d.Pause(ctx, "A"))
+			_, err = d.Queue(ctx, "A", event.URI, model.NormalizedEvent{Original: "3"})
+			assert.NoError(t, err)
+			result, err = d.Resume(ctx, "A", true)
+			assert.NoError(t, err)
+			assert.Equal(t, &model.DispatchResult{Scope: "A", Discarded: 1}, result)
+			assert.Equal(t, 2, len(payloads))
+			states, err = d.GetStates(ctx)
+			assert.NoError(t, err)
+			assert.Empty(t, states)
+		},
+	},
+	{
+		name: "resume dispatch keeps events failed to run",
+		run: func(t *testing.T, f *storeFixture) {
+			ctx := context.Background()
+			event := f.createEvent(t, "A", "retry", "secret", false)
+			assert.NoError(t, f.CreateTrigger(storeContext("A", false), event.URI, "p1", nil))
+			runner := &model.MockRunner{}
+			history := NewRunHistory(f.Store, time.Hour)
+			d := NewDispatcher(f.Store, runner, history, NewRateLimiter(f.Store, runner, history))
+			assert.NoError(t, d.Pause(ctx, "A"))
+			for _, payload := range []string{"1", "2"} {
+				_, err := d.Queue(ctx, "A", event.URI, model.NormalizedEvent{Original: payload})
+				assert.NoError(t, err)
+			}
+			// first event fails to run: it is kept queued, second event runs, dispatch stays paused
+			runner.On("Run", "A", []string{"p1"}, mock.Anything, model.NormalizedEvent{Original: "1"}).Return(nil, errors.New("TEST ERROR")).Once()
+			runner.On("Run", "A", []string{"p1"}, mock.Anything, model.NormalizedEvent{Original: "2"}).Return(nil, nil).Once()
+			result, err := d.Resume(ctx, "A", false)
+			assert.NoError(t, err)
+			assert.Equal(t, &model.DispatchResult{Scope: "A", Dispatched: 1, Failed: 1}, result)
+			states, err := d.GetStates(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(states))
+			assert.Equal(t, 1, states[0].Queued)
+			records, err := history.GetRuns(ctx, model.RunFilter{Account: "A", Status: model.RunStatusFailed})
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(records))
+			// failed event runs on next resume
+			runner.On("Run", "A", []string{"p1"}, mock.Anything, model.NormalizedEvent{Original: "1"}).Return(nil, nil).Once()
+			result, err = d.Resume(ctx, "A", false)
+			assert.NoError(t, err)
+			assert.Equal(t, &model.DispatchResult{Scope: "A", Dispatched: 1}, result)
+			states, err = d.GetStates(ctx)
+			assert.NoError(t, err)
+			assert.Empty(t, states)
+			// event rejected by rate limit is kept queued
+			assert.NoError(t, f.SetRateLimit(ctx, model.RateLimit{Account: "A", Rate: 1, Burst: 1, Action: model.RateLimitReject}))
+			assert.NoError(t, d.Pause(ctx, "A"))
+			for _, payload := range []string{"3", "4"} {
+				_, err = d.Queue(ctx, "A", event.URI, model.NormalizedEvent{Original: payload})
+				assert.NoError(t, err)
+			}
+			runner.On("Run", "A", []string{"p1"}, mock.Anything, model.NormalizedEvent{Original: "3"}).Return(nil, nil).Once()
+			result, err = d.Resume(ctx, "A", false)
+			assert.NoError(t, err)
+			assert.Equal(t, &model.DispatchResult{Scope: "A", Dispatched: 1, Failed: 1}, result)
+			records, err = history.GetRuns(ctx, model.RunFilter{Account: "A", Status: model.RunStatusRateLimited})
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(records))
+			runner.AssertExpectations(t)
+		},
+	},
+	{
+		name: "resume dispatch stops when lock is lost",
+		run: func(t *testing.T, f *storeFixture) {
+			ctx := context.Background()
+			event := f.createEvent(t, "A", "lost", "secret", false)
+			assert.NoError(t, f.CreateTrigger(storeContext("A", false), event.URI, "p1", nil))
+			runner := &model.MockRunner{}
+			runner.On("Run", "A", []string{"p1"}, mock.Anything, model.NormalizedEvent{Original: "1"}).Return(nil, nil).Once()
+			history := NewRunHistory(f.Store, time.Hour)
+			d := NewDispatcher(&lockLosingStore{Store: f.Store, extensions: 1}, runner, history, NewRateLimiter(f.Store, runner, history))
+			assert.NoError(t, d.Pause(ctx, "A"))
+			for _, payload := range []string{"1", "2"} {
+				_, err := d.Queue(ctx, "A", event.URI, model.NormalizedEvent{Original: payload})
+				assert.NoError(t, err)
+			}
+			// second event is left queued for resume holding the lock
+			result, err := d.Resume(ctx, "A", false)
+			assert.Equal(t, ErrLockLost, err)
+			assert.Equal(t, &model.DispatchResult{Scope: "A", Dispatched: 1}, result)
+			states, err := d.GetStates(ctx)
+			assert.NoError(t, err)
+			if assert.Equal(t, 1, len(states)) {
+				assert.Equal(t, 1, states[0].Queued)
+			}
+			runner.AssertExpectations(t)
+		},
+	},
+	{
+		name: "resume dispatch once at a time",
+		run: func(t *testing.T, f *storeFixture) {
+			ctx := context.Background()
+			history := NewRunHistory(f.Store, time.Hour)
+			d := NewDispatcher(f.Store, &model.MockRunner{}, history, NewRateLimiter(f.Store, &model.MockRunner{}, history))
+			assert.NoError(t, d.Pause(ctx, "A"))
+			locked, err := f.AcquireLock(ctx, getDispatchLock("A"), "other", time.Minute)
+			assert.NoError(t, err)
+			assert.True(t, locked)
+			_, err = d.Resume(ctx, "A", false)
+			assert.Equal(t, model.ErrDispatchResuming, err)
+			assert.NoError(t, f.ReleaseLock(ctx, getDispatchLock("A"), "other"))
+			_, err = d.Resume(ctx, "A", false)
+			assert.NoError(t, err)
+		},
+	},
+	{
+		name: "acquire and release lock",
+		run: func(t *testing.T, f *storeFixture) {
+			ctx := context.Background()
+			locked, err := f.AcquireLock(ctx, "job", "r1", time.Minute)
+			assert.NoError(t, err)
+			assert.True(t, locked)
+			locked, err = f.AcquireLock(ctx, "job", "r2", time.Minute)
+			assert.NoError(t, err)
+			assert.False(t, locked)
+			// release by another owner keeps lock
+			assert.NoError(t, f.ReleaseLock(ctx, "job", "r2"))
+			locked, err = f.AcquireLock(ctx, "job", "r2", time.Minute)
+			assert.NoError(t, err)
+			assert.False(t, locked)
+			// only owner extends lock
+			extended, err := f.ExtendLock(ctx, "job", "r1", time.Minute)
+			assert.NoError(t, err)
+			assert.True(t, extended)
+			extended, err = f.ExtendLock(ctx, "job", "r2", time.Minute)
+			assert.NoError(t, err)
+			assert.False(t, extended)
+			assert.NoError(t, f.ReleaseLock(ctx, "job", "r1"))
+			locked, err = f.AcquireLock(ctx, "job", "r2", time.Minute)
+			assert.NoError(t, err)
+			assert.True(t, locked)
+			// released lock is lost
+			extended, err = f.ExtendLock(ctx, "job", "r1", time.Minute)
+			assert.NoError(t, err)
+			assert.False(t, extended)
+			assert.NoError(t, f.ReleaseLock(ctx, "job", "r2"))
+			extended, err = f.ExtendLock(ctx, "job", "r2", time.Minute)
+			assert.NoError(t, err)
+			assert.False(t, extended)
+		},
+	},
+	{
+		name: "reconciliation stops when lock is lost",
+		run: func(t *testing.T, f *storeFixture) {
+			ctx := context.Background()
+			f.createEvent(t, "A", "first", "secret", false)
+			f.createEvent(t, "A", "second", "secret", false)
+			f.provider.On("MatchType", mock.Anything).Return(&model.EventType{}, nil)
+			f.provider.On("GetEventInfo", mock.Anything, mock.Anything, "secret").Return(&model.EventInfo{Status: "error"}, nil)
+			r := NewReconciler(&lockLosingStore{Store: f.Store, extensions: 1}, f.provider, "r1")
+			_, err := r.Reconcile(ctx)
+			assert.Equal(t, ErrLockLost, err)
+			f.provider.AssertNumberOfCalls(t, "GetEventInfo", 1)
+			runs, err := r.GetReconcileRuns(ctx)
+			assert.NoError(t, err)
+			assert.Empty(t, runs)
+		},
+	},
+	{
+		name: "reconcile trigger events",
+		run: func(t *testing.T, f *storeFixture) {
+			ctx := context.Background()
+			changed := f.createEvent(t, "A", "changed", "secret", false)
+			same := f.createEvent(t, "A", "same", "secret", false)
+			public := f.createEvent(t, "B", "public", "secret", true)
+			unmatched := f.createEvent(t, "B", "unmatched", "secret", false)
+			f.provider.On("MatchType", unmatched.URI).Return(nil, errors.New("failed to match event type"))
+			f.provider.On("MatchType", mock.Anything).Return(&model.EventType{}, nil)
+			// description set by user is kept
+			description := "set by user"
+			_, err := f.UpdateEvent(storeContext("A", false), changed.URI, model.EventUpdate{Description: &description})
+			assert.NoError(t, err)
+			f.provider.On("GetEventInfo", mock.Anything, changed.URI, "secret").Return(&model.EventInfo{Endpoint: "http://endpoint/changed", Description: "provider", Status: "error"}, nil)
+			f.provider.On("GetEventInfo", mock.Anything, same.URI, "secret").Return(&same.EventInfo, nil)
+			f.provider.On("GetEventInfo", mock.Anything, public.URI, "secret").Return(nil, errors.New("provider error"))
+			r := NewReconciler(f.Store, f.provider, "r1")
+			// locked by another replica
+			locked, err := f.AcquireLock(ctx, reconcileLock, "r2", time.Minute)
+			assert.NoError(t, err)
+			assert.True(t, locked)
+			_, err = r.Reconcile(ctx)
+			assert.Equal(t, model.ErrReconcileLocked, err)
+			assert.NoError(t, f.ReleaseLock(ctx, reconcileLock, "r2"))
+			// reconcile
+			run, err := r.Reconcile(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, 4, run.Events)
+			assert.Equal(t, []model.ReconciledEvent{{URI: changed.URI, Fields: []string{"status"}}}, run.Updated)
+			assert.Equal(t, []string{unmatched.URI}, run.Unmatched)
+			assert.Equal(t, []model.ReconciledEvent{{URI: public.URI, Error: "provider error"}}, run.Failed)
+			event, err := f.GetEvent(storeContext("A", false), changed.URI)
+			assert.NoError(t, err)
+			assert.Equal(t, "error", event.Status)
+			assert.Equal(t, "set by user", event.Description)
+			assert.Equal(t, "secret", event.Secret)
+			// lock is released, recent runs are kept latest first
+			for i := 0; i < reconcileRunsLimit; i++ {
+				_, err = r.Reconcile(ctx)
+				assert.NoError(t, err)
+			}
+			last, err := r.Reconcile(ctx)
+			assert.NoError(t, err)
+			runs, err := r.GetReconcileRuns(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, reconcileRunsLimit, len(runs))
+			assert.Equal(t, last.ID, runs[0].ID)
+			assert.Empty(t, runs[0].Updated)
+			assert.True(t, runs[0].ID > runs[1].ID)
+		},
+	},
+	{
+		name: "collect triggers of deleted pipelines",
+		run: func(t *testing.T, f *storeFixture) {
+			ctx := context.Background()
+			a := f.createEvent(t, "A", "repo-a", "secret", false)
+			b := f.createEvent(t, "B", "repo-b", "secret", false)
+			public := f.createEvent(t, "A", "public", "secret", true)
+			for pipeline, events := range map[string][]*model.Event{
+				"p-deleted": {a, b, public},
+				"p-moved":   {a, b},
+				"p-ok":      {a},
+				"p-public":  {public},
+			} {
+				for _, event := range events {
+					assert.NoError(t, f.CreateTrigger(storeContext(event.Account, false), event.URI, pipeline, map[string]string{"tag": "master"}))
+				}
+			}
+			assert.NoError(t, f.SetTriggerPaused(storeContext("A", false), a.URI, "p-deleted", true))
+			pipelineSvc := &codefresh.MockPipelineService{}
+			pipelineSvc.On("GetPipeline", mock.Anything, mock.Anything, "p-deleted").Return(nil, codefresh.ErrPipelineNotFound)
+			pipelineSvc.On("GetPipeline", mock.Anything, "A", "p-moved").Return(nil, codefresh.ErrPipelineNoMatch)
+			pipelineSvc.On("GetPipeline", mock.Anything, mock.Anything, mock.Anything).Return(&codefresh.Pipeline{}, nil)
+			gc := NewTriggerGC(f.Store, pipelineSvc, 1000)
+			want := []model.CollectedTriggers{
+				{Pipeline: "p-deleted", Account: "A", Reason: model.GCReasonNotFound, Events: []string{a.URI}},
+				{Pipeline: "p-deleted", Account: "B", Reason: model.GCReasonNotFound, Events: []string{b.URI, public.URI}},
+				{Pipeline: "p-moved", Account: "A", Reason: model.GCReasonNoMatch, Events: []string{a.URI}},
+			}
+			before, err := f.GetPipelineEvents(ctx)
+			assert.NoError(t, err)
+			// dry run only reports
+			report, err := gc.Collect(ctx, true)
+			assert.NoError(t, err)
+			assert.True(t, report.DryRun)
+			assert.Equal(t, 4, report.Pipelines)
+			assert.Equal(t, want, report.Collected)
+			assert.Equal(t, []string{"p-public"}, report.Skipped)
+			assert.Empty(t, report.Failed)
+			after, err := f.GetPipelineEvents(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, before, after)
+			// collect
+			report, err = gc.Collect(ctx, false)
+			assert.NoError(t, err)
+			assert.Equal(t, want, report.Collected)
+			after, err = f.GetPipelineEvents(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, map[string][]string{"p-moved": {b.URI}, "p-ok": {a.URI}, "p-public": {public.URI}}, after)
+			checkReport, err := f.CheckStore(ctx, false)
+			assert.NoError(t, err)
+			assert.Empty(t, checkReport.Issues)
+		},
+	},
+	{
+		name: "delete pipeline triggers skips changed triggers",
+		run: func(t *testing.T, f *storeFixture) {
+			a := f.createEvent(t, "A", "stale-a", "secret", false)
+			b := f.createEvent(t, "B", "stale-b", "secret", false)
+			gone := f.createEvent(t, "A", "stale-gone", "secret", false)
+			ctx := storeContext("A", false)
+			for _, event := range []*model.Event{a, gone} {
+				assert.NoError(t, f.CreateTrigger(ctx, event.URI, "p1", nil))
+			}
+			assert.NoError(t, f.CreateTrigger(storeContext("B", false), b.URI, "p1", nil))
+			untriggered := f.createEvent(t, "A", "stale-untriggered", "secret", false)
+			// trigger deleted after pipeline check
+			assert.NoError(t, f.CreateTrigger(ctx, untriggered.URI, "p1", nil))
+			assert.NoError(t, f.DeleteTrigger(ctx, untriggered.URI, "p1"))
+			// trigger event deleted after pipeline check
+			_, err := f.ForceDeleteEvent(ctx, gone.URI, "")
+			assert.NoError(t, err)
+			// event of other account is not unlinked
+			deleted, err := f.DeletePipelineTriggers(ctx, "p1", []string{a.URI, b.URI, gone.URI, untriggered.URI})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{a.URI}, deleted)
+			events, err := f.GetPipelineEvents(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, map[string][]string{"p1": {b.URI}}, events)
+			// nothing left to delete
+			deleted, err = f.DeletePipelineTriggers(ctx, "p1", []string{a.URI})
+			assert.NoError(t, err)
+			assert.Empty(t, deleted)
+		},
+	},
+	{
+		name: "audit trigger event and trigger changes",
+		run: func(t *testing.T, f *storeFixture) {
+			audited := NewAuditStore(f.Store, time.Hour)
+			auth := base64.StdEncoding.EncodeToString([]byte(`{"name":"alice","_id":"u1"}`))
+			ctx := context.WithValue(storeContext("A", false), model.ContextAuthEntity, auth)
+			ctx = context.WithValue(ctx, model.ContextRequestID, "req-1")
+			values := map[string]string{"name": "audited"}
+			uri := fmt.Sprintf("registry:dockerhub:audited:%s", model.CalculateAccountHash("A"))
+			f.provider.On("ConstructEventURI", "registry", "dockerhub", "A", values).Return(uri, nil)
+			f.provider.On("SubscribeToEvent", mock.Anything, uri, "secret", map[string]string(nil)).Return(&model.EventInfo{Status: "active"}, nil)
+			start := time.Now().Add(-time.Second)
+			_, err := audited.CreateEvent(ctx, "registry", "dockerhub", "secret", "", values)
+			assert.NoError(t, err)
+			description := "updated"
+			_, err = audited.UpdateEvent(ctx, uri, model.EventUpdate{Description: &description})
+			assert.NoError(t, err)
+			assert.NoError(t, audited.CreateTrigger(ctx, uri, "p1", nil))
+			assert.NoError(t, audited.CreateTrigger(ctx, uri, "p1", map[string]string{"tag": "master"}))
+			assert.NoError(t, audited.DeleteTrigger(ctx, uri, "p1"))
+			assert.NoError(t, audited.DeleteEvent(ctx, uri, ""))
+			// changes made without audit are not recorded
+			f.createEvent(t, "A", "not-audited", "secret", false)
+			// query all entries, latest first
+			entries, err := audited.GetAudit(ctx, model.AuditFilter{Account: "A"})
+			assert.NoError(t, err)
+			type change struct{ action, resource string }
+			var changes []change
+			for _, entry := range entries {
+				changes = append(changes, change{entry.Action, entry.Resource})
+				assert.Equal(t, "A", entry.Account)
+				assert.Equal(t, "req-1", entry.RequestID)
+				assert.Equal(t, &model.AuditActor{Name: "alice", ID: "u1", Type: "user"}, entry.Actor)
+				assert.Equal(t, uri, entry.Event)
+				// secrets are redacted
+				assert.NotContains(t, string(entry.Before), `:"secret"`)
+				assert.NotContains(t, string(entry.After), `:"secret"`)
+			}
+			assert.Equal(t, []change{
+				{model.AuditActionDelete, model.AuditResourceEvent},
+				{model.AuditActionDelete, model.AuditResourceTrigger},
+				{model.AuditActionUpdate, model.AuditResourceTrigger},
+				{model.AuditActionCreate, model.AuditResourceTrigger},
+				{model.AuditActionUpdate, model.AuditResourceEvent},
+				{model.AuditActionCreate, model.AuditResourceEvent},
+			}, changes)
+			assert.Nil(t, entries[0].After)
+			assert.Contains(t, string(entries[2].Before), `"pipeline":"p1"`)
+			assert.Contains(t, string(entries[2].After), `"tag":"master"`)
+			assert.Contains(t, string(entries[4].After), `"description":"updated"`)
+			// filter by resource, pipeline and limit
+			entries, err = audited.GetAudit(ctx, model.AuditFilter{Account: "A", Resource: model.AuditResourceTrigger, Pipeline: "p1", Limit: 2})
+			assert.NoError(t, err)
+			if assert.Len(t, entries, 2) {
+				assert.Equal(t, model.AuditActionDelete, entries[0].Action)
+				assert.Equal(t, model.AuditActionUpdate, entries[1].Action)
+			}
+			// filter by time range and account
+			entries, err = audited.GetAudit(ctx, model.AuditFilter{Account: "A", From: start, To: time.Now().Add(time.Second)})
+			assert.NoError(t, err)
+			assert.Len(t, entries, 6)
+			entries, err = audited.GetAudit(ctx, model.AuditFilter{Account: "A", To: start})
+			assert.NoError(t, err)
+			assert.Empty(t, entries)
+			entries, err = audited.GetAudit(ctx, model.AuditFilter{Account: "B"})
+			assert.NoError(t, err)
+			assert.Empty(t, entries)
+			// secret read is recorded without secret
+			audited.(model.SecretReadAuditor).AuditSecretRead(ctx, &model.Event{URI: uri, Account: "A", Secret: "secret"})
+			entries, err = audited.GetAudit(ctx, model.AuditFilter{Account: "A", Limit: 1})
+			assert.NoError(t, err)
+			if assert.Len(t, entries, 1) {
+				assert.Equal(t, model.AuditActionReadSecret, entries[0].Action)
+				assert.Equal(t, model.AuditResourceEvent, entries[0].Resource)
+				assert.Equal(t, uri, entries[0].Event)
+				assert.Equal(t, &model.AuditActor{Name: "alice", ID: "u1", Type: "user"}, entries[0].Actor)
+				assert.Nil(t, entries[0].Before)
+				assert.Nil(t, entries[0].After)
+			}
+		},
+	},
+	{
+		name: "count changes not recorded in audit log",
+		run: func(t *testing.T, f *storeFixture) {
+			event := f.createEvent(t, "A", "audit-failure", "secret", false)
+			audited := NewAuditStore(&failingAuditStore{f.Store}, time.Hour)
+			ctx := storeContext("A", false)
+			// change is stored, audit failure is counted
+			assert.NoError(t, audited.CreateTrigger(ctx, event.URI, "p1", nil))
+			assert.NoError(t, audited.SetTriggerPaused(ctx, event.URI, "p1", true))
+			triggers, err := f.GetEventTriggers(ctx, event.URI)
+			assert.NoError(t, err)
+			if assert.Len(t, triggers, 1) {
+				assert.True(t, triggers[0].Paused)
+			}
+			assert.Equal(t, model.AuditStats{Failures: 2}, audited.(model.AuditStatser).AuditStats())
+		},
+	},
+	{
+		name: "remove audit entries older than retention",
+		run: func(t *testing.T, f *storeFixture) {
+			ctx := storeContext("A", false)
+			old := &model.AuditEntry{ID: getIDBound(time.Now().Add(-2 * time.Hour)), Account: "A", Action: model.AuditActionCreate, Resource: model.AuditResourceEvent}
+			assert.NoError(t, f.AppendAudit(ctx, old, 3*time.Hour))
+			id, err := util.GenerateMonotonicULID()
+			assert.NoError(t, err)
+			recent := &model.AuditEntry{ID: id, Account: "A", Action: model.AuditActionDelete, Resource: model.AuditResourceEvent}
+			assert.NoError(t, f.AppendAudit(ctx, recent, time.Hour))
+			entries, err := f.GetAudit(ctx, model.AuditFilter{Account: "A"})
+			assert.NoError(t, err)
+			if assert.Len(t, entries, 1) {
+				assert.Equal(t, id, entries[0].ID)
+			}
+		},
+	},
+	{
+		name: "run history",
+		run: func(t *testing.T, f *storeFixture) {
+			history := NewRunHistory(f.Store, time.Hour)
+			ctx := context.Background()
+			// expired record is removed when record is added
+			expired := &model.RunRecord{ID: getIDBound(time.Now().Add(-2 * time.Hour)), Account: "A", Event: "uri:1", Status: model.RunStatusStarted}
+			assert.NoError(t, f.SaveRun(ctx, expired, 3*time.Hour))
+			records := []*model.RunRecord{
+				{Account: "A", Event: "uri:1", Status: model.RunStatusStarted, SecretValid: true, Matched: []string{"p1"},
+					Skipped: []model.SkippedPipeline{{Pipeline: "p2", Reason: model.SkipReasonPaused}},
+					Runs:    []model.PipelineRunRecord{{Pipeline: "p1", ID: "run1"}}},
+				{Account: "B", Event: "uri:2", Status: model.RunStatusRejected, Error: "invalid secret"},
+				{Account: "A", Event: "uri:3", Status: model.RunStatusSkipped, SecretValid: true},
+			}
+			for _, record := range records {
+				record.Received = time.Now().UTC().Truncate(time.Millisecond)
+				assert.NoError(t, history.AddRun(ctx, record))
+				assert.NotEmpty(t, record.ID)
+			}
+			// list account records, latest first
+			list, err := history.GetRuns(ctx, model.RunFilter{Account: "A"})
+			assert.NoError(t, err)
+			if assert.Len(t, list, 2) {
+				assert.Equal(t, *records[2], list[0])
+				assert.True(t, list[1].Received.Equal(records[0].Received))
+				assert.Equal(t, records[0].Skipped, list[1].Skipped)
+				assert.Equal(t, records[0].Runs, list[1].Runs)
+			}
+			list, err = history.GetRuns(ctx, model.RunFilter{Account: "A", Event: "uri:1"})
+			assert.NoError(t, err)
+			assert.Len(t, list, 1)
+			list, err = history.GetRuns(ctx, model.RunFilter{Account: "A", Limit: 1})
+			assert.NoError(t, err)
+			assert.Len(t, list, 1)
+			list, err = history.GetRuns(ctx, model.RunFilter{Account: "B", Status: model.RunStatusStarted})
+			assert.NoError(t, err)
+			assert.Empty(t, list)
+			// get record of context account
+			record, err := history.GetRun(storeContext("B", false), records[1].ID)
+			assert.NoError(t, err)
+			assert.Equal(t, "invalid secret", record.Error)
+			_, err = history.GetRun(storeContext("A", false), records[1].ID)
+			assert.Equal(t, model.ErrRunNotFound, err)
+			_, err = history.GetRun(storeContext("A", false), expired.ID)
+			assert.Equal(t, model.ErrRunNotFound, err)
+			// disabled history does not keep records
+			assert.NoError(t, NewRunHistory(f.Store, 0).AddRun(ctx, &model.RunRecord{Account: "C"}))
+			list, err = history.GetRuns(ctx, model.RunFilter{Account: "C"})
+			assert.NoError(t, err)
+			assert.Empty(t, list)
+		},
+	},
+	{
+		name: "replay run",
+		run: func(t *testing.T, f *storeFixture) {
+			event := f.createEvent(t, "A", "replay", "secret", false)
+			ctx := storeContext("A", false)
+			assert.NoError(t, f.CreateTrigger(ctx, event.URI, "p1", nil))
+			assert.NoError(t, f.CreateTrigger(ctx, event.URI, "p2", map[string]string{"tag": "master"}))
+			assert.NoError(t, f.CreateTrigger(ctx, event.URI, "p3", nil))
+			history := NewRunHistory(f.Store, time.Hour)
+			replayed := &model.RunRecord{Account: "A", Event: event.URI, Status: model.RunStatusFailed, SecretValid: true, Matched: []string{"p1"},
+				Data: &model.NormalizedEvent{Original: "payload", Variables: map[string]string{"tag": "dev"}}}
+			assert.NoError(t, history.AddRun(ctx, replayed))
+			rejected := &model.RunRecord{Account: "A", Event: event.URI, Status: model.RunStatusRejected}
+			assert.NoError(t, history.AddRun(ctx, rejected))
+			runner := &model.MockRunner{}
+			replayVars := map[string]string{"tag": "dev", model.ReplayVariable: replayed.ID}
+			runner.On("Run", "A", mock.Anything, mock.Anything, model.NormalizedEvent{Original: "payload", Variables: replayVars}).Return([]model.PipelineRun{{ID: "run1"}, {ID: "run3"}}, nil)
+			limiter := NewRateLimiter(f.Store, runner, history)
+			replayer := NewReplayer(f.Store, runner, NewDispatcher(f.Store, runner, history, limiter), history, limiter)
+			// replay to originally matched pipelines
+			record, err := replayer.Replay(ctx, replayed.ID, true)
+			assert.NoError(t, err)
+			assert.Equal(t, replayed.ID, record.Replay)
+			assert.Equal(t, model.RunStatusStarted, record.Status)
+			assert.Equal(t, []string{"p1"}, record.Matched)
+			runner.AssertCalled(t, "Run", "A", []string{"p1"}, mock.Anything, mock.Anything)
+			// replay is recorded with marked event
+			stored, err := history.GetRun(ctx, record.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, replayed.ID, stored.Replay)
+			assert.Equal(t, replayed.ID, stored.Data.Variables[model.ReplayVariable])
+			// replay to currently matching pipelines
+			record, err = replayer.Replay(ctx, replayed.ID, false)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"p1", "p3"}, record.Matched)
+			assert.Equal(t, []model.SkippedPipeline{{Pipeline: "p2", Reason: model.SkipReasonFilter}}, record.Skipped)
+			assert.Equal(t, []model.PipelineRunRecord{{Pipeline: "p1", ID: "run1"}, {Pipeline: "p3", ID: "run3"}}, record.Runs)
+			runner.AssertCalled(t, "Run", "A", []string{"p1", "p3"}, mock.MatchedBy(func(vars map[string]string) bool {
+				return vars["EVENT_PAYLOAD"] == "payload" && vars[model.ReplayVariable] == replayed.ID
+			}), mock.Anything)
+			records, err := history.GetRuns(ctx, model.RunFilter{Account: "A"})
+			assert.NoError(t, err)
+			assert.Len(t, records, 4)
+			// rejected event and record of another account are not replayed
+			_, err = replayer.Replay(ctx, rejected.ID, false)
+			assert.Equal(t, model.ErrRunNotReplayable, err)
+			_, err = replayer.Replay(storeContext("B", false), replayed.ID, false)
+			assert.Equal(t, model.ErrRunNotFound, err)
+			// replay is rate limited
+			assert.NoError(t, limiter.SetLimit(ctx, model.RateLimit{Account: "A", Rate: 1, Action: model.RateLimitReject}))
+			_, err = replayer.Replay(ctx, replayed.ID, true)
+			assert.NoError(t, err)
+			_, err = replayer.Replay(ctx, replayed.ID, true)
+			assert.Equal(t, model.ErrRateLimitExceeded, err)
+			records, err = history.GetRuns(ctx, model.RunFilter{Account: "A", Status: model.RunStatusRateLimited})
+			assert.NoError(t, err)
+			assert.Len(t, records, 1)
+		},
+	},
+	{
+		name: "replay run to original pipelines with active triggers",
+		run: func(t *testing.T, f *storeFixture) {
+			event := f.createEvent(t, "A", "replay-original", "secret", false)
+			ctx := storeContext("A", false)
+			for _, pipeline := range []string{"p1", "p2", "p3", "p4"} {
+				assert.NoError(t, f.CreateTrigger(ctx, event.URI, pipeline, nil))
+			}
+			history := NewRunHistory(f.Store, time.Hour)
+			replayed := &model.RunRecord{Account: "A", Event: event.URI, Status: model.RunStatusFailed, SecretValid: true, Matched: []string{"p1", "p2", "p3", "p4"},
+				Data: &model.NormalizedEvent{Original: "payload", Variables: map[string]string{"tag": "dev"}}}
+			assert.NoError(t, history.AddRun(ctx, replayed))
+			// after the run: p2 filter does not match event anymore, p3 is paused and p4 trigger is deleted
+			assert.NoError(t, f.CreateTrigger(ctx, event.URI, "p2", map[string]string{"tag": "master"}))
+			assert.NoError(t, f.SetTriggerPaused(ctx, event.URI, "p3", true))
+			assert.NoError(t, f.DeleteTrigger(ctx, event.URI, "p4"))
+			runner := &model.MockRunner{}
+			runner.On("Run", "A", []string{"p1", "p2"}, mock.Anything, mock.Anything).Return([]model.PipelineRun{{ID: "run1"}, {ID: "run2"}}, nil)
+			limiter := NewRateLimiter(f.Store, runner, history)
+			replayer := NewReplayer(f.Store, runner, NewDispatcher(f.Store, runner, history, limiter), history, limiter)
+			record, err := replayer.Replay(ctx, replayed.ID, true)
+			assert.NoError(t, err)
+			assert.Equal(t, model.RunStatusStarted, record.Status)
+			assert.Equal(t, []string{"p1", "p2"}, record.Matched)
+			assert.Equal(t, []model.SkippedPipeline{
+				{Pipeline: "p3", Reason: model.SkipReasonPaused},
+				{Pipeline: "p4", Reason: model.SkipReasonDeleted},
+			}, record.Skipped)
+			runner.AssertExpectations(t)
+			// all original triggers deleted: nothing to replay
+			for _, pipeline := range []string{"p1", "p2", "p3"} {
+				assert.NoError(t, f.DeleteTrigger(ctx, event.URI, pipeline))
+			}
+			record, err = replayer.Replay(ctx, replayed.ID, true)
+			assert.NoError(t, err)
+			assert.Equal(t, model.RunStatusSkipped, record.Status)
+			assert.Empty(t, record.Matched)
+			runner.AssertNumberOfCalls(t, "Run", 1)
+		},
+	},
+	{
+		name: "deduplicate event deliveries",
+		run: func(t *testing.T, f *storeFixture) {
+			ctx := context.Background()
+			f.provider.On("MatchType", "git:github:repo").Return(&model.EventType{DeliveryID: "DELIVERY"}, nil)
+			f.provider.On("MatchType", mock.MatchedBy(func(event string) bool {
+				return strings.HasPrefix(event, "registry:")
+			})).Return(&model.EventType{DedupPayload: true}, nil)
+			f.provider.On("MatchType", mock.Anything).Return(&model.EventType{}, nil)
+			d := NewDeduplicator(f.Store, f.provider, time.Hour)
+			git := func(delivery, original string) model.NormalizedEvent {
+				return model.NormalizedEvent{Original: original, Variables: map[string]string{"DELIVERY": delivery}}
+			}
+			// delivery ID variable
+			first, err := d.Deliver(ctx, "git:github:repo", git("d1", "push"), "run1")
+			assert.NoError(t, err)
+			assert.Nil(t, first)
+			first, err = d.Deliver(ctx, "git:github:repo", git("d1", "push again"), "run2")
+			assert.NoError(t, err)
+			if assert.NotNil(t, first) {
+				assert.Equal(t, "d1", first.ID)
+				assert.Equal(t, "run1", first.Run)
+				assert.True(t, first.Expires.After(first.Received))
+			}
+			first, err = d.Deliver(ctx, "git:github:repo", git("d2", "push"), "run3")
+			assert.NoError(t, err)
+			assert.Nil(t, first)
+			// identical payloads without delivery ID are different deliveries
+			for _, run := range []string{"run4a", "run4b"} {
+				first, err = d.Deliver(ctx, "cron:hourly", model.NormalizedEvent{Original: "tick"}, run)
+				assert.NoError(t, err)
+				assert.Nil(t, first)
+			}
+			// hash of original payload: event type opts in
+			first, err = d.Deliver(ctx, "registry:dockerhub:repo", model.NormalizedEvent{Original: "push"}, "run4")
+			assert.NoError(t, err)
+			assert.Nil(t, first)
+			first, err = d.Deliver(ctx, "registry:dockerhub:repo", model.NormalizedEvent{Original: "push"}, "run5")
+			assert.NoError(t, err)
+			if assert.NotNil(t, first) {
+				assert.Equal(t, "run4", first.Run)
+			}
+			// same delivery of another trigger event
+			first, err = d.Deliver(ctx, "registry:dockerhub:other", model.NormalizedEvent{Original: "push"}, "run6")
+			assert.NoError(t, err)
+			assert.Nil(t, first)
+			// event without payload is not deduplicated
+			for _, run := range []string{"run7", "run8"} {
+				first, err = d.Deliver(ctx, "registry:dockerhub:repo", model.NormalizedEvent{}, run)
+				assert.NoError(t, err)
+				assert.Nil(t, first)
+			}
+			// disabled deduplication
+			first, err = NewDeduplicator(f.Store, f.provider, 0).Deliver(ctx, "registry:dockerhub:repo", model.NormalizedEvent{Original: "push"}, "run9")
+			assert.NoError(t, err)
+			assert.Nil(t, first)
+			// forgotten delivery of failed run: retried delivery is first delivery
+			assert.NoError(t, d.Forget(ctx, "git:github:repo", git("d1", "push"), "other"))
+			first, err = d.Deliver(ctx, "git:github:repo", git("d1", "push"), "run10")
+			assert.NoError(t, err)
+			assert.NotNil(t, first)
+			assert.NoError(t, d.Forget(ctx, "git:github:repo", git("d1", "push"), "run1"))
+			first, err = d.Deliver(ctx, "git:github:repo", git("d1", "push"), "run11")
+			assert.NoError(t, err)
+			assert.Nil(t, first)
+			// other deliveries are kept
+			first, err = d.Deliver(ctx, "git:github:repo", git("d2", "push"), "run12")
+			assert.NoError(t, err)
+			if assert.NotNil(t, first) {
+				assert.Equal(t, "run3", first.Run)
+			}
+		},
+	},
+	{
+		name: "rate limit pipeline runs",
+		run: func(t *testing.T, f *storeFixture) {
+			ctx := context.Background()
+			l := NewRateLimiter(f.Store, &model.MockRunner{}, NewRunHistory(f.Store, time.Hour))
+			// one run per minute: buckets are not refilled during test
+			assert.Equal(t, model.ErrInvalidRateLimit, l.SetLimit(ctx, model.RateLimit{Account: "A", Rate: 0}))
+			assert.Equal(t, model.ErrInvalidRateLimit, l.SetLimit(ctx, model.RateLimit{Account: "A", Rate: 1, Action: "wait"}))
+			assert.NoError(t, l.SetLimit(ctx, model.RateLimit{Account: "A", Rate: 1, Burst: 2}))
+			assert.NoError(t, l.SetLimit(ctx, model.RateLimit{Account: "B", Event: "uri:1", Pipeline: "p1", Rate: 1, Action: model.RateLimitReject}))
+			assert.NoError(t, l.SetLimit(ctx, model.RateLimit{Account: "C", Event: "uri:1", Rate: 1, Action: model.RateLimitQueue}))
+			limits, err := l.GetLimits(ctx, "A")
+			assert.NoError(t, err)
+			assert.Equal(t, []model.RateLimit{{Account: "A", Rate: 1, Burst: 2, Action: model.RateLimitDrop}}, limits)
+			// account limit: drop
+			result, err := l.Take(ctx, "A", "uri:1", []string{"p1", "p2", "p3"})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"p1", "p2"}, result.Allowed)
+			assert.Equal(t, []model.LimitedPipeline{{Pipeline: "p3", Scope: model.RateLimitScopeAccount, Action: model.RateLimitDrop}}, result.Limited)
+			assert.False(t, result.Rejected())
+			// trigger limit: reject
+			result, err = l.Take(ctx, "B", "uri:1", []string{"p1", "p2"})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"p1", "p2"}, result.Allowed)
+			result, err = l.Take(ctx, "B", "uri:1", []string{"p1", "p2"})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"p2"}, result.Allowed)
+			assert.True(t, result.Rejected())
+			assert.True(t, result.RetryAfter > 50*time.Second)
+			// changed limit starts with full bucket
+			assert.NoError(t, l.SetLimit(ctx, model.RateLimit{Account: "B", Event: "uri:1", Pipeline: "p1", Rate: 1, Action: model.RateLimitReject}))
+			result, err = l.Take(ctx, "B", "uri:1", []string{"p1"})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"p1"}, result.Allowed)
+			// trigger event limit: queue up to burst, then drop
+			result, err = l.Take(ctx, "C", "uri:1", []string{"p1", "p2", "p3"})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"p1"}, result.Allowed)
+			if assert.Len(t, result.Limited, 2) {
+				assert.Equal(t, model.RateLimitQueue, result.Limited[0].Action)
+				assert.True(t, result.Limited[0].Delay > 50*time.Second)
+				assert.Equal(t, model.LimitedPipeline{Pipeline: "p3", Scope: model.RateLimitScopeEvent, Action: model.RateLimitDrop}, result.Limited[1])
+			}
+			// other trigger event is not limited
+			result, err = l.Take(ctx, "C", "uri:2", []string{"p1"})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"p1"}, result.Allowed)
+			// delete limit
+			assert.NoError(t, l.DeleteLimit(ctx, model.RateLimit{Account: "A"}))
+			assert.Equal(t, model.ErrRateLimitNotFound, l.DeleteLimit(ctx, model.RateLimit{Account: "A"}))
+			result, err = l.Take(ctx, "A", "uri:1", []string{"p1", "p2", "p3"})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"p1", "p2", "p3"}, result.Allowed)
+			assert.Empty(t, result.Limited)
+			// dropped run takes no token from other limits
+			assert.NoError(t, l.SetLimit(ctx, model.RateLimit{Account: "D", Event: "uri:1", Pipeline: "p1", Rate: 1, Action: model.RateLimitQueue}))
+			assert.NoError(t, l.SetLimit(ctx, model.RateLimit{Account: "D", Rate: 1}))
+			result, err = l.Take(ctx, "D", "uri:1", []string{"p2", "p1"})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"p2"}, result.Allowed)
+			assert.Equal(t, []model.LimitedPipeline{{Pipeline: "p1", Scope: model.RateLimitScopeAccount, Action: model.RateLimitDrop}}, result.Limited)
+			assert.NoError(t, l.DeleteLimit(ctx, model.RateLimit{Account: "D"}))
+			result, err = l.Take(ctx, "D", "uri:1", []string{"p1"})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"p1"}, result.Allowed)
+			assert.Empty(t, result.Limited)
+			// limited runs are counted
+			assert.Equal(t, model.RateLimitStats{Dropped: 3, Queued: 1, Rejected: 1}, l.RateLimitStats())
+		},
+	},
+	{
+		name: "run delayed pipeline runs",
+		run: func(t *testing.T, f *storeFixture) {
+			ctx := context.Background()
+			runner := &model.MockRunner{}
+			history := NewRunHistory(f.Store, time.Hour)
+			l := NewRateLimiter(f.Store, runner, history)
+			data := model.NormalizedEvent{Secret: "secret", Original: "payload", Variables: map[string]string{"tag": "dev"}}
+			assert.NoError(t, l.Delay(ctx, "A", "uri:1", "run1", []model.LimitedPipeline{
+				{Pipeline: "p1", Action: model.RateLimitQueue, Delay: time.Millisecond},
+				{Pipeline: "p2", Action: model.RateLimitDrop},
+				{Pipeline: "p3", Action: model.RateLimitQueue, Delay: time.Hour},
+			}, data))
+			time.Sleep(5 * time.Millisecond)
+			// due run is run once and recorded in run history
+			runner.On("Run", "A", []string{"p1"}, mock.MatchedBy(func(vars map[string]string) bool {
+				return vars["EVENT_PAYLOAD"] == "payload" && vars["tag"] == "dev"
+			}), data).Return([]model.PipelineRun{{ID: "r1"}}, nil).Once()
+			n, err := l.RunDue(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, n)
+			n, err = l.RunDue(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, 0, n)
+			runner.AssertExpectations(t)
+			records, err := history.GetRuns(ctx, model.RunFilter{Account: "A"})
+			assert.NoError(t, err)
+			if assert.Len(t, records, 1) {
+				assert.Equal(t, "run1", records[0].Delayed)
+				assert.Equal(t, model.RunStatusStarted, records[0].Status)
+				assert.Equal(t, []model.PipelineRunRecord{{Pipeline: "p1", ID: "r1"}}, records[0].Runs)
+			}
+			// claimed run is due again when claim expires, unless removed
+			now := time.Now().Add(2 * time.Hour)
+			claimed, err := util.GenerateULIDAt(now.Add(time.Hour))
+			assert.NoError(t, err)
+			run, err := f.ClaimDelayedRun(ctx, now, claimed)
+			assert.NoError(t, err)
+			if assert.NotNil(t, run) {
+				assert.Equal(t, &model.DelayedRun{ID: claimed, Account: "A", Event: "uri:1", Pipeline: "p3", Run: "run1", Data: data}, run)
+			}
+			run, err = f.ClaimDelayedRun(ctx, now, claimed)
+			assert.NoError(t, err)
+			assert.Nil(t, run)
+			run, err = f.ClaimDelayedRun(ctx, now.Add(2*time.Hour), claimed)
+			assert.NoError(t, err)
+			assert.NotNil(t, run)
+			assert.NoError(t, f.RemoveDelayedRun(ctx, claimed))
+			run, err = f.ClaimDelayedRun(ctx, now.Add(2*time.Hour), claimed)
+			assert.NoError(t, err)
+			assert.Nil(t, run)
+		},
+	},
+	{
+		name: "list events of all accounts",
+		run: func(t *testing.T, f *storeFixture) {
+			e1 := f.createEvent(t, "A", "repo1", "secret", false)
+			e2 := f.createEvent(t, "B", "repo2", "secret", false)
+			e3 := f.createEvent(t, "A", "public", "secret", true)
+			// skip account check
+			all := storeContext("-", false)
+			events, err := f.GetEvents(all, "", "", "")
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, []model.Event{*e1, *e2, *e3}, events)
+			events, err = f.GetEvents(all, "registry", "dockerhub", "registry:dockerhub:repo*")
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, []model.Event{*e1, *e2}, events)
+			triggers, err := f.GetEventTriggers(all, "")
+			assert.NoError(t, err)
+			assert.Empty(t, triggers)
+			// deleted event is removed from indexes
+			assert.NoError(t, f.DeleteEvent(storeContext("B", false), e2.URI, ""))
+			events, err = f.GetEvents(all, "registry", "dockerhub", "")
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, []model.Event{*e1, *e3}, events)
+			events, err = f.GetEvents(storeContext("B", true), "", "", "")
+			assert.NoError(t, err)
+			assert.Equal(t, []model.Event{*e3}, events)
+		},
+	},
+	{
+		name: "reindex events",
+		run: func(t *testing.T, f *storeFixture) {
+			e1 := f.createEvent(t, "A", "repo1", "secret", false)
+			e2 := f.createEvent(t, "A", "public", "secret", true)
+			for i := 0; i < 2; i++ {
+				n, err := f.Reindex(context.Background())
+				assert.NoError(t, err)
+				assert.Equal(t, 2, n)
+			}
+			events, err := f.GetEvents(storeContext("A", true), "", "", "")
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, []model.Event{*e1, *e2}, events)
+		},
+	},
+	{
+		name: "schema version",
+		run: func(t *testing.T, f *storeFixture) {
+			ctx := context.Background()
+			// empty store has current schema
+			version, err := f.GetSchemaVersion(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, SchemaVersion, version)
+			// data without schema version
+			f.createEvent(t, "A", "repo", "secret", false)
+			version, err = f.GetSchemaVersion(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, 0, version)
+			assert.NoError(t, f.SetSchemaVersion(ctx, SchemaVersion))
+			version, err = f.GetSchemaVersion(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, SchemaVersion, version)
+		},
+	},
+	{
+		name: "concurrent trigger updates",
+		run: func(t *testing.T, f *storeFixture) {
+			event := f.createEvent(t, "A", "repo", "secret", false)
+			ctx := storeContext("A", false)
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					pipeline := fmt.Sprintf("p%02d", i)
+					assert.NoError(t, f.CreateTrigger(ctx, event.URI, pipeline, map[string]string{"tag": "master"}))
+					_, err := f.GetEventTriggers(ctx, event.URI)
+					assert.NoError(t, err)
+				}(i)
+			}
+			wg.Wait()
+			triggers, err := f.GetEventTriggers(ctx, event.URI)
+			assert.NoError(t, err)
+			assert.Equal(t, 20, len(triggers))
+		},
+	},
+	{
+		name: "create trigger for missing event",
+		run: func(t *testing.T, f *storeFixture) {
+			err := f.CreateTrigger(storeContext("A", false), "registry:dockerhub:missing:"+model.CalculateAccountHash("A"), "p1", nil)
+			assert.Equal(t, model.ErrEventNotFound, err)
+		},
+	},
+	{
+		name: "concurrent create trigger and delete event",
+		run: func(t *testing.T, f *storeFixture) {
+			ctx := storeContext("A", false)
+			for i := 0; i < 10; i++ {
+				event := f.createEvent(t, "A", fmt.Sprintf("repo%d", i), "secret", false)
+				var wg sync.WaitGroup
+				var createErr, deleteErr error
+				wg.Add(2)
+				go func() {
+					defer wg.Done()
+					createErr = f.CreateTrigger(ctx, event.URI, "p1", map[string]string{"tag": "master"})
+				}()
+				go func() {
+					defer wg.Done()
+					deleteErr = f.DeleteEvent(ctx, event.URI, "")
+				}()
+				wg.Wait()
+				// exactly one wins: trigger for existing event or deleted event without trigger
+				if createErr == nil {
+					assert.Equal(t, model.ErrEventDeleteWithTriggers, deleteErr)
+				} else {
+					assert.Equal(t, model.ErrEventNotFound, createErr)
+					assert.NoError(t, deleteErr)
+				}
+			}
+			report, err := f.CheckStore(context.Background(), false)
+			assert.NoError(t, err)
+			assert.Empty(t, report.Issues)
+		},
+	},
+	{
+		name: "concurrent create event stores single event",
+		run: func(t *testing.T, f *storeFixture) {
+			values := map[string]string{"name": "repo"}
+			uri := "registry:dockerhub:repo:" + model.CalculateAccountHash("A")
+			f.provider.On("ConstructEventURI", "registry", "dockerhub", "A", values).Return(uri, nil)
+			f.provider.On("SubscribeToEvent", mock.Anything, uri, mock.Anything, map[string]string(nil)).Return(&model.EventInfo{Status: "active"}, nil)
+			events := make([]*model.Event, 10)
+			errs := make([]error, 10)
+			var wg sync.WaitGroup
+			for i := range events {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					event, err := f.CreateEvent(storeContext("A", false), "registry", "dockerhub", fmt.Sprintf("secret%d", i), "", values)
+					errs[i] = err
+					events[i] = event
+				}(i)
+			}
+			wg.Wait()
+			// all callers get stored event: fields of single create call; others get secret conflict
+			stored, err := f.GetEvent(storeContext("A", false), uri)
+			assert.NoError(t, err)
+			created := 0
+			for i, event := range events {
+				assert.Equal(t, stored, event)
+				if errs[i] == nil {
+					created++
+				} else {
+					assert.Equal(t, model.ErrEventSecretConflict, errs[i])
+				}
+			}
+			assert.Equal(t, 1, created)
+		},
+	},
+	{
+		name: "concurrent delete all triggers by pipeline and create trigger",
+		run: func(t *testing.T, f *storeFixture) {
+			ctx := storeContext("A", false)
+			var uris []string
+			for i := 0; i < 10; i++ {
+				event := f.createEvent(t, "A", fmt.Sprintf("repo%d", i), "secret", false)
+				uris = append(uris, event.URI)
+				assert.NoError(t, f.CreateTrigger(ctx, event.URI, "p1", map[string]string{"tag": "master"}))
+			}
+			var wg sync.WaitGroup
+			for i, uri := range uris {
+				wg.Add(2)
+				go func(uri string) {
+					defer wg.Done()
+					assert.NoError(t, f.CreateTrigger(ctx, uri, fmt.Sprintf("p%d", len(uri)%3+1), nil))
+				}(uri)
+				go func(i int) {
+					defer wg.Done()
+					if i%3 == 0 {
+						assert.NoError(t, f.DeleteAllTriggersByPipeline(ctx, "p1"))
+					}
+				}(i)
+			}
+			wg.Wait()
+			report, err := f.CheckStore(context.Background(), false)
+			assert.NoError(t, err)
+			assert.Empty(t, report.Issues)
+		},
+	},
+	{
+		name: "list events in pages",
+		run: func(t *testing.T, f *storeFixture) {
+			var uris []string
+			for i := 0; i < 5; i++ {
+				uris = append(uris, f.createEvent(t, "A", fmt.Sprintf("repo%d", i), "secret", false).URI)
+			}
+			uris = append(uris, f.createEvent(t, "A", "public", "secret", true).URI)
+			f.createEvent(t, "B", "other", "secret", false)
+			sort.Strings(uris)
+			// iterate pages, including public events
+			ctx := storeContext("A", true)
+			var listed []string
+			opts := model.PageOptions{Limit: 2}
+			for pages := 1; ; pages++ {
+				page, err := f.GetEventsPage(ctx, "registry", "dockerhub", "", opts)
+				assert.NoError(t, err)
+				assert.True(t, len(page.Events) <= 2)
+				for _, e := range page.Events {
+					listed = append(listed, e.URI)
+				}
+				if page.NextCursor == "" {
+					assert.Equal(t, 3, pages)
+					break
+				}
+				opts.Cursor = page.NextCursor
+			}
+			assert.Equal(t, uris, listed)
+			// descending order
+			page, err := f.GetEventsPage(ctx, "", "", "", model.PageOptions{Limit: 1, Sort: "-uri"})
+			assert.NoError(t, err)
+			assert.Equal(t, uris[len(uris)-1], page.Events[0].URI)
+			// cursor of another sort order, malformed cursor and unknown sort field
+			_, err = f.GetEventsPage(ctx, "", "", "", model.PageOptions{Sort: "uri", Cursor: page.NextCursor})
+			assert.Equal(t, model.ErrInvalidCursor, err)
+			_, err = f.GetEventsPage(ctx, "", "", "", model.PageOptions{Cursor: "invalid"})
+			assert.Equal(t, model.ErrInvalidCursor, err)
+			_, err = f.GetEventsPage(ctx, "", "", "", model.PageOptions{Sort: "secret"})
+			assert.Equal(t, model.ErrInvalidSort, err)
+			// no matching events
+			page, err = f.GetEventsPage(ctx, "git", "", "", model.PageOptions{})
+			assert.NoError(t, err)
+			assert.Empty(t, page.Events)
+			assert.Empty(t, page.NextCursor)
+		},
+	},
+	{
+		name: "list triggers in pages",
+		run: func(t *testing.T, f *storeFixture) {
+			ctx := storeContext("A", false)
+			for i := 0; i < 3; i++ {
+				event := f.createEvent(t, "A", fmt.Sprintf("repo%d", i), "secret", false)
+				for _, pipeline := range []string{"p2", "p1"} {
+					assert.NoError(t, f.CreateTrigger(ctx, event.URI, pipeline, map[string]string{"tag": pipeline}))
+				}
+			}
+			all, err := f.GetEventTriggers(ctx, "*")
+			assert.NoError(t, err)
+			// list in pages with every sort order
+			for _, order := range []string{"", "-event", "pipeline", "-pipeline"} {
+				var listed []model.Trigger
+				opts := model.PageOptions{Limit: 4, Sort: order}
+				for {
+					page, err := f.GetEventTriggersPage(ctx, "*", opts)
+					assert.NoError(t, err)
+					listed = append(listed, page.Triggers...)
+					if page.NextCursor == "" {
+						break
+					}
+					opts.Cursor = page.NextCursor
+				}
+				assert.ElementsMatch(t, all, listed, order)
+				assert.True(t, sort.SliceIsSorted(listed, func(i, j int) bool {
+					a := []string{listed[i].Event, listed[i].Pipeline}
+					b := []string{listed[j].Event, listed[j].Pipeline}
+					if strings.HasSuffix(order, "pipeline") {
+						a[0], a[1], b[0], b[1] = a[1], a[0], b[1], b[0]
+					}
+					if strings.HasPrefix(order, "-") {
+						a, b = b, a
+					}
+					return a[0] < b[0] || a[0] == b[0] && a[1] < b[1]
+				}), order)
+			}
+			// single event triggers
+			page, err := f.GetEventTriggersPage(ctx, all[0].Event, model.PageOptions{})
+			assert.NoError(t, err)
+			assert.Equal(t, 2, len(page.Triggers))
+			_, err = f.GetEventTriggersPage(ctx, "*", model.PageOptions{Sort: "filters"})
+			assert.Equal(t, model.ErrInvalidSort, err)
+		},
+	},
+	{
+		name: "rotate event secret",
+		run: func(t *testing.T, f *storeFixture) {
+			created := f.createEvent(t, "A", "repo", "secret", false)
+			ctx := storeContext("A", false)
+			rotated, err := f.RotateEventSecret(ctx, created.URI, model.SecretRotation{Grace: time.Hour})
+			assert.NoError(t, err)
+			assert.Equal(t, 16, len(rotated.Secret))
+			assert.NotEqual(t, "secret", rotated.Secret)
+			assert.Equal(t, "secret", rotated.PreviousSecret)
+			assert.Equal(t, []string{rotated.Secret, "secret"}, rotated.Secrets(time.Now()))
+			assert.Equal(t, []string{rotated.Secret}, rotated.Secrets(time.Now().Add(2*time.Hour)))
+			got, err := f.GetEvent(ctx, created.URI)
+			assert.NoError(t, err)
+			assert.Equal(t, rotated, got)
+			// previous secret is kept during grace period
+			n, err := f.PurgeExpiredSecrets(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, 0, n)
+			// rotate again with expired grace period: only new secret is valid; previous secret is purged
+			again, err := f.RotateEventSecret(ctx, created.URI, model.SecretRotation{Grace: time.Nanosecond})
+			assert.NoError(t, err)
+			assert.Equal(t, rotated.Secret, again.PreviousSecret)
+			assert.Equal(t, []string{again.Secret}, again.Secrets(time.Now()))
+			n, err = f.PurgeExpiredSecrets(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, 1, n)
+			got, err = f.GetEvent(ctx, created.URI)
+			assert.NoError(t, err)
+			assert.Equal(t, again.Secret, got.Secret)
+			assert.Empty(t, got.PreviousSecret)
+			assert.Nil(t, got.PreviousSecretExpires)
+			// rotate without grace period
+			rotated, err = f.RotateEventSecret(ctx, created.URI, model.SecretRotation{})
+			assert.NoError(t, err)
+			assert.Empty(t, rotated.PreviousSecret)
+			// another account
+			_, err = f.RotateEventSecret(storeContext("B", false), created.URI, model.SecretRotation{})
+			assert.Equal(t, model.ErrEventNotFound, err)
+		},
+	},
+	{
+		name: "rotate event secret and push it to event provider",
+		run: func(t *testing.T, f *storeFixture) {
+			created := f.createEvent(t, "A", "repo", "secret", false)
+			f.provider.On("SubscribeToEvent", mock.Anything, created.URI, mock.Anything, map[string]string(nil)).Return(&model.EventInfo{Endpoint: "http://new", Status: "active"}, nil).Once()
+			rotated, err := f.RotateEventSecret(storeContext("A", false), created.URI, model.SecretRotation{Grace: time.Hour, Push: true})
+			assert.NoError(t, err)
+			assert.Equal(t, "http://new", rotated.Endpoint)
+			f.provider.AssertCalled(t, "SubscribeToEvent", mock.Anything, created.URI, rotated.Secret, map[string]string(nil))
+			// provider error: secret is not changed
+			f.provider.On("SubscribeToEvent", mock.Anything, created.URI, mock.Anything, map[string]string(nil)).Return(nil, errors.New("provider error")).Once()
+			_, err = f.RotateEventSecret(storeContext("A", false), created.URI, model.SecretRotation{Push: true})
+			assert.Error(t, err)
+			got, err := f.GetEvent(storeContext("A", false), created.URI)
+			assert.NoError(t, err)
+			assert.Equal(t, rotated, got)
+			// new secret is stored before it is pushed to event provider
+			var stored *model.Event
+			f.provider.On("SubscribeToEvent", mock.Anything, created.URI, mock.Anything, map[string]string(nil)).Run(func(args mock.Arguments) {
+				stored, _ = f.GetEvent(storeContext("A", false), created.URI)
+				assert.Equal(t, args.String(2), stored.Secret)
+			}).Return(&model.EventInfo{Endpoint: "http://newer", Status: "active"}, nil).Once()
+			again, err := f.RotateEventSecret(storeContext("A", false), created.URI, model.SecretRotation{Grace: time.Hour, Push: true})
+			assert.NoError(t, err)
+			if assert.NotNil(t, stored) {
+				assert.Equal(t, again.Secret, stored.Secret)
+				assert.Equal(t, rotated.Secret, stored.PreviousSecret)
+			}
+			got, err = f.GetEvent(storeContext("A", false), created.URI)
+			assert.NoError(t, err)
+			assert.Equal(t, again, got)
+			assert.Equal(t, "http://newer", got.Endpoint)
+		},
+	},
+}
+
+// run the same behavioral tests against every registered storage driver
+func TestStoreConformance(t *testing.T) {
+	for _, driver := range StoreDrivers() {
+		t.Run(driver, func(t *testing.T) {
+			for _, tt := range storeConformanceTests {
+				t.Run(tt.name, func(t *testing.T) {
+					tt.run(t, newTestStore(t, driver))
+				})
+			}
+		})
+	}
+}
+
+// benchProvider event provider for benchmarks: constructs registry:dockerhub:{name}:{hash(account)} URIs
+type benchProvider struct {
+	provider.EventProvider
+}
+
+func (benchProvider) ConstructEventURI(t string, k string, a string, values map[string]string) (string, error) {
+	return fmt.Sprintf("%s:%s:%s:%s", t, k, values["name"], model.CalculateAccountHash(a)), nil
+}
+
+func (benchProvider) SubscribeToEvent(ctx context.Context, event, secret string, credentials map[string]string) (*model.EventInfo, error) {
+	return &model.EventInfo{Status: "active"}, nil
+}
+
+// listing account events should not depend on total number of trigger events (all accounts)
+func BenchmarkStore_GetEvents(b *testing.B) {
+	const accountEvents = 10
+	for _, driver := range StoreDrivers() {
+		for _, total := range []int{100, 1000, 10000} {
+			b.Run(fmt.Sprintf("%s/total=%d", driver, total), func(b *testing.B) {
+				store := openTestStore(b, driver, nil, benchProvider{})
+				for i := 0; i < total; i++ {
+					account := "A"
+					if i >= accountEvents {
+						account = fmt.Sprintf("account-%d", i%100)
+					}
+					_, err := store.CreateEvent(storeContext(account, false), "registry", "dockerhub", "secret", "", map[string]string{"name": fmt.Sprintf("repo-%d", i)})
+					if err != nil {
+						b.Fatalf("failed to create trigger event: %v", err)
+					}
+				}
+				ctx := storeContext("A", false)
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					events, err := store.GetEvents(ctx, "", "", "")
+					if err != nil || len(events) != accountEvents {
+						b.Fatalf("unexpected events: %d, %v", len(events), err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	_, err := NewStore("unknown", StoreConfig{}, nil, nil)
+	assert.Error(t, err)
+	store, err := NewStore("memory", StoreConfig{}, nil, nil)
+	assert.NoError(t, err)
+	assert.IsType(t, &MemoryStore{}, store)
+}
+
+func Test_compileKeyPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{"event:*", "event:uri:0/30 * * ?", true},
+		{"event:*:abc", "event:uri:abc", true},
+		{"event:*:abc", "event:uri:abd", false},
+		{"event:uri", "event:uri", true},
+		{"event:uri", "event:uri:abc", false},
+		{"event:ur?", "event:uri", true},
+		{"event:[ab]", "event:b", true},
+		{"event:[^ab]", "event:b", false},
+		{"event:a.b", "event:aXb", false},
+		{`event:\*`, "event:*", true},
+		{`event:\*`, "event:x", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.key, func(t *testing.T) {
+			re, err := compileKeyPattern(tt.pattern)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, re.MatchString(tt.key))
+		})
+	}
+}
@@ -65,7 +65,7 @@ func checkResponse(text string, err error, resp *http.Response) error {
 			msg = fmt.Sprintf("%s; more details: %s", msg, details)
 		}
 		log.Error(msg)
-		return fmt.Errorf(msg)
+		return errors.New(msg)
 	}
 	return nil
 }
@@ -199,6 +199,11 @@ func (api *APIEndpoint) getPipeline(ctx context.Context, account, id string) (*P
 		log.Debug("get pipelines, using public cfapi")
 		resp, err = apiClient.Get(fmt.Sprint("api/pipelines/", id)).ReceiveSuccess(pipeline)
 	}
+	// deleted (or never existing) pipeline
+	if err == nil && resp != nil && resp.StatusCode == http.StatusNotFound {
+		log.WithField("pipeline", id).Error("failed to find pipeline with id")
+		return nil, ErrPipelineNotFound
+	}
 	err = checkResponse("get pipelines", err, resp)
 	if err != nil {
 		log.WithError(err).Error("failed to get pipelines")
@@ -206,7 +211,7 @@ func (api *APIEndpoint) getPipeline(ctx context.Context, account, id string) (*P
 	}
 
 	// scan for pipeline ID
-	if pipeline != nil {
+	if pipeline.ID != "" {
 		log.WithFields(log.Fields{
 			"pipeline":   pipeline.ID,
 			"account-id": pipeline.Account.ID,
@@ -0,0 +1,55 @@
+package codefresh
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIEndpoint_GetPipeline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/pipelines/p1":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id": "p1", "account": {"_id": "A"}}`))
+		case "/api/pipelines/empty":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{}`))
+		case "/api/pipelines/broken":
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		default:
+			http.Error(w, `{"message": "pipeline not found"}`, http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	api := NewCodefreshEndpoint(srv.URL+"/", "test-token")
+	tests := []struct {
+		name     string
+		account  string
+		id       string
+		want     *Pipeline
+		wantErr  error
+		anyError bool
+	}{
+		{name: "found", account: "A", id: "p1", want: &Pipeline{ID: "p1", Account: "A"}},
+		{name: "other account", account: "B", id: "p1", wantErr: ErrPipelineNoMatch},
+		{name: "deleted", account: "A", id: "deleted", wantErr: ErrPipelineNotFound},
+		{name: "empty response", account: "A", id: "empty", wantErr: ErrPipelineNotFound},
+		{name: "server error", account: "A", id: "broken", anyError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := api.GetPipeline(context.Background(), tt.account, tt.id)
+			if tt.anyError {
+				assert.Error(t, err)
+				assert.NotEqual(t, ErrPipelineNotFound, err)
+				return
+			}
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
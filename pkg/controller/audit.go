@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultAuditLimit default number of listed audit entries
+const defaultAuditLimit = 100
+
+// AuditController audit log controller
+type AuditController struct {
+	auditReader model.AuditReader
+}
+
+// NewAuditController new audit log controller
+func NewAuditController(auditReader model.AuditReader) *AuditController {
+	return &AuditController{auditReader}
+}
+
+// GetAudit list account audit entries, latest first
+// query: from and to (RFC3339 time range), resource (event|trigger), event, pipeline and limit
+func (c *AuditController) GetAudit(ctx *gin.Context) {
+	filter, err := getAuditFilter(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResult{http.StatusBadRequest, "invalid audit query", err.Error()})
+		return
+	}
+	entries, err := c.auditReader.GetAudit(getContext(ctx), filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorResult{http.StatusInternalServerError, "failed to get audit entries", err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, entries)
+}
+
+// get audit filter from URL account and query parameters
+func getAuditFilter(ctx *gin.Context) (model.AuditFilter, error) {
+	filter := model.AuditFilter{
+		Account:  getParam(ctx, "account"),
+		Resource: ctx.Query("resource"),
+		Event:    ctx.Query("event"),
+		Pipeline: ctx.Query("pipeline"),
+	}
+	if filter.Resource != "" && filter.Resource != model.AuditResourceEvent && filter.Resource != model.AuditResourceTrigger {
+		return filter, fmt.Errorf("invalid resource %q, expected %s or %s", filter.Resource, model.AuditResourceEvent, model.AuditResourceTrigger)
+	}
+	var err error
+	if filter.From, filter.To, err = getTimeRange(ctx); err != nil {
+		return filter, err
+	}
+	if filter.Limit, err = getLimit(ctx, defaultAuditLimit); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAuditController_GetAudit(t *testing.T) {
+	from, _ := time.Parse(time.RFC3339, "2018-01-02T10:00:00Z")
+	tests := []struct {
+		name       string
+		query      string
+		wantFilter model.AuditFilter
+		wantErr    error
+		wantCode   int
+	}{
+		{
+			name:       "all entries",
+			wantFilter: model.AuditFilter{Account: "A", Limit: defaultAuditLimit},
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:       "filtered entries",
+			query:      "?from=2018-01-02T10:00:00Z&resource=trigger&event=uri:1&pipeline=p1&limit=5",
+			wantFilter: model.AuditFilter{Account: "A", From: from, Resource: "trigger", Event: "uri:1", Pipeline: "p1", Limit: 5},
+			wantCode:   http.StatusOK,
+		},
+		{name: "invalid time", query: "?to=yesterday", wantCode: http.StatusBadRequest},
+		{name: "invalid resource", query: "?resource=pipeline", wantCode: http.StatusBadRequest},
+		{name: "invalid limit", query: "?limit=-1", wantCode: http.StatusBadRequest},
+		{
+			name:       "store error",
+			wantFilter: model.AuditFilter{Account: "A", Limit: defaultAuditLimit},
+			wantErr:    errors.New("TEST ERROR"),
+			wantCode:   http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &model.MockAuditReader{}
+			c := NewAuditController(mockSvc)
+			w := httptest.NewRecorder()
+			ginCtx, _ := gin.CreateTestContext(w)
+			ginCtx.Request, _ = http.NewRequest("GET", "/test"+tt.query, nil)
+			ginCtx.Params = gin.Params{gin.Param{Key: "account", Value: "A"}}
+			// prepare mock
+			mockSvc.On("GetAudit", mock.Anything, tt.wantFilter).Return([]model.AuditEntry{{ID: "01", Account: "A"}}, tt.wantErr)
+			// invoke
+			c.GetAudit(ginCtx)
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode != http.StatusBadRequest {
+				mockSvc.AssertExpectations(t)
+			}
+		})
+	}
+}
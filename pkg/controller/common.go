@@ -2,7 +2,12 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/codefresh-io/hermes/pkg/codefresh"
 	"github.com/codefresh-io/hermes/pkg/model"
@@ -19,6 +24,12 @@ type ErrorResult struct {
 	Error   string `json:"error"`
 }
 
+// ConflictResult returned by controllers with conflict status: error and existing object
+type ConflictResult struct {
+	ErrorResult
+	Existing interface{} `json:"existing"`
+}
+
 type contextKey string
 
 func getParam(c *gin.Context, name string) string {
@@ -64,3 +75,96 @@ func getContext(c *gin.Context) context.Context {
 	}
 	return ctx
 }
+
+// get listing page options from limit, cursor and sort query parameters
+// returns false, when listing is not paged (no paging parameter is set)
+func getPageOptions(c *gin.Context) (model.PageOptions, bool, error) {
+	var page model.PageOptions
+	limit, hasLimit := c.GetQuery("limit")
+	cursor, hasCursor := c.GetQuery("cursor")
+	sort, hasSort := c.GetQuery("sort")
+	if !hasLimit && !hasCursor && !hasSort {
+		return page, false, nil
+	}
+	if hasLimit {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return page, true, fmt.Errorf("invalid limit %q", limit)
+		}
+		page.Limit = n
+	}
+	page.Cursor = cursor
+	page.Sort = sort
+	return page, true, nil
+}
+
+// get from and to query parameters: RFC3339 time range (zero time when not set)
+func getTimeRange(c *gin.Context) (from, to time.Time, err error) {
+	if v := c.Query("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, fmt.Errorf("invalid from time %q", v)
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, fmt.Errorf("invalid to time %q", v)
+		}
+	}
+	return from, to, nil
+}
+
+// get limit query parameter or default limit when not set (0: no limit)
+func getLimit(c *gin.Context, limit int) (int, error) {
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return limit, fmt.Errorf("invalid limit %q", v)
+		}
+		return n, nil
+	}
+	return limit, nil
+}
+
+// select fields of listed items: fields is comma separated list of JSON field names ("": all fields)
+func selectFields(items interface{}, fields string, allowed ...string) (interface{}, error) {
+	if fields == "" {
+		return items, nil
+	}
+	names := strings.Split(fields, ",")
+	for _, name := range names {
+		known := false
+		for _, a := range allowed {
+			known = known || a == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown field %q, expected one of: %s", name, strings.Join(allowed, ","))
+		}
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	var list []map[string]interface{}
+	if err = json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	selected := make([]map[string]interface{}, len(list))
+	for i, item := range list {
+		selected[i] = make(map[string]interface{}, len(names))
+		for _, name := range names {
+			if v, ok := item[name]; ok {
+				selected[i][name] = v
+			}
+		}
+	}
+	return selected, nil
+}
+
+// listing page response: items under name and next page cursor (if any)
+func pageResult(name string, items interface{}, nextCursor string) gin.H {
+	result := gin.H{name: items}
+	if nextCursor != "" {
+		result["next_cursor"] = nextCursor
+	}
+	return result
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// DispatchController pipeline dispatch admin controller (maintenance mode)
+type DispatchController struct {
+	dispatcher model.Dispatcher
+}
+
+// NewDispatchController new pipeline dispatch admin controller
+func NewDispatchController(dispatcher model.Dispatcher) *DispatchController {
+	return &DispatchController{dispatcher}
+}
+
+// GetDispatch list paused dispatch scopes with number of queued events
+func (c *DispatchController) GetDispatch(ctx *gin.Context) {
+	states, err := c.dispatcher.GetStates(getContext(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorResult{http.StatusInternalServerError, "failed to get dispatch states", err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, states)
+}
+
+// PauseDispatch pause pipeline dispatch for account or for all accounts; received events are queued
+func (c *DispatchController) PauseDispatch(ctx *gin.Context) {
+	scope := getDispatchScope(ctx)
+	if err := c.dispatcher.Pause(getContext(ctx), scope); err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorResult{http.StatusInternalServerError, "failed to pause dispatch", err.Error()})
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
+
+// ResumeDispatch run queued events in order (or discard them with 'discard=true') and resume pipeline dispatch
+// queue is drained in background: progress is reported to log and run history, and by dispatch states
+func (c *DispatchController) ResumeDispatch(ctx *gin.Context) {
+	discard := false
+	if v := ctx.Query("discard"); v != "" {
+		var err error
+		if discard, err = strconv.ParseBool(v); err != nil {
+			ctx.JSON(http.StatusBadRequest, ErrorResult{http.StatusBadRequest, "invalid discard parameter", err.Error()})
+			return
+		}
+	}
+	scope := getDispatchScope(ctx)
+	go func() {
+		lg := log.WithFields(log.Fields{"scope": scope, "discard": discard})
+		result, err := c.dispatcher.Resume(context.Background(), scope, discard)
+		if err != nil {
+			lg.WithError(err).Error("failed to resume dispatch")
+			return
+		}
+		lg.WithFields(log.Fields{
+			"dispatched": result.Dispatched,
+			"requeued":   result.Requeued,
+			"discarded":  result.Discarded,
+			"failed":     result.Failed,
+		}).Info("dispatch queue drained")
+	}()
+	ctx.Status(http.StatusAccepted)
+	ctx.Writer.WriteHeaderNow()
+}
+
+// dispatch scope: account from URL or all accounts
+func getDispatchScope(ctx *gin.Context) string {
+	if account := getParam(ctx, "account"); account != "" {
+		return account
+	}
+	return model.DispatchGlobal
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDispatchController_ResumeDispatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		account   string
+		query     string
+		wantScope string
+		discard   bool
+		wantErr   error
+		wantCode  int
+	}{
+		{name: "resume global dispatch", wantScope: model.DispatchGlobal, wantCode: http.StatusAccepted},
+		{name: "resume account dispatch", account: "A", wantScope: "A", wantCode: http.StatusAccepted},
+		{name: "discard account queue", account: "A", query: "?discard=true", wantScope: "A", discard: true, wantCode: http.StatusAccepted},
+		{name: "invalid discard parameter", query: "?discard=maybe", wantCode: http.StatusBadRequest},
+		{name: "resume with error", wantScope: model.DispatchGlobal, wantErr: errors.New("TEST ERROR"), wantCode: http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &model.MockDispatcher{}
+			c := NewDispatchController(mockSvc)
+			w := httptest.NewRecorder()
+			ginCtx, _ := gin.CreateTestContext(w)
+			if tt.account != "" {
+				ginCtx.Params = gin.Params{gin.Param{Key: "account", Value: tt.account}}
+			}
+			ginCtx.Request, _ = http.NewRequest("POST", "/test"+tt.query, nil)
+			// prepare mock
+			result := &model.DispatchResult{Scope: tt.wantScope, Dispatched: 2}
+			resumed := make(chan struct{})
+			mockSvc.On("Resume", mock.Anything, tt.wantScope, tt.discard).Return(result, tt.wantErr).Run(func(mock.Arguments) {
+				close(resumed)
+			})
+			// invoke
+			c.ResumeDispatch(ginCtx)
+			// assert code and background resume
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode == http.StatusAccepted {
+				select {
+				case <-resumed:
+				case <-time.After(time.Second):
+					t.Fatal("dispatch was not resumed")
+				}
+				mockSvc.AssertExpectations(t)
+			}
+		})
+	}
+}
@@ -3,19 +3,36 @@ package controller
 import (
 	"context"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/codefresh-io/go-infra/pkg/logger"
 	"github.com/codefresh-io/hermes/pkg/model"
 	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
 )
 
+// trigger event fields, that can be selected with fields query parameter
+var eventFields = []string{"uri", "type", "kind", "account", "secret", "endpoint", "description", "status", "help"}
+
 // TriggerEventController trigger controller
 type TriggerEventController struct {
 	svc model.TriggerEventReaderWriter
+	// auth entities (name, ID or service name) allowed to read trigger event secrets; empty: any caller
+	secretReaders []string
+}
+
+// SecretResult trigger event secrets
+type SecretResult struct {
+	URI                   string     `json:"uri"`
+	Secret                string     `json:"secret"`
+	PreviousSecret        string     `json:"previous_secret,omitempty"`
+	PreviousSecretExpires *time.Time `json:"previous_secret_expires,omitempty"`
 }
 
-// NewTriggerEventController new trigger controller
-func NewTriggerEventController(svc model.TriggerEventReaderWriter) *TriggerEventController {
-	return &TriggerEventController{svc}
+// NewTriggerEventController new trigger controller; secret readers restrict who can read trigger event secrets
+func NewTriggerEventController(svc model.TriggerEventReaderWriter, secretReaders ...string) *TriggerEventController {
+	return &TriggerEventController{svc: svc, secretReaders: secretReaders}
 }
 
 // GetEvents get defined trigger events
@@ -30,15 +47,44 @@ func (c *TriggerEventController) GetEvents(ctx *gin.Context) {
 		actionContext = context.WithValue(actionContext, model.ContextKeyPublic, true)
 	}
 
+	page, paged, err := getPageOptions(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResult{http.StatusBadRequest, "invalid paging parameters", err.Error()})
+		return
+	}
+	fields := ctx.Query("fields")
+
 	// list trigger events, optionally filtered by type/kind and event uri filter
-	if events, err := c.svc.GetEvents(actionContext, eventType, kind, filter); err != nil {
+	var events []model.Event
+	var nextCursor string
+	if paged {
+		var eventPage *model.EventPage
+		if eventPage, err = c.svc.GetEventsPage(actionContext, eventType, kind, filter, page); err == nil {
+			events, nextCursor = eventPage.Events, eventPage.NextCursor
+		}
+	} else {
+		events, err = c.svc.GetEvents(actionContext, eventType, kind, filter)
+	}
+	if err != nil {
 		status := http.StatusInternalServerError
 		if err == model.ErrTriggerNotFound {
 			status = http.StatusNotFound
+		} else if err == model.ErrInvalidCursor || err == model.ErrInvalidSort {
+			status = http.StatusBadRequest
 		}
 		ctx.JSON(status, ErrorResult{status, "failed to list trigger events", err.Error()})
+		return
+	}
+	// select requested fields, without secrets
+	result, err := selectFields(model.RedactEvents(events), fields, eventFields...)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResult{http.StatusBadRequest, "invalid fields", err.Error()})
+		return
+	}
+	if paged {
+		ctx.JSON(http.StatusOK, pageResult("events", result, nextCursor))
 	} else {
-		ctx.JSON(http.StatusOK, events)
+		ctx.JSON(http.StatusOK, result)
 	}
 }
 
@@ -47,15 +93,80 @@ func (c *TriggerEventController) GetEvent(ctx *gin.Context) {
 	event := getParam(ctx, "event")
 	if triggerEvent, err := c.svc.GetEvent(getContext(ctx), event); err != nil {
 		status := http.StatusInternalServerError
-		if err == model.ErrTriggerNotFound {
+		if err == model.ErrTriggerNotFound || err == model.ErrEventNotFound {
 			status = http.StatusNotFound
 		}
 		ctx.JSON(status, ErrorResult{status, "failed to get trigger event", err.Error()})
 	} else {
-		ctx.JSON(http.StatusOK, triggerEvent)
+		ctx.JSON(http.StatusOK, triggerEvent.Redacted())
 	}
 }
 
+// GetSecret get trigger event secrets: the only API returning secrets; every request is logged and every read is
+// recorded in audit log
+func (c *TriggerEventController) GetSecret(ctx *gin.Context) {
+	event := getParam(ctx, "event")
+	actionContext := getContext(ctx)
+	lg := log.WithFields(log.Fields{
+		"audit":     "read-secret",
+		"event-uri": event,
+		"account":   ctx.Param("account"),
+	})
+	auth, err := model.GetAuthEntity(actionContext)
+	if err != nil {
+		lg.WithError(err).Error("failed to load authenticated entity")
+	}
+	if auth != nil {
+		lg = lg.WithFields(log.Fields{
+			logger.FieldAuthName: auth.Name,
+			logger.FieldAuthID:   auth.ID,
+			logger.FieldAuthType: auth.Type,
+		})
+	}
+	if !c.canReadSecret(auth) {
+		lg.Warn("trigger event secret access denied")
+		ctx.JSON(http.StatusForbidden, ErrorResult{http.StatusForbidden, "failed to get trigger event secret", "access denied"})
+		return
+	}
+
+	triggerEvent, err := c.svc.GetEvent(actionContext, event)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err == model.ErrTriggerNotFound || err == model.ErrEventNotFound {
+			status = http.StatusNotFound
+		}
+		lg.WithError(err).Warn("failed to read trigger event secret")
+		ctx.JSON(status, ErrorResult{status, "failed to get trigger event secret", err.Error()})
+		return
+	}
+	lg.Info("trigger event secret read")
+	if auditor, ok := c.svc.(model.SecretReadAuditor); ok {
+		auditor.AuditSecretRead(actionContext, triggerEvent)
+	}
+	ctx.JSON(http.StatusOK, SecretResult{
+		URI:                   triggerEvent.URI,
+		Secret:                triggerEvent.Secret,
+		PreviousSecret:        triggerEvent.PreviousSecret,
+		PreviousSecretExpires: triggerEvent.PreviousSecretExpires,
+	})
+}
+
+// auth entity is allowed to read trigger event secrets
+func (c *TriggerEventController) canReadSecret(auth *model.AuthEntity) bool {
+	if len(c.secretReaders) == 0 {
+		return true
+	}
+	if auth == nil {
+		return false
+	}
+	for _, reader := range c.secretReaders {
+		if reader == auth.Name || reader == auth.ID || reader == auth.ServiceName {
+			return true
+		}
+	}
+	return false
+}
+
 // CreateEvent create trigger event
 func (c *TriggerEventController) CreateEvent(ctx *gin.Context) {
 	type createReq struct {
@@ -82,6 +193,11 @@ func (c *TriggerEventController) CreateEvent(ctx *gin.Context) {
 		status := http.StatusInternalServerError
 		if err == model.ErrTriggerAlreadyExists {
 			status = http.StatusBadRequest
+		} else if err == model.ErrEventSecretConflict {
+			// report existing trigger event
+			status = http.StatusConflict
+			ctx.JSON(status, ConflictResult{ErrorResult{status, "failed to add trigger event", err.Error()}, event.Redacted()})
+			return
 		}
 		ctx.JSON(status, ErrorResult{status, "failed to add trigger event", err.Error()})
 	} else {
@@ -90,15 +206,90 @@ func (c *TriggerEventController) CreateEvent(ctx *gin.Context) {
 	}
 }
 
-// DeleteEvent delete trigger event
+// UpdateEvent update trigger event: change description, replace secret or refresh event info from event provider
+func (c *TriggerEventController) UpdateEvent(ctx *gin.Context) {
+	event := getParam(ctx, "event")
+	var req model.EventUpdate
+	if err := ctx.BindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResult{http.StatusBadRequest, "error in request JSON body", err.Error()})
+		return
+	}
+
+	if triggerEvent, err := c.svc.UpdateEvent(getContext(ctx), event, req); err != nil {
+		status := http.StatusInternalServerError
+		if err == model.ErrEventNotFound {
+			status = http.StatusNotFound
+		} else if err == model.ErrStoreConflict {
+			status = http.StatusConflict
+		}
+		ctx.JSON(status, ErrorResult{status, "failed to update trigger event", err.Error()})
+	} else {
+		ctx.JSON(http.StatusOK, triggerEvent.Redacted())
+	}
+}
+
+// RotateSecret rotate trigger event secret: previous secret stays valid for grace period
+func (c *TriggerEventController) RotateSecret(ctx *gin.Context) {
+	event := getParam(ctx, "event")
+	type rotateReq struct {
+		Grace   string `json:"grace,omitempty"`
+		Push    bool   `json:"push,omitempty"`
+		Context string `json:"context,omitempty"`
+	}
+	var req rotateReq
+	if err := ctx.BindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResult{http.StatusBadRequest, "error in request JSON body", err.Error()})
+		return
+	}
+	rotation := model.SecretRotation{Grace: model.DefaultSecretGrace, Push: req.Push, Context: req.Context}
+	if req.Grace != "" {
+		grace, err := time.ParseDuration(req.Grace)
+		if err != nil || grace < 0 {
+			ctx.JSON(http.StatusBadRequest, ErrorResult{http.StatusBadRequest, "invalid grace period", req.Grace})
+			return
+		}
+		rotation.Grace = grace
+	}
+
+	if triggerEvent, err := c.svc.RotateEventSecret(getContext(ctx), event, rotation); err != nil {
+		status := http.StatusInternalServerError
+		if err == model.ErrEventNotFound {
+			status = http.StatusNotFound
+		} else if err == model.ErrStoreConflict {
+			status = http.StatusConflict
+		}
+		ctx.JSON(status, ErrorResult{status, "failed to rotate trigger event secret", err.Error()})
+	} else {
+		ctx.JSON(http.StatusOK, triggerEvent.Redacted())
+	}
+}
+
+// DeleteEvent delete trigger event; with force=true delete it together with linked triggers and report them
 func (c *TriggerEventController) DeleteEvent(ctx *gin.Context) {
 	event := getParam(ctx, "event")
 	context := ctx.Params.ByName("context")
+	force, _ := strconv.ParseBool(ctx.Query("force"))
 
+	if force {
+		if deletion, err := c.svc.ForceDeleteEvent(getContext(ctx), event, context); err != nil {
+			status := http.StatusInternalServerError
+			if err == model.ErrTriggerNotFound || err == model.ErrEventNotFound {
+				status = http.StatusNotFound
+			} else if err == model.ErrStoreConflict {
+				status = http.StatusConflict
+			}
+			ctx.JSON(status, ErrorResult{status, "failed to delete trigger event", err.Error()})
+		} else {
+			ctx.JSON(http.StatusOK, deletion)
+		}
+		return
+	}
 	if err := c.svc.DeleteEvent(getContext(ctx), event, context); err != nil {
 		status := http.StatusInternalServerError
-		if err == model.ErrTriggerNotFound {
+		if err == model.ErrTriggerNotFound || err == model.ErrEventNotFound {
 			status = http.StatusNotFound
+		} else if err == model.ErrStoreConflict || err == model.ErrEventDeleteWithTriggers {
+			status = http.StatusConflict
 		}
 		ctx.JSON(status, ErrorResult{status, "failed to delete trigger event", err.Error()})
 	} else {
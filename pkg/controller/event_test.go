@@ -1,12 +1,17 @@
 package controller
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/codefresh-io/hermes/pkg/codefresh"
 	"github.com/codefresh-io/hermes/pkg/model"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -191,3 +196,369 @@ func TestTriggerEventController_GetEvents(t *testing.T) {
 		})
 	}
 }
+
+func TestTriggerEventController_GetEventsPage(t *testing.T) {
+	events := []model.Event{
+		{URI: "uri:1", Type: "test-type", Kind: "test-kind", Secret: "secret"},
+		{URI: "uri:2", Type: "test-type", Kind: "test-kind", Secret: "secret"},
+	}
+	tests := []struct {
+		name     string
+		query    string
+		page     *model.PageOptions
+		wantErr  error
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "first page",
+			query:    "limit=2",
+			page:     &model.PageOptions{Limit: 2},
+			wantCode: http.StatusOK,
+			wantBody: `{"events":[{"uri":"uri:1","type":"test-type","kind":"test-kind","account":"","secret":"*****"},{"uri":"uri:2","type":"test-type","kind":"test-kind","account":"","secret":"*****"}],"next_cursor":"next"}`,
+		},
+		{
+			name:     "next page with selected fields",
+			query:    "cursor=abc&sort=-uri&fields=uri,kind",
+			page:     &model.PageOptions{Cursor: "abc", Sort: "-uri"},
+			wantCode: http.StatusOK,
+			wantBody: `{"events":[{"kind":"test-kind","uri":"uri:1"},{"kind":"test-kind","uri":"uri:2"}],"next_cursor":"next"}`,
+		},
+		{
+			name:     "invalid limit",
+			query:    "limit=-1",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "unknown field",
+			query:    "limit=2&fields=uri,password",
+			page:     &model.PageOptions{Limit: 2},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "invalid cursor",
+			query:    "cursor=abc",
+			page:     &model.PageOptions{Cursor: "abc"},
+			wantErr:  model.ErrInvalidCursor,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "invalid sort",
+			query:    "sort=secret",
+			page:     &model.PageOptions{Sort: "secret"},
+			wantErr:  model.ErrInvalidSort,
+			wantCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &model.MockTriggerEventReaderWriter{}
+			c := &TriggerEventController{
+				svc: mockSvc,
+			}
+			w := httptest.NewRecorder()
+			ginCtx, _ := gin.CreateTestContext(w)
+			ginCtx.Request, _ = http.NewRequest("GET", "/test?type=test-type&"+tt.query, nil)
+			// prepare mock
+			if tt.page != nil {
+				call := mockSvc.On("GetEventsPage", mock.Anything, "test-type", "", "", *tt.page)
+				if tt.wantErr != nil {
+					call.Return(nil, tt.wantErr)
+				} else {
+					call.Return(&model.EventPage{Events: events, NextCursor: "next"}, nil)
+				}
+			}
+			// invoke
+			c.GetEvents(ginCtx)
+			t.Log(w.Body.String())
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			}
+			// assert exectations
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTriggerEventController_CreateEventSecretConflict(t *testing.T) {
+	mockSvc := &model.MockTriggerEventReaderWriter{}
+	c := &TriggerEventController{
+		svc: mockSvc,
+	}
+	existing := &model.Event{URI: "uri:1", Type: "test-type", Kind: "test-kind", Secret: "secret"}
+	mockSvc.On("CreateEvent", mock.Anything, "test-type", "test-kind", "another", "", map[string]string(nil)).Return(existing, model.ErrEventSecretConflict)
+	w := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(w)
+	ginCtx.Request, _ = http.NewRequest("POST", "/test", strings.NewReader(`{"type":"test-type","kind":"test-kind","secret":"another"}`))
+	// invoke
+	c.CreateEvent(ginCtx)
+	t.Log(w.Body.String())
+	assert.Equal(t, http.StatusConflict, w.Code)
+	var got struct {
+		ErrorResult
+		Existing model.Event `json:"existing"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, http.StatusConflict, got.Status)
+	// secret is not revealed
+	assert.Equal(t, existing.Redacted(), got.Existing)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestTriggerEventController_UpdateEvent(t *testing.T) {
+	description := "new description"
+	tests := []struct {
+		name     string
+		body     string
+		update   *model.EventUpdate
+		event    *model.Event
+		wantErr  error
+		wantCode int
+	}{
+		{
+			name:     "update event",
+			body:     `{"description":"new description","refresh":true}`,
+			update:   &model.EventUpdate{Description: &description, Refresh: true},
+			event:    &model.Event{URI: "uri:1", EventInfo: model.EventInfo{Description: description}},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "invalid request",
+			body:     `{"refresh":"yes"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "missing event",
+			body:     `{"refresh":true}`,
+			update:   &model.EventUpdate{Refresh: true},
+			wantErr:  model.ErrEventNotFound,
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "event provider error",
+			body:     `{"refresh":true}`,
+			update:   &model.EventUpdate{Refresh: true},
+			wantErr:  errors.New("TEST ERROR"),
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &model.MockTriggerEventReaderWriter{}
+			c := &TriggerEventController{
+				svc: mockSvc,
+			}
+			w := httptest.NewRecorder()
+			ginCtx, _ := gin.CreateTestContext(w)
+			ginCtx.Params = gin.Params{gin.Param{Key: "event", Value: "uri%3A1"}}
+			ginCtx.Request, _ = http.NewRequest("PATCH", "/test", strings.NewReader(tt.body))
+			// prepare mock
+			if tt.update != nil {
+				mockSvc.On("UpdateEvent", mock.Anything, "uri:1", *tt.update).Return(tt.event, tt.wantErr)
+			}
+			// invoke
+			c.UpdateEvent(ginCtx)
+			t.Log(w.Body.String())
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.event != nil {
+				var got model.Event
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+				assert.Equal(t, *tt.event, got)
+			}
+			// assert exectations
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTriggerEventController_GetSecret(t *testing.T) {
+	// base64 encoded auth entity JSON
+	authEntity := func(entity string) string {
+		return base64.StdEncoding.EncodeToString([]byte(entity))
+	}
+	event := &model.Event{URI: "uri:1", Secret: "secret", PreviousSecret: "previous"}
+	tests := []struct {
+		name       string
+		readers    []string
+		authEntity string
+		event      *model.Event
+		wantErr    error
+		wantCode   int
+		wantBody   string
+	}{
+		{
+			name:     "get secret",
+			event:    event,
+			wantCode: http.StatusOK,
+			wantBody: `{"uri":"uri:1","secret":"secret","previous_secret":"previous"}`,
+		},
+		{
+			name:       "allowed user",
+			readers:    []string{"admin", "5a3b"},
+			authEntity: authEntity(`{"_id":"5a3b","name":"user"}`),
+			event:      event,
+			wantCode:   http.StatusOK,
+			wantBody:   `{"uri":"uri:1","secret":"secret","previous_secret":"previous"}`,
+		},
+		{
+			name:       "allowed service",
+			readers:    []string{"cfapi"},
+			authEntity: authEntity(`{"serviceName":"cfapi","type":"service"}`),
+			event:      event,
+			wantCode:   http.StatusOK,
+			wantBody:   `{"uri":"uri:1","secret":"secret","previous_secret":"previous"}`,
+		},
+		{
+			name:       "denied user",
+			readers:    []string{"admin"},
+			authEntity: authEntity(`{"_id":"5a3b","name":"user"}`),
+			wantCode:   http.StatusForbidden,
+		},
+		{
+			name:     "denied without auth entity",
+			readers:  []string{"admin"},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:       "denied with invalid auth entity",
+			readers:    []string{"admin"},
+			authEntity: "invalid",
+			wantCode:   http.StatusForbidden,
+		},
+		{
+			name:     "missing event",
+			wantErr:  model.ErrEventNotFound,
+			wantCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &model.MockTriggerEventReaderWriter{}
+			c := NewTriggerEventController(mockSvc, tt.readers...)
+			w := httptest.NewRecorder()
+			ginCtx, _ := gin.CreateTestContext(w)
+			ginCtx.Params = []gin.Param{{Key: "event", Value: "uri:1"}}
+			ginCtx.Request, _ = http.NewRequest("GET", "/test", nil)
+			if tt.authEntity != "" {
+				ginCtx.Request.Header.Set(codefresh.AuthEntity, tt.authEntity)
+			}
+			// prepare mock
+			if tt.event != nil || tt.wantErr != nil {
+				mockSvc.On("GetEvent", mock.Anything, "uri:1").Return(tt.event, tt.wantErr)
+			}
+			// invoke
+			c.GetSecret(ginCtx)
+			t.Log(w.Body.String())
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			}
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+// trigger event service recording secret reads
+type secretReadAuditorMock struct {
+	*model.MockTriggerEventReaderWriter
+	reads []string
+}
+
+func (m *secretReadAuditorMock) AuditSecretRead(ctx context.Context, event *model.Event) {
+	m.reads = append(m.reads, event.URI)
+}
+
+func TestTriggerEventController_GetSecretAudited(t *testing.T) {
+	mockSvc := &secretReadAuditorMock{MockTriggerEventReaderWriter: &model.MockTriggerEventReaderWriter{}}
+	c := NewTriggerEventController(mockSvc, "admin")
+	mockSvc.On("GetEvent", mock.Anything, "uri:1").Return(&model.Event{URI: "uri:1", Secret: "secret"}, nil)
+	for _, auth := range []string{`{"name":"admin"}`, `{"name":"user"}`} {
+		w := httptest.NewRecorder()
+		ginCtx, _ := gin.CreateTestContext(w)
+		ginCtx.Params = []gin.Param{{Key: "event", Value: "uri:1"}}
+		ginCtx.Request, _ = http.NewRequest("GET", "/test", nil)
+		ginCtx.Request.Header.Set(codefresh.AuthEntity, base64.StdEncoding.EncodeToString([]byte(auth)))
+		c.GetSecret(ginCtx)
+	}
+	// only granted read is recorded
+	assert.Equal(t, []string{"uri:1"}, mockSvc.reads)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestTriggerEventController_GetEventRedacted(t *testing.T) {
+	mockSvc := &model.MockTriggerEventReaderWriter{}
+	c := NewTriggerEventController(mockSvc)
+	mockSvc.On("GetEvent", mock.Anything, "uri:1").Return(&model.Event{URI: "uri:1", Secret: "s3cr3t", PreviousSecret: "pr3v"}, nil)
+	mockSvc.On("GetEvents", mock.Anything, "", "", "").Return([]model.Event{{URI: "uri:1", Secret: "s3cr3t"}}, nil)
+	for _, invoke := range []func(*gin.Context){c.GetEvent, c.GetEvents} {
+		w := httptest.NewRecorder()
+		ginCtx, _ := gin.CreateTestContext(w)
+		ginCtx.Params = []gin.Param{{Key: "event", Value: "uri:1"}}
+		ginCtx.Request, _ = http.NewRequest("GET", "/test", nil)
+		invoke(ginCtx)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "s3cr3t")
+		assert.NotContains(t, w.Body.String(), "pr3v")
+		assert.Contains(t, w.Body.String(), `"secret":"*****"`)
+	}
+	mockSvc.AssertExpectations(t)
+}
+
+func TestTriggerEventController_DeleteEvent(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		deletion *model.EventDeletion
+		wantErr  error
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "delete event",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "delete event with triggers",
+			wantErr:  model.ErrEventDeleteWithTriggers,
+			wantCode: http.StatusConflict,
+		},
+		{
+			name:     "force delete event with triggers",
+			query:    "?force=true",
+			deletion: &model.EventDeletion{URI: "uri:1", Pipelines: []string{"p1", "p2"}, Filters: []string{"p1"}},
+			wantCode: http.StatusOK,
+			wantBody: `{"uri":"uri:1","pipelines":["p1","p2"],"filters":["p1"]}`,
+		},
+		{
+			name:     "force delete missing event",
+			query:    "?force=true",
+			wantErr:  model.ErrEventNotFound,
+			wantCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &model.MockTriggerEventReaderWriter{}
+			c := NewTriggerEventController(mockSvc)
+			w := httptest.NewRecorder()
+			ginCtx, _ := gin.CreateTestContext(w)
+			ginCtx.Params = []gin.Param{{Key: "event", Value: "uri:1"}, {Key: "context", Value: "/ctx"}}
+			ginCtx.Request, _ = http.NewRequest("DELETE", "/test"+tt.query, nil)
+			// prepare mock
+			if tt.query != "" {
+				mockSvc.On("ForceDeleteEvent", mock.Anything, "uri:1", "/ctx").Return(tt.deletion, tt.wantErr)
+			} else {
+				mockSvc.On("DeleteEvent", mock.Anything, "uri:1", "/ctx").Return(tt.wantErr)
+			}
+			// invoke
+			c.DeleteEvent(ginCtx)
+			t.Log(w.Body.String())
+			assert.Equal(t, tt.wantCode, ginCtx.Writer.Status())
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			}
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
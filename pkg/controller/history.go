@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultRunsLimit default number of listed run records
+const defaultRunsLimit = 100
+
+// RunHistoryController trigger execution history controller
+type RunHistoryController struct {
+	historySvc  model.RunHistory
+	replayerSvc model.Replayer
+}
+
+// NewRunHistoryController new trigger execution history controller
+func NewRunHistoryController(historySvc model.RunHistory, replayerSvc model.Replayer) *RunHistoryController {
+	return &RunHistoryController{historySvc, replayerSvc}
+}
+
+// GetRuns list account run records, latest first
+// query: from and to (RFC3339 time range), event, status and limit
+func (c *RunHistoryController) GetRuns(ctx *gin.Context) {
+	filter := model.RunFilter{
+		Account: getParam(ctx, "account"),
+		Event:   ctx.Query("event"),
+		Status:  ctx.Query("status"),
+	}
+	var err error
+	if filter.From, filter.To, err = getTimeRange(ctx); err == nil {
+		filter.Limit, err = getLimit(ctx, defaultRunsLimit)
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResult{http.StatusBadRequest, "invalid run history query", err.Error()})
+		return
+	}
+	records, err := c.historySvc.GetRuns(getContext(ctx), filter)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorResult{http.StatusInternalServerError, "failed to get run records", err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, records)
+}
+
+// GetRun get account run record
+func (c *RunHistoryController) GetRun(ctx *gin.Context) {
+	record, err := c.historySvc.GetRun(getContext(ctx), getParam(ctx, "id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err == model.ErrRunNotFound {
+			status = http.StatusNotFound
+		}
+		ctx.JSON(status, ErrorResult{status, "failed to get run record", err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, record)
+}
+
+// ReplayRun replay event of account run record to currently matching pipelines
+// (or to originally matched pipelines with 'original=true'); returns run record of replay
+func (c *RunHistoryController) ReplayRun(ctx *gin.Context) {
+	original := false
+	if v := ctx.Query("original"); v != "" {
+		var err error
+		if original, err = strconv.ParseBool(v); err != nil {
+			ctx.JSON(http.StatusBadRequest, ErrorResult{http.StatusBadRequest, "invalid original parameter", err.Error()})
+			return
+		}
+	}
+	record, err := c.replayerSvc.Replay(getContext(ctx), getParam(ctx, "id"), original)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch err {
+		case model.ErrRunNotFound:
+			status = http.StatusNotFound
+		case model.ErrRunNotReplayable:
+			status = http.StatusBadRequest
+		case model.ErrRateLimitExceeded:
+			status = http.StatusTooManyRequests
+		}
+		ctx.JSON(status, ErrorResult{status, "failed to replay run", err.Error()})
+		return
+	}
+	if record.Status == model.RunStatusQueued {
+		ctx.JSON(http.StatusAccepted, record)
+		return
+	}
+	ctx.JSON(http.StatusOK, record)
+}
@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRunHistoryController_GetRuns(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantFilter model.RunFilter
+		wantErr    error
+		wantCode   int
+	}{
+		{
+			name:       "all records",
+			wantFilter: model.RunFilter{Account: "A", Limit: defaultRunsLimit},
+			wantCode:   http.StatusOK,
+		},
+		{
+			name:       "filtered records",
+			query:      "?event=uri:1&status=started&limit=0",
+			wantFilter: model.RunFilter{Account: "A", Event: "uri:1", Status: "started"},
+			wantCode:   http.StatusOK,
+		},
+		{name: "invalid time", query: "?from=today", wantCode: http.StatusBadRequest},
+		{
+			name:       "store error",
+			wantFilter: model.RunFilter{Account: "A", Limit: defaultRunsLimit},
+			wantErr:    errors.New("TEST ERROR"),
+			wantCode:   http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &model.MockRunHistory{}
+			c := NewRunHistoryController(mockSvc, nil)
+			w := httptest.NewRecorder()
+			ginCtx, _ := gin.CreateTestContext(w)
+			ginCtx.Request, _ = http.NewRequest("GET", "/test"+tt.query, nil)
+			ginCtx.Params = gin.Params{gin.Param{Key: "account", Value: "A"}}
+			// prepare mock
+			mockSvc.On("GetRuns", mock.Anything, tt.wantFilter).Return([]model.RunRecord{{ID: "01", Account: "A"}}, tt.wantErr)
+			// invoke
+			c.GetRuns(ginCtx)
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode != http.StatusBadRequest {
+				mockSvc.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+func TestRunHistoryController_GetRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		wantErr  error
+		wantCode int
+	}{
+		{"get record", nil, http.StatusOK},
+		{"record not found", model.ErrRunNotFound, http.StatusNotFound},
+		{"store error", errors.New("TEST ERROR"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &model.MockRunHistory{}
+			c := NewRunHistoryController(mockSvc, nil)
+			w := httptest.NewRecorder()
+			ginCtx, _ := gin.CreateTestContext(w)
+			ginCtx.Request, _ = http.NewRequest("GET", "/test", nil)
+			ginCtx.Params = gin.Params{gin.Param{Key: "account", Value: "A"}, gin.Param{Key: "id", Value: "01"}}
+			// prepare mock
+			var record *model.RunRecord
+			if tt.wantErr == nil {
+				record = &model.RunRecord{ID: "01", Account: "A"}
+			}
+			mockSvc.On("GetRun", mock.Anything, "01").Return(record, tt.wantErr)
+			// invoke
+			c.GetRun(ginCtx)
+			assert.Equal(t, tt.wantCode, w.Code)
+		})
+	}
+}
+
+func TestRunHistoryController_ReplayRun(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		wantOriginal bool
+		wantStatus   string
+		wantErr      error
+		wantCode     int
+	}{
+		{"replay to matching pipelines", "", false, model.RunStatusStarted, nil, http.StatusOK},
+		{"replay to original pipelines", "?original=true", true, model.RunStatusStarted, nil, http.StatusOK},
+		{"replay while dispatch is paused", "", false, model.RunStatusQueued, nil, http.StatusAccepted},
+		{"record not found", "", false, "", model.ErrRunNotFound, http.StatusNotFound},
+		{"record without event", "", false, "", model.ErrRunNotReplayable, http.StatusBadRequest},
+		{"replay error", "", false, "", errors.New("TEST ERROR"), http.StatusInternalServerError},
+		{"invalid original", "?original=maybe", false, "", nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &model.MockReplayer{}
+			c := NewRunHistoryController(nil, mockSvc)
+			w := httptest.NewRecorder()
+			ginCtx, _ := gin.CreateTestContext(w)
+			ginCtx.Request, _ = http.NewRequest("POST", "/test"+tt.query, nil)
+			ginCtx.Params = gin.Params{gin.Param{Key: "account", Value: "A"}, gin.Param{Key: "id", Value: "01"}}
+			// prepare mock
+			var record *model.RunRecord
+			if tt.wantErr == nil {
+				record = &model.RunRecord{ID: "02", Account: "A", Status: tt.wantStatus, Replay: "01"}
+			}
+			mockSvc.On("Replay", mock.Anything, "01", tt.wantOriginal).Return(record, tt.wantErr)
+			// invoke
+			c.ReplayRun(ginCtx)
+			assert.Equal(t, tt.wantCode, w.Code)
+		})
+	}
+}
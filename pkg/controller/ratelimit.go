@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitController pipeline run rate limits controller
+type RateLimitController struct {
+	limiterSvc model.RateLimiter
+}
+
+// NewRateLimitController new pipeline run rate limits controller
+func NewRateLimitController(limiterSvc model.RateLimiter) *RateLimitController {
+	return &RateLimitController{limiterSvc}
+}
+
+// GetLimits list account rate limits
+func (c *RateLimitController) GetLimits(ctx *gin.Context) {
+	limits, err := c.limiterSvc.GetLimits(getContext(ctx), getParam(ctx, "account"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorResult{http.StatusInternalServerError, "failed to get rate limits", err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, limits)
+}
+
+// SetLimit add or replace account, trigger event (with event) or trigger (with event and pipeline) rate limit
+func (c *RateLimitController) SetLimit(ctx *gin.Context) {
+	var limit model.RateLimit
+	if err := ctx.BindJSON(&limit); err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResult{http.StatusBadRequest, "error in JSON body", err.Error()})
+		return
+	}
+	limit.Account = getParam(ctx, "account")
+	if err := c.limiterSvc.SetLimit(getContext(ctx), limit); err != nil {
+		status := http.StatusInternalServerError
+		if err == model.ErrInvalidRateLimit {
+			status = http.StatusBadRequest
+		}
+		ctx.JSON(status, ErrorResult{status, "failed to set rate limit", err.Error()})
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
+
+// DeleteLimit delete account rate limit
+// query: event and pipeline (trigger event and trigger limits)
+func (c *RateLimitController) DeleteLimit(ctx *gin.Context) {
+	limit := model.RateLimit{
+		Account:  getParam(ctx, "account"),
+		Event:    ctx.Query("event"),
+		Pipeline: ctx.Query("pipeline"),
+	}
+	if err := c.limiterSvc.DeleteLimit(getContext(ctx), limit); err != nil {
+		status := http.StatusInternalServerError
+		if err == model.ErrRateLimitNotFound {
+			status = http.StatusNotFound
+		}
+		ctx.JSON(status, ErrorResult{status, "failed to delete rate limit", err.Error()})
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRateLimitController_SetLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantLimit model.RateLimit
+		wantErr   error
+		wantCode  int
+	}{
+		{
+			name:      "trigger limit",
+			body:      `{"event":"uri:1","pipeline":"p1","rate":10,"burst":5,"action":"queue"}`,
+			wantLimit: model.RateLimit{Account: "A", Event: "uri:1", Pipeline: "p1", Rate: 10, Burst: 5, Action: model.RateLimitQueue},
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "account from path",
+			body:      `{"account":"B","rate":60}`,
+			wantLimit: model.RateLimit{Account: "A", Rate: 60},
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "invalid limit",
+			body:      `{"rate":-1}`,
+			wantLimit: model.RateLimit{Account: "A", Rate: -1},
+			wantErr:   model.ErrInvalidRateLimit,
+			wantCode:  http.StatusBadRequest,
+		},
+		{name: "invalid JSON", body: `{`, wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &model.MockRateLimiter{}
+			c := NewRateLimitController(mockSvc)
+			w := httptest.NewRecorder()
+			ginCtx, _ := gin.CreateTestContext(w)
+			ginCtx.Request, _ = http.NewRequest("PUT", "/test", strings.NewReader(tt.body))
+			ginCtx.Params = gin.Params{gin.Param{Key: "account", Value: "A"}}
+			// prepare mock
+			mockSvc.On("SetLimit", mock.Anything, tt.wantLimit).Return(tt.wantErr)
+			// invoke
+			c.SetLimit(ginCtx)
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantLimit.Account != "" {
+				mockSvc.AssertExpectations(t)
+			}
+		})
+	}
+}
+
+func TestRateLimitController_DeleteLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantLimit model.RateLimit
+		wantErr   error
+		wantCode  int
+	}{
+		{name: "account limit", wantLimit: model.RateLimit{Account: "A"}, wantCode: http.StatusOK},
+		{
+			name:      "trigger limit",
+			query:     "?event=uri:1&pipeline=p1",
+			wantLimit: model.RateLimit{Account: "A", Event: "uri:1", Pipeline: "p1"},
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "not found",
+			query:     "?event=uri:2",
+			wantLimit: model.RateLimit{Account: "A", Event: "uri:2"},
+			wantErr:   model.ErrRateLimitNotFound,
+			wantCode:  http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &model.MockRateLimiter{}
+			c := NewRateLimitController(mockSvc)
+			w := httptest.NewRecorder()
+			ginCtx, _ := gin.CreateTestContext(w)
+			ginCtx.Request, _ = http.NewRequest("DELETE", "/test"+tt.query, nil)
+			ginCtx.Params = gin.Params{gin.Param{Key: "account", Value: "A"}}
+			// prepare mock
+			mockSvc.On("DeleteLimit", mock.Anything, tt.wantLimit).Return(tt.wantErr)
+			// invoke
+			c.DeleteLimit(ginCtx)
+			assert.Equal(t, tt.wantCode, w.Code)
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
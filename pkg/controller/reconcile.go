@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/gin-gonic/gin"
+)
+
+// ReconcileController trigger event reconciliation admin controller
+type ReconcileController struct {
+	reconciler model.Reconciler
+}
+
+// NewReconcileController new trigger event reconciliation admin controller
+func NewReconcileController(reconciler model.Reconciler) *ReconcileController {
+	return &ReconcileController{reconciler}
+}
+
+// GetReconcileRuns list recent reconciliation runs, latest first
+func (c *ReconcileController) GetReconcileRuns(ctx *gin.Context) {
+	runs, err := c.reconciler.GetReconcileRuns(getContext(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorResult{http.StatusInternalServerError, "failed to get reconciliation runs", err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, runs)
+}
+
+// Reconcile refresh trigger event info from event providers now
+func (c *ReconcileController) Reconcile(ctx *gin.Context) {
+	run, err := c.reconciler.Reconcile(getContext(ctx))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err == model.ErrReconcileLocked {
+			status = http.StatusConflict
+		}
+		ctx.JSON(status, ErrorResult{status, "failed to reconcile trigger events", err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, run)
+}
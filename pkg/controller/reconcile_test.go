@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestReconcileController_Reconcile(t *testing.T) {
+	tests := []struct {
+		name     string
+		wantErr  error
+		wantCode int
+	}{
+		{"reconcile", nil, http.StatusOK},
+		{"running on another replica", model.ErrReconcileLocked, http.StatusConflict},
+		{"reconcile with error", errors.New("TEST ERROR"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &model.MockReconciler{}
+			c := NewReconcileController(mockSvc)
+			w := httptest.NewRecorder()
+			ginCtx, _ := gin.CreateTestContext(w)
+			ginCtx.Request, _ = http.NewRequest("POST", "/test", nil)
+			// prepare mock
+			var run *model.ReconcileRun
+			if tt.wantErr == nil {
+				run = &model.ReconcileRun{ID: "01", Events: 1}
+			}
+			mockSvc.On("Reconcile", mock.Anything).Return(run, tt.wantErr)
+			// invoke
+			c.Reconcile(ginCtx)
+			assert.Equal(t, tt.wantCode, w.Code)
+		})
+	}
+}
@@ -2,7 +2,10 @@ package controller
 
 import (
 	"context"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/codefresh-io/hermes/pkg/model"
 	"github.com/codefresh-io/hermes/pkg/util"
@@ -15,25 +18,34 @@ import (
 
 // RunnerController trigger controller
 type RunnerController struct {
-	runnerSvc    model.Runner
-	publisherSvc model.EventPublisher
-	eventSvc     model.TriggerEventReaderWriter
-	triggerSvc   model.TriggerReaderWriter
-	checkerSvc   model.SecretChecker
+	runnerSvc     model.Runner
+	publisherSvc  model.EventPublisher
+	eventSvc      model.TriggerEventReaderWriter
+	triggerSvc    model.TriggerReaderWriter
+	checkerSvc    model.SecretChecker
+	dispatcherSvc model.Dispatcher
+	historySvc    model.RunHistory
+	dedupSvc      model.Deduplicator
+	limiterSvc    model.RateLimiter
 }
 
 // NewRunnerController new runner controller
-func NewRunnerController(runnerSvc model.Runner, publisherSvc model.EventPublisher, eventSvc model.TriggerEventReaderWriter, triggerSvc model.TriggerReaderWriter, checkerSvc model.SecretChecker) *RunnerController {
+func NewRunnerController(runnerSvc model.Runner, publisherSvc model.EventPublisher, eventSvc model.TriggerEventReaderWriter, triggerSvc model.TriggerReaderWriter, checkerSvc model.SecretChecker, dispatcherSvc model.Dispatcher, historySvc model.RunHistory, dedupSvc model.Deduplicator, limiterSvc model.RateLimiter) *RunnerController {
 	return &RunnerController{
-		runnerSvc:    runnerSvc,
-		publisherSvc: publisherSvc,
-		eventSvc:     eventSvc,
-		triggerSvc:   triggerSvc,
-		checkerSvc:   checkerSvc}
+		runnerSvc:     runnerSvc,
+		publisherSvc:  publisherSvc,
+		eventSvc:      eventSvc,
+		triggerSvc:    triggerSvc,
+		checkerSvc:    checkerSvc,
+		dispatcherSvc: dispatcherSvc,
+		historySvc:    historySvc,
+		dedupSvc:      dedupSvc,
+		limiterSvc:    limiterSvc}
 }
 
 // RunTrigger pipelines for trigger
 func (c *RunnerController) RunTrigger(ctx *gin.Context) {
+	received := time.Now().UTC()
 	// get trigger event
 	event := getParam(ctx, "event")
 	log.WithField("event", event).Debug("triggering pipelines for event")
@@ -61,20 +73,71 @@ func (c *RunnerController) RunTrigger(ctx *gin.Context) {
 		ctx.JSON(status, ErrorResult{status, "failed to get event", err.Error()})
 		return
 	}
-	if err = c.checkerSvc.Validate(normEvent.Original, normEvent.Secret, triggerEvent.Secret); err != nil {
+	// record execution history of trigger event account
+	record := &model.RunRecord{Account: triggerEvent.Account, Event: triggerEvent.URI, Received: received}
+	defer c.addRun(allCtx, record)
+	// accept current secret and previous secret during rotation grace period
+	for _, secret := range triggerEvent.Secrets(time.Now()) {
+		if err = c.checkerSvc.Validate(normEvent.Original, normEvent.Secret, secret); err == nil {
+			break
+		}
+	}
+	if err != nil {
 		status := http.StatusInternalServerError
 		if err == model.ErrTriggerNotFound {
 			status = http.StatusNotFound
 		}
+		record.Status, record.Error = model.RunStatusRejected, err.Error()
 		ctx.JSON(status, ErrorResult{status, "failed secret validation", err.Error()})
 		return
 	}
+	record.SecretValid = true
+	// keep validated event for replay
+	record.Data = &model.NormalizedEvent{Original: normEvent.Original, Variables: normEvent.Variables}
+	// skip event already delivered: respond with runs of first delivery
+	if record.ID, err = util.GenerateMonotonicULID(); err != nil {
+		log.WithError(err).Error("failed to generate run record ID")
+	}
+	first, err := c.dedupSvc.Deliver(allCtx, triggerEvent.URI, normEvent, record.ID)
+	if err != nil {
+		// on error report to log and continue
+		log.WithError(err).Error("failed to check event delivery")
+	}
+	if first != nil {
+		record.Status, record.Duplicate = model.RunStatusDuplicate, first.Run
+		if original, err := c.historySvc.GetRun(allCtx, first.Run); err == nil {
+			first.Runs = original.Runs
+		}
+		ctx.JSON(http.StatusOK, first)
+		return
+	}
+	// forget delivery when event failed to run (or was rejected by rate limit): retried delivery is not a duplicate
+	defer func() {
+		if ctx.Writer.Status() < http.StatusBadRequest {
+			return
+		}
+		if err := c.dedupSvc.Forget(allCtx, triggerEvent.URI, normEvent, record.ID); err != nil {
+			log.WithError(err).Error("failed to forget event delivery")
+		}
+	}()
 	// report event to eventbus
 	err = c.publisherSvc.Publish(allCtx, triggerEvent.Account, event, normEvent)
 	if err != nil {
 		// on error report to log and continue
 		log.WithError(err).Error("failed to publish event to eventbus")
 	}
+	// queue event while dispatch is paused (maintenance mode)
+	queued, err := c.dispatcherSvc.Queue(allCtx, triggerEvent.Account, event, normEvent)
+	if err != nil {
+		record.Status, record.Error = model.RunStatusFailed, err.Error()
+		ctx.JSON(http.StatusInternalServerError, ErrorResult{http.StatusInternalServerError, "failed to queue event", err.Error()})
+		return
+	}
+	if queued != nil {
+		record.Status = model.RunStatusQueued
+		ctx.JSON(http.StatusAccepted, queued)
+		return
+	}
 	// add original payload to variables
 	vars := make(map[string]string)
 	for k, v := range normEvent.Variables {
@@ -82,19 +145,57 @@ func (c *RunnerController) RunTrigger(ctx *gin.Context) {
 	}
 	vars["EVENT_PAYLOAD"] = normEvent.Original
 	// get connected pipelines
-	pipelines, err := c.triggerSvc.GetTriggerPipelines(allCtx, event, vars)
+	pipelines, skipped, err := c.triggerSvc.MatchTriggerPipelines(allCtx, event, vars)
 	if err != nil {
 		// if there are no pipelines connected to the trigger event don't fail this REST method
 		// to avoid multiple 'errors' reported to the event provider log
 		// it's possible to have trigger event defined and not connected to any pipeline
 		if err == model.ErrPipelineNotFound || err == model.ErrTriggerNotFound {
 			log.WithField("event", event).Warn("there are no pipelines associated with trigger event")
+			record.Status = model.RunStatusSkipped
 			ctx.Status(http.StatusNoContent)
 			return
 		}
+		record.Status, record.Error = model.RunStatusFailed, err.Error()
 		ctx.JSON(http.StatusInternalServerError, ErrorResult{http.StatusInternalServerError, "failed to run trigger pipelines", err.Error()})
 		return
 	}
+	record.Matched = pipelines
+	record.Skipped = skipped
+	if len(pipelines) == 0 {
+		record.Status = model.RunStatusSkipped
+	}
+	// apply account, trigger event and trigger rate limits
+	if len(pipelines) > 0 {
+		limits, err := c.limiterSvc.Take(allCtx, triggerEvent.Account, event, pipelines)
+		if err != nil {
+			// on error report to log and run all pipelines
+			log.WithError(err).Error("failed to check rate limits")
+		} else if len(limits.Limited) > 0 {
+			record.Limited = limits.Limited
+			if txn != nil {
+				if err := txn.AddAttribute("rate-limited", len(limits.Limited)); err != nil {
+					log.WithError(err).Error("failed to add NewRelic attribute")
+				}
+			}
+			if limits.Rejected() {
+				record.Status = model.RunStatusRateLimited
+				ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(limits.RetryAfter.Seconds()))))
+				ctx.JSON(http.StatusTooManyRequests, ErrorResult{http.StatusTooManyRequests, "rate limit exceeded", "pipeline runs rejected by rate limit"})
+				return
+			}
+			// keep queued pipeline runs in store until their delay passes
+			if err := c.limiterSvc.Delay(allCtx, triggerEvent.Account, event, record.ID, limits.Limited, normEvent); err != nil {
+				record.Status, record.Error = model.RunStatusFailed, err.Error()
+				ctx.JSON(http.StatusInternalServerError, ErrorResult{http.StatusInternalServerError, "failed to queue rate limited pipeline runs", err.Error()})
+				return
+			}
+			pipelines = limits.Allowed
+			if len(pipelines) == 0 {
+				record.Status = model.RunStatusRateLimited
+			}
+		}
+	}
 	// record execution history without run IDS
 	log.WithFields(log.Fields{
 		"account":   triggerEvent.Account,
@@ -109,9 +210,14 @@ func (c *RunnerController) RunTrigger(ctx *gin.Context) {
 	// run piplines
 	runs, err := c.runnerSvc.Run(triggerEvent.Account, pipelines, vars, normEvent)
 	if err != nil {
+		record.Status, record.Error = model.RunStatusFailed, err.Error()
 		ctx.JSON(http.StatusInternalServerError, ErrorResult{http.StatusInternalServerError, "failed to run trigger pipelines", err.Error()})
 		return
 	}
+	if len(pipelines) > 0 {
+		record.Status = model.RunStatusStarted
+	}
+	record.Runs = model.PipelineRunRecords(pipelines, runs)
 	// record execution history with run IDS
 	log.WithFields(log.Fields{
 		"account":   triggerEvent.Account,
@@ -122,3 +228,13 @@ func (c *RunnerController) RunTrigger(ctx *gin.Context) {
 	// return ok status with run IDS
 	ctx.JSON(http.StatusOK, runs)
 }
+
+// add run record to execution history; failure is logged and does not fail /run call
+func (c *RunnerController) addRun(ctx context.Context, record *model.RunRecord) {
+	if err := c.historySvc.AddRun(ctx, record); err != nil {
+		log.WithFields(log.Fields{
+			"account": record.Account,
+			"event":   record.Event,
+		}).WithError(err).Error("failed to record run history")
+	}
+}
@@ -0,0 +1,321 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type nopPublisher struct{}
+
+func (nopPublisher) Publish(ctx context.Context, account string, eventURI string, event model.NormalizedEvent) error {
+	return nil
+}
+
+func TestRunnerController_RunTriggerRotatedSecret(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name     string
+		secret   string
+		expires  time.Time
+		wantCode int
+	}{
+		{"current secret", "new", future, http.StatusNoContent},
+		{"previous secret during grace period", "old", future, http.StatusNoContent},
+		{"expired previous secret", "old", past, http.StatusInternalServerError},
+		{"unknown secret", "other", future, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eventSvc := &model.MockTriggerEventReaderWriter{}
+			triggerSvc := &model.MockTriggerReaderWriter{}
+			checker := &model.MockSecretChecker{}
+			dispatcher := &model.MockDispatcher{}
+			dispatcher.On("Queue", mock.Anything, mock.Anything, "uri:1", mock.Anything).Return(nil, nil)
+			history := &model.MockRunHistory{}
+			history.On("AddRun", mock.Anything, mock.Anything).Return(nil)
+			dedup := &model.MockDeduplicator{}
+			dedup.On("Deliver", mock.Anything, "uri:1", mock.Anything, mock.Anything).Return(nil, nil)
+			c := NewRunnerController(nil, nopPublisher{}, eventSvc, triggerSvc, checker, dispatcher, history, dedup, &model.MockRateLimiter{})
+			expires := tt.expires
+			event := &model.Event{URI: "uri:1", Secret: "new", PreviousSecret: "old", PreviousSecretExpires: &expires}
+			eventSvc.On("GetEvent", mock.Anything, "uri:1").Return(event, nil)
+			checker.On("Validate", "payload", mock.Anything, mock.Anything).Return(func(message, secret, key string) error {
+				if secret != key {
+					return errors.New("invalid secret")
+				}
+				return nil
+			})
+			triggerSvc.On("MatchTriggerPipelines", mock.Anything, "uri:1", mock.Anything).Return(nil, nil, model.ErrPipelineNotFound).Maybe()
+			w := httptest.NewRecorder()
+			ginCtx, _ := gin.CreateTestContext(w)
+			ginCtx.Params = gin.Params{gin.Param{Key: "event", Value: "uri:1"}}
+			body := `{"secret":"` + tt.secret + `","original":"payload"}`
+			ginCtx.Request, _ = http.NewRequest("POST", "/run/uri:1", strings.NewReader(body))
+			// invoke
+			c.RunTrigger(ginCtx)
+			t.Log(w.Body.String())
+			assert.Equal(t, tt.wantCode, ginCtx.Writer.Status())
+		})
+	}
+}
+
+func TestRunnerController_RunTriggerDispatchPaused(t *testing.T) {
+	eventSvc := &model.MockTriggerEventReaderWriter{}
+	triggerSvc := &model.MockTriggerReaderWriter{}
+	checker := &model.MockSecretChecker{}
+	dispatcher := &model.MockDispatcher{}
+	history := &model.MockRunHistory{}
+	dedup := &model.MockDeduplicator{}
+	dedup.On("Deliver", mock.Anything, "uri:1", mock.Anything, mock.Anything).Return(nil, nil)
+	c := NewRunnerController(nil, nopPublisher{}, eventSvc, triggerSvc, checker, dispatcher, history, dedup, &model.MockRateLimiter{})
+	eventSvc.On("GetEvent", mock.Anything, "uri:1").Return(&model.Event{URI: "uri:1", Account: "A", Secret: "s"}, nil)
+	checker.On("Validate", "payload", "s", "s").Return(nil)
+	queued := &model.QueuedEvent{ID: "01", Account: "A", Event: "uri:1"}
+	dispatcher.On("Queue", mock.Anything, "A", "uri:1", mock.Anything).Return(queued, nil)
+	history.On("AddRun", mock.Anything, mock.MatchedBy(func(r *model.RunRecord) bool {
+		return r.Account == "A" && r.SecretValid && r.Status == model.RunStatusQueued
+	})).Return(nil)
+	w := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(w)
+	ginCtx.Params = gin.Params{gin.Param{Key: "event", Value: "uri:1"}}
+	ginCtx.Request, _ = http.NewRequest("POST", "/run/uri:1", strings.NewReader(`{"secret":"s","original":"payload"}`))
+	// invoke
+	c.RunTrigger(ginCtx)
+	// event is queued: pipelines are not run
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	triggerSvc.AssertNotCalled(t, "MatchTriggerPipelines", mock.Anything, mock.Anything, mock.Anything)
+	history.AssertExpectations(t)
+}
+
+func TestRunnerController_RunTriggerHistory(t *testing.T) {
+	eventSvc := &model.MockTriggerEventReaderWriter{}
+	triggerSvc := &model.MockTriggerReaderWriter{}
+	checker := &model.MockSecretChecker{}
+	dispatcher := &model.MockDispatcher{}
+	runner := &model.MockRunner{}
+	history := &model.MockRunHistory{}
+	dedup := &model.MockDeduplicator{}
+	dedup.On("Deliver", mock.Anything, "uri:1", mock.Anything, mock.Anything).Return(nil, nil)
+	limiter := &model.MockRateLimiter{}
+	limiter.On("Take", mock.Anything, "A", "uri:1", []string{"p1", "p2"}).Return(&model.RateLimitResult{Allowed: []string{"p1", "p2"}}, nil)
+	c := NewRunnerController(runner, nopPublisher{}, eventSvc, triggerSvc, checker, dispatcher, history, dedup, limiter)
+	eventSvc.On("GetEvent", mock.Anything, "uri:1").Return(&model.Event{URI: "uri:1", Account: "A", Secret: "s"}, nil)
+	checker.On("Validate", "payload", "s", "s").Return(nil)
+	dispatcher.On("Queue", mock.Anything, "A", "uri:1", mock.Anything).Return(nil, nil)
+	triggerSvc.On("MatchTriggerPipelines", mock.Anything, "uri:1", mock.Anything).Return([]string{"p1", "p2"}, []model.SkippedPipeline{
+		{Pipeline: "p3", Reason: model.SkipReasonPaused},
+		{Pipeline: "p4", Reason: model.SkipReasonFilter},
+	}, nil)
+	runner.On("Run", "A", []string{"p1", "p2"}, mock.Anything, mock.Anything).Return([]model.PipelineRun{
+		{ID: "run1"},
+		{Error: errors.New("TEST ERROR")},
+	}, nil)
+	var record *model.RunRecord
+	history.On("AddRun", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		record = args.Get(1).(*model.RunRecord)
+	}).Return(nil)
+	w := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(w)
+	ginCtx.Params = gin.Params{gin.Param{Key: "event", Value: "uri:1"}}
+	ginCtx.Request, _ = http.NewRequest("POST", "/run/uri:1", strings.NewReader(`{"secret":"s","original":"payload"}`))
+	// invoke
+	c.RunTrigger(ginCtx)
+	assert.Equal(t, http.StatusOK, w.Code)
+	if assert.NotNil(t, record) {
+		assert.Equal(t, "A", record.Account)
+		assert.Equal(t, "uri:1", record.Event)
+		assert.Equal(t, model.RunStatusStarted, record.Status)
+		assert.True(t, record.SecretValid)
+		assert.False(t, record.Received.IsZero())
+		assert.Equal(t, []string{"p1", "p2"}, record.Matched)
+		assert.Equal(t, []model.SkippedPipeline{
+			{Pipeline: "p3", Reason: model.SkipReasonPaused},
+			{Pipeline: "p4", Reason: model.SkipReasonFilter},
+		}, record.Skipped)
+		assert.Equal(t, []model.PipelineRunRecord{
+			{Pipeline: "p1", ID: "run1"},
+			{Pipeline: "p2", Error: "TEST ERROR"},
+		}, record.Runs)
+	}
+}
+
+func TestRunnerController_RunTriggerDuplicate(t *testing.T) {
+	eventSvc := &model.MockTriggerEventReaderWriter{}
+	triggerSvc := &model.MockTriggerReaderWriter{}
+	checker := &model.MockSecretChecker{}
+	dispatcher := &model.MockDispatcher{}
+	runner := &model.MockRunner{}
+	history := &model.MockRunHistory{}
+	dedup := &model.MockDeduplicator{}
+	c := NewRunnerController(runner, nopPublisher{}, eventSvc, triggerSvc, checker, dispatcher, history, dedup, &model.MockRateLimiter{})
+	eventSvc.On("GetEvent", mock.Anything, "uri:1").Return(&model.Event{URI: "uri:1", Account: "A", Secret: "s"}, nil)
+	checker.On("Validate", "payload", "s", "s").Return(nil)
+	first := &model.Delivery{ID: "d1", Event: "uri:1", Run: "01"}
+	dedup.On("Deliver", mock.Anything, "uri:1", model.NormalizedEvent{Secret: "s", Original: "payload"}, mock.Anything).Return(first, nil)
+	history.On("GetRun", mock.Anything, "01").Return(&model.RunRecord{ID: "01", Runs: []model.PipelineRunRecord{{Pipeline: "p1", ID: "run1"}}}, nil)
+	history.On("AddRun", mock.Anything, mock.MatchedBy(func(r *model.RunRecord) bool {
+		return r.Status == model.RunStatusDuplicate && r.Duplicate == "01"
+	})).Return(nil)
+	w := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(w)
+	ginCtx.Params = gin.Params{gin.Param{Key: "event", Value: "uri:1"}}
+	ginCtx.Request, _ = http.NewRequest("POST", "/run/uri:1", strings.NewReader(`{"secret":"s","original":"payload"}`))
+	// invoke
+	c.RunTrigger(ginCtx)
+	// duplicate points at runs of first delivery: pipelines are not run
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"run1"`)
+	dispatcher.AssertNotCalled(t, "Queue", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	runner.AssertNotCalled(t, "Run", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	history.AssertExpectations(t)
+}
+
+// memDeduplicator remembers deliveries by original payload
+type memDeduplicator map[string]string
+
+func (d memDeduplicator) Deliver(ctx context.Context, event string, data model.NormalizedEvent, run string) (*model.Delivery, error) {
+	if first, ok := d[data.Original]; ok {
+		return &model.Delivery{ID: data.Original, Event: event, Run: first}, nil
+	}
+	d[data.Original] = run
+	return nil, nil
+}
+
+func (d memDeduplicator) Forget(ctx context.Context, event string, data model.NormalizedEvent, run string) error {
+	if d[data.Original] == run {
+		delete(d, data.Original)
+	}
+	return nil
+}
+
+func TestRunnerController_RunTriggerRetryFailed(t *testing.T) {
+	eventSvc := &model.MockTriggerEventReaderWriter{}
+	triggerSvc := &model.MockTriggerReaderWriter{}
+	checker := &model.MockSecretChecker{}
+	dispatcher := &model.MockDispatcher{}
+	runner := &model.MockRunner{}
+	history := &model.MockRunHistory{}
+	limiter := &model.MockRateLimiter{}
+	limiter.On("Take", mock.Anything, "A", "uri:1", []string{"p1"}).Return(&model.RateLimitResult{Allowed: []string{"p1"}}, nil)
+	c := NewRunnerController(runner, nopPublisher{}, eventSvc, triggerSvc, checker, dispatcher, history, memDeduplicator{}, limiter)
+	eventSvc.On("GetEvent", mock.Anything, "uri:1").Return(&model.Event{URI: "uri:1", Account: "A", Secret: "s"}, nil)
+	checker.On("Validate", "payload", "s", "s").Return(nil)
+	dispatcher.On("Queue", mock.Anything, "A", "uri:1", mock.Anything).Return(nil, nil)
+	triggerSvc.On("MatchTriggerPipelines", mock.Anything, "uri:1", mock.Anything).Return([]string{"p1"}, nil, nil)
+	history.On("AddRun", mock.Anything, mock.Anything).Return(nil)
+	history.On("GetRun", mock.Anything, mock.Anything).Return(&model.RunRecord{}, nil)
+	runner.On("Run", "A", []string{"p1"}, mock.Anything, mock.Anything).Return(nil, errors.New("TEST ERROR")).Once()
+	runner.On("Run", "A", []string{"p1"}, mock.Anything, mock.Anything).Return([]model.PipelineRun{{ID: "run1"}}, nil).Once()
+	// first delivery fails to run, retried delivery runs, next delivery is duplicate
+	for _, wantCode := range []int{http.StatusInternalServerError, http.StatusOK, http.StatusOK} {
+		w := httptest.NewRecorder()
+		ginCtx, _ := gin.CreateTestContext(w)
+		ginCtx.Params = gin.Params{gin.Param{Key: "event", Value: "uri:1"}}
+		ginCtx.Request, _ = http.NewRequest("POST", "/run/uri:1", strings.NewReader(`{"secret":"s","original":"payload"}`))
+		c.RunTrigger(ginCtx)
+		assert.Equal(t, wantCode, w.Code)
+	}
+	runner.AssertNumberOfCalls(t, "Run", 2)
+	history.AssertNumberOfCalls(t, "GetRun", 1)
+}
+
+func TestRunnerController_RunTriggerRateLimited(t *testing.T) {
+	tests := []struct {
+		name       string
+		limited    []model.LimitedPipeline
+		allowed    []string
+		wantCode   int
+		wantStatus string
+		wantRun    []string
+	}{
+		{
+			name:       "drop",
+			limited:    []model.LimitedPipeline{{Pipeline: "p2", Scope: model.RateLimitScopeTrigger, Action: model.RateLimitDrop}},
+			allowed:    []string{"p1"},
+			wantCode:   http.StatusOK,
+			wantStatus: model.RunStatusStarted,
+			wantRun:    []string{"p1"},
+		},
+		{
+			name: "drop all",
+			limited: []model.LimitedPipeline{
+				{Pipeline: "p1", Scope: model.RateLimitScopeAccount, Action: model.RateLimitDrop},
+				{Pipeline: "p2", Scope: model.RateLimitScopeAccount, Action: model.RateLimitDrop},
+			},
+			wantCode:   http.StatusOK,
+			wantStatus: model.RunStatusRateLimited,
+		},
+		{
+			name:       "queue",
+			limited:    []model.LimitedPipeline{{Pipeline: "p2", Scope: model.RateLimitScopeEvent, Action: model.RateLimitQueue, Delay: time.Minute}},
+			allowed:    []string{"p1"},
+			wantCode:   http.StatusOK,
+			wantStatus: model.RunStatusStarted,
+			wantRun:    []string{"p1"},
+		},
+		{
+			name:       "reject",
+			limited:    []model.LimitedPipeline{{Pipeline: "p2", Scope: model.RateLimitScopeEvent, Action: model.RateLimitReject}},
+			allowed:    []string{"p1"},
+			wantCode:   http.StatusTooManyRequests,
+			wantStatus: model.RunStatusRateLimited,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eventSvc := &model.MockTriggerEventReaderWriter{}
+			triggerSvc := &model.MockTriggerReaderWriter{}
+			checker := &model.MockSecretChecker{}
+			dispatcher := &model.MockDispatcher{}
+			runner := &model.MockRunner{}
+			history := &model.MockRunHistory{}
+			dedup := &model.MockDeduplicator{}
+			dedup.On("Deliver", mock.Anything, "uri:1", mock.Anything, mock.Anything).Return(nil, nil)
+			dedup.On("Forget", mock.Anything, "uri:1", mock.Anything, mock.Anything).Return(nil).Maybe()
+			limiter := &model.MockRateLimiter{}
+			limiter.On("Take", mock.Anything, "A", "uri:1", []string{"p1", "p2"}).Return(&model.RateLimitResult{
+				Allowed:    tt.allowed,
+				Limited:    tt.limited,
+				RetryAfter: 1500 * time.Millisecond,
+			}, nil)
+			limiter.On("Delay", mock.Anything, "A", "uri:1", mock.Anything, tt.limited, mock.Anything).Return(nil).Maybe()
+			c := NewRunnerController(runner, nopPublisher{}, eventSvc, triggerSvc, checker, dispatcher, history, dedup, limiter)
+			eventSvc.On("GetEvent", mock.Anything, "uri:1").Return(&model.Event{URI: "uri:1", Account: "A", Secret: "s"}, nil)
+			checker.On("Validate", "payload", "s", "s").Return(nil)
+			dispatcher.On("Queue", mock.Anything, "A", "uri:1", mock.Anything).Return(nil, nil)
+			triggerSvc.On("MatchTriggerPipelines", mock.Anything, "uri:1", mock.Anything).Return([]string{"p1", "p2"}, nil, nil)
+			runner.On("Run", "A", tt.wantRun, mock.Anything, mock.Anything).Return([]model.PipelineRun{}, nil).Maybe()
+			history.On("AddRun", mock.Anything, mock.MatchedBy(func(r *model.RunRecord) bool {
+				return r.Status == tt.wantStatus && assert.ObjectsAreEqual(tt.limited, r.Limited)
+			})).Return(nil)
+			w := httptest.NewRecorder()
+			ginCtx, _ := gin.CreateTestContext(w)
+			ginCtx.Params = gin.Params{gin.Param{Key: "event", Value: "uri:1"}}
+			ginCtx.Request, _ = http.NewRequest("POST", "/run/uri:1", strings.NewReader(`{"secret":"s","original":"payload"}`))
+			// invoke
+			c.RunTrigger(ginCtx)
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode == http.StatusTooManyRequests {
+				assert.Equal(t, "2", w.Header().Get("Retry-After"))
+				runner.AssertNotCalled(t, "Run", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				// rejected delivery can be retried
+				dedup.AssertCalled(t, "Forget", mock.Anything, "uri:1", mock.Anything, mock.Anything)
+			} else {
+				// queued runs are kept by limiter, with run record ID
+				limiter.AssertCalled(t, "Delay", mock.Anything, "A", "uri:1", mock.AnythingOfType("string"), tt.limited, mock.Anything)
+			}
+			history.AssertExpectations(t)
+		})
+	}
+}
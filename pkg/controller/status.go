@@ -9,15 +9,25 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// HealthResult health status with storage backend connection pool and audit log statistics
+type HealthResult struct {
+	Status string            `json:"status"`
+	Pool   *model.PoolStats  `json:"pool,omitempty"`
+	Audit  *model.AuditStats `json:"audit,omitempty"`
+	// RateLimit pipeline runs limited by rate limits since start
+	RateLimit *model.RateLimitStats `json:"rate-limit,omitempty"`
+}
+
 // StatusController status controller
 type StatusController struct {
 	backend   model.Pinger
 	codefresh codefresh.PipelineService
+	limiter   model.RateLimiter
 }
 
 // NewStatusController init status controller
-func NewStatusController(backend model.Pinger, codefresh codefresh.PipelineService) *StatusController {
-	return &StatusController{backend, codefresh}
+func NewStatusController(backend model.Pinger, codefresh codefresh.PipelineService, limiter model.RateLimiter) *StatusController {
+	return &StatusController{backend, codefresh, limiter}
 }
 
 // GetHealth status
@@ -34,7 +44,26 @@ func (c *StatusController) GetHealth(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, ErrorResult{http.StatusInternalServerError, "failed to talk to Codefresh API", err.Error()})
 		return
 	}
-	// everything is good
+	// everything is good; report store connection pool and audit log statistics, if JSON is asked
+	if ctx.NegotiateFormat(gin.MIMEPlain, gin.MIMEJSON) == gin.MIMEJSON {
+		result := HealthResult{Status: "Healthy"}
+		if stats, ok := c.backend.(model.PoolStatser); ok {
+			pool := stats.PoolStats()
+			result.Pool = &pool
+		}
+		if stats, ok := c.backend.(model.AuditStatser); ok {
+			audit := stats.AuditStats()
+			result.Audit = &audit
+		}
+		if stats, ok := c.limiter.(model.RateLimitStatser); ok {
+			limited := stats.RateLimitStats()
+			result.RateLimit = &limited
+		}
+		if result.Pool != nil || result.Audit != nil || result.RateLimit != nil {
+			ctx.JSON(http.StatusOK, result)
+			return
+		}
+	}
 	ctx.String(http.StatusOK, "Healthy")
 }
 
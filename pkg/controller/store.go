@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/gin-gonic/gin"
+)
+
+// StoreController storage backend admin controller
+type StoreController struct {
+	checker model.StoreChecker
+}
+
+// NewStoreController new storage backend admin controller
+func NewStoreController(checker model.StoreChecker) *StoreController {
+	return &StoreController{checker}
+}
+
+// CheckStore check storage backend consistency and report found issues
+func (c *StoreController) CheckStore(ctx *gin.Context) {
+	c.checkStore(ctx, false)
+}
+
+// RepairStore check storage backend consistency and repair found issues
+func (c *StoreController) RepairStore(ctx *gin.Context) {
+	c.checkStore(ctx, true)
+}
+
+func (c *StoreController) checkStore(ctx *gin.Context, repair bool) {
+	report, err := c.checker.CheckStore(getContext(ctx), repair)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, ErrorResult{http.StatusInternalServerError, "failed to check store", err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, report)
+}
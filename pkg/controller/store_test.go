@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestStoreController_CheckStore(t *testing.T) {
+	tests := []struct {
+		name     string
+		repair   bool
+		report   *model.StoreReport
+		wantErr  error
+		wantCode int
+	}{
+		{
+			name:     "check store",
+			report:   &model.StoreReport{Events: 1, Issues: []model.StoreIssue{{Type: model.StoreIssueOrphanFilter, Key: "filter:1:a:p"}}},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "repair store",
+			repair:   true,
+			report:   &model.StoreReport{Events: 1, Issues: []model.StoreIssue{{Type: model.StoreIssueOrphanFilter, Key: "filter:1:a:p", Repaired: true}}},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "check store with error",
+			wantErr:  errors.New("TEST ERROR"),
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &model.MockStoreChecker{}
+			c := NewStoreController(mockSvc)
+			w := httptest.NewRecorder()
+			ginCtx, _ := gin.CreateTestContext(w)
+			ginCtx.Request, _ = http.NewRequest("GET", "/test", nil)
+			// prepare mock
+			mockSvc.On("CheckStore", mock.Anything, tt.repair).Return(tt.report, tt.wantErr)
+			// invoke
+			if tt.repair {
+				c.RepairStore(ginCtx)
+			} else {
+				c.CheckStore(ginCtx)
+			}
+			// assert code and report
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantErr == nil {
+				report := new(model.StoreReport)
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), report))
+				assert.Equal(t, tt.report, report)
+			}
+			// assert exectations
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
@@ -9,6 +9,9 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// trigger fields, that can be selected with fields query parameter
+var triggerFields = []string{"event", "pipeline", "filters", "paused", "event-data"}
+
 // TriggerController trigger controller
 type TriggerController struct {
 	trigger model.TriggerReaderWriter
@@ -23,29 +26,54 @@ func NewTriggerController(trigger model.TriggerReaderWriter) *TriggerController
 func (c *TriggerController) GetEventTriggers(ctx *gin.Context) {
 	// get event
 	event := getParam(ctx, "event")
-	// list trigger events, optionally filtered by type/kind and event uri filter
-	if triggers, err := c.trigger.GetEventTriggers(getContext(ctx), event); err != nil {
-		status := http.StatusInternalServerError
-		if err == model.ErrTriggerNotFound {
-			status = http.StatusNotFound
-		}
-		ctx.JSON(status, ErrorResult{status, "failed to list triggers for event", err.Error()})
-	} else {
-		ctx.JSON(http.StatusOK, triggers)
-	}
+	c.listEventTriggers(ctx, event)
 }
 
 // GetTriggers list triggers for trigger event
 func (c *TriggerController) GetTriggers(ctx *gin.Context) {
 	// list trigger events for all events
-	if triggers, err := c.trigger.GetEventTriggers(getContext(ctx), "*"); err != nil {
+	c.listEventTriggers(ctx, "*")
+}
+
+// list triggers for trigger events matching event, paged when paging parameters are set
+func (c *TriggerController) listEventTriggers(ctx *gin.Context, event string) {
+	page, paged, err := getPageOptions(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResult{http.StatusBadRequest, "invalid paging parameters", err.Error()})
+		return
+	}
+	fields := ctx.Query("fields")
+
+	var triggers []model.Trigger
+	var nextCursor string
+	if paged {
+		var triggerPage *model.TriggerPage
+		if triggerPage, err = c.trigger.GetEventTriggersPage(getContext(ctx), event, page); err == nil {
+			triggers, nextCursor = triggerPage.Triggers, triggerPage.NextCursor
+		}
+	} else {
+		triggers, err = c.trigger.GetEventTriggers(getContext(ctx), event)
+	}
+	if err != nil {
 		status := http.StatusInternalServerError
 		if err == model.ErrTriggerNotFound {
 			status = http.StatusNotFound
+		} else if err == model.ErrInvalidCursor || err == model.ErrInvalidSort {
+			status = http.StatusBadRequest
 		}
 		ctx.JSON(status, ErrorResult{status, "failed to list triggers for event", err.Error()})
+		return
+	}
+	// select requested fields, without secrets
+	result, err := selectFields(model.RedactTriggers(triggers), fields, triggerFields...)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, ErrorResult{http.StatusBadRequest, "invalid fields", err.Error()})
+		return
+	}
+	if paged {
+		ctx.JSON(http.StatusOK, pageResult("triggers", result, nextCursor))
 	} else {
-		ctx.JSON(http.StatusOK, triggers)
+		ctx.JSON(http.StatusOK, result)
 	}
 }
 
@@ -63,7 +91,7 @@ func (c *TriggerController) GetPipelineTriggers(ctx *gin.Context) {
 		}
 		ctx.JSON(status, ErrorResult{status, "failed to list triggers for pipeline", err.Error()})
 	} else {
-		ctx.JSON(http.StatusOK, triggers)
+		ctx.JSON(http.StatusOK, model.RedactTriggers(triggers))
 	}
 }
 
@@ -87,8 +115,10 @@ func (c *TriggerController) CreateTrigger(ctx *gin.Context) {
 	// perform action
 	if err := c.trigger.CreateTrigger(getContext(ctx), event, pipeline, request.Filters); err != nil {
 		status := http.StatusInternalServerError
-		if err == model.ErrTriggerNotFound {
+		if err == model.ErrTriggerNotFound || err == model.ErrEventNotFound {
 			status = http.StatusNotFound
+		} else if err == model.ErrStoreConflict {
+			status = http.StatusConflict
 		}
 		ctx.JSON(status, ErrorResult{status, "failed to create trigger: event <-> pipeline", err.Error()})
 	} else {
@@ -106,6 +136,8 @@ func (c *TriggerController) DeleteTrigger(ctx *gin.Context) {
 		status := http.StatusInternalServerError
 		if err == model.ErrTriggerNotFound {
 			status = http.StatusNotFound
+		} else if err == model.ErrStoreConflict {
+			status = http.StatusConflict
 		}
 		ctx.JSON(status, ErrorResult{status, "failed to delete trigger: event <-X-> pipeline", err.Error()})
 	} else {
@@ -113,6 +145,39 @@ func (c *TriggerController) DeleteTrigger(ctx *gin.Context) {
 	}
 }
 
+// PauseTrigger pause trigger: keep trigger and its filters, but do not run pipeline
+func (c *TriggerController) PauseTrigger(ctx *gin.Context) {
+	c.setTriggerPaused(ctx, true)
+}
+
+// ResumeTrigger resume paused trigger
+func (c *TriggerController) ResumeTrigger(ctx *gin.Context) {
+	c.setTriggerPaused(ctx, false)
+}
+
+func (c *TriggerController) setTriggerPaused(ctx *gin.Context, paused bool) {
+	// get trigger event (event-uri)
+	event := getParam(ctx, "event")
+	// get pipeline
+	pipeline := ctx.Param("pipeline")
+	log.WithFields(log.Fields{"event": event, "pipeline": pipeline, "paused": paused}).Info("Set trigger paused state")
+	if err := c.trigger.SetTriggerPaused(getContext(ctx), event, pipeline, paused); err != nil {
+		status := http.StatusInternalServerError
+		if err == model.ErrTriggerNotFound {
+			status = http.StatusNotFound
+		} else if err == model.ErrStoreConflict {
+			status = http.StatusConflict
+		}
+		action := "resume"
+		if paused {
+			action = "pause"
+		}
+		ctx.JSON(status, ErrorResult{status, "failed to " + action + " trigger: event <-> pipeline", err.Error()})
+	} else {
+		ctx.Status(http.StatusOK)
+	}
+}
+
 func (c *TriggerController) DeleteTriggersForPipeline(ctx *gin.Context) {
 	// get pipeline
 	pipeline := ctx.Param("pipeline")
@@ -120,9 +185,11 @@ func (c *TriggerController) DeleteTriggersForPipeline(ctx *gin.Context) {
 		status := http.StatusInternalServerError
 		if err == model.ErrTriggerNotFound {
 			status = http.StatusNotFound
+		} else if err == model.ErrStoreConflict {
+			status = http.StatusConflict
 		}
 		ctx.JSON(status, ErrorResult{status, "failed to delete trigger: event <-X-> pipeline", err.Error()})
 	} else {
 		ctx.Status(http.StatusOK)
 	}
-}
\ No newline at end of file
+}
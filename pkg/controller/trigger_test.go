@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codefresh-io/hermes/pkg/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestTriggerController_SetTriggerPaused(t *testing.T) {
+	tests := []struct {
+		name     string
+		paused   bool
+		wantErr  error
+		wantCode int
+	}{
+		{
+			name:     "pause trigger",
+			paused:   true,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "resume trigger",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "pause missing trigger",
+			paused:   true,
+			wantErr:  model.ErrTriggerNotFound,
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "resume trigger concurrently deleted",
+			wantErr:  model.ErrStoreConflict,
+			wantCode: http.StatusConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &model.MockTriggerReaderWriter{}
+			c := NewTriggerController(mockSvc)
+			w := httptest.NewRecorder()
+			ginCtx, _ := gin.CreateTestContext(w)
+			ginCtx.Params = []gin.Param{{Key: "event", Value: "uri:1"}, {Key: "pipeline", Value: "p1"}}
+			ginCtx.Request, _ = http.NewRequest("POST", "/test", nil)
+			// prepare mock
+			mockSvc.On("SetTriggerPaused", mock.Anything, "uri:1", "p1", tt.paused).Return(tt.wantErr)
+			// invoke
+			if tt.paused {
+				c.PauseTrigger(ginCtx)
+			} else {
+				c.ResumeTrigger(ginCtx)
+			}
+			t.Log(w.Body.String())
+			assert.Equal(t, tt.wantCode, ginCtx.Writer.Status())
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
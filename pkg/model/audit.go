@@ -0,0 +1,88 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// audit actions
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+	// AuditActionReadSecret trigger event secret read through API
+	AuditActionReadSecret = "read-secret"
+)
+
+// audited resources
+const (
+	AuditResourceEvent   = "event"
+	AuditResourceTrigger = "trigger"
+)
+
+type (
+	// AuditActor authenticated entity that made the change
+	AuditActor struct {
+		Name string `json:"name,omitempty" yaml:"name,omitempty"`
+		ID   string `json:"id,omitempty" yaml:"id,omitempty"`
+		Type string `json:"type,omitempty" yaml:"type,omitempty"`
+	}
+
+	// AuditEntry single trigger event or trigger change
+	AuditEntry struct {
+		// ID entry ULID: entries are ordered by ID
+		ID string `json:"id" yaml:"id"`
+		// Time change time
+		Time time.Time `json:"time" yaml:"time"`
+		// Account account that made the change
+		Account string `json:"account" yaml:"account"`
+		// Actor authenticated entity; empty for CLI and background jobs
+		Actor *AuditActor `json:"actor,omitempty" yaml:"actor,omitempty"`
+		// RequestID request correlation ID
+		RequestID string `json:"request-id,omitempty" yaml:"request-id,omitempty"`
+		// Action create, update, delete or read-secret (see AuditAction* constants)
+		Action string `json:"action" yaml:"action"`
+		// Resource event or trigger (see AuditResource* constants)
+		Resource string `json:"resource" yaml:"resource"`
+		// Event trigger event URI
+		Event string `json:"event" yaml:"event"`
+		// Pipeline trigger pipeline (trigger resource only)
+		Pipeline string `json:"pipeline,omitempty" yaml:"pipeline,omitempty"`
+		// Before resource state before the change (secrets are redacted)
+		Before json.RawMessage `json:"before,omitempty" yaml:"-"`
+		// After resource state after the change (secrets are redacted)
+		After json.RawMessage `json:"after,omitempty" yaml:"-"`
+	}
+
+	// AuditFilter audit log query; empty fields match all entries
+	AuditFilter struct {
+		// Account audit log account
+		Account string
+		// From entries at or after time
+		From time.Time
+		// To entries before time
+		To time.Time
+		// Resource event or trigger
+		Resource string
+		// Event trigger event URI
+		Event string
+		// Pipeline trigger pipeline
+		Pipeline string
+		// Limit maximum number of returned entries (0: no limit)
+		Limit int
+	}
+
+	// AuditReader queries audit log of trigger event and trigger changes
+	AuditReader interface {
+		// GetAudit list account audit entries matching filter, latest first
+		GetAudit(ctx context.Context, filter AuditFilter) ([]AuditEntry, error)
+	}
+)
+
+// Match check if audit entry matches filter resource, event and pipeline
+func (f AuditFilter) Match(entry *AuditEntry) bool {
+	return (f.Resource == "" || f.Resource == entry.Resource) &&
+		(f.Event == "" || f.Event == entry.Event) &&
+		(f.Pipeline == "" || f.Pipeline == entry.Pipeline)
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+)
+
+// AuthEntity Codefresh authenticated entity: user {_id, name} or service {serviceName, type}
+type AuthEntity struct {
+	// user fields
+	Name string `json:"name,omitempty"`
+	ID   string `json:"_id,omitempty"`
+	// service fields
+	ServiceName string `json:"serviceName,omitempty"`
+	Type        string `json:"type,omitempty"`
+}
+
+// GetAuthEntity decode authenticated entity (base64 encoded JSON) from context; nil when context has no auth entity
+// missing type is set to "user", missing ID to "none" and missing name to service name
+func GetAuthEntity(ctx context.Context) (*AuthEntity, error) {
+	authEntity, ok := ctx.Value(ContextAuthEntity).(string)
+	if !ok {
+		return nil, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(authEntity)
+	if err != nil {
+		return nil, err
+	}
+	auth := new(AuthEntity)
+	if err = json.Unmarshal(data, auth); err != nil {
+		return nil, err
+	}
+	// set type to user if empty
+	if auth.Type == "" {
+		auth.Type = "user"
+	}
+	// set id to none if empty
+	if auth.ID == "" {
+		auth.ID = "none"
+	}
+	// set name to serviceName
+	if auth.Name == "" {
+		auth.Name = auth.ServiceName
+	}
+	return auth, nil
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+type (
+	// Delivery first delivery of event: duplicates point at its run record
+	Delivery struct {
+		// ID delivery ID: event type delivery ID variable or hash of original payload
+		ID string `json:"id" yaml:"id"`
+		// Event trigger event URI
+		Event string `json:"event" yaml:"event"`
+		// Run run record ID of first delivery
+		Run string `json:"run" yaml:"run"`
+		// Received time of first delivery
+		Received time.Time `json:"received" yaml:"received"`
+		// Expires time delivery ID is forgotten
+		Expires time.Time `json:"expires" yaml:"expires"`
+		// Runs pipeline runs of first delivery, when known
+		Runs []PipelineRunRecord `json:"runs,omitempty" yaml:"runs,omitempty"`
+	}
+
+	// Deduplicator remembers delivered events per trigger event URI
+	Deduplicator interface {
+		// Deliver remember event delivery with run record ID; returns first delivery when event was already delivered
+		Deliver(ctx context.Context, event string, data NormalizedEvent, run string) (*Delivery, error)
+		// Forget forget event delivery of run record ID, when event failed to run: next delivery is not a duplicate
+		Forget(ctx context.Context, event string, data NormalizedEvent, run string) error
+	}
+)
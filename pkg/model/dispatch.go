@@ -0,0 +1,62 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DispatchGlobal dispatch scope of all accounts; other scopes are account IDs
+const DispatchGlobal = "-"
+
+type (
+	// QueuedEvent normalized event queued while dispatch is paused
+	QueuedEvent struct {
+		// ID queued event ULID: queue order
+		ID string `json:"id"`
+		// Account trigger event account
+		Account string `json:"account"`
+		// Event trigger event URI
+		Event string `json:"event"`
+		// Queued time event was queued
+		Queued time.Time `json:"queued"`
+		// Data normalized event (validated before queued)
+		Data NormalizedEvent `json:"-"`
+	}
+
+	// DispatchState paused dispatch scope: global or account
+	DispatchState struct {
+		Scope  string    `json:"scope"`
+		Since  time.Time `json:"since"`
+		Queued int       `json:"queued"`
+	}
+
+	// DispatchResult resumed dispatch scope: number of queued events run, moved to paused account queue, discarded
+	// or failed (kept at queue tail: dispatch stays paused)
+	DispatchResult struct {
+		Scope      string `json:"scope"`
+		Dispatched int    `json:"dispatched"`
+		Requeued   int    `json:"requeued,omitempty"`
+		Discarded  int    `json:"discarded,omitempty"`
+		Failed     int    `json:"failed,omitempty"`
+	}
+
+	// Dispatcher pauses pipeline dispatch globally or for account; queues normalized events while paused
+	Dispatcher interface {
+		// Queue queue event when dispatch is paused for all accounts or for account; nil when dispatch is not paused
+		Queue(ctx context.Context, account, event string, data NormalizedEvent) (*QueuedEvent, error)
+		// Pause pause dispatch for scope
+		Pause(ctx context.Context, scope string) error
+		// Resume run queued events in order (or discard them) and resume dispatch for scope, when all queued events
+		// were run; events failed to run are kept queued
+		Resume(ctx context.Context, scope string, discard bool) (*DispatchResult, error)
+		// GetStates list paused scopes
+		GetStates(ctx context.Context) ([]DispatchState, error)
+	}
+)
+
+// ErrDispatchQueueNotEmpty error when resuming dispatch scope with queued events
+var ErrDispatchQueueNotEmpty = errors.New("dispatch queue is not empty")
+
+// ErrDispatchResuming error when dispatch scope is already being resumed
+var ErrDispatchResuming = errors.New("dispatch is already being resumed")
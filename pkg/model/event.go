@@ -4,6 +4,7 @@ import (
 	"crypto/sha1"
 	"fmt"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	yaml "gopkg.in/yaml.v2"
@@ -36,9 +37,52 @@ type (
 		Account string `json:"account" yaml:"account"`
 		// event secret, used for event validation
 		Secret string `json:"secret" yaml:"secret"`
+		// previous event secret, still valid during secret rotation grace period
+		PreviousSecret string `json:"previous_secret,omitempty" yaml:"previous_secret,omitempty"`
+		// previous event secret expiration time
+		PreviousSecretExpires *time.Time `json:"previous_secret_expires,omitempty" yaml:"previous_secret_expires,omitempty"`
+	}
+
+	// SecretRotation trigger event secret rotation options
+	SecretRotation struct {
+		// Grace period, when previous secret is still valid (0: invalidate immediately)
+		Grace time.Duration
+		// Push push new secret to event provider (subscribe to event with new secret)
+		Push bool
+		// Context Codefresh context with event provider credentials (used with Push)
+		Context string
+	}
+
+	// EventDeletion trigger event deleted together with its triggers
+	EventDeletion struct {
+		// URI deleted trigger event
+		URI string `json:"uri" yaml:"uri"`
+		// Pipelines pipelines unlinked from trigger event (deleted triggers)
+		Pipelines []string `json:"pipelines" yaml:"pipelines"`
+		// Filters pipelines with deleted trigger filters
+		Filters []string `json:"filters" yaml:"filters"`
+	}
+
+	// EventUpdate trigger event changes; nil fields are kept
+	EventUpdate struct {
+		// Description new description
+		Description *string `json:"description,omitempty"`
+		// Secret new secret; GenerateKeyword to generate random secret
+		Secret *string `json:"secret,omitempty"`
+		// Refresh get event info (endpoint, description, status, help) from event provider
+		Refresh bool `json:"refresh,omitempty"`
+		// Info event info already fetched from event provider (set by reconciler, not by API): only provider owned
+		// fields (endpoint, status, help) are updated, description is kept
+		Info *EventInfo `json:"-"`
 	}
 )
 
+// DefaultSecretGrace default secret rotation grace period
+const DefaultSecretGrace = 24 * time.Hour
+
+// RedactedSecret replaces trigger event secrets in API responses, CLI output and logs
+const RedactedSecret = "*****"
+
 // PublicAccount public account ID [0]{12}
 var PublicAccount = strings.Repeat("0", 12)
 
@@ -54,8 +98,38 @@ func (t EventInfo) String() string {
 	return string(d)
 }
 
-// String retrun event info as YAML string
+// Secrets event secrets valid at specified time: current secret and previous secret during rotation grace period
+func (t Event) Secrets(now time.Time) []string {
+	secrets := []string{t.Secret}
+	if t.PreviousSecret != "" && t.PreviousSecretExpires != nil && now.Before(*t.PreviousSecretExpires) {
+		secrets = append(secrets, t.PreviousSecret)
+	}
+	return secrets
+}
+
+// Redacted event copy with secrets replaced by RedactedSecret
+func (t Event) Redacted() Event {
+	if t.Secret != "" {
+		t.Secret = RedactedSecret
+	}
+	if t.PreviousSecret != "" {
+		t.PreviousSecret = RedactedSecret
+	}
+	return t
+}
+
+// RedactEvents replace secrets of listed events with RedactedSecret
+func RedactEvents(events []Event) []Event {
+	redacted := make([]Event, len(events))
+	for i, event := range events {
+		redacted[i] = event.Redacted()
+	}
+	return redacted
+}
+
+// String retrun event info as YAML string (without secrets)
 func (t Event) String() string {
+	t = t.Redacted()
 	d, err := yaml.Marshal(&t)
 	if err != nil {
 		log.WithError(err).Error("Failed to convert Event to YAML")
@@ -77,6 +151,8 @@ func StringsMapToEvent(event string, fields map[string]string) *Event {
 			Help:        fields["help"],
 			Status:      fields["status"],
 		},
+		PreviousSecret:        fields["previous_secret"],
+		PreviousSecretExpires: parseTime(fields["previous_secret_expires"]),
 	}
 }
 
@@ -88,13 +164,40 @@ func CalculateAccountHash(account string) string {
 	return string(runes[0:12])
 }
 
+// EventAccountHash account hash of event URI: last URI segment, when it is 12 hex chars; empty otherwise
+func EventAccountHash(uri string) string {
+	i := strings.LastIndexByte(uri, ':')
+	if i < 0 || len(uri)-i-1 != 12 {
+		return ""
+	}
+	hash := uri[i+1:]
+	for _, c := range hash {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return ""
+		}
+	}
+	return hash
+}
+
 // MatchAccount match account for passed uri
 func MatchAccount(account, uri string) bool {
-	hash := CalculateAccountHash(account)
-	return strings.HasSuffix(uri, hash)
+	return EventAccountHash(uri) == CalculateAccountHash(account)
 }
 
 // MatchPublicAccount match public account for passed uri
 func MatchPublicAccount(uri string) bool {
-	return strings.HasSuffix(uri, PublicAccountHash)
+	return EventAccountHash(uri) == PublicAccountHash
+}
+
+// parse RFC3339 time; nil for empty or invalid value
+func parseTime(value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		log.WithError(err).WithField("value", value).Error("Failed to parse time")
+		return nil
+	}
+	return &t
 }
@@ -0,0 +1,47 @@
+package model
+
+// garbage collection reasons: Codefresh pipeline check result
+const (
+	// GCReasonNotFound pipeline was deleted
+	GCReasonNotFound = "not-found"
+	// GCReasonNoMatch pipeline belongs to another account
+	GCReasonNoMatch = "no-match"
+)
+
+type (
+	// CollectedTriggers triggers of pipeline removed (or to be removed on dry run) by garbage collection
+	CollectedTriggers struct {
+		// Pipeline Codefresh pipeline UID
+		Pipeline string `json:"pipeline" yaml:"pipeline"`
+		// Account account the pipeline was checked for
+		Account string `json:"account" yaml:"account"`
+		// Reason pipeline check result (see GCReason* constants)
+		Reason string `json:"reason" yaml:"reason"`
+		// Events trigger events unlinked from pipeline
+		Events []string `json:"events" yaml:"events"`
+	}
+
+	// TriggerGCFailure pipeline that could not be checked
+	TriggerGCFailure struct {
+		// Pipeline Codefresh pipeline UID
+		Pipeline string `json:"pipeline" yaml:"pipeline"`
+		// Account account the pipeline was checked for
+		Account string `json:"account,omitempty" yaml:"account,omitempty"`
+		// Error failure reason
+		Error string `json:"error" yaml:"error"`
+	}
+
+	// TriggerGCReport trigger garbage collection report
+	TriggerGCReport struct {
+		// DryRun true if triggers were only reported, not removed
+		DryRun bool `json:"dry-run" yaml:"dry-run"`
+		// Pipelines number of checked pipelines
+		Pipelines int `json:"pipelines" yaml:"pipelines"`
+		// Collected removed triggers
+		Collected []CollectedTriggers `json:"collected,omitempty" yaml:"collected,omitempty"`
+		// Skipped pipelines linked only to public trigger events: pipeline account is unknown
+		Skipped []string `json:"skipped,omitempty" yaml:"skipped,omitempty"`
+		// Failed pipelines Codefresh or store failed for
+		Failed []TriggerGCFailure `json:"failed,omitempty" yaml:"failed,omitempty"`
+	}
+)
@@ -0,0 +1,151 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// run record status: result of /run call
+const (
+	// RunStatusRejected event secret validation failed
+	RunStatusRejected = "rejected"
+	// RunStatusQueued event queued while dispatch is paused
+	RunStatusQueued = "queued"
+	// RunStatusSkipped no pipeline matched event
+	RunStatusSkipped = "skipped"
+	// RunStatusStarted matched pipelines started (some pipeline runs may fail)
+	RunStatusStarted = "started"
+	// RunStatusFailed failed to get or run matched pipelines
+	RunStatusFailed = "failed"
+	// RunStatusDuplicate event was already delivered: pipelines are not run
+	RunStatusDuplicate = "duplicate"
+	// RunStatusRateLimited event rejected or all matched pipelines dropped or queued by rate limits
+	RunStatusRateLimited = "rate-limited"
+)
+
+// skipped pipeline reasons
+const (
+	// SkipReasonPaused trigger is paused
+	SkipReasonPaused = "paused"
+	// SkipReasonFilter trigger filters do not match event variables
+	SkipReasonFilter = "filter"
+	// SkipReasonDeleted originally matched trigger was deleted (replay of original pipelines)
+	SkipReasonDeleted = "deleted"
+)
+
+type (
+	// SkippedPipeline pipeline linked to trigger event, but not run
+	SkippedPipeline struct {
+		Pipeline string `json:"pipeline" yaml:"pipeline"`
+		// Reason see SkipReason* constants
+		Reason string `json:"reason" yaml:"reason"`
+	}
+
+	// PipelineRunRecord pipeline run ID or run error
+	PipelineRunRecord struct {
+		Pipeline string `json:"pipeline" yaml:"pipeline"`
+		ID       string `json:"id,omitempty" yaml:"id,omitempty"`
+		Error    string `json:"error,omitempty" yaml:"error,omitempty"`
+	}
+
+	// RunRecord execution record of single /run call, replay, queued event run or delayed pipeline run
+	RunRecord struct {
+		// ID record ULID: records are ordered by ID
+		ID string `json:"id" yaml:"id"`
+		// Account trigger event account
+		Account string `json:"account" yaml:"account"`
+		// Event trigger event URI
+		Event string `json:"event" yaml:"event"`
+		// Received time event was received
+		Received time.Time `json:"received" yaml:"received"`
+		// Status see RunStatus* constants
+		Status string `json:"status" yaml:"status"`
+		// SecretValid secret validation result
+		SecretValid bool `json:"secret-valid" yaml:"secret-valid"`
+		// Matched pipelines matching event
+		Matched []string `json:"matched,omitempty" yaml:"matched,omitempty"`
+		// Skipped pipelines linked to trigger event, but not matching event
+		Skipped []SkippedPipeline `json:"skipped,omitempty" yaml:"skipped,omitempty"`
+		// Limited matched pipelines exceeding rate limits: dropped, queued or rejected
+		Limited []LimitedPipeline `json:"limited,omitempty" yaml:"limited,omitempty"`
+		// Runs pipeline run IDs and errors
+		Runs []PipelineRunRecord `json:"runs,omitempty" yaml:"runs,omitempty"`
+		// Error secret validation or run failure
+		Error string `json:"error,omitempty" yaml:"error,omitempty"`
+		// Replay ID of replayed run record (replays only)
+		Replay string `json:"replay,omitempty" yaml:"replay,omitempty"`
+		// Duplicate run record ID of first delivery (duplicates only)
+		Duplicate string `json:"duplicate,omitempty" yaml:"duplicate,omitempty"`
+		// Queued ID of queued event: event queued while dispatch was paused, and its run on dispatch resume
+		Queued string `json:"queued,omitempty" yaml:"queued,omitempty"`
+		// Delayed run record ID of event run that queued pipeline run by rate limit (delayed runs only)
+		Delayed string `json:"delayed,omitempty" yaml:"delayed,omitempty"`
+		// Data normalized event without secret; kept for replay of validated events
+		Data *NormalizedEvent `json:"-" yaml:"-"`
+	}
+
+	// RunFilter run history query; empty fields match all records
+	RunFilter struct {
+		// Account trigger event account
+		Account string
+		// Event trigger event URI
+		Event string
+		// Status record status
+		Status string
+		// From records received at or after time
+		From time.Time
+		// To records received before time
+		To time.Time
+		// Limit maximum number of returned records (0: no limit)
+		Limit int
+	}
+
+	// RunHistory execution history of /run calls
+	RunHistory interface {
+		// AddRun add run record; record ID is generated when empty
+		AddRun(ctx context.Context, record *RunRecord) error
+		// GetRuns list account run records matching filter, latest first
+		GetRuns(ctx context.Context, filter RunFilter) ([]RunRecord, error)
+		// GetRun get context account run record
+		GetRun(ctx context.Context, id string) (*RunRecord, error)
+	}
+
+	// Replayer replays normalized event of previous run
+	Replayer interface {
+		// Replay run pipelines currently matching event of context account run record (original: run pipelines
+		// originally matched); returns run record of replay
+		Replay(ctx context.Context, id string, original bool) (*RunRecord, error)
+	}
+)
+
+// ReplayVariable event variable set to replayed run record ID on replay
+const ReplayVariable = "EVENT_REPLAY"
+
+// Match check if run record matches filter event and status
+func (f RunFilter) Match(record *RunRecord) bool {
+	return (f.Event == "" || f.Event == record.Event) &&
+		(f.Status == "" || f.Status == record.Status)
+}
+
+// PipelineRunRecords run records of pipelines; runs are ordered as run pipelines
+func PipelineRunRecords(pipelines []string, runs []PipelineRun) []PipelineRunRecord {
+	var records []PipelineRunRecord
+	for i, run := range runs {
+		record := PipelineRunRecord{ID: run.ID}
+		if i < len(pipelines) {
+			record.Pipeline = pipelines[i]
+		}
+		if run.Error != nil {
+			record.Error = run.Error.Error()
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// ErrRunNotFound error when run record is not found (or expired)
+var ErrRunNotFound = errors.New("run record not found")
+
+// ErrRunNotReplayable error when run record has no event to replay: secret validation failed or record is older than replay support
+var ErrRunNotReplayable = errors.New("run record has no event to replay")
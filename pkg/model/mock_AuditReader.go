@@ -0,0 +1,33 @@
+// Code generated by mockery v1.0.0
+package model
+
+import context "context"
+import mock "github.com/stretchr/testify/mock"
+
+// MockAuditReader is an autogenerated mock type for the AuditReader type
+type MockAuditReader struct {
+	mock.Mock
+}
+
+// GetAudit provides a mock function with given fields: ctx, filter
+func (_m *MockAuditReader) GetAudit(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	ret := _m.Called(ctx, filter)
+
+	var r0 []AuditEntry
+	if rf, ok := ret.Get(0).(func(context.Context, AuditFilter) []AuditEntry); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]AuditEntry)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, AuditFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
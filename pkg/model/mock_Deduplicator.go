@@ -0,0 +1,47 @@
+// Code generated by mockery v1.0.0
+package model
+
+import context "context"
+import mock "github.com/stretchr/testify/mock"
+
+// MockDeduplicator is an autogenerated mock type for the Deduplicator type
+type MockDeduplicator struct {
+	mock.Mock
+}
+
+// Deliver provides a mock function with given fields: ctx, event, data, run
+func (_m *MockDeduplicator) Deliver(ctx context.Context, event string, data NormalizedEvent, run string) (*Delivery, error) {
+	ret := _m.Called(ctx, event, data, run)
+
+	var r0 *Delivery
+	if rf, ok := ret.Get(0).(func(context.Context, string, NormalizedEvent, string) *Delivery); ok {
+		r0 = rf(ctx, event, data, run)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*Delivery)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, NormalizedEvent, string) error); ok {
+		r1 = rf(ctx, event, data, run)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Forget provides a mock function with given fields: ctx, event, data, run
+func (_m *MockDeduplicator) Forget(ctx context.Context, event string, data NormalizedEvent, run string) error {
+	ret := _m.Called(ctx, event, data, run)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, NormalizedEvent, string) error); ok {
+		r0 = rf(ctx, event, data, run)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
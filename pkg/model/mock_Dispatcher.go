@@ -0,0 +1,93 @@
+// Code generated by mockery v1.0.0
+package model
+
+import context "context"
+import mock "github.com/stretchr/testify/mock"
+
+// MockDispatcher is an autogenerated mock type for the Dispatcher type
+type MockDispatcher struct {
+	mock.Mock
+}
+
+// GetStates provides a mock function with given fields: ctx
+func (_m *MockDispatcher) GetStates(ctx context.Context) ([]DispatchState, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []DispatchState
+	if rf, ok := ret.Get(0).(func(context.Context) []DispatchState); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]DispatchState)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Pause provides a mock function with given fields: ctx, scope
+func (_m *MockDispatcher) Pause(ctx context.Context, scope string) error {
+	ret := _m.Called(ctx, scope)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, scope)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Queue provides a mock function with given fields: ctx, account, event, data
+func (_m *MockDispatcher) Queue(ctx context.Context, account string, event string, data NormalizedEvent) (*QueuedEvent, error) {
+	ret := _m.Called(ctx, account, event, data)
+
+	var r0 *QueuedEvent
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, NormalizedEvent) *QueuedEvent); ok {
+		r0 = rf(ctx, account, event, data)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*QueuedEvent)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, NormalizedEvent) error); ok {
+		r1 = rf(ctx, account, event, data)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Resume provides a mock function with given fields: ctx, scope, discard
+func (_m *MockDispatcher) Resume(ctx context.Context, scope string, discard bool) (*DispatchResult, error) {
+	ret := _m.Called(ctx, scope, discard)
+
+	var r0 *DispatchResult
+	if rf, ok := ret.Get(0).(func(context.Context, string, bool) *DispatchResult); ok {
+		r0 = rf(ctx, scope, discard)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*DispatchResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, bool) error); ok {
+		r1 = rf(ctx, scope, discard)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -0,0 +1,98 @@
+// Code generated by mockery v1.0.0
+package model
+
+import context "context"
+import mock "github.com/stretchr/testify/mock"
+
+// MockRateLimiter is an autogenerated mock type for the RateLimiter type
+type MockRateLimiter struct {
+	mock.Mock
+}
+
+// DeleteLimit provides a mock function with given fields: ctx, limit
+func (_m *MockRateLimiter) DeleteLimit(ctx context.Context, limit RateLimit) error {
+	ret := _m.Called(ctx, limit)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, RateLimit) error); ok {
+		r0 = rf(ctx, limit)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Delay provides a mock function with given fields: ctx, account, event, run, limited, data
+func (_m *MockRateLimiter) Delay(ctx context.Context, account string, event string, run string, limited []LimitedPipeline, data NormalizedEvent) error {
+	ret := _m.Called(ctx, account, event, run, limited, data)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, []LimitedPipeline, NormalizedEvent) error); ok {
+		r0 = rf(ctx, account, event, run, limited, data)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetLimits provides a mock function with given fields: ctx, account
+func (_m *MockRateLimiter) GetLimits(ctx context.Context, account string) ([]RateLimit, error) {
+	ret := _m.Called(ctx, account)
+
+	var r0 []RateLimit
+	if rf, ok := ret.Get(0).(func(context.Context, string) []RateLimit); ok {
+		r0 = rf(ctx, account)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]RateLimit)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, account)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetLimit provides a mock function with given fields: ctx, limit
+func (_m *MockRateLimiter) SetLimit(ctx context.Context, limit RateLimit) error {
+	ret := _m.Called(ctx, limit)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, RateLimit) error); ok {
+		r0 = rf(ctx, limit)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Take provides a mock function with given fields: ctx, account, event, pipelines
+func (_m *MockRateLimiter) Take(ctx context.Context, account string, event string, pipelines []string) (*RateLimitResult, error) {
+	ret := _m.Called(ctx, account, event, pipelines)
+
+	var r0 *RateLimitResult
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, []string) *RateLimitResult); ok {
+		r0 = rf(ctx, account, event, pipelines)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*RateLimitResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, []string) error); ok {
+		r1 = rf(ctx, account, event, pipelines)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -0,0 +1,56 @@
+// Code generated by mockery v1.0.0
+package model
+
+import context "context"
+import mock "github.com/stretchr/testify/mock"
+
+// MockReconciler is an autogenerated mock type for the Reconciler type
+type MockReconciler struct {
+	mock.Mock
+}
+
+// GetReconcileRuns provides a mock function with given fields: ctx
+func (_m *MockReconciler) GetReconcileRuns(ctx context.Context) ([]ReconcileRun, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []ReconcileRun
+	if rf, ok := ret.Get(0).(func(context.Context) []ReconcileRun); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]ReconcileRun)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Reconcile provides a mock function with given fields: ctx
+func (_m *MockReconciler) Reconcile(ctx context.Context) (*ReconcileRun, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *ReconcileRun
+	if rf, ok := ret.Get(0).(func(context.Context) *ReconcileRun); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*ReconcileRun)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
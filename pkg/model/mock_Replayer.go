@@ -0,0 +1,33 @@
+// Code generated by mockery v1.0.0
+package model
+
+import context "context"
+import mock "github.com/stretchr/testify/mock"
+
+// MockReplayer is an autogenerated mock type for the Replayer type
+type MockReplayer struct {
+	mock.Mock
+}
+
+// Replay provides a mock function with given fields: ctx, id, original
+func (_m *MockReplayer) Replay(ctx context.Context, id string, original bool) (*RunRecord, error) {
+	ret := _m.Called(ctx, id, original)
+
+	var r0 *RunRecord
+	if rf, ok := ret.Get(0).(func(context.Context, string, bool) *RunRecord); ok {
+		r0 = rf(ctx, id, original)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*RunRecord)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, bool) error); ok {
+		r1 = rf(ctx, id, original)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
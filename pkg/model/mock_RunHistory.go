@@ -0,0 +1,70 @@
+// Code generated by mockery v1.0.0
+package model
+
+import context "context"
+import mock "github.com/stretchr/testify/mock"
+
+// MockRunHistory is an autogenerated mock type for the RunHistory type
+type MockRunHistory struct {
+	mock.Mock
+}
+
+// AddRun provides a mock function with given fields: ctx, record
+func (_m *MockRunHistory) AddRun(ctx context.Context, record *RunRecord) error {
+	ret := _m.Called(ctx, record)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *RunRecord) error); ok {
+		r0 = rf(ctx, record)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetRun provides a mock function with given fields: ctx, id
+func (_m *MockRunHistory) GetRun(ctx context.Context, id string) (*RunRecord, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *RunRecord
+	if rf, ok := ret.Get(0).(func(context.Context, string) *RunRecord); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*RunRecord)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRuns provides a mock function with given fields: ctx, filter
+func (_m *MockRunHistory) GetRuns(ctx context.Context, filter RunFilter) ([]RunRecord, error) {
+	ret := _m.Called(ctx, filter)
+
+	var r0 []RunRecord
+	if rf, ok := ret.Get(0).(func(context.Context, RunFilter) []RunRecord); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]RunRecord)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, RunFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
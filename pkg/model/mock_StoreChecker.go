@@ -0,0 +1,33 @@
+// Code generated by mockery v1.0.0
+package model
+
+import context "context"
+import mock "github.com/stretchr/testify/mock"
+
+// MockStoreChecker is an autogenerated mock type for the StoreChecker type
+type MockStoreChecker struct {
+	mock.Mock
+}
+
+// CheckStore provides a mock function with given fields: ctx, repair
+func (_m *MockStoreChecker) CheckStore(ctx context.Context, repair bool) (*StoreReport, error) {
+	ret := _m.Called(ctx, repair)
+
+	var r0 *StoreReport
+	if rf, ok := ret.Get(0).(func(context.Context, bool) *StoreReport); ok {
+		r0 = rf(ctx, repair)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*StoreReport)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, bool) error); ok {
+		r1 = rf(ctx, repair)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
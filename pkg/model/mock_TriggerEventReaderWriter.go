@@ -46,6 +46,29 @@ func (_m *MockTriggerEventReaderWriter) DeleteEvent(ctx context.Context, event s
 	return r0
 }
 
+// ForceDeleteEvent provides a mock function with given fields: ctx, event, _a2
+func (_m *MockTriggerEventReaderWriter) ForceDeleteEvent(ctx context.Context, event string, _a2 string) (*EventDeletion, error) {
+	ret := _m.Called(ctx, event, _a2)
+
+	var r0 *EventDeletion
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *EventDeletion); ok {
+		r0 = rf(ctx, event, _a2)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*EventDeletion)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, event, _a2)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetEvent provides a mock function with given fields: ctx, event
 func (_m *MockTriggerEventReaderWriter) GetEvent(ctx context.Context, event string) (*Event, error) {
 	ret := _m.Called(ctx, event)
@@ -91,3 +114,72 @@ func (_m *MockTriggerEventReaderWriter) GetEvents(ctx context.Context, eventType
 
 	return r0, r1
 }
+
+// GetEventsPage provides a mock function with given fields: ctx, eventType, kind, filter, page
+func (_m *MockTriggerEventReaderWriter) GetEventsPage(ctx context.Context, eventType string, kind string, filter string, page PageOptions) (*EventPage, error) {
+	ret := _m.Called(ctx, eventType, kind, filter, page)
+
+	var r0 *EventPage
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, PageOptions) *EventPage); ok {
+		r0 = rf(ctx, eventType, kind, filter, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*EventPage)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string, PageOptions) error); ok {
+		r1 = rf(ctx, eventType, kind, filter, page)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateEvent provides a mock function with given fields: ctx, event, update
+func (_m *MockTriggerEventReaderWriter) UpdateEvent(ctx context.Context, event string, update EventUpdate) (*Event, error) {
+	ret := _m.Called(ctx, event, update)
+
+	var r0 *Event
+	if rf, ok := ret.Get(0).(func(context.Context, string, EventUpdate) *Event); ok {
+		r0 = rf(ctx, event, update)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*Event)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, EventUpdate) error); ok {
+		r1 = rf(ctx, event, update)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RotateEventSecret provides a mock function with given fields: ctx, event, rotation
+func (_m *MockTriggerEventReaderWriter) RotateEventSecret(ctx context.Context, event string, rotation SecretRotation) (*Event, error) {
+	ret := _m.Called(ctx, event, rotation)
+
+	var r0 *Event
+	if rf, ok := ret.Get(0).(func(context.Context, string, SecretRotation) *Event); ok {
+		r0 = rf(ctx, event, rotation)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*Event)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, SecretRotation) error); ok {
+		r1 = rf(ctx, event, rotation)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -60,6 +60,29 @@ func (_m *MockTriggerReaderWriter) GetEventTriggers(ctx context.Context, event s
 	return r0, r1
 }
 
+// GetEventTriggersPage provides a mock function with given fields: ctx, event, page
+func (_m *MockTriggerReaderWriter) GetEventTriggersPage(ctx context.Context, event string, page PageOptions) (*TriggerPage, error) {
+	ret := _m.Called(ctx, event, page)
+
+	var r0 *TriggerPage
+	if rf, ok := ret.Get(0).(func(context.Context, string, PageOptions) *TriggerPage); ok {
+		r0 = rf(ctx, event, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*TriggerPage)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, PageOptions) error); ok {
+		r1 = rf(ctx, event, page)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetPipelineTriggers provides a mock function with given fields: ctx, pipeline, withEvent
 func (_m *MockTriggerReaderWriter) GetPipelineTriggers(ctx context.Context, pipeline string, withEvent bool) ([]Trigger, error) {
 	ret := _m.Called(ctx, pipeline, withEvent)
@@ -106,6 +129,38 @@ func (_m *MockTriggerReaderWriter) GetTriggerPipelines(ctx context.Context, even
 	return r0, r1
 }
 
+// MatchTriggerPipelines provides a mock function with given fields: ctx, event, vars
+func (_m *MockTriggerReaderWriter) MatchTriggerPipelines(ctx context.Context, event string, vars map[string]string) ([]string, []SkippedPipeline, error) {
+	ret := _m.Called(ctx, event, vars)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, string, map[string]string) []string); ok {
+		r0 = rf(ctx, event, vars)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 []SkippedPipeline
+	if rf, ok := ret.Get(1).(func(context.Context, string, map[string]string) []SkippedPipeline); ok {
+		r1 = rf(ctx, event, vars)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]SkippedPipeline)
+		}
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, string, map[string]string) error); ok {
+		r2 = rf(ctx, event, vars)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 func (_m *MockTriggerReaderWriter) DeleteAllTriggersByPipeline(ctx context.Context, pipeline string) error {
 	triggers, err := _m.GetPipelineTriggers(ctx, pipeline, true)
 
@@ -122,3 +177,17 @@ func (_m *MockTriggerReaderWriter) DeleteAllTriggersByPipeline(ctx context.Conte
 
 	return nil
 }
+
+// SetTriggerPaused provides a mock function with given fields: ctx, event, pipeline, paused
+func (_m *MockTriggerReaderWriter) SetTriggerPaused(ctx context.Context, event string, pipeline string, paused bool) error {
+	ret := _m.Called(ctx, event, pipeline, paused)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, bool) error); ok {
+		r0 = rf(ctx, event, pipeline, paused)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
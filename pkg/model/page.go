@@ -0,0 +1,35 @@
+package model
+
+import "errors"
+
+type (
+	// PageOptions listing page options
+	PageOptions struct {
+		// Limit maximum number of items in page (0: default page size)
+		Limit int
+		// Cursor position after the last item of previous page, as returned in NextCursor ("": first page)
+		Cursor string
+		// Sort sort field, "-" prefix for descending order ("": default sort)
+		Sort string
+	}
+
+	// EventPage single page of trigger events
+	EventPage struct {
+		Events []Event `json:"events" yaml:"events"`
+		// NextCursor cursor of next page; empty for last page
+		NextCursor string `json:"next_cursor,omitempty" yaml:"next_cursor,omitempty"`
+	}
+
+	// TriggerPage single page of triggers
+	TriggerPage struct {
+		Triggers []Trigger `json:"triggers" yaml:"triggers"`
+		// NextCursor cursor of next page; empty for last page
+		NextCursor string `json:"next_cursor,omitempty" yaml:"next_cursor,omitempty"`
+	}
+)
+
+// ErrInvalidCursor error when page cursor is malformed or created for another sort order
+var ErrInvalidCursor = errors.New("invalid page cursor")
+
+// ErrInvalidSort error when listing can't be sorted by requested field
+var ErrInvalidSort = errors.New("invalid sort field")
@@ -0,0 +1,138 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// rate limit actions: what to do with pipeline run exceeding rate limit
+const (
+	// RateLimitDrop skip pipeline run (default)
+	RateLimitDrop = "drop"
+	// RateLimitQueue delay pipeline run until limit allows it; run is dropped when limit is exceeded by burst
+	RateLimitQueue = "queue"
+	// RateLimitReject reject event with 429 Too Many Requests: no pipeline is run
+	RateLimitReject = "reject"
+)
+
+// rate limit scopes
+const (
+	RateLimitScopeAccount = "account"
+	RateLimitScopeEvent   = "event"
+	RateLimitScopeTrigger = "trigger"
+)
+
+type (
+	// RateLimit token bucket limit of pipeline runs: account limit (no event), trigger event limit (no pipeline)
+	// or trigger limit
+	RateLimit struct {
+		// Account account the limit applies to
+		Account string `json:"account" yaml:"account"`
+		// Event trigger event URI (event and trigger limits)
+		Event string `json:"event,omitempty" yaml:"event,omitempty"`
+		// Pipeline trigger pipeline (trigger limits)
+		Pipeline string `json:"pipeline,omitempty" yaml:"pipeline,omitempty"`
+		// Rate pipeline runs per minute: bucket refill rate
+		Rate float64 `json:"rate" yaml:"rate"`
+		// Burst bucket size: pipeline runs allowed at once
+		Burst int `json:"burst" yaml:"burst"`
+		// Action see RateLimit* action constants
+		Action string `json:"action" yaml:"action"`
+	}
+
+	// LimitedPipeline pipeline run exceeding rate limit
+	LimitedPipeline struct {
+		// Pipeline Codefresh pipeline UID
+		Pipeline string `json:"pipeline" yaml:"pipeline"`
+		// Scope exceeded limit scope (see RateLimitScope* constants)
+		Scope string `json:"scope" yaml:"scope"`
+		// Action applied action (see RateLimit* action constants)
+		Action string `json:"action" yaml:"action"`
+		// Delay queued pipeline run delay
+		Delay time.Duration `json:"delay,omitempty" yaml:"delay,omitempty"`
+	}
+
+	// RateLimitResult rate limits check of pipeline runs
+	RateLimitResult struct {
+		// Allowed pipelines to run now
+		Allowed []string
+		// Limited pipelines dropped, queued (delayed) or rejected
+		Limited []LimitedPipeline
+		// RetryAfter time until rejected pipeline runs are allowed
+		RetryAfter time.Duration
+	}
+
+	// DelayedRun pipeline run queued (delayed) by rate limit; kept in store until it is due
+	DelayedRun struct {
+		// ID ULID with due time timestamp: delayed runs are ordered by due time
+		ID string `json:"id"`
+		// Account trigger event account
+		Account string `json:"account"`
+		// Event trigger event URI
+		Event string `json:"event"`
+		// Pipeline delayed pipeline
+		Pipeline string `json:"pipeline"`
+		// Run run record ID of event run that queued pipeline run
+		Run string `json:"run"`
+		// Data normalized event (validated before delayed)
+		Data NormalizedEvent `json:"-"`
+	}
+
+	// RateLimitStats number of pipeline runs dropped, queued (delayed) and rejected by rate limits since start
+	RateLimitStats struct {
+		Dropped  int64 `json:"dropped"`
+		Queued   int64 `json:"queued"`
+		Rejected int64 `json:"rejected"`
+	}
+
+	// RateLimitStatser reports rate limit stats
+	RateLimitStatser interface {
+		RateLimitStats() RateLimitStats
+	}
+
+	// RateLimiter limits pipeline runs per account, trigger event and trigger
+	RateLimiter interface {
+		// Take take token from every rate limit bucket of every pipeline run
+		Take(ctx context.Context, account, event string, pipelines []string) (*RateLimitResult, error)
+		// Delay keep pipeline runs queued by rate limits until their delay passes; run: run record ID of event run
+		Delay(ctx context.Context, account, event, run string, limited []LimitedPipeline, data NormalizedEvent) error
+		// GetLimits list account rate limits
+		GetLimits(ctx context.Context, account string) ([]RateLimit, error)
+		// SetLimit add or replace rate limit
+		SetLimit(ctx context.Context, limit RateLimit) error
+		// DeleteLimit delete rate limit (rate, burst and action are ignored)
+		DeleteLimit(ctx context.Context, limit RateLimit) error
+	}
+)
+
+// Scope rate limit scope: account, event or trigger
+func (l RateLimit) Scope() string {
+	switch {
+	case l.Event == "":
+		return RateLimitScopeAccount
+	case l.Pipeline == "":
+		return RateLimitScopeEvent
+	default:
+		return RateLimitScopeTrigger
+	}
+}
+
+// Rejected check if any pipeline run is rejected
+func (r *RateLimitResult) Rejected() bool {
+	for _, l := range r.Limited {
+		if l.Action == RateLimitReject {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrRateLimitNotFound error when deleted rate limit is not found
+var ErrRateLimitNotFound = errors.New("rate limit not found")
+
+// ErrRateLimitExceeded error when pipeline runs are rejected by rate limit
+var ErrRateLimitExceeded = errors.New("rate limit exceeded")
+
+// ErrInvalidRateLimit error when rate limit has invalid rate, burst or action
+var ErrInvalidRateLimit = errors.New("invalid rate limit: rate and burst must be positive, action one of drop, queue or reject")
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type (
+	// ReconciledEvent trigger event changed or failed during reconciliation
+	ReconciledEvent struct {
+		// URI trigger event URI
+		URI string `json:"uri" yaml:"uri"`
+		// Fields updated event info fields
+		Fields []string `json:"fields,omitempty" yaml:"fields,omitempty"`
+		// Error failure reason
+		Error string `json:"error,omitempty" yaml:"error,omitempty"`
+	}
+
+	// ReconcileRun single reconciliation run result
+	ReconcileRun struct {
+		// ID run ULID
+		ID string `json:"id" yaml:"id"`
+		// Owner replica that ran reconciliation
+		Owner string `json:"owner" yaml:"owner"`
+		// Started run start time
+		Started time.Time `json:"started" yaml:"started"`
+		// Finished run end time
+		Finished time.Time `json:"finished" yaml:"finished"`
+		// Events number of checked trigger events
+		Events int `json:"events" yaml:"events"`
+		// Updated trigger events with refreshed event info
+		Updated []ReconciledEvent `json:"updated,omitempty" yaml:"updated,omitempty"`
+		// Unmatched trigger events, which type does not match any configured event type
+		Unmatched []string `json:"unmatched,omitempty" yaml:"unmatched,omitempty"`
+		// Failed trigger events event provider or store failed for
+		Failed []ReconciledEvent `json:"failed,omitempty" yaml:"failed,omitempty"`
+	}
+
+	// Reconciler refreshes stored trigger event info (endpoint, description, status, help) from event providers
+	Reconciler interface {
+		// Reconcile run reconciliation now; fails with ErrReconcileLocked when running on another replica
+		Reconcile(ctx context.Context) (*ReconcileRun, error)
+		// GetReconcileRuns list recent reconciliation runs, latest first
+		GetReconcileRuns(ctx context.Context) ([]ReconcileRun, error)
+	}
+)
+
+// ErrReconcileLocked error when reconciliation is already running (on this or another replica)
+var ErrReconcileLocked = errors.New("reconciliation is already running")
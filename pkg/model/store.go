@@ -0,0 +1,58 @@
+package model
+
+import "context"
+
+type (
+	// StoreIssue single storage backend inconsistency
+	StoreIssue struct {
+		// Type issue type (see StoreIssue* constants)
+		Type string `json:"type" yaml:"type"`
+		// Key inconsistent key
+		Key string `json:"key" yaml:"key"`
+		// Member inconsistent set member (optional)
+		Member string `json:"member,omitempty" yaml:"member,omitempty"`
+		// Repaired true if issue was fixed
+		Repaired bool `json:"repaired" yaml:"repaired"`
+	}
+
+	// StoreReport storage backend consistency check report
+	StoreReport struct {
+		// Events number of checked trigger events
+		Events int `json:"events" yaml:"events"`
+		// Triggers number of checked triggers (event -> pipeline links)
+		Triggers int `json:"triggers" yaml:"triggers"`
+		// Filters number of checked trigger filters
+		Filters int `json:"filters" yaml:"filters"`
+		// Issues found inconsistencies
+		Issues []StoreIssue `json:"issues" yaml:"issues"`
+	}
+
+	// StoreChecker checks storage backend consistency and optionally repairs found issues
+	StoreChecker interface {
+		CheckStore(ctx context.Context, repair bool) (*StoreReport, error)
+	}
+)
+
+// Store issue types
+const (
+	// StoreIssueInvalidEvent trigger event without account, type or kind (not repaired)
+	StoreIssueInvalidEvent = "invalid-event"
+	// StoreIssueOrphanTrigger trigger for deleted trigger event
+	StoreIssueOrphanTrigger = "orphan-trigger"
+	// StoreIssueMissingPipelineLink trigger pipeline without link back to trigger event
+	StoreIssueMissingPipelineLink = "missing-pipeline-link"
+	// StoreIssueOrphanPipelineLink pipeline linked to deleted trigger event
+	StoreIssueOrphanPipelineLink = "orphan-pipeline-link"
+	// StoreIssueStalePipelineLink pipeline linked to trigger event without trigger
+	StoreIssueStalePipelineLink = "stale-pipeline-link"
+	// StoreIssueStalePausedTrigger paused trigger state without trigger
+	StoreIssueStalePausedTrigger = "stale-paused-trigger"
+	// StoreIssueOrphanFilter filter without trigger
+	StoreIssueOrphanFilter = "orphan-filter"
+	// StoreIssueInvalidFilterKey filter key that cannot be parsed (not repaired; run store migrate)
+	StoreIssueInvalidFilterKey = "invalid-filter-key"
+	// StoreIssueMissingIndex trigger event missing from account or type index
+	StoreIssueMissingIndex = "missing-index"
+	// StoreIssueStaleIndex index entry for deleted trigger event or in wrong index
+	StoreIssueStaleIndex = "stale-index"
+)
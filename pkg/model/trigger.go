@@ -3,6 +3,8 @@ package model
 import (
 	"context"
 	"errors"
+	"time"
+
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
 )
@@ -29,6 +31,8 @@ type (
 		Pipeline string `json:"pipeline" yaml:"pipeline"`
 		// filter
 		Filters map[string]string `json:"filters,omitempty" yaml:"filters,omitempty"`
+		// paused trigger does not run pipeline; filters are kept
+		Paused bool `json:"paused,omitempty" yaml:"paused,omitempty"`
 		// event details (optional)
 		EventData Event `json:"event-data,omitempty" yaml:"event-data,omitempty"`
 	}
@@ -43,19 +47,28 @@ type (
 		// trigger events
 		TriggerEventGetter
 		GetEvents(ctx context.Context, eventType, kind, filter string) ([]Event, error)
+		GetEventsPage(ctx context.Context, eventType, kind, filter string, page PageOptions) (*EventPage, error)
 		CreateEvent(ctx context.Context, eventType, kind, secret, context string, values map[string]string) (*Event, error)
+		UpdateEvent(ctx context.Context, event string, update EventUpdate) (*Event, error)
+		RotateEventSecret(ctx context.Context, event string, rotation SecretRotation) (*Event, error)
 		DeleteEvent(ctx context.Context, event, context string) error
+		ForceDeleteEvent(ctx context.Context, event, context string) (*EventDeletion, error)
 	}
 
 	// TriggerReaderWriter interface
 	TriggerReaderWriter interface {
 		// triggers
 		GetEventTriggers(ctx context.Context, event string) ([]Trigger, error)
+		GetEventTriggersPage(ctx context.Context, event string, page PageOptions) (*TriggerPage, error)
 		GetPipelineTriggers(ctx context.Context, pipeline string, withEvent bool) ([]Trigger, error)
 		DeleteTrigger(ctx context.Context, event, pipeline string) error
 		CreateTrigger(ctx context.Context, event, pipeline string, filters map[string]string) error
 		GetTriggerPipelines(ctx context.Context, event string, vars map[string]string) ([]string, error)
+		// MatchTriggerPipelines get pipelines matching event vars, and skipped pipelines of paused triggers and
+		// triggers with not matching filters
+		MatchTriggerPipelines(ctx context.Context, event string, vars map[string]string) ([]string, []SkippedPipeline, error)
 		DeleteAllTriggersByPipeline(ctx context.Context, pipeline string) error
+		SetTriggerPaused(ctx context.Context, event, pipeline string, paused bool) error
 	}
 
 	// Runner pipeline runner
@@ -73,6 +86,39 @@ type (
 		Ping() (string, error)
 	}
 
+	// PoolStats storage backend connection pool statistics
+	PoolStats struct {
+		// Active number of connections in pool: in use and idle
+		Active int `json:"active"`
+		// Idle number of idle connections
+		Idle int `json:"idle"`
+		// WaitCount number of times connection was requested from exhausted pool
+		WaitCount int64 `json:"wait_count"`
+		// WaitDuration total time spent waiting for connection
+		WaitDuration time.Duration `json:"wait_duration"`
+	}
+
+	// PoolStatser reports storage backend connection pool statistics
+	PoolStatser interface {
+		PoolStats() PoolStats
+	}
+
+	// AuditStats audit log statistics
+	AuditStats struct {
+		// Failures number of changes made, but not recorded in audit log
+		Failures int64 `json:"failures"`
+	}
+
+	// AuditStatser reports audit log statistics
+	AuditStatser interface {
+		AuditStats() AuditStats
+	}
+
+	// SecretReadAuditor records trigger event secret reads in audit log
+	SecretReadAuditor interface {
+		AuditSecretRead(ctx context.Context, event *Event)
+	}
+
 	// SecretChecker validates message secret or HMAC signature
 	SecretChecker interface {
 		Validate(message string, secret string, key string) error
@@ -107,11 +153,28 @@ var ErrPipelineNotFound = errors.New("pipeline not found")
 // ErrTriggerAlreadyExists error when trigger already exists
 var ErrTriggerAlreadyExists = errors.New("trigger already exists")
 
+// ErrEventSecretConflict error when trigger event already exists with different secret
+var ErrEventSecretConflict = errors.New("trigger event already exists with different secret")
+
+// ErrStoreConflict error when store update keeps conflicting with concurrent updates
+var ErrStoreConflict = errors.New("concurrent store update conflict, try again")
+
 // GenerateKeyword keyword used to auto-generate secret
 const GenerateKeyword = "!generate"
 
-// String retrun trigger as YAML string
+// RedactTriggers replace secrets of trigger event details with RedactedSecret
+func RedactTriggers(triggers []Trigger) []Trigger {
+	redacted := make([]Trigger, len(triggers))
+	for i, trigger := range triggers {
+		trigger.EventData = trigger.EventData.Redacted()
+		redacted[i] = trigger
+	}
+	return redacted
+}
+
+// String retrun trigger as YAML string (without secrets)
 func (t Trigger) String() string {
+	t.EventData = t.EventData.Redacted()
 	d, err := yaml.Marshal(&t)
 	if err != nil {
 		log.WithError(err).Error("Failed to convert Trigger to YAML")
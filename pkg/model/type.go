@@ -54,6 +54,10 @@ type (
 		Config []ConfigField `json:"config" yaml:"config"`
 		// Filters - fields that support filtering
 		Filters []FilterField `json:"filters" yaml:"filters"`
+		// DeliveryID normalized event variable with unique delivery ID; events without delivery ID are not deduplicated
+		DeliveryID string `json:"delivery-id,omitempty" yaml:"delivery-id,omitempty"`
+		// DedupPayload deduplicate events without delivery ID by hash of original payload (identical payloads run once)
+		DedupPayload bool `json:"dedup-payload,omitempty" yaml:"dedup-payload,omitempty"`
 	}
 
 	// EventTypes array of event types
@@ -100,7 +100,7 @@ func (api *APIEndpoint) SubscribeToEvent(ctx context.Context, event, secret stri
 	encoded := base64.StdEncoding.EncodeToString(creds)
 	// invoke POST method passing credentials as base64 encoded string; receive eventinfo on success
 	path := fmt.Sprint("/event/", url.PathEscape(event), "/", secret, "/", url.PathEscape(encoded))
-	log.WithField("path", path).Debug("POST event to event provider")
+	log.WithField("path", fmt.Sprint("/event/", url.PathEscape(event), "/", model.RedactedSecret, "/...")).Debug("POST event to event provider")
 	resp, err := setContext(ctx, api.endpoint.New()).Post(path).Receive(&info, &apiError)
 	if err != nil && err != io.EOF {
 		log.WithError(err).Error("failed to invoke method")
@@ -133,7 +133,7 @@ func (api *APIEndpoint) UnsubscribeFromEvent(ctx context.Context, event string,
 	encoded := base64.StdEncoding.EncodeToString(creds)
 	// invoke DELETE method passing credentials as base64 encoded string
 	path := fmt.Sprint("/event/", url.PathEscape(event), "/", url.PathEscape(encoded))
-	log.WithField("path", path).Debug("DELETE event from event provider")
+	log.WithField("path", fmt.Sprint("/event/", url.PathEscape(event), "/...")).Debug("DELETE event from event provider")
 	resp, err := setContext(ctx, api.endpoint.New()).Delete(path).Receive(nil, &apiError)
 	if err != nil && err != io.EOF {
 		log.WithError(err).Error("failed to invoke method")
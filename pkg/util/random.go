@@ -1,6 +1,8 @@
 package util
 
 import (
+	crand "crypto/rand"
+	"math/big"
 	"math/rand"
 	"time"
 )
@@ -17,6 +19,22 @@ func init() {
 	r = rand.New(rand.NewSource(time.Now().UnixNano()))
 }
 
+const chars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// SecureRandomString generates random string with cryptographically secure random generator
+func SecureRandomString(strlen int) (string, error) {
+	result := make([]byte, strlen)
+	max := big.NewInt(int64(len(chars)))
+	for i := range result {
+		index, err := crand.Int(crand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		result[i] = chars[index.Int64()]
+	}
+	return string(result), nil
+}
+
 // RandomString generates random string
 func RandomString(strlen int) string {
 	// use contant string for test mode
@@ -24,7 +42,6 @@ func RandomString(strlen int) string {
 		return TestRandomString
 	}
 
-	const chars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 	result := ""
 	for i := 0; i < strlen; i++ {
 		index := r.Intn(len(chars))
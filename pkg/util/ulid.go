@@ -3,6 +3,7 @@ package util
 import (
 	"github.com/oklog/ulid"
 	"math/rand"
+	"sync"
 	"time"
 )
 
@@ -14,3 +15,39 @@ func GenerateULID() (string, error) {
 	ulid, err := ulid.New(ulid.Timestamp(time.Now()), entropy)
 	return ulid.String(), err
 }
+
+// GenerateULIDAt generate ULID with timestamp of time t: ULID sorts by time t
+func GenerateULIDAt(t time.Time) (string, error) {
+	entropy := rand.New(rand.NewSource(time.Now().UnixNano()))
+	id, err := ulid.New(ulid.Timestamp(t), entropy)
+	return id.String(), err
+}
+
+var (
+	lastULIDMutex sync.Mutex
+	lastULID      ulid.ULID
+)
+
+// GenerateMonotonicULID generate ULID greater than any ULID previously generated by this process,
+// also within the same millisecond (increments entropy of the last ULID)
+func GenerateMonotonicULID() (string, error) {
+	lastULIDMutex.Lock()
+	defer lastULIDMutex.Unlock()
+	id, err := GenerateULID()
+	if err != nil {
+		return "", err
+	}
+	next := ulid.MustParse(id)
+	if next.Compare(lastULID) <= 0 {
+		next = lastULID
+		// entropy: last 10 bytes, big endian
+		for i := len(next) - 1; i >= 6; i-- {
+			next[i]++
+			if next[i] != 0 {
+				break
+			}
+		}
+	}
+	lastULID = next
+	return next.String(), nil
+}